@@ -203,9 +203,19 @@ func (up *largeUpload) returnUploadURL(upload *api.GetUploadPartURLResponse) {
 }
 
 // Transfer a chunk
-func (up *largeUpload) transferChunk(ctx context.Context, part int64, body []byte) error {
-	err := up.f.pacer.Call(func() (bool, error) {
-		fs.Debugf(up.o, "Sending chunk %d length %d", part, len(body))
+func (up *largeUpload) transferChunk(ctx context.Context, part int64, body io.ReadSeeker) (n int64, err error) {
+	n, err = body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	err = up.f.pacer.Call(func() (bool, error) {
+		fs.Debugf(up.o, "Sending chunk %d length %d", part, n)
+
+		// rewind the chunk to the start for each try
+		_, err := body.Seek(0, io.SeekStart)
+		if err != nil {
+			return false, err
+		}
 
 		// Get upload URL
 		upload, err := up.getUploadURL(ctx)
@@ -213,8 +223,8 @@ func (up *largeUpload) transferChunk(ctx context.Context, part int64, body []byt
 			return false, err
 		}
 
-		in := newHashAppendingReader(bytes.NewReader(body), sha1.New())
-		size := int64(len(body)) + int64(in.AdditionalLength())
+		in := newHashAppendingReader(body, sha1.New())
+		size := n + int64(in.AdditionalLength())
 
 		// Authorization
 		//
@@ -271,7 +281,7 @@ func (up *largeUpload) transferChunk(ctx context.Context, part int64, body []byt
 	} else {
 		fs.Debugf(up.o, "Done sending chunk %d", part)
 	}
-	return err
+	return n, err
 }
 
 // Copy a chunk
@@ -411,7 +421,8 @@ func (up *largeUpload) Stream(ctx context.Context, initialUploadBlock []byte) (e
 			part := part // for the closure
 			g.Go(func() (err error) {
 				defer up.f.putBuf(buf, false)
-				return up.transferChunk(gCtx, part, buf)
+				_, err = up.transferChunk(gCtx, part, bytes.NewReader(buf))
+				return err
 			})
 		}
 		return nil
@@ -463,7 +474,7 @@ func (up *largeUpload) Upload(ctx context.Context) (err error) {
 			g.Go(func() (err error) {
 				defer up.f.putBuf(buf, up.doCopy)
 				if !up.doCopy {
-					err = up.transferChunk(gCtx, part, buf)
+					_, err = up.transferChunk(gCtx, part, bytes.NewReader(buf))
 				} else {
 					err = up.copyChunk(gCtx, part, reqSize)
 				}
@@ -479,3 +490,23 @@ func (up *largeUpload) Upload(ctx context.Context) (err error) {
 	}
 	return up.finish(ctx)
 }
+
+// WriteChunk uploads chunk number chunkNumber (starting from 0) from
+// reader
+func (up *largeUpload) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if chunkNumber < 0 || int64(chunkNumber) >= up.parts {
+		return 0, fmt.Errorf("invalid chunk number %d - must be 0 to %d", chunkNumber, up.parts-1)
+	}
+	return up.transferChunk(ctx, int64(chunkNumber)+1, reader)
+}
+
+// Close finishes the large upload once all the chunks have been
+// written with WriteChunk
+func (up *largeUpload) Close(ctx context.Context) error {
+	return up.finish(ctx)
+}
+
+// Abort cancels the large upload
+func (up *largeUpload) Abort(ctx context.Context) error {
+	return up.cancel(ctx)
+}
@@ -345,6 +345,16 @@ func (o *Object) Hash(ctx context.Context, ht hash.Type) (string, error) {
 	return liveHash, nil
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	if err := o.refreshFromSource(ctx, false); err != nil {
+		return nil, err
+	}
+	return fs.GetMetadata(ctx, o.Object)
+}
+
 // persist adds this object to the persistent cache
 func (o *Object) persist() *Object {
 	err := o.CacheFs.cache.AddObject(o)
@@ -382,4 +392,5 @@ func (o *Object) UnWrap() fs.Object {
 var (
 	_ fs.Object          = (*Object)(nil)
 	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
 )
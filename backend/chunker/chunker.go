@@ -2396,6 +2396,13 @@ func (o *Object) ID() string {
 	return ""
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.mainChunk())
+}
+
 // Meta format `simplejson`
 type metaSimpleJSON struct {
 	// required core fields
@@ -2564,4 +2571,5 @@ var (
 	_ fs.Object          = (*Object)(nil)
 	_ fs.ObjectUnWrapper = (*Object)(nil)
 	_ fs.IDer            = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
 )
@@ -0,0 +1,141 @@
+package combine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdjustmentDo(t *testing.T) {
+	for _, test := range []struct {
+		root       string
+		mountpoint string
+		in         string
+		want       string
+		wantErr    error
+	}{
+		{
+			root:       "",
+			mountpoint: "mountpoint",
+			in:         "path/to/file.txt",
+			want:       "mountpoint/path/to/file.txt",
+		},
+		{
+			root:       "mountpoint",
+			mountpoint: "mountpoint",
+			in:         "path/to/file.txt",
+			want:       "path/to/file.txt",
+		},
+		{
+			root:       "mountpoint/path",
+			mountpoint: "mountpoint",
+			in:         "path/to/file.txt",
+			want:       "to/file.txt",
+		},
+		{
+			root:       "mountpoint/path",
+			mountpoint: "mountpoint",
+			in:         "path",
+			want:       "",
+		},
+		{
+			root:       "wrongmountpoint/path",
+			mountpoint: "mountpoint",
+			in:         "path/to/file.txt",
+			wantErr:    errNotUnderRoot,
+		},
+		{
+			root:       "mountpoint/wrongpath",
+			mountpoint: "mountpoint",
+			in:         "path/to/file.txt",
+			wantErr:    errNotUnderRoot,
+		},
+	} {
+		what := fmt.Sprintf("%+v", test)
+		a := newAdjustment(test.root, test.mountpoint)
+		got, gotErr := a.do(test.in)
+		assert.Equal(t, test.wantErr, gotErr, what)
+		assert.Equal(t, test.want, got, what)
+	}
+}
+
+func TestAdjustmentUndo(t *testing.T) {
+	for _, test := range []struct {
+		root       string
+		mountpoint string
+		in         string
+		want       string
+		wantErr    error
+	}{
+		{
+			root:       "",
+			mountpoint: "mountpoint",
+			in:         "mountpoint/path/to/file.txt",
+			want:       "path/to/file.txt",
+		},
+		{
+			root:       "mountpoint",
+			mountpoint: "mountpoint",
+			in:         "path/to/file.txt",
+			want:       "path/to/file.txt",
+		},
+		{
+			root:       "mountpoint/path",
+			mountpoint: "mountpoint",
+			in:         "to/file.txt",
+			want:       "path/to/file.txt",
+		},
+		{
+			root:       "",
+			mountpoint: "mountpoint",
+			in:         "mountpoint",
+			want:       "",
+		},
+		{
+			root:       "",
+			mountpoint: "mountpoint",
+			in:         "mountpointX/file.txt",
+			wantErr:    errNotUnderRoot,
+		},
+		{
+			root:       "wrongmountpoint/path",
+			mountpoint: "mountpoint",
+			in:         "to/file.txt",
+			wantErr:    errNotUnderRoot,
+		},
+	} {
+		what := fmt.Sprintf("%+v", test)
+		a := newAdjustment(test.root, test.mountpoint)
+		got, gotErr := a.undo(test.in)
+		assert.Equal(t, test.wantErr, gotErr, what)
+		assert.Equal(t, test.want, got, what)
+	}
+}
+
+func TestParseUpstream(t *testing.T) {
+	for _, test := range []struct {
+		in         string
+		wantDir    string
+		wantRemote string
+		wantErr    bool
+	}{
+		{in: "dir=remote:path", wantDir: "dir", wantRemote: "remote:path"},
+		{in: "dir=remote:path=with=equals", wantDir: "dir", wantRemote: "remote:path=with=equals"},
+		{in: "dir with space=/local/path", wantDir: "dir with space", wantRemote: "/local/path"},
+		{in: "remote:path", wantErr: true},
+		{in: "=remote:path", wantErr: true},
+		{in: "dir=", wantErr: true},
+		{in: "dir/sub=remote:path", wantErr: true},
+		{in: "..=remote:path", wantErr: true},
+	} {
+		gotDir, gotRemote, err := parseUpstream(test.in)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		assert.NoError(t, err, test.in)
+		assert.Equal(t, test.wantDir, gotDir, test.in)
+		assert.Equal(t, test.wantRemote, gotRemote, test.in)
+	}
+}
@@ -43,6 +43,8 @@ const (
 	minCompressionRatio = 1.1
 
 	gzFileExt           = ".gz"
+	zstdFileExt         = ".zst"
+	lz4FileExt          = ".lz4"
 	metaFileExt         = ".json"
 	uncompressedFileExt = ".bin"
 )
@@ -51,6 +53,8 @@ const (
 const (
 	Uncompressed = 0
 	Gzip         = 2
+	Zstd         = 3
+	LZ4          = 4
 )
 
 var nameRegexp = regexp.MustCompile("^(.+?)\\.([A-Za-z0-9-_]{11})$")
@@ -62,6 +66,12 @@ func init() {
 		{ // Default compression mode options {
 			Value: "gzip",
 			Help:  "Standard gzip compression with fastest parameters.",
+		}, {
+			Value: "zstd",
+			Help:  "Zstandard compression in seekable frames. Faster and smaller than gzip.",
+		}, {
+			Value: "lz4",
+			Help:  "LZ4 compression in seekable frames. Very fast with lower compression.",
 		},
 	}
 
@@ -81,7 +91,9 @@ func init() {
 			Examples: compressionModeOptions,
 		}, {
 			Name: "level",
-			Help: `GZIP compression level (-2 to 9).
+			Help: `Compression level.
+
+For gzip the level is from -2 to 9.
 
 Generally -1 (default, equivalent to 5) is recommended.
 Levels 1 to 9 increase compression at the cost of speed. Going past 6 
@@ -89,7 +101,13 @@ generally offers very little return.
 
 Level -2 uses Huffmann encoding only. Only use if you know what you
 are doing.
-Level 0 turns off compression.`,
+Level 0 turns off compression.
+
+For zstd the level is from 1 to 22 as used by the zstd tool, and is
+mapped onto the nearest level the encoder supports. -1 (default)
+uses the default zstd level.
+
+The level is ignored by lz4.`,
 			Default:  sgzip.DefaultCompression,
 			Advanced: true,
 		}, {
@@ -195,6 +213,10 @@ func compressionModeFromName(name string) int {
 	switch name {
 	case "gzip":
 		return Gzip
+	case "zstd":
+		return Zstd
+	case "lz4":
+		return LZ4
 	default:
 		return Uncompressed
 	}
@@ -237,7 +259,22 @@ func processFileName(compressedFileName string) (origFileName string, extension
 	if err != nil {
 		return "", "", 0, errors.New("Could not decode size")
 	}
-	return match[1], gzFileExt, size, nil
+	return match[1], extension, size, nil
+}
+
+// modeFileExt returns the file extension used for data compressed
+// with mode
+func modeFileExt(mode int) string {
+	switch mode {
+	case Zstd:
+		return zstdFileExt
+	case LZ4:
+		return lz4FileExt
+	case Uncompressed:
+		return uncompressedFileExt
+	default:
+		return gzFileExt
+	}
 }
 
 // Generates the file name for a metadata file
@@ -253,7 +290,7 @@ func isMetadataFile(filename string) bool {
 // makeDataName generates the file name for a data file with specified compression mode
 func makeDataName(remote string, size int64, mode int) (newRemote string) {
 	if mode != Uncompressed {
-		newRemote = remote + "." + int64ToBase64(size) + gzFileExt
+		newRemote = remote + "." + int64ToBase64(size) + modeFileExt(mode)
 	} else {
 		newRemote = remote + uncompressedFileExt
 	}
@@ -366,13 +403,13 @@ func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
 		return nil, errors.New("error decoding metadata")
 	}
 	// Create our Object
-	o, err := f.Fs.NewObject(ctx, makeDataName(remote, meta.CompressionMetadata.Size, meta.Mode))
+	o, err := f.Fs.NewObject(ctx, makeDataName(remote, meta.Size, meta.Mode))
 	return f.newObject(o, mo, meta), err
 }
 
-// checkCompressAndType checks if an object is compressible and determines it's mime type
+// checkCompressAndType checks if an object is compressible with mode and determines it's mime type
 // returns a multireader with the bytes that were read to determine mime type
-func checkCompressAndType(in io.Reader) (newReader io.Reader, compressible bool, mimeType string, err error) {
+func checkCompressAndType(in io.Reader, mode int) (newReader io.Reader, compressible bool, mimeType string, err error) {
 	in, wrap := accounting.UnWrap(in)
 	buf := make([]byte, heuristicBytes)
 	n, err := in.Read(buf)
@@ -381,7 +418,7 @@ func checkCompressAndType(in io.Reader) (newReader io.Reader, compressible bool,
 		return nil, false, "", err
 	}
 	mime := mimetype.Detect(buf)
-	compressible, err = isCompressible(bytes.NewReader(buf))
+	compressible, err = isCompressible(bytes.NewReader(buf), mode)
 	if err != nil {
 		return nil, false, "", err
 	}
@@ -389,11 +426,14 @@ func checkCompressAndType(in io.Reader) (newReader io.Reader, compressible bool,
 	return wrap(in), compressible, mime.String(), nil
 }
 
-// isCompressible checks the compression ratio of the provided data and returns true if the ratio exceeds
-// the configured threshold
-func isCompressible(r io.Reader) (bool, error) {
+// isCompressible checks the compression ratio of the provided data using mode and returns true if the ratio
+// exceeds the configured threshold
+func isCompressible(r io.Reader, mode int) (bool, error) {
+	if mode == Uncompressed {
+		return false, nil
+	}
 	var b bytes.Buffer
-	w, err := sgzip.NewWriterLevel(&b, sgzip.DefaultCompression)
+	w, err := newCompressor(&b, mode, sgzip.DefaultCompression)
 	if err != nil {
 		return false, err
 	}
@@ -429,9 +469,48 @@ func (f *Fs) verifyObjectHash(ctx context.Context, o fs.Object, hasher *hash.Mul
 
 type putFn func(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error)
 
+// compressor is a compressing writer for one of the compression modes
+type compressor interface {
+	io.WriteCloser
+	// setMetadata records the details needed to read the
+	// compressed data back in meta. It must be called after Close.
+	setMetadata(meta *ObjectMetadata)
+}
+
+// gzipCompressor compresses using sgzip
+type gzipCompressor struct {
+	*sgzip.Writer
+}
+
+// setMetadata records the sgzip metadata in meta
+func (gz gzipCompressor) setMetadata(meta *ObjectMetadata) {
+	meta.CompressionMetadata = gz.MetaData()
+	meta.Size = meta.CompressionMetadata.Size
+}
+
+// newCompressor returns a compressor writing the data compressed with
+// mode at level to w
+func newCompressor(w io.Writer, mode int, level int) (compressor, error) {
+	switch mode {
+	case Gzip:
+		gz, err := sgzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return gzipCompressor{gz}, nil
+	case Zstd, LZ4:
+		codec, err := newFrameCodec(mode, level)
+		if err != nil {
+			return nil, err
+		}
+		return newFrameWriter(w, codec), nil
+	}
+	return nil, fmt.Errorf("unknown compression mode %d", mode)
+}
+
 type compressionResult struct {
 	err  error
-	meta sgzip.GzipMetadata
+	meta *ObjectMetadata
 }
 
 // replicating some of operations.Rcat functionality because we want to support remotes without streaming
@@ -492,29 +571,32 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 
 	// Compress the file
 	pipeReader, pipeWriter := io.Pipe()
-	results := make(chan compressionResult)
+	results := make(chan compressionResult, 1)
 	go func() {
-		gz, err := sgzip.NewWriterLevel(pipeWriter, f.opt.CompressionLevel)
+		c, err := newCompressor(pipeWriter, f.mode, f.opt.CompressionLevel)
 		if err != nil {
-			results <- compressionResult{err: err, meta: sgzip.GzipMetadata{}}
+			_ = pipeWriter.CloseWithError(err)
+			results <- compressionResult{err: err}
 			return
 		}
-		_, err = io.Copy(gz, in)
-		gzErr := gz.Close()
-		if gzErr != nil {
-			fs.Errorf(nil, "Failed to close compress: %v", gzErr)
+		_, err = io.Copy(c, in)
+		cErr := c.Close()
+		if cErr != nil {
+			fs.Errorf(nil, "Failed to close compress: %v", cErr)
 			if err == nil {
-				err = gzErr
+				err = cErr
 			}
 		}
-		closeErr := pipeWriter.Close()
+		closeErr := pipeWriter.CloseWithError(err)
 		if closeErr != nil {
 			fs.Errorf(nil, "Failed to close pipe: %v", closeErr)
 			if err == nil {
 				err = closeErr
 			}
 		}
-		results <- compressionResult{err: err, meta: gz.MetaData()}
+		meta := new(ObjectMetadata)
+		c.setMetadata(meta)
+		results <- compressionResult{err: err, meta: meta}
 	}()
 	wrappedIn := wrap(bufio.NewReaderSize(pipeReader, bufferSize)) // Probably no longer needed as sgzip has it's own buffering
 
@@ -561,7 +643,10 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 	}
 
 	// Generate metadata
-	meta := newMetadata(result.meta.Size, f.mode, result.meta, hex.EncodeToString(metaHasher.Sum(nil)), mimeType)
+	meta := result.meta
+	meta.Mode = f.mode
+	meta.MD5 = hex.EncodeToString(metaHasher.Sum(nil))
+	meta.MimeType = mimeType
 
 	// Check the hashes of the compressed data if we were comparing them
 	if ht != hash.None && hasher != nil {
@@ -615,7 +700,7 @@ func (f *Fs) putUncompress(ctx context.Context, in io.Reader, src fs.ObjectInfo,
 	if err != nil {
 		return nil, nil, err
 	}
-	return o, newMetadata(o.Size(), Uncompressed, sgzip.GzipMetadata{}, hex.EncodeToString(sum), mimeType), nil
+	return o, newMetadata(o.Size(), Uncompressed, hex.EncodeToString(sum), mimeType), nil
 }
 
 // This function will write a metadata struct to a metadata Object for an src. Returns a wrappable metadata object.
@@ -686,7 +771,7 @@ func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options .
 	o, err := f.NewObject(ctx, src.Remote())
 	if err == fs.ErrorObjectNotFound {
 		// Get our file compressibility
-		in, compressible, mimeType, err := checkCompressAndType(in)
+		in, compressible, mimeType, err := checkCompressAndType(in, f.mode)
 		if err != nil {
 			return nil, err
 		}
@@ -706,7 +791,7 @@ func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, opt
 	}
 	found := err == nil
 
-	in, compressible, mimeType, err := checkCompressAndType(in)
+	in, compressible, mimeType, err := checkCompressAndType(in, f.mode)
 	if err != nil {
 		return nil, err
 	}
@@ -1010,6 +1095,7 @@ type ObjectMetadata struct {
 	MD5                 string // MD5 hash of the file.
 	MimeType            string // Mime type of the file
 	CompressionMetadata sgzip.GzipMetadata
+	SeekTable           *SeekTable `json:",omitempty"` // Frames of a zstd or lz4 compressed file
 }
 
 // Object with external metadata
@@ -1023,11 +1109,10 @@ type Object struct {
 }
 
 // This function generates a metadata object
-func newMetadata(size int64, mode int, cmeta sgzip.GzipMetadata, md5 string, mimeType string) *ObjectMetadata {
+func newMetadata(size int64, mode int, md5 string, mimeType string) *ObjectMetadata {
 	meta := new(ObjectMetadata)
 	meta.Size = size
 	meta.Mode = mode
-	meta.CompressionMetadata = cmeta
 	meta.MD5 = md5
 	meta.MimeType = mimeType
 	return meta
@@ -1085,7 +1170,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		return o.mo, o.mo.Update(ctx, in, src, options...)
 	}
 
-	in, compressible, mimeType, err := checkCompressAndType(in)
+	in, compressible, mimeType, err := checkCompressAndType(in, o.f.mode)
 	if err != nil {
 		return err
 	}
@@ -1280,6 +1365,9 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 	}
 	// Get a chunkedreader for the wrapped object
 	chunkedReader := chunkedreader.New(ctx, o.Object, initialChunkSize, maxChunkSize)
+	if o.meta.Mode == Zstd || o.meta.Mode == LZ4 {
+		return o.openFrames(ctx, chunkedReader, offset, limit)
+	}
 	// Get file handle
 	var file io.Reader
 	if offset != 0 {
@@ -1301,6 +1389,38 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 	return ReadCloserWrapper{Reader: fileReader, Closer: chunkedReader}, nil
 }
 
+// openFrames opens a file compressed in seekable frames reading only
+// the frames needed for offset and limit
+func (o *Object) openFrames(ctx context.Context, chunkedReader *chunkedreader.ChunkedReader, offset, limit int64) (rc io.ReadCloser, err error) {
+	codec, err := newFrameCodec(o.meta.Mode, o.f.opt.CompressionLevel)
+	if err != nil {
+		_ = chunkedReader.Close()
+		return nil, err
+	}
+	fr, err := newFrameReader(ctx, chunkedReader, codec, o.meta.SeekTable, offset, limit)
+	if err != nil {
+		_ = chunkedReader.Close()
+		return nil, err
+	}
+	var fileReader io.Reader = fr
+	if limit != -1 {
+		fileReader = io.LimitReader(fr, limit)
+	}
+	return ReadCloserWrapper{Reader: fileReader, Closer: frameReadCloser{fr: fr, in: chunkedReader}}, nil
+}
+
+// frameReadCloser closes both the frameReader and its input
+type frameReadCloser struct {
+	fr *frameReader
+	in io.Closer
+}
+
+// Close the frameReader and its input
+func (c frameReadCloser) Close() error {
+	_ = c.fr.Close()
+	return c.in.Close()
+}
+
 // ObjectInfo describes a wrapped fs.ObjectInfo for being the source
 type ObjectInfo struct {
 	src    fs.ObjectInfo
@@ -1369,6 +1489,13 @@ func (o *Object) ID() string {
 	return do.ID()
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
 // Name of the remote (as passed into NewFs)
 func (f *Fs) Name() string {
 	return f.name
@@ -1418,5 +1545,6 @@ var (
 	_ fs.Object          = (*Object)(nil)
 	_ fs.ObjectUnWrapper = (*Object)(nil)
 	_ fs.IDer            = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
 	_ fs.MimeTyper       = (*Object)(nil)
 )
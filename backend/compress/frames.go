@@ -0,0 +1,329 @@
+package compress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The zstd and lz4 modes split the data into frames of frameSize
+// bytes which are compressed independently of each other. The
+// compressed size of each frame is stored in the SeekTable in the
+// metadata so reading can start from any frame without decompressing
+// the frames before it.
+//
+// Each frame is a complete zstd or lz4 frame, so the data files can
+// also be decompressed with the standard tools.
+
+const (
+	frameSize = 1024 * 1024 // uncompressed size of each frame
+)
+
+// SeekTable describes the frames of a zstd or lz4 compressed file
+type SeekTable struct {
+	FrameSize  int64    // Uncompressed size of each frame except the last
+	Size       int64    // Uncompressed size of the file
+	FrameSizes []uint32 // Compressed size of each frame
+}
+
+// frameOffset returns the offset in the compressed data of frame
+func (t *SeekTable) frameOffset(frame int) (offset int64) {
+	for _, size := range t.FrameSizes[:frame] {
+		offset += int64(size)
+	}
+	return offset
+}
+
+// frameCodec compresses and decompresses independent frames
+type frameCodec interface {
+	// compressFrame appends src compressed as a single frame to dst
+	compressFrame(dst, src []byte) ([]byte, error)
+	// decompressFrame appends the uncompressed contents of the
+	// frame in src to dst
+	decompressFrame(dst, src []byte) ([]byte, error)
+	// close releases any resources used by the codec
+	close()
+}
+
+// newFrameCodec returns the frameCodec for mode using the compression
+// level given
+func newFrameCodec(mode int, level int) (frameCodec, error) {
+	switch mode {
+	case Zstd:
+		return &zstdCodec{level: level}, nil
+	case LZ4:
+		return &lz4Codec{}, nil
+	}
+	return nil, fmt.Errorf("compression mode %d doesn't use frames", mode)
+}
+
+// zstdCodec compresses each frame as a zstd frame
+type zstdCodec struct {
+	level int
+	enc   *zstd.Encoder
+	dec   *zstd.Decoder
+}
+
+// zstdLevel converts the level option into a zstd encoder level
+//
+// Negative levels give the default level, otherwise levels are
+// interpreted as for the zstd command line tool.
+func zstdLevel(level int) zstd.EncoderLevel {
+	if level < 0 {
+		return zstd.SpeedDefault
+	}
+	return zstd.EncoderLevelFromZstd(level)
+}
+
+// compressFrame appends src compressed as a single zstd frame to dst
+func (c *zstdCodec) compressFrame(dst, src []byte) ([]byte, error) {
+	if c.enc == nil {
+		var err error
+		c.enc, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstdLevel(c.level)), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.enc.EncodeAll(src, dst), nil
+}
+
+// decompressFrame appends the uncompressed contents of the zstd frame
+// in src to dst
+func (c *zstdCodec) decompressFrame(dst, src []byte) ([]byte, error) {
+	if c.dec == nil {
+		var err error
+		c.dec, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.dec.DecodeAll(src, dst)
+}
+
+// close releases the encoder and decoder
+func (c *zstdCodec) close() {
+	if c.enc != nil {
+		_ = c.enc.Close()
+		c.enc = nil
+	}
+	if c.dec != nil {
+		c.dec.Close()
+		c.dec = nil
+	}
+}
+
+// frameWriter compresses the data written to it into independent
+// frames recording their sizes in a SeekTable
+type frameWriter struct {
+	w     io.Writer
+	codec frameCodec
+	buf   []byte // uncompressed data for the current frame
+	out   []byte // compressed data for the current frame
+	table SeekTable
+	err   error
+}
+
+// newFrameWriter returns a frameWriter writing the frames compressed
+// with codec to w
+func newFrameWriter(w io.Writer, codec frameCodec) *frameWriter {
+	return &frameWriter{
+		w:     w,
+		codec: codec,
+		buf:   make([]byte, 0, frameSize),
+		table: SeekTable{
+			FrameSize: frameSize,
+		},
+	}
+}
+
+// Write compresses p writing out each frame as it fills
+func (fw *frameWriter) Write(p []byte) (n int, err error) {
+	if fw.err != nil {
+		return 0, fw.err
+	}
+	for len(p) > 0 {
+		copied := copy(fw.buf[len(fw.buf):cap(fw.buf)], p)
+		fw.buf = fw.buf[:len(fw.buf)+copied]
+		p = p[copied:]
+		n += copied
+		if len(fw.buf) == cap(fw.buf) {
+			err = fw.flush()
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush compresses and writes out the current frame
+func (fw *frameWriter) flush() error {
+	if fw.err != nil || len(fw.buf) == 0 {
+		return fw.err
+	}
+	fw.out, fw.err = fw.codec.compressFrame(fw.out[:0], fw.buf)
+	if fw.err != nil {
+		return fw.err
+	}
+	_, fw.err = fw.w.Write(fw.out)
+	if fw.err != nil {
+		return fw.err
+	}
+	fw.table.FrameSizes = append(fw.table.FrameSizes, uint32(len(fw.out)))
+	fw.table.Size += int64(len(fw.buf))
+	fw.buf = fw.buf[:0]
+	return nil
+}
+
+// Close writes out the last frame. It doesn't close the underlying
+// writer.
+func (fw *frameWriter) Close() error {
+	err := fw.flush()
+	fw.codec.close()
+	if err == nil {
+		fw.err = errors.New("write on closed frameWriter")
+	}
+	return err
+}
+
+// setMetadata records the SeekTable in meta
+func (fw *frameWriter) setMetadata(meta *ObjectMetadata) {
+	table := fw.table
+	meta.SeekTable = &table
+	meta.Size = table.Size
+}
+
+// rangeSeeker is the interface used to position the input of a
+// frameReader
+type rangeSeeker interface {
+	RangeSeek(ctx context.Context, offset int64, whence int, length int64) (int64, error)
+}
+
+// frameReader reads the uncompressed data from a sequence of frames
+type frameReader struct {
+	in         io.Reader
+	codec      frameCodec
+	table      *SeekTable
+	frame      int    // index of the next frame to read
+	compressed []byte // buffer for the compressed frame
+	out        []byte // buffer for the uncompressed frame
+	buf        []byte // uncompressed data not yet returned
+	err        error
+}
+
+// newFrameReader returns a reader for the uncompressed data starting
+// at offset. If limit is > 0 then only the frames needed to read
+// limit bytes are fetched from in.
+func newFrameReader(ctx context.Context, in io.Reader, codec frameCodec, table *SeekTable, offset, limit int64) (*frameReader, error) {
+	if table == nil || table.FrameSize <= 0 {
+		return nil, errors.New("missing seek table in metadata")
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	frame := int(offset / table.FrameSize)
+	if frame > len(table.FrameSizes) {
+		frame = len(table.FrameSizes)
+	}
+	// Nothing needs to be read if we start at the end, so don't
+	// seek as seeking to the end of the input may fail
+	atEnd := frame == len(table.FrameSizes) || limit == 0
+	if !atEnd && (offset != 0 || limit > 0) {
+		rs, ok := in.(rangeSeeker)
+		if !ok {
+			return nil, errors.New("can't seek in compressed data")
+		}
+		start := table.frameOffset(frame)
+		length := int64(-1)
+		if limit > 0 {
+			endFrame := int((offset + limit + table.FrameSize - 1) / table.FrameSize)
+			if endFrame > len(table.FrameSizes) {
+				endFrame = len(table.FrameSizes)
+			}
+			length = table.frameOffset(endFrame) - start
+		}
+		_, err := rs.RangeSeek(ctx, start, io.SeekStart, length)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fr := &frameReader{
+		in:    in,
+		codec: codec,
+		table: table,
+		frame: frame,
+	}
+	if atEnd {
+		fr.frame = len(table.FrameSizes)
+		return fr, nil
+	}
+	skip := offset - int64(frame)*table.FrameSize
+	if skip > 0 {
+		err := fr.fill()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if skip > int64(len(fr.buf)) {
+			skip = int64(len(fr.buf))
+		}
+		fr.buf = fr.buf[skip:]
+	}
+	return fr, nil
+}
+
+// fill reads and decompresses the next frame into fr.buf
+func (fr *frameReader) fill() (err error) {
+	if fr.frame >= len(fr.table.FrameSizes) {
+		return io.EOF
+	}
+	size := int(fr.table.FrameSizes[fr.frame])
+	if cap(fr.compressed) < size {
+		fr.compressed = make([]byte, size)
+	}
+	fr.compressed = fr.compressed[:size]
+	_, err = io.ReadFull(fr.in, fr.compressed)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read frame %d: %w", fr.frame, err)
+	}
+	fr.out, err = fr.codec.decompressFrame(fr.out[:0], fr.compressed)
+	if err != nil {
+		return fmt.Errorf("failed to decompress frame %d: %w", fr.frame, err)
+	}
+	// Check the frame is the size we expect
+	want := fr.table.FrameSize
+	if fr.frame == len(fr.table.FrameSizes)-1 {
+		want = fr.table.Size - int64(fr.frame)*fr.table.FrameSize
+	}
+	if int64(len(fr.out)) != want {
+		return fmt.Errorf("frame %d decompressed to %d bytes but expected %d", fr.frame, len(fr.out), want)
+	}
+	fr.buf = fr.out
+	fr.frame++
+	return nil
+}
+
+// Read reads uncompressed data into p
+func (fr *frameReader) Read(p []byte) (n int, err error) {
+	for len(fr.buf) == 0 {
+		if fr.err != nil {
+			return 0, fr.err
+		}
+		fr.err = fr.fill()
+	}
+	n = copy(p, fr.buf)
+	fr.buf = fr.buf[n:]
+	return n, nil
+}
+
+// Close releases the resources used by the codec. It doesn't close
+// the underlying reader.
+func (fr *frameReader) Close() error {
+	fr.codec.close()
+	return nil
+}
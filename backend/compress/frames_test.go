@@ -0,0 +1,139 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeReader is an in memory reader implementing rangeSeeker
+type rangeReader struct {
+	data []byte
+	r    io.Reader
+}
+
+func newRangeReader(data []byte) *rangeReader {
+	return &rangeReader{data: data, r: bytes.NewReader(data)}
+}
+
+func (rr *rangeReader) Read(p []byte) (int, error) {
+	return rr.r.Read(p)
+}
+
+func (rr *rangeReader) RangeSeek(ctx context.Context, offset int64, whence int, length int64) (int64, error) {
+	end := int64(len(rr.data))
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+	rr.r = bytes.NewReader(rr.data[offset:end])
+	return offset, nil
+}
+
+// testData returns n bytes of somewhat compressible data
+func testData(n int) []byte {
+	r := rand.New(rand.NewSource(1))
+	words := []string{"one ", "two ", "three ", "four ", "five\n"}
+	var buf bytes.Buffer
+	for buf.Len() < n {
+		if r.Intn(10) == 0 {
+			buf.WriteByte(byte(r.Intn(256)))
+		} else {
+			buf.WriteString(words[r.Intn(len(words))])
+		}
+	}
+	return buf.Bytes()[:n]
+}
+
+func TestXXH32(t *testing.T) {
+	assert.Equal(t, uint32(0x02CC5D05), xxh32(nil, 0))
+	assert.Equal(t, uint32(0x32D153FF), xxh32([]byte("abc"), 0))
+	assert.Equal(t, byte(0xA7), lz4HeaderChecksum([]byte{0x64, 0x40}))
+}
+
+func TestFrameCodecs(t *testing.T) {
+	for _, mode := range []int{Zstd, LZ4} {
+		for _, size := range []int{0, 1, 15, 100, 65536, 300000, frameSize} {
+			codec, err := newFrameCodec(mode, -1)
+			require.NoError(t, err)
+			in := testData(size)
+			compressed, err := codec.compressFrame(nil, in)
+			require.NoError(t, err)
+			out, err := codec.decompressFrame(nil, compressed)
+			require.NoError(t, err, "mode %d size %d", mode, size)
+			assert.Equal(t, in, out, "mode %d size %d", mode, size)
+			codec.close()
+		}
+	}
+}
+
+func TestLZ4Incompressible(t *testing.T) {
+	in := make([]byte, 10000)
+	_, _ = rand.New(rand.NewSource(2)).Read(in)
+	c := &lz4Codec{}
+	compressed, err := c.compressFrame(nil, in)
+	require.NoError(t, err)
+	// header + block size + data + end mark
+	assert.Equal(t, 7+4+len(in)+4, len(compressed))
+	out, err := c.decompressFrame(nil, compressed)
+	require.NoError(t, err)
+	assert.Equal(t, in, out)
+
+	_, err = c.decompressFrame(nil, compressed[:len(compressed)-1])
+	assert.Error(t, err)
+}
+
+func TestFrameWriterReader(t *testing.T) {
+	ctx := context.Background()
+	in := testData(3*frameSize + 12345)
+	for _, mode := range []int{Zstd, LZ4} {
+		var buf bytes.Buffer
+		c, err := newCompressor(&buf, mode, -1)
+		require.NoError(t, err)
+		_, err = c.Write(in[:100])
+		require.NoError(t, err)
+		_, err = c.Write(in[100:])
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+		meta := new(ObjectMetadata)
+		c.setMetadata(meta)
+		require.NotNil(t, meta.SeekTable)
+		assert.Equal(t, int64(len(in)), meta.Size)
+		assert.Equal(t, 4, len(meta.SeekTable.FrameSizes))
+		assert.Equal(t, int64(buf.Len()), meta.SeekTable.frameOffset(4))
+
+		for _, test := range []struct {
+			offset int64
+			limit  int64
+		}{
+			{0, -1},
+			{1, -1},
+			{frameSize, -1},
+			{frameSize - 1, 2},
+			{2*frameSize + 17, 100000},
+			{int64(len(in)) - 10, -1},
+			{int64(len(in)), -1},
+			{0, 0},
+		} {
+			codec, err := newFrameCodec(mode, -1)
+			require.NoError(t, err)
+			fr, err := newFrameReader(ctx, newRangeReader(buf.Bytes()), codec, meta.SeekTable, test.offset, test.limit)
+			require.NoError(t, err)
+			var r io.Reader = fr
+			want := in[test.offset:]
+			if test.limit >= 0 {
+				r = io.LimitReader(r, test.limit)
+				want = want[:test.limit]
+			}
+			got, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "mode %d offset %d limit %d", mode, test.offset, test.limit)
+			require.NoError(t, fr.Close())
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package compress
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// This implements just enough of the LZ4 block and frame formats to
+// compress each seekable frame as a standalone LZ4 frame. This means
+// the data files can be decompressed by the standard lz4 tool.
+//
+// See https://github.com/lz4/lz4/blob/dev/doc/lz4_Block_format.md
+// and https://github.com/lz4/lz4/blob/dev/doc/lz4_Frame_format.md
+
+const (
+	lz4Magic        = 0x184D2204
+	lz4MinMatch     = 4
+	lz4HashLog      = 16
+	lz4MaxOffset    = 65535
+	lz4LastLiterals = 5  // the last 5 bytes of a block are always literals
+	lz4MFLimit      = 12 // the last match must start at least 12 bytes before the end
+	lz4Uncompressed = 0x80000000
+
+	// frame descriptor flags - version 01, independent blocks and no checksums
+	lz4FLG = 0x60
+	// block descriptor - maximum block size of 4 MiB
+	lz4BD = 0x70
+	// maximum block size for lz4BD
+	lz4MaxBlockSize = 4 * 1024 * 1024
+)
+
+var (
+	errLZ4Corrupt  = errors.New("lz4: corrupted data")
+	errLZ4Checksum = errors.New("lz4: checksum mismatch")
+)
+
+// lz4Codec compresses each frame as an LZ4 frame
+type lz4Codec struct {
+	table []int32 // hash table used for compression
+}
+
+// compressFrame appends src compressed as an LZ4 frame with a single
+// block to dst
+func (c *lz4Codec) compressFrame(dst, src []byte) ([]byte, error) {
+	if len(src) > lz4MaxBlockSize {
+		return nil, fmt.Errorf("lz4: frame of %d bytes too big", len(src))
+	}
+	dst = lz4AppendFrameHeader(dst)
+	if len(src) > 0 {
+		sizePos := len(dst)
+		dst = append(dst, 0, 0, 0, 0)
+		if c.table == nil {
+			c.table = make([]int32, 1<<lz4HashLog)
+		} else {
+			for i := range c.table {
+				c.table[i] = 0
+			}
+		}
+		dst = lz4CompressBlock(dst, src, c.table)
+		blockSize := len(dst) - sizePos - 4
+		if blockSize >= len(src) {
+			// store uncompressed if compression didn't help
+			dst = append(dst[:sizePos+4], src...)
+			binary.LittleEndian.PutUint32(dst[sizePos:], uint32(len(src))|lz4Uncompressed)
+		} else {
+			binary.LittleEndian.PutUint32(dst[sizePos:], uint32(blockSize))
+		}
+	}
+	// EndMark
+	return append(dst, 0, 0, 0, 0), nil
+}
+
+// decompressFrame appends the uncompressed data from the LZ4 frame in
+// src to dst
+func (c *lz4Codec) decompressFrame(dst, src []byte) ([]byte, error) {
+	if len(src) < 7 || binary.LittleEndian.Uint32(src) != lz4Magic {
+		return nil, errors.New("lz4: bad frame header")
+	}
+	flg, bd := src[4], src[5]
+	if flg&0xC0 != 0x40 {
+		return nil, fmt.Errorf("lz4: unsupported frame version %d", flg>>6)
+	}
+	if src[6] != lz4HeaderChecksum(src[4:6]) {
+		return nil, errors.New("lz4: bad frame header checksum")
+	}
+	if flg&0x08 != 0 || flg&0x01 != 0 {
+		return nil, errors.New("lz4: unsupported frame flags")
+	}
+	linked := flg&0x20 == 0
+	blockChecksum := flg&0x10 != 0
+	contentChecksum := flg&0x04 != 0
+	maxBlockSize := 1 << (8 + 2*((bd>>4)&0x07))
+	src = src[7:]
+	frameStart := len(dst)
+	for {
+		if len(src) < 4 {
+			return nil, errLZ4Corrupt
+		}
+		blockSize := binary.LittleEndian.Uint32(src)
+		src = src[4:]
+		if blockSize == 0 {
+			break
+		}
+		uncompressed := blockSize&lz4Uncompressed != 0
+		blockSize &^= lz4Uncompressed
+		if int(blockSize) > len(src) || int(blockSize) > maxBlockSize {
+			return nil, errLZ4Corrupt
+		}
+		block := src[:blockSize]
+		src = src[blockSize:]
+		if blockChecksum {
+			if len(src) < 4 {
+				return nil, errLZ4Corrupt
+			}
+			if binary.LittleEndian.Uint32(src) != xxh32(block, 0) {
+				return nil, errLZ4Checksum
+			}
+			src = src[4:]
+		}
+		if uncompressed {
+			dst = append(dst, block...)
+			continue
+		}
+		// matches in linked blocks can refer to the previous blocks
+		window := len(dst)
+		if linked {
+			window = frameStart
+		}
+		var err error
+		dst, err = lz4DecompressBlock(dst, block, window, maxBlockSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if contentChecksum {
+		if len(src) < 4 {
+			return nil, errLZ4Corrupt
+		}
+		if binary.LittleEndian.Uint32(src) != xxh32(dst[frameStart:], 0) {
+			return nil, errLZ4Checksum
+		}
+		src = src[4:]
+	}
+	if len(src) != 0 {
+		return nil, errLZ4Corrupt
+	}
+	return dst, nil
+}
+
+// close releases any resources used by the codec
+func (c *lz4Codec) close() {
+	c.table = nil
+}
+
+// lz4AppendFrameHeader appends the frame magic and descriptor to dst
+func lz4AppendFrameHeader(dst []byte) []byte {
+	dst = append(dst, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(dst[len(dst)-4:], lz4Magic)
+	descriptor := []byte{lz4FLG, lz4BD}
+	dst = append(dst, descriptor...)
+	return append(dst, lz4HeaderChecksum(descriptor))
+}
+
+// lz4HeaderChecksum returns the header checksum of the frame descriptor
+func lz4HeaderChecksum(descriptor []byte) byte {
+	return byte(xxh32(descriptor, 0) >> 8)
+}
+
+// lz4CompressBlock appends src compressed as an LZ4 block to dst
+//
+// table is used as the hash table and must be zeroed and have
+// 1<<lz4HashLog entries
+func lz4CompressBlock(dst, src []byte, table []int32) []byte {
+	n := len(src)
+	anchor := 0
+	if n > lz4MFLimit {
+		limit := n - lz4MFLimit
+		for i := 0; i < limit; {
+			seq := binary.LittleEndian.Uint32(src[i:])
+			h := (seq * 2654435761) >> (32 - lz4HashLog)
+			// positions are stored +1 so 0 means empty
+			ref := int(table[h]) - 1
+			table[h] = int32(i + 1)
+			if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+				i++
+				continue
+			}
+			// extend the match backwards
+			for i > anchor && ref > 0 && src[i-1] == src[ref-1] {
+				i--
+				ref--
+			}
+			// and forwards, leaving the last literals alone
+			matchLen := lz4MinMatch
+			maxMatch := n - lz4LastLiterals - i
+			for matchLen < maxMatch && src[i+matchLen] == src[ref+matchLen] {
+				matchLen++
+			}
+			dst = lz4AppendSequence(dst, src[anchor:i], i-ref, matchLen)
+			i += matchLen
+			anchor = i
+		}
+	}
+	// last literals
+	literals := src[anchor:]
+	if len(literals) >= 15 {
+		dst = append(dst, 0xF0)
+		dst = lz4AppendLength(dst, len(literals)-15)
+	} else {
+		dst = append(dst, byte(len(literals)<<4))
+	}
+	return append(dst, literals...)
+}
+
+// lz4AppendSequence appends a sequence of literals followed by a
+// match to dst
+func lz4AppendSequence(dst, literals []byte, offset, matchLen int) []byte {
+	litLen := len(literals)
+	ml := matchLen - lz4MinMatch
+	var token byte
+	if litLen >= 15 {
+		token = 0xF0
+	} else {
+		token = byte(litLen << 4)
+	}
+	if ml >= 15 {
+		token |= 0x0F
+	} else {
+		token |= byte(ml)
+	}
+	dst = append(dst, token)
+	if litLen >= 15 {
+		dst = lz4AppendLength(dst, litLen-15)
+	}
+	dst = append(dst, literals...)
+	dst = append(dst, byte(offset), byte(offset>>8))
+	if ml >= 15 {
+		dst = lz4AppendLength(dst, ml-15)
+	}
+	return dst
+}
+
+// lz4AppendLength appends the extra bytes of a length to dst
+func lz4AppendLength(dst []byte, n int) []byte {
+	for n >= 255 {
+		dst = append(dst, 255)
+		n -= 255
+	}
+	return append(dst, byte(n))
+}
+
+// lz4ReadLength reads the extra bytes of a length from src at i
+// returning the length and the new position
+func lz4ReadLength(src []byte, i int, n int) (int, int, error) {
+	for {
+		if i >= len(src) {
+			return 0, 0, errLZ4Corrupt
+		}
+		b := src[i]
+		i++
+		n += int(b)
+		if b != 255 {
+			return n, i, nil
+		}
+	}
+}
+
+// lz4DecompressBlock appends the uncompressed data of the LZ4 block
+// in src to dst. Matches may refer back as far as dst[window:]. It
+// returns an error if the block decompresses to more than maxSize
+// bytes.
+func lz4DecompressBlock(dst, src []byte, window, maxSize int) ([]byte, error) {
+	start := len(dst)
+	var err error
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		// literals
+		litLen := int(token >> 4)
+		if litLen == 15 {
+			litLen, i, err = lz4ReadLength(src, i, litLen)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if litLen > len(src)-i || len(dst)-start+litLen > maxSize {
+			return nil, errLZ4Corrupt
+		}
+		dst = append(dst, src[i:i+litLen]...)
+		i += litLen
+		if i == len(src) {
+			// the last sequence has no match
+			break
+		}
+
+		// match
+		if i+2 > len(src) {
+			return nil, errLZ4Corrupt
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst)-window {
+			return nil, errLZ4Corrupt
+		}
+		matchLen := int(token & 0x0F)
+		if matchLen == 15 {
+			matchLen, i, err = lz4ReadLength(src, i, matchLen)
+			if err != nil {
+				return nil, err
+			}
+		}
+		matchLen += lz4MinMatch
+		if len(dst)-start+matchLen > maxSize {
+			return nil, errLZ4Corrupt
+		}
+		pos := len(dst) - offset
+		if offset >= matchLen {
+			dst = append(dst, dst[pos:pos+matchLen]...)
+		} else {
+			// overlapping match so copy byte by byte
+			for k := 0; k < matchLen; k++ {
+				dst = append(dst, dst[pos+k])
+			}
+		}
+	}
+	return dst, nil
+}
+
+// xxHash32 primes
+const (
+	xxhPrime1 uint32 = 2654435761
+	xxhPrime2 uint32 = 2246822519
+	xxhPrime3 uint32 = 3266489917
+	xxhPrime4 uint32 = 668265263
+	xxhPrime5 uint32 = 374761393
+)
+
+// xxh32Round mixes input into acc
+func xxh32Round(acc, input uint32) uint32 {
+	return bits.RotateLeft32(acc+input*xxhPrime2, 13) * xxhPrime1
+}
+
+// xxh32 returns the xxHash32 of b with seed as used in the LZ4 frame
+// format
+func xxh32(b []byte, seed uint32) uint32 {
+	n := len(b)
+	var h uint32
+	if n >= 16 {
+		v1 := seed + xxhPrime1 + xxhPrime2
+		v2 := seed + xxhPrime2
+		v3 := seed
+		v4 := seed - xxhPrime1
+		for len(b) >= 16 {
+			v1 = xxh32Round(v1, binary.LittleEndian.Uint32(b[0:]))
+			v2 = xxh32Round(v2, binary.LittleEndian.Uint32(b[4:]))
+			v3 = xxh32Round(v3, binary.LittleEndian.Uint32(b[8:]))
+			v4 = xxh32Round(v4, binary.LittleEndian.Uint32(b[12:]))
+			b = b[16:]
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) + bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + xxhPrime5
+	}
+	h += uint32(n)
+	for len(b) >= 4 {
+		h += binary.LittleEndian.Uint32(b) * xxhPrime3
+		h = bits.RotateLeft32(h, 17) * xxhPrime4
+		b = b[4:]
+	}
+	for _, c := range b {
+		h += uint32(c) * xxhPrime5
+		h = bits.RotateLeft32(h, 11) * xxhPrime1
+	}
+	h ^= h >> 15
+	h *= xxhPrime2
+	h ^= h >> 13
+	h *= xxhPrime3
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,41 @@
+//go:build go1.18
+// +build go1.18
+
+package compress
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzLZ4 checks that anything compressed decompresses to the same
+// data and that decompressing arbitrary data doesn't panic
+//
+// Run it with go test -fuzz FuzzLZ4 ./backend/compress
+func FuzzLZ4(f *testing.F) {
+	f.Add([]byte("hello hello hello hello hello"))
+	f.Add(bytes.Repeat([]byte{0}, 1000))
+	f.Add(testData(10000))
+	f.Fuzz(func(t *testing.T, in []byte) {
+		c := &lz4Codec{}
+		frame, err := c.compressFrame(nil, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := c.decompressFrame(nil, frame)
+		if err != nil {
+			t.Fatalf("decompress failed: %v", err)
+		}
+		if !bytes.Equal(in, out) {
+			t.Fatal("round trip mismatch")
+		}
+
+		// arbitrary input as a frame and as a block
+		_, _ = c.decompressFrame(nil, in)
+		frame = lz4AppendFrameHeader(nil)
+		frame = append(frame, byte(len(in)), byte(len(in)>>8), byte(len(in)>>16), 0)
+		frame = append(frame, in...)
+		frame = append(frame, 0, 0, 0, 0)
+		_, _ = c.decompressFrame(nil, frame)
+	})
+}
@@ -0,0 +1,106 @@
+package compress
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLZ4Reference decodes frames made by the reference lz4 tool
+//
+// The files in testdata were made with lz4 v1.9.4 from testData with
+//
+//	lz4 in lz4-default.lz4                        # 20000 bytes
+//	lz4 -BX in lz4-block-checksum.lz4             # 20000 bytes
+//	lz4 -9 -BD -B4 in lz4-linked.lz4              # 100000 bytes
+func TestLZ4Reference(t *testing.T) {
+	c := &lz4Codec{}
+	for _, test := range []struct {
+		name string
+		size int
+	}{
+		{"lz4-default.lz4", 20000},
+		{"lz4-block-checksum.lz4", 20000},
+		{"lz4-linked.lz4", 100000},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			frame, err := ioutil.ReadFile(filepath.Join("testdata", test.name))
+			require.NoError(t, err)
+			got, err := c.decompressFrame(nil, frame)
+			require.NoError(t, err)
+			assert.Equal(t, testData(test.size), got)
+
+			// corruption is detected by the checksums
+			frame[len(frame)-10] ^= 0x55
+			_, err = c.decompressFrame(nil, frame)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// lz4Data returns a variety of inputs for testing the codec
+func lz4Data() (out [][]byte) {
+	r := rand.New(rand.NewSource(3))
+	for _, size := range []int{0, 1, 4, 5, 12, 13, 16, 100, 1000, 65535, 65536, 70000, 300000} {
+		random := make([]byte, size)
+		_, _ = r.Read(random)
+		repeats := make([]byte, size)
+		for i := range repeats {
+			repeats[i] = byte(i % 7)
+		}
+		out = append(out, testData(size), random, repeats)
+	}
+	return out
+}
+
+// TestLZ4Tool checks the codec against the lz4 tool if it is installed
+func TestLZ4Tool(t *testing.T) {
+	lz4, err := exec.LookPath("lz4")
+	if err != nil {
+		t.Skip("lz4 tool not found")
+	}
+	c := &lz4Codec{}
+	for _, in := range lz4Data() {
+		// our frames decompress with the lz4 tool
+		frame, err := c.compressFrame(nil, in)
+		require.NoError(t, err)
+		cmd := exec.Command(lz4, "-d", "-c")
+		cmd.Stdin = bytes.NewReader(frame)
+		out, err := cmd.Output()
+		require.NoError(t, err, "size %d", len(in))
+		assert.Equal(t, in, out, "size %d", len(in))
+
+		// and we can decompress the lz4 tool's frames
+		for _, args := range [][]string{{}, {"-9"}, {"-BX"}, {"--no-frame-crc"}} {
+			cmd := exec.Command(lz4, append(args, "-c", "-")...)
+			cmd.Stdin = bytes.NewReader(in)
+			frame, err := cmd.Output()
+			require.NoError(t, err)
+			out, err := c.decompressFrame(nil, frame)
+			require.NoError(t, err, "size %d args %v", len(in), args)
+			assert.Equal(t, in, out, "size %d args %v", len(in), args)
+		}
+	}
+}
+
+func TestLZ4Corrupt(t *testing.T) {
+	c := &lz4Codec{}
+	frame, err := c.compressFrame(nil, testData(10000))
+	require.NoError(t, err)
+	// corrupting any byte must give an error or wrong data, never a panic
+	for i := range frame {
+		corrupt := append([]byte(nil), frame...)
+		corrupt[i] ^= 0xFF
+		_, _ = c.decompressFrame(nil, corrupt)
+	}
+	for i := range frame {
+		_, err = c.decompressFrame(nil, frame[:i])
+		assert.Error(t, err, "truncated to %d", i)
+	}
+}
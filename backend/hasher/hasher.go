@@ -461,6 +461,13 @@ func (o *Object) ID() string {
 	return ""
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
 // GetTier returns the Tier of the Object if possible
 func (o *Object) GetTier() string {
 	if doer, ok := o.Object.(fs.GetTierer); ok {
@@ -512,5 +519,6 @@ var (
 	_ fs.IDer            = (*Object)(nil)
 	_ fs.SetTierer       = (*Object)(nil)
 	_ fs.GetTierer       = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
 	_ fs.MimeTyper       = (*Object)(nil)
 )
@@ -0,0 +1,157 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+const metadataTimeFormat = time.RFC3339Nano
+
+// system metadata keys which this backend owns
+var systemMetadataInfo = map[string]fs.MetadataHelp{
+	"mode": {
+		Help:    "File type and mode",
+		Type:    "octal, unix style",
+		Example: "0100664",
+	},
+	"uid": {
+		Help:    "User ID of owner",
+		Type:    "decimal number",
+		Example: "500",
+	},
+	"gid": {
+		Help:    "Group ID of owner",
+		Type:    "decimal number",
+		Example: "500",
+	},
+	"rdev": {
+		Help:    "Device ID (if special file)",
+		Type:    "hexadecimal",
+		Example: "1abc",
+	},
+	"atime": {
+		Help:    "Time of last access",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999999999Z07:00",
+	},
+	"mtime": {
+		Help:    "Time of last modification",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999999999Z07:00",
+	},
+	"btime": {
+		Help:    "Time of file birth (creation)",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999999999Z07:00",
+	},
+}
+
+// parse a time string from metadata with key
+func (o *Object) parseMetadataTime(m fs.Metadata, key string) (t time.Time, ok bool) {
+	value, ok := m[key]
+	if ok {
+		var err error
+		t, err = time.Parse(metadataTimeFormat, value)
+		if err != nil {
+			fs.Debugf(o, "failed to parse metadata %s: %q: %v", key, value, err)
+			ok = false
+		}
+	}
+	return t, ok
+}
+
+// parse an int from metadata with key and base
+func (o *Object) parseMetadataInt(m fs.Metadata, key string, base int) (result int, ok bool) {
+	value, ok := m[key]
+	if ok {
+		result64, err := strconv.ParseInt(value, base, 64)
+		if err != nil {
+			fs.Debugf(o, "failed to parse metadata %s: %q: %v", key, value, err)
+			ok = false
+		}
+		result = int(result64)
+	}
+	return result, ok
+}
+
+// Write the metadata into the file
+//
+// It isn't possible to set the ctime and btime under Unix
+func (o *Object) writeMetadata(metadata fs.Metadata) (outErr error) {
+	var err error
+	atime, atimeOK := o.parseMetadataTime(metadata, "atime")
+	mtime, mtimeOK := o.parseMetadataTime(metadata, "mtime")
+	btime, btimeOK := o.parseMetadataTime(metadata, "btime")
+	uid, hasUID := o.parseMetadataInt(metadata, "uid", 10)
+	gid, hasGID := o.parseMetadataInt(metadata, "gid", 10)
+	mode, hasMode := o.parseMetadataInt(metadata, "mode", 8)
+
+	// Set the user defined metadata
+	err = o.setXattr(metadata)
+	if err != nil {
+		outErr = err
+	}
+
+	// Set the times
+	if atimeOK || mtimeOK {
+		if atimeOK && !mtimeOK {
+			mtime = atime
+		}
+		if !atimeOK && mtimeOK {
+			atime = mtime
+		}
+		if o.translatedLink {
+			err = lChtimes(o.path, atime, mtime)
+		} else {
+			err = os.Chtimes(o.path, atime, mtime)
+		}
+		if err != nil {
+			outErr = err
+		}
+	}
+
+	// If we have a BTime then set it if we can
+	if btimeOK {
+		fs.Debugf(o, "Unable to set btime to %v on %s", btime, runtime.GOOS)
+	}
+
+	// Set the owner
+	if hasUID != hasGID {
+		fs.Debugf(o, "Need both UID and GID to change ownership")
+	} else if hasUID && hasGID {
+		err = os.Lchown(o.path, uid, gid)
+		if err != nil {
+			outErr = fmt.Errorf("failed to change ownership: %w", err)
+		}
+	}
+
+	// Set the permissions - symlinks don't have permissions of their own
+	if hasMode && !o.translatedLink {
+		err = os.Chmod(o.path, fileModeFromUnix(uint32(mode)))
+		if err != nil {
+			outErr = fmt.Errorf("failed to change permissions: %w", err)
+		}
+	}
+	return outErr
+}
+
+// fileModeFromUnix converts the permission and special bits of a
+// unix style mode into an os.FileMode suitable for os.Chmod
+func fileModeFromUnix(mode uint32) os.FileMode {
+	m := os.FileMode(mode & 0777)
+	if mode&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
@@ -0,0 +1,28 @@
+//go:build !darwin && !freebsd && !netbsd && !linux
+// +build !darwin,!freebsd,!netbsd,!linux
+
+package local
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+var metadataWarning sync.Once
+
+// Read the metadata from the file into metadata where possible
+func (o *Object) readMetadataFromFile(m *fs.Metadata) (err error) {
+	metadataWarning.Do(func() {
+		fs.Debugf(o, "Only mode and mtime metadata is supported on %s", runtime.GOOS)
+	})
+	info, err := o.fs.lstat(o.path)
+	if err != nil {
+		return err
+	}
+	m.Set("mode", fmt.Sprintf("%0o", info.Mode().Perm()))
+	m.Set("mtime", info.ModTime().Format(metadataTimeFormat))
+	return nil
+}
@@ -0,0 +1,126 @@
+//go:build linux
+// +build linux
+
+package local
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	xattrPrefix    = "user." // user defined metadata is stored in this namespace
+	xattrSupported = true
+)
+
+// xattrIsNotSupported returns true if the error means that xattrs
+// aren't supported by the file system
+func xattrIsNotSupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP)
+}
+
+// Called on xattr errors. If the error means xattrs aren't
+// supported then it disables them for the remote and returns true.
+func (f *Fs) xattrIsNotSupported(err error) bool {
+	if !xattrIsNotSupported(err) {
+		return false
+	}
+	// Xattrs not supported - disable them for this remote
+	if atomic.CompareAndSwapInt32(&f.xattrSupported, 1, 0) {
+		fs.Errorf(f, "xattrs not supported - disabling: %v", err)
+	}
+	return true
+}
+
+// readXattr reads the extended attribute name from path using the
+// size hint passed in, growing the buffer if needed
+func readXattr(path string, get func(path, attr string, dest []byte) (int, error), attr string) ([]byte, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := get(path, attr, buf)
+		if err == unix.ERANGE {
+			buf = make([]byte, 2*len(buf))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// getXattr returns the extended attributes for an object
+//
+// It doesn't return any attributes owned by this backend in
+// metadataKeys
+func (o *Object) getXattr() (metadata fs.Metadata, err error) {
+	if atomic.LoadInt32(&o.fs.xattrSupported) == 0 || o.translatedLink {
+		return nil, nil
+	}
+	list, get := unix.Llistxattr, unix.Lgetxattr
+	if o.fs.opt.FollowSymlinks {
+		list, get = unix.Listxattr, unix.Getxattr
+	}
+	names, err := readXattr(o.path, func(path, _ string, dest []byte) (int, error) {
+		return list(path, dest)
+	}, "")
+	if err != nil {
+		if o.fs.xattrIsNotSupported(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read xattr: %w", err)
+	}
+	for _, k := range strings.Split(string(names), "\x00") {
+		if !strings.HasPrefix(k, xattrPrefix) {
+			continue
+		}
+		v, err := readXattr(o.path, get, k)
+		if err != nil {
+			if o.fs.xattrIsNotSupported(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to read xattr key %q: %w", k, err)
+		}
+		k = strings.ToLower(k[len(xattrPrefix):])
+		if _, found := systemMetadataInfo[k]; found {
+			continue
+		}
+		metadata.Set(k, string(v))
+	}
+	return metadata, nil
+}
+
+// setXattr sets the metadata on the file Xattrs
+//
+// It doesn't set any attributes owned by this backend in metadataKeys
+func (o *Object) setXattr(metadata fs.Metadata) (err error) {
+	// Linux doesn't allow user xattrs on symlinks
+	if atomic.LoadInt32(&o.fs.xattrSupported) == 0 || o.translatedLink {
+		return nil
+	}
+	set := unix.Lsetxattr
+	if o.fs.opt.FollowSymlinks {
+		set = unix.Setxattr
+	}
+	for k, value := range metadata {
+		k = strings.ToLower(k)
+		if _, found := systemMetadataInfo[k]; found {
+			continue
+		}
+		k = xattrPrefix + k
+		err = set(o.path, k, []byte(value), 0)
+		if err != nil {
+			if o.fs.xattrIsNotSupported(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to set xattr key %q: %w", k, err)
+		}
+	}
+	return nil
+}
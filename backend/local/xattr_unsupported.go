@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package local
+
+import (
+	"github.com/rclone/rclone/fs"
+)
+
+const xattrSupported = false
+
+// getXattr returns the extended attributes for an object
+func (o *Object) getXattr() (metadata fs.Metadata, err error) {
+	return nil, nil
+}
+
+// setXattr sets the metadata on the file Xattrs
+func (o *Object) setXattr(metadata fs.Metadata) (err error) {
+	return nil
+}
@@ -35,6 +35,12 @@ func init() {
 		Description: "In memory object storage system.",
 		NewFs:       NewFs,
 		Options:     []fs.Option{},
+		MetadataInfo: &fs.MetadataInfo{
+			Help: `The memory backend stores arbitrary user metadata.
+
+Any metadata keys and values are stored verbatim with the object and
+returned unchanged.`,
+		},
 	})
 }
 
@@ -166,6 +172,7 @@ type objectData struct {
 	hash     string
 	mimeType string
 	data     []byte
+	meta     fs.Metadata
 }
 
 // Object describes a memory object
@@ -240,6 +247,9 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		WriteMimeType:     true,
 		BucketBased:       true,
 		BucketBasedRootOK: true,
+		ReadMetadata:      true,
+		WriteMetadata:     true,
+		UserMetadata:      true,
 	}).Fill(ctx, f)
 	if f.rootBucket != "" && f.rootDirectory != "" {
 		od := buckets.getObjectData(f.rootBucket, f.rootDirectory)
@@ -587,11 +597,16 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	if err != nil {
 		return fmt.Errorf("failed to update memory object: %w", err)
 	}
+	meta, err := fs.GetMetadataOptions(ctx, src, options)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata from source object: %w", err)
+	}
 	o.od = &objectData{
 		data:     data,
 		hash:     "",
 		modTime:  src.ModTime(ctx),
 		mimeType: fs.MimeType(ctx, src),
+		meta:     meta,
 	}
 	buckets.updateObjectData(bucket, bucketPath, o.od)
 	return nil
@@ -612,6 +627,18 @@ func (o *Object) MimeType(ctx context.Context) string {
 	return o.od.mimeType
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	if o.od.meta == nil {
+		return nil, nil
+	}
+	metadata = make(fs.Metadata, len(o.od.meta))
+	metadata.Merge(o.od.meta)
+	return metadata, nil
+}
+
 // Check the interfaces are satisfied
 var (
 	_ fs.Fs          = &Fs{}
@@ -620,4 +647,5 @@ var (
 	_ fs.ListRer     = &Fs{}
 	_ fs.Object      = &Object{}
 	_ fs.MimeTyper   = &Object{}
+	_ fs.Metadataer  = &Object{}
 )
@@ -8,6 +8,7 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -61,6 +62,10 @@ func init() {
 		Description: "Amazon S3 Compliant Storage Providers including AWS, Alibaba, Ceph, China Mobile, ArvanCloud, Digital Ocean, Dreamhost, IBM COS, Lyve Cloud, Minio, Netease, RackCorp, Scaleway, SeaweedFS, StackPath, Storj, Tencent COS and Wasabi",
 		NewFs:       NewFs,
 		CommandHelp: commandHelp,
+		MetadataInfo: &fs.MetadataInfo{
+			System: systemMetadataInfo,
+			Help:   `User metadata is stored as x-amz-meta- keys. S3 metadata keys are case insensitive and are always returned in lower case.`,
+		},
 		Options: []fs.Option{{
 			Name: fs.ConfigProvider,
 			Help: "Choose your S3 provider.",
@@ -1845,6 +1850,51 @@ const (
 	maxExpireDuration   = fs.Duration(7 * 24 * time.Hour) // max expiry is 1 week
 )
 
+// system metadata keys which this backend owns
+var systemMetadataInfo = map[string]fs.MetadataHelp{
+	"cache-control": {
+		Help:    "Cache-Control header",
+		Type:    "string",
+		Example: "no-cache",
+	},
+	"content-disposition": {
+		Help:    "Content-Disposition header",
+		Type:    "string",
+		Example: "inline",
+	},
+	"content-encoding": {
+		Help:    "Content-Encoding header",
+		Type:    "string",
+		Example: "gzip",
+	},
+	"content-language": {
+		Help:    "Content-Language header",
+		Type:    "string",
+		Example: "en-US",
+	},
+	"content-type": {
+		Help:    "Content-Type header",
+		Type:    "string",
+		Example: "text/plain",
+	},
+	"tier": {
+		Help:     "Tier of the object",
+		Type:     "string",
+		Example:  "GLACIER",
+		ReadOnly: true,
+	},
+	"mtime": {
+		Help:    "Time of last modification, read from rclone metadata",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999999999Z07:00",
+	},
+	"btime": {
+		Help:    "Time of file birth (creation) read from Last-Modified header",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999999999Z07:00",
+	},
+}
+
 // Options defines the configuration for this backend
 type Options struct {
 	Provider              string               `config:"provider"`
@@ -1924,6 +1974,12 @@ type Object struct {
 	meta         map[string]*string // The object metadata if known - may be nil
 	mimeType     string             // MimeType of object - may be ""
 	storageClass string             // e.g. GLACIER
+
+	// Metadata as pointers to strings as they often won't be present
+	cacheControl       *string // Cache-Control: header
+	contentDisposition *string // Content-Disposition: header
+	contentEncoding    *string // Content-Encoding: header
+	contentLanguage    *string // Content-Language: header
 }
 
 // ------------------------------------------------------------
@@ -2378,6 +2434,9 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		SetTier:           true,
 		GetTier:           true,
 		SlowModTime:       true,
+		ReadMetadata:      true,
+		WriteMetadata:     true,
+		UserMetadata:      true,
 	}).Fill(ctx, f)
 	if f.rootBucket != "" && f.rootDirectory != "" && !opt.NoHeadObject && !strings.HasSuffix(root, "/") {
 		// Check to see if the (bucket,directory) is actually an existing file
@@ -3544,18 +3603,19 @@ func (o *Object) readMetaData(ctx context.Context) (err error) {
 	if err != nil {
 		return err
 	}
-	o.setMetaData(resp.ETag, resp.ContentLength, resp.LastModified, resp.Metadata, resp.ContentType, resp.StorageClass)
+	o.setMetaData(resp)
 	return nil
 }
 
-func (o *Object) setMetaData(etag *string, contentLength *int64, lastModified *time.Time, meta map[string]*string, mimeType *string, storageClass *string) {
+// setMetaData sets the object info from the HEAD response passed in
+func (o *Object) setMetaData(resp *s3.HeadObjectOutput) {
 	// Ignore missing Content-Length assuming it is 0
 	// Some versions of ceph do this due their apache proxies
-	if contentLength != nil {
-		o.bytes = *contentLength
+	if resp.ContentLength != nil {
+		o.bytes = *resp.ContentLength
 	}
-	o.setMD5FromEtag(aws.StringValue(etag))
-	o.meta = meta
+	o.setMD5FromEtag(aws.StringValue(resp.ETag))
+	o.meta = resp.Metadata
 	if o.meta == nil {
 		o.meta = map[string]*string{}
 	}
@@ -3570,14 +3630,20 @@ func (o *Object) setMetaData(etag *string, contentLength *int64, lastModified *t
 			o.md5 = hex.EncodeToString(md5sumBytes)
 		}
 	}
-	o.storageClass = aws.StringValue(storageClass)
-	if lastModified == nil {
+	o.storageClass = aws.StringValue(resp.StorageClass)
+	if resp.LastModified == nil {
 		o.lastModified = time.Now()
 		fs.Logf(o, "Failed to read last modified")
 	} else {
-		o.lastModified = *lastModified
+		o.lastModified = *resp.LastModified
 	}
-	o.mimeType = aws.StringValue(mimeType)
+	o.mimeType = aws.StringValue(resp.ContentType)
+
+	// Set system metadata
+	o.cacheControl = resp.CacheControl
+	o.contentDisposition = resp.ContentDisposition
+	o.contentEncoding = resp.ContentEncoding
+	o.contentLanguage = resp.ContentLanguage
 }
 
 // ModTime returns the modification time of the object
@@ -3683,11 +3749,27 @@ func (o *Object) downloadFromURL(ctx context.Context, bucketPath string, options
 		}
 	}
 
-	storageClass := resp.Header.Get("X-Amz-Storage-Class")
-	contentType := resp.Header.Get("Content-Type")
-	etag := resp.Header.Get("Etag")
-
-	o.setMetaData(&etag, contentLength, &lastModified, metaData, &contentType, &storageClass)
+	header := func(k string) *string {
+		v := resp.Header.Get(k)
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+
+	var head = s3.HeadObjectOutput{
+		ETag:               header("Etag"),
+		ContentLength:      contentLength,
+		LastModified:       &lastModified,
+		Metadata:           metaData,
+		CacheControl:       header("Cache-Control"),
+		ContentDisposition: header("Content-Disposition"),
+		ContentEncoding:    header("Content-Encoding"),
+		ContentLanguage:    header("Content-Language"),
+		ContentType:        header("Content-Type"),
+		StorageClass:       header("X-Amz-Storage-Class"),
+	}
+	o.setMetaData(&head)
 	return resp.Body, err
 }
 
@@ -3762,21 +3844,73 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.Read
 			fs.Debugf(o, "Failed to find length in %q", contentRange)
 		}
 	}
-	o.setMetaData(resp.ETag, size, resp.LastModified, resp.Metadata, resp.ContentType, resp.StorageClass)
+	var head s3.HeadObjectOutput
+	structs.SetFrom(&head, resp)
+	head.ContentLength = size
+	o.setMetaData(&head)
 	return resp.Body, nil
 }
 
 var warnStreamUpload sync.Once
 
-func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, size int64, in io.Reader) (etag string, err error) {
-	f := o.fs
+// s3ChunkWriter uploads the parts of a multipart upload
+type s3ChunkWriter struct {
+	f           *Fs
+	o           *Object
+	req         *s3.PutObjectInput // the request the upload was made with
+	uploadID    *string            // ID of the multipart upload
+	chunkSize   int64              // size of each part
+	size        int64              // total size or -1 if unknown
+	concurrency int                // number of parts uploaded at once
+	partsMu     sync.Mutex         // to protect parts
+	parts       []*s3.CompletedPart
+	md5sMu      sync.Mutex // to protect md5s
+	md5s        []byte
+	eTag        string // multipart Etag calculated on Close
+}
 
-	// make concurrency machinery
+// OpenChunkWriter returns the chunk size and a ChunkWriter
+//
+// Pass in the remote and the src object
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	bucket, _ := o.split()
+	err = f.makeBucket(ctx, bucket)
+	if err != nil {
+		return info, nil, err
+	}
+	req, _, err := o.buildS3Req(ctx, src, options, true)
+	if err != nil {
+		return info, nil, err
+	}
+	var w *s3ChunkWriter
+	if session := resumeSession(options); session != "" {
+		w, err = f.resumeChunkWriter(ctx, o, req, src.Size(), session)
+	} else {
+		w, err = f.newChunkWriter(ctx, o, req, src.Size())
+	}
+	if err != nil {
+		return info, nil, err
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:         w.chunkSize,
+		Concurrency:       w.concurrency,
+		LeavePartsOnError: f.opt.LeavePartsOnError,
+	}
+	return info, w, nil
+}
+
+// newChunkWriter starts a multipart upload of size bytes with req
+// choosing a part size so the upload fits in the maximum number of
+// parts
+func (f *Fs) newChunkWriter(ctx context.Context, o *Object, req *s3.PutObjectInput, size int64) (*s3ChunkWriter, error) {
 	concurrency := f.opt.UploadConcurrency
 	if concurrency < 1 {
 		concurrency = 1
 	}
-	tokens := pacer.NewTokenDispenser(concurrency)
 
 	uploadParts := f.opt.MaxUploadParts
 	if uploadParts < 1 {
@@ -3786,7 +3920,7 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	}
 
 	// calculate size of parts
-	partSize := int(f.opt.ChunkSize)
+	partSize := int64(f.opt.ChunkSize)
 
 	// size can be -1 here meaning we don't know the size of the incoming file. We use ChunkSize
 	// buffers here (default 5 MiB). With a maximum number of parts (10,000) this will be a file of
@@ -3794,45 +3928,290 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	if size == -1 {
 		warnStreamUpload.Do(func() {
 			fs.Logf(f, "Streaming uploads using chunk size %v will have maximum file size of %v",
-				f.opt.ChunkSize, fs.SizeSuffix(int64(partSize)*uploadParts))
+				f.opt.ChunkSize, fs.SizeSuffix(partSize*uploadParts))
 		})
 	} else {
 		// Adjust partSize until the number of parts is small enough.
-		if size/int64(partSize) >= uploadParts {
+		if size/partSize >= uploadParts {
 			// Calculate partition size rounded up to the nearest MiB
-			partSize = int((((size / uploadParts) >> 20) + 1) << 20)
+			partSize = (((size / uploadParts) >> 20) + 1) << 20
 		}
 	}
 
-	memPool := f.getMemoryPool(int64(partSize))
-
 	var mReq s3.CreateMultipartUploadInput
 	structs.SetFrom(&mReq, req)
 	var cout *s3.CreateMultipartUploadOutput
-	err = f.pacer.Call(func() (bool, error) {
+	err := f.pacer.Call(func() (bool, error) {
 		var err error
 		cout, err = f.c.CreateMultipartUploadWithContext(ctx, &mReq)
 		return f.shouldRetry(ctx, err)
 	})
 	if err != nil {
-		return etag, fmt.Errorf("multipart upload failed to initialise: %w", err)
+		return nil, fmt.Errorf("multipart upload failed to initialise: %w", err)
+	}
+	return &s3ChunkWriter{
+		f:           f,
+		o:           o,
+		req:         req,
+		uploadID:    cout.UploadId,
+		chunkSize:   partSize,
+		size:        size,
+		concurrency: concurrency,
+	}, nil
+}
+
+// resumeSession returns the session from any fs.ResumeOption in options
+func resumeSession(options []fs.OpenOption) string {
+	for _, option := range options {
+		if resumeOption, ok := option.(*fs.ResumeOption); ok {
+			return resumeOption.Session
+		}
+	}
+	return ""
+}
+
+// s3ChunkWriterSession is the state of an s3ChunkWriter saved so the
+// upload can be resumed
+type s3ChunkWriterSession struct {
+	UploadID  string          `json:"uploadId"`
+	Size      int64           `json:"size"`
+	ChunkSize int64           `json:"chunkSize"`
+	Parts     []s3SessionPart `json:"parts"`
+}
+
+// s3SessionPart is a completed part in an s3ChunkWriterSession
+type s3SessionPart struct {
+	PartNumber int64  `json:"partNumber"`
+	ETag       string `json:"etag"`
+	MD5        []byte `json:"md5"`
+}
+
+// Session returns the state of the upload so it can be resumed with
+// fs.ResumeOption
+func (w *s3ChunkWriter) Session() (string, error) {
+	session := s3ChunkWriterSession{
+		UploadID:  aws.StringValue(w.uploadID),
+		Size:      w.size,
+		ChunkSize: w.chunkSize,
+	}
+	w.partsMu.Lock()
+	w.md5sMu.Lock()
+	for _, part := range w.parts {
+		partNum := aws.Int64Value(part.PartNumber)
+		sessionPart := s3SessionPart{
+			PartNumber: partNum,
+			ETag:       aws.StringValue(part.ETag),
+		}
+		if end := partNum * md5.Size; end <= int64(len(w.md5s)) {
+			sessionPart.MD5 = w.md5s[end-md5.Size : end]
+		}
+		session.Parts = append(session.Parts, sessionPart)
+	}
+	data, err := json.Marshal(&session)
+	w.md5sMu.Unlock()
+	w.partsMu.Unlock()
+	if err != nil {
+		return "", err
 	}
-	uid := cout.UploadId
+	return string(data), nil
+}
+
+// resumeChunkWriter carries on the multipart upload saved in session
+// by Session after checking it still exists
+func (f *Fs) resumeChunkWriter(ctx context.Context, o *Object, req *s3.PutObjectInput, size int64, session string) (*s3ChunkWriter, error) {
+	var saved s3ChunkWriterSession
+	err := json.Unmarshal([]byte(session), &saved)
+	if err != nil {
+		return nil, fmt.Errorf("multipart upload failed to resume: bad session: %w", err)
+	}
+	if saved.Size != size || saved.ChunkSize <= 0 {
+		return nil, errors.New("multipart upload failed to resume: size doesn't match")
+	}
+	err = f.pacer.Call(func() (bool, error) {
+		_, err := f.c.ListPartsWithContext(ctx, &s3.ListPartsInput{
+			Bucket:       req.Bucket,
+			Key:          req.Key,
+			UploadId:     &saved.UploadID,
+			MaxParts:     aws.Int64(1),
+			RequestPayer: req.RequestPayer,
+		})
+		return f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("multipart upload failed to resume: %w", err)
+	}
+	concurrency := f.opt.UploadConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	w := &s3ChunkWriter{
+		f:           f,
+		o:           o,
+		req:         req,
+		uploadID:    aws.String(saved.UploadID),
+		chunkSize:   saved.ChunkSize,
+		size:        size,
+		concurrency: concurrency,
+	}
+	for _, part := range saved.Parts {
+		w.addPart(part.PartNumber, aws.String(part.ETag))
+		if len(part.MD5) == md5.Size {
+			w.addMd5(part.MD5, part.PartNumber-1)
+		}
+	}
+	return w, nil
+}
+
+// addPart records that part partNum has been uploaded with eTag,
+// replacing any previous upload of it
+func (w *s3ChunkWriter) addPart(partNum int64, eTag *string) {
+	w.partsMu.Lock()
+	defer w.partsMu.Unlock()
+	part := &s3.CompletedPart{
+		PartNumber: &partNum,
+		ETag:       eTag,
+	}
+	for i := range w.parts {
+		if aws.Int64Value(w.parts[i].PartNumber) == partNum {
+			w.parts[i] = part
+			return
+		}
+	}
+	w.parts = append(w.parts, part)
+}
+
+// addMd5 adds the md5 of a part at chunkNumber to the list of md5s
+func (w *s3ChunkWriter) addMd5(md5binary []byte, chunkNumber int64) {
+	w.md5sMu.Lock()
+	defer w.md5sMu.Unlock()
+	start := chunkNumber * md5.Size
+	end := start + md5.Size
+	if extend := end - int64(len(w.md5s)); extend > 0 {
+		w.md5s = append(w.md5s, make([]byte, extend)...)
+	}
+	copy(w.md5s[start:end], md5binary)
+}
+
+// WriteChunk uploads chunk number chunkNumber (starting from 0) from reader
+func (w *s3ChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if chunkNumber < 0 {
+		return 0, fmt.Errorf("invalid chunk number %d", chunkNumber)
+	}
+	partNum := int64(chunkNumber) + 1
+
+	// create checksum of the part for integrity checking
+	md5sumHasher := md5.New()
+	partLength, err := io.Copy(md5sumHasher, reader)
+	if err != nil {
+		return 0, fmt.Errorf("multipart upload failed to read part: %w", err)
+	}
+	md5sumBinary := md5sumHasher.Sum(nil)
+	w.addMd5(md5sumBinary, partNum-1)
+	md5sum := base64.StdEncoding.EncodeToString(md5sumBinary)
+
+	err = w.f.pacer.Call(func() (bool, error) {
+		// rewind the part to the start for each try
+		_, err := reader.Seek(0, io.SeekStart)
+		if err != nil {
+			return false, err
+		}
+		uploadPartReq := &s3.UploadPartInput{
+			Body:                 reader,
+			Bucket:               w.req.Bucket,
+			Key:                  w.req.Key,
+			PartNumber:           &partNum,
+			UploadId:             w.uploadID,
+			ContentMD5:           &md5sum,
+			ContentLength:        &partLength,
+			RequestPayer:         w.req.RequestPayer,
+			SSECustomerAlgorithm: w.req.SSECustomerAlgorithm,
+			SSECustomerKey:       w.req.SSECustomerKey,
+			SSECustomerKeyMD5:    w.req.SSECustomerKeyMD5,
+		}
+		uout, err := w.f.c.UploadPartWithContext(ctx, uploadPartReq)
+		if err != nil {
+			if partNum <= int64(w.concurrency) {
+				return w.f.shouldRetry(ctx, err)
+			}
+			// retry all chunks once have done the first batch
+			return true, err
+		}
+		w.addPart(partNum, uout.ETag)
+
+		return false, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("multipart upload failed to upload part: %w", err)
+	}
+	return partLength, nil
+}
+
+// Abort cancels the multipart upload
+func (w *s3ChunkWriter) Abort(ctx context.Context) error {
+	err := w.f.pacer.Call(func() (bool, error) {
+		_, err := w.f.c.AbortMultipartUploadWithContext(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:       w.req.Bucket,
+			Key:          w.req.Key,
+			UploadId:     w.uploadID,
+			RequestPayer: w.req.RequestPayer,
+		})
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// Close completes the multipart upload
+func (w *s3ChunkWriter) Close(ctx context.Context) error {
+	// sort the completed parts by part number
+	w.partsMu.Lock()
+	defer w.partsMu.Unlock()
+	sort.Slice(w.parts, func(i, j int) bool {
+		return *w.parts[i].PartNumber < *w.parts[j].PartNumber
+	})
+
+	err := w.f.pacer.Call(func() (bool, error) {
+		_, err := w.f.c.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket: w.req.Bucket,
+			Key:    w.req.Key,
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: w.parts,
+			},
+			RequestPayer: w.req.RequestPayer,
+			UploadId:     w.uploadID,
+		})
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return fmt.Errorf("multipart upload failed to finalise: %w", err)
+	}
+	w.md5sMu.Lock()
+	hashOfHashes := md5.Sum(w.md5s)
+	w.md5sMu.Unlock()
+	w.eTag = fmt.Sprintf("%s-%d", hex.EncodeToString(hashOfHashes[:]), len(w.parts))
+	return nil
+}
+
+func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, size int64, in io.Reader) (etag string, err error) {
+	f := o.fs
+
+	chunkWriter, err := f.newChunkWriter(ctx, o, req, size)
+	if err != nil {
+		return etag, err
+	}
+
+	// make concurrency machinery
+	tokens := pacer.NewTokenDispenser(chunkWriter.concurrency)
+	memPool := f.getMemoryPool(chunkWriter.chunkSize)
 
 	defer atexit.OnError(&err, func() {
 		if o.fs.opt.LeavePartsOnError {
 			return
 		}
 		fs.Debugf(o, "Cancelling multipart upload")
-		errCancel := f.pacer.Call(func() (bool, error) {
-			_, err := f.c.AbortMultipartUploadWithContext(context.Background(), &s3.AbortMultipartUploadInput{
-				Bucket:       req.Bucket,
-				Key:          req.Key,
-				UploadId:     uid,
-				RequestPayer: req.RequestPayer,
-			})
-			return f.shouldRetry(ctx, err)
-		})
+		errCancel := chunkWriter.Abort(ctx)
 		if errCancel != nil {
 			fs.Debugf(o, "Failed to cancel multipart upload: %v", errCancel)
 		}
@@ -3841,24 +4220,9 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	var (
 		g, gCtx  = errgroup.WithContext(ctx)
 		finished = false
-		partsMu  sync.Mutex // to protect parts
-		parts    []*s3.CompletedPart
 		off      int64
-		md5sMu   sync.Mutex
-		md5s     []byte
 	)
 
-	addMd5 := func(md5binary *[md5.Size]byte, partNum int64) {
-		md5sMu.Lock()
-		defer md5sMu.Unlock()
-		start := partNum * md5.Size
-		end := start + md5.Size
-		if extend := end - int64(len(md5s)); extend > 0 {
-			md5s = append(md5s, make([]byte, extend)...)
-		}
-		copy(md5s[start:end], (*md5binary)[:])
-	}
-
 	for partNum := int64(1); !finished; partNum++ {
 		// Get a block of memory from the pool and token which limits concurrency.
 		tokens.Get()
@@ -3892,53 +4256,13 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 		}
 		buf = buf[:n]
 
-		partNum := partNum
+		chunkNumber := int(partNum - 1)
 		fs.Debugf(o, "multipart upload starting chunk %d size %v offset %v/%v", partNum, fs.SizeSuffix(n), fs.SizeSuffix(off), fs.SizeSuffix(size))
 		off += int64(n)
 		g.Go(func() (err error) {
 			defer free()
-			partLength := int64(len(buf))
-
-			// create checksum of buffer for integrity checking
-			md5sumBinary := md5.Sum(buf)
-			addMd5(&md5sumBinary, partNum-1)
-			md5sum := base64.StdEncoding.EncodeToString(md5sumBinary[:])
-
-			err = f.pacer.Call(func() (bool, error) {
-				uploadPartReq := &s3.UploadPartInput{
-					Body:                 bytes.NewReader(buf),
-					Bucket:               req.Bucket,
-					Key:                  req.Key,
-					PartNumber:           &partNum,
-					UploadId:             uid,
-					ContentMD5:           &md5sum,
-					ContentLength:        &partLength,
-					RequestPayer:         req.RequestPayer,
-					SSECustomerAlgorithm: req.SSECustomerAlgorithm,
-					SSECustomerKey:       req.SSECustomerKey,
-					SSECustomerKeyMD5:    req.SSECustomerKeyMD5,
-				}
-				uout, err := f.c.UploadPartWithContext(gCtx, uploadPartReq)
-				if err != nil {
-					if partNum <= int64(concurrency) {
-						return f.shouldRetry(ctx, err)
-					}
-					// retry all chunks once have done the first batch
-					return true, err
-				}
-				partsMu.Lock()
-				parts = append(parts, &s3.CompletedPart{
-					PartNumber: &partNum,
-					ETag:       uout.ETag,
-				})
-				partsMu.Unlock()
-
-				return false, nil
-			})
-			if err != nil {
-				return fmt.Errorf("multipart upload failed to upload part: %w", err)
-			}
-			return nil
+			_, err = chunkWriter.WriteChunk(gCtx, chunkNumber, bytes.NewReader(buf))
+			return err
 		})
 	}
 	err = g.Wait()
@@ -3946,42 +4270,18 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 		return etag, err
 	}
 
-	// sort the completed parts by part number
-	sort.Slice(parts, func(i, j int) bool {
-		return *parts[i].PartNumber < *parts[j].PartNumber
-	})
-
-	err = f.pacer.Call(func() (bool, error) {
-		_, err := f.c.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
-			Bucket: req.Bucket,
-			Key:    req.Key,
-			MultipartUpload: &s3.CompletedMultipartUpload{
-				Parts: parts,
-			},
-			RequestPayer: req.RequestPayer,
-			UploadId:     uid,
-		})
-		return f.shouldRetry(ctx, err)
-	})
+	err = chunkWriter.Close(ctx)
 	if err != nil {
-		return etag, fmt.Errorf("multipart upload failed to finalise: %w", err)
+		return etag, err
 	}
-	hashOfHashes := md5.Sum(md5s)
-	etag = fmt.Sprintf("%s-%d", hex.EncodeToString(hashOfHashes[:]), len(parts))
-	return etag, nil
+	return chunkWriter.eTag, nil
 }
 
-// Update the Object from in with modTime and size
-func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+// buildS3Req makes the upload request for src including the metadata
+// and any upload options. It returns the hex MD5 of src if known.
+func (o *Object) buildS3Req(ctx context.Context, src fs.ObjectInfo, options []fs.OpenOption, multipart bool) (req *s3.PutObjectInput, md5sumHex string, err error) {
 	bucket, bucketPath := o.split()
-	err := o.fs.makeBucket(ctx, bucket)
-	if err != nil {
-		return err
-	}
 	modTime := src.ModTime(ctx)
-	size := src.Size()
-
-	multipart := size < 0 || size >= int64(o.fs.opt.UploadCutoff)
 
 	// Set the mtime in the meta data
 	metadata := map[string]*string{
@@ -3995,7 +4295,6 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	// - for multipart provided checksums aren't disabled
 	//    - so we can add the md5sum in the metadata as metaMD5Hash
 	var md5sumBase64 string
-	var md5sumHex string
 	if !multipart || !o.fs.opt.DisableChecksum {
 		md5sumHex, err = src.Hash(ctx, hash.MD5)
 		if err == nil && matchMd5.MatchString(md5sumHex) {
@@ -4015,13 +4314,20 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 
 	// Guess the content type
 	mimeType := fs.MimeType(ctx, src)
-	req := s3.PutObjectInput{
+	req = &s3.PutObjectInput{
 		Bucket:      &bucket,
 		ACL:         &o.fs.opt.ACL,
 		Key:         &bucketPath,
 		ContentType: &mimeType,
 		Metadata:    metadata,
 	}
+
+	// Fetch metadata if --metadata is in use
+	meta, err := fs.GetMetadataOptions(ctx, src, options)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read metadata from source object: %w", err)
+	}
+	o.setUploadMetadata(req, meta)
 	if md5sumBase64 != "" {
 		req.ContentMD5 = &md5sumBase64
 	}
@@ -4075,18 +4381,36 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 			}
 		}
 	}
+	return req, md5sumHex, nil
+}
+
+// Update the Object from in with modTime and size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	bucket, _ := o.split()
+	err := o.fs.makeBucket(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	size := src.Size()
+
+	multipart := size < 0 || size >= int64(o.fs.opt.UploadCutoff)
+
+	req, md5sumHex, err := o.buildS3Req(ctx, src, options, multipart)
+	if err != nil {
+		return err
+	}
 
 	var resp *http.Response // response from PUT
 	var wantETag string     // Multipart upload Etag to check
 	if multipart {
-		wantETag, err = o.uploadMultipart(ctx, &req, size, in)
+		wantETag, err = o.uploadMultipart(ctx, req, size, in)
 		if err != nil {
 			return err
 		}
 	} else {
 
 		// Create the request
-		putObj, _ := o.fs.c.PutObjectRequest(&req)
+		putObj, _ := o.fs.c.PutObjectRequest(req)
 
 		// Sign it so we can upload using a presigned request.
 		//
@@ -4163,7 +4487,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	if err != nil {
 		return err
 	}
-	o.setMetaData(head.ETag, head.ContentLength, head.LastModified, head.Metadata, head.ContentType, head.StorageClass)
+	o.setMetaData(head)
 	if o.fs.opt.UseMultipartEtag.Value && !o.fs.etagIsNotMD5 && wantETag != "" && head.ETag != nil && *head.ETag != "" {
 		gotETag := strings.Trim(strings.ToLower(*head.ETag), `"`)
 		if wantETag != gotETag {
@@ -4174,6 +4498,85 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	return err
 }
 
+// setUploadMetadata applies the metadata passed in to the upload
+// request. System metadata keys set the corresponding headers and any
+// other keys are stored as user metadata.
+func (o *Object) setUploadMetadata(req *s3.PutObjectInput, meta fs.Metadata) {
+	for k, v := range meta {
+		k = strings.ToLower(k)
+		switch k {
+		case "cache-control":
+			req.CacheControl = aws.String(v)
+		case "content-disposition":
+			req.ContentDisposition = aws.String(v)
+		case "content-encoding":
+			req.ContentEncoding = aws.String(v)
+		case "content-language":
+			req.ContentLanguage = aws.String(v)
+		case "content-type":
+			req.ContentType = aws.String(v)
+		case "tier":
+			// ignore - read only
+		case "mtime":
+			// mtime in meta overrides source ModTime
+			metaModTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				fs.Debugf(o, "failed to parse metadata %s: %q: %v", k, v, err)
+			} else {
+				req.Metadata[metaMtime] = aws.String(swift.TimeToFloatString(metaModTime))
+			}
+		default:
+			// btime can't be set so it is stored as user metadata
+			req.Metadata[k] = aws.String(v)
+		}
+	}
+}
+
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	err = o.readMetaData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata = make(fs.Metadata, len(o.meta)+7)
+	for k, v := range o.meta {
+		switch k {
+		case metaMtime:
+			if modTime, err := swift.FloatStringToTime(*v); err == nil {
+				metadata["mtime"] = modTime.Format(time.RFC3339Nano)
+			}
+		case metaMD5Hash:
+			// don't write hash metadata
+		default:
+			metadata[strings.ToLower(k)] = *v
+		}
+	}
+	if o.mimeType != "" {
+		metadata["content-type"] = o.mimeType
+	}
+	// Use the upload time as the btime unless one was stored
+	if _, found := metadata["btime"]; !found && !o.lastModified.IsZero() {
+		metadata["btime"] = o.lastModified.Format(time.RFC3339Nano)
+	}
+
+	// Set system metadata
+	setMetadata := func(k string, v *string) {
+		if v == nil || *v == "" {
+			return
+		}
+		metadata[k] = *v
+	}
+	setMetadata("cache-control", o.cacheControl)
+	setMetadata("content-disposition", o.contentDisposition)
+	setMetadata("content-encoding", o.contentEncoding)
+	setMetadata("content-language", o.contentLanguage)
+	metadata["tier"] = o.GetTier()
+
+	return metadata, nil
+}
+
 // Remove an object
 func (o *Object) Remove(ctx context.Context) error {
 	bucket, bucketPath := o.split()
@@ -4228,14 +4631,17 @@ func (o *Object) GetTier() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Commander   = &Fs{}
-	_ fs.CleanUpper  = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
-	_ fs.GetTierer   = &Object{}
-	_ fs.SetTierer   = &Object{}
+	_ fs.Fs                 = &Fs{}
+	_ fs.Copier             = &Fs{}
+	_ fs.PutStreamer        = &Fs{}
+	_ fs.ListRer            = &Fs{}
+	_ fs.Commander          = &Fs{}
+	_ fs.CleanUpper         = &Fs{}
+	_ fs.OpenChunkWriter    = &Fs{}
+	_ fs.ChunkWriterSession = &s3ChunkWriter{}
+	_ fs.Object             = &Object{}
+	_ fs.MimeTyper          = &Object{}
+	_ fs.GetTierer          = &Object{}
+	_ fs.SetTierer          = &Object{}
+	_ fs.Metadataer         = &Object{}
 )
@@ -0,0 +1,158 @@
+package smb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/rclone/rclone/backend/smb/smb2"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fshttp"
+)
+
+// conn encapsulates a SMB session and the shares mounted on it
+type conn struct {
+	session *smb2.Session
+	shares  map[string]*smb2.Share
+}
+
+// Open a new connection to the SMB server.
+func (f *Fs) newConnection(ctx context.Context) (c *conn, err error) {
+	fs.Debugf(f, "Connecting to SMB server")
+	err = f.pacer.Call(func() (bool, error) {
+		var tcpConn net.Conn
+		tcpConn, err = fshttp.NewDialer(ctx).Dial("tcp", f.dialAddr)
+		if err != nil {
+			return shouldRetry(ctx, err)
+		}
+		var session *smb2.Session
+		session, err = smb2.NewSession(tcpConn, &smb2.Options{
+			Server:   f.opt.Host,
+			User:     f.user,
+			Password: f.pass,
+			Domain:   f.domain,
+		})
+		if err != nil {
+			return shouldRetry(ctx, err)
+		}
+		c = &conn{
+			session: session,
+			shares:  make(map[string]*smb2.Share),
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to make SMB connection to %q: %w", f.dialAddr, err)
+	}
+	return c, nil
+}
+
+// share returns the named share mounting it if necessary
+func (c *conn) share(name string) (*smb2.Share, error) {
+	if sh, ok := c.shares[name]; ok {
+		return sh, nil
+	}
+	sh, err := c.session.Mount(name)
+	if err != nil {
+		return nil, err
+	}
+	c.shares[name] = sh
+	return sh, nil
+}
+
+// close the connection, logging off the session
+func (c *conn) close() error {
+	return c.session.Logoff()
+}
+
+// Get a SMB connection from the pool, or open a new one
+func (f *Fs) getConnection(ctx context.Context) (c *conn, err error) {
+	accounting.LimitTPS(ctx)
+	f.poolMu.Lock()
+	if len(f.pool) > 0 {
+		c = f.pool[0]
+		f.pool = f.pool[1:]
+	}
+	f.poolMu.Unlock()
+	if c != nil {
+		return c, nil
+	}
+	return f.newConnection(ctx)
+}
+
+// Get a SMB connection from the pool with the share mounted
+func (f *Fs) getShare(ctx context.Context, shareName string) (c *conn, sh *smb2.Share, err error) {
+	c, err = f.getConnection(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	sh, err = c.share(shareName)
+	if err != nil {
+		f.putConnection(&c, err)
+		return nil, nil, err
+	}
+	return c, sh, nil
+}
+
+// Return a SMB connection to the pool
+//
+// It nils the pointed to connection out so it can't be reused
+//
+// if err is not nil then it discards the connection if the session
+// has been lost, or checks the connection is alive using an ECHO
+// request if err wasn't from the server
+func (f *Fs) putConnection(pc **conn, err error) {
+	if pc == nil {
+		return
+	}
+	c := *pc
+	if c == nil {
+		return
+	}
+	*pc = nil
+	if err != nil {
+		if smb2.IsSessionLost(err) {
+			fs.Debugf(f, "Session lost, closing: %v", err)
+			_ = c.session.Close()
+			return
+		}
+		var status smb2.Status
+		if !errors.As(err, &status) {
+			echoErr := c.session.Echo()
+			if echoErr != nil {
+				fs.Debugf(f, "Connection failed, closing: %v", echoErr)
+				_ = c.session.Close()
+				return
+			}
+		}
+	}
+	f.poolMu.Lock()
+	f.pool = append(f.pool, c)
+	if f.opt.IdleTimeout > 0 {
+		f.drain.Reset(time.Duration(f.opt.IdleTimeout)) // nudge on the pool emptying timer
+	}
+	f.poolMu.Unlock()
+}
+
+// Drain the pool of any connections
+func (f *Fs) drainPool(ctx context.Context) (err error) {
+	f.poolMu.Lock()
+	defer f.poolMu.Unlock()
+	if f.opt.IdleTimeout > 0 {
+		f.drain.Stop()
+	}
+	if len(f.pool) != 0 {
+		fs.Debugf(f, "closing %d unused connections", len(f.pool))
+	}
+	for i, c := range f.pool {
+		if cErr := c.close(); cErr != nil {
+			err = cErr
+		}
+		f.pool[i] = nil
+	}
+	f.pool = nil
+	return err
+}
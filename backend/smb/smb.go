@@ -0,0 +1,828 @@
+// Package smb provides an interface to SMB servers
+package smb
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/backend/smb/smb2"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/bucket"
+	"github.com/rclone/rclone/lib/encoder"
+	"github.com/rclone/rclone/lib/env"
+	"github.com/rclone/rclone/lib/pacer"
+	"github.com/rclone/rclone/lib/readers"
+)
+
+const (
+	minSleep      = 10 * time.Millisecond
+	maxSleep      = 2 * time.Second
+	decayConstant = 2 // bigger for slower decay, exponential
+
+	// size of buffers for reading and writing so requests are as
+	// large as the server allows
+	bufferSize = 1024 * 1024
+)
+
+var (
+	currentUser = env.CurrentUser()
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "smb",
+		Description: "SMB / CIFS",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name:     "host",
+			Help:     "SMB server hostname to connect to.\n\nE.g. \"example.com\".",
+			Required: true,
+		}, {
+			Name:    "user",
+			Help:    "SMB username.",
+			Default: currentUser,
+		}, {
+			Name:    "port",
+			Help:    "SMB port number.",
+			Default: 445,
+		}, {
+			Name:       "pass",
+			Help:       "SMB password.",
+			IsPassword: true,
+		}, {
+			Name:    "domain",
+			Help:    "Domain name for NTLM authentication.",
+			Default: "WORKGROUP",
+		}, {
+			Name:    "idle_timeout",
+			Default: fs.Duration(60 * time.Second),
+			Help: `Max time before closing idle connections.
+
+If no connections have been returned to the connection pool in the time
+given, rclone will empty the connection pool.
+
+Set to 0 to keep connections indefinitely.
+`,
+			Advanced: true,
+		}, {
+			Name:     "hide_special_share",
+			Help:     "Hide special shares (e.g. print$) which users aren't supposed to access.",
+			Default:  true,
+			Advanced: true,
+		}, {
+			Name: "case_insensitive",
+			Help: `Whether the server is configured to be case-insensitive.
+
+Always true on Windows shares.`,
+			Default:  true,
+			Advanced: true,
+		}, {
+			Name:     config.ConfigEncoding,
+			Help:     config.ConfigEncodingHelp,
+			Advanced: true,
+			Default: encoder.EncodeZero |
+				// path separator
+				encoder.EncodeSlash |
+				encoder.EncodeBackSlash |
+				// windows
+				encoder.EncodeWin |
+				encoder.EncodeCtl |
+				encoder.EncodeDot |
+				// the file turns into 8.3 names (and cannot be converted back)
+				encoder.EncodeRightSpace |
+				encoder.EncodeRightPeriod |
+				//
+				encoder.EncodeInvalidUtf8,
+		},
+		}})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Host            string               `config:"host"`
+	User            string               `config:"user"`
+	Port            string               `config:"port"`
+	Pass            string               `config:"pass"`
+	Domain          string               `config:"domain"`
+	HideSpecial     bool                 `config:"hide_special_share"`
+	CaseInsensitive bool                 `config:"case_insensitive"`
+	IdleTimeout     fs.Duration          `config:"idle_timeout"`
+	Enc             encoder.MultiEncoder `config:"encoding"`
+}
+
+// Fs represents a SMB remote
+type Fs struct {
+	name     string       // name of this remote
+	root     string       // the path we are working on if any
+	opt      Options      // parsed config options
+	features *fs.Features // optional features
+	pacer    *fs.Pacer    // pacer for connections
+	dialAddr string       // host:port to connect to
+	user     string       // user name without the domain
+	pass     string       // revealed password
+	domain   string       // domain for authentication
+	poolMu   sync.Mutex
+	pool     []*conn
+	drain    *time.Timer // used to drain the pool when we stop using the connections
+}
+
+// Object describes a file at the server
+type Object struct {
+	fs      *Fs       // reference to Fs
+	remote  string    // the remote path
+	size    int64     // size of the object
+	modTime time.Time // modification time of the object
+}
+
+// ------------------------------------------------------------
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	share, dir := f.split("")
+	if share == "" {
+		return fmt.Sprintf("smb://%s@%s/", f.user, f.dialAddr)
+	}
+	return fmt.Sprintf("smb://%s@%s/%s/%s", f.user, f.dialAddr, share, dir)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// shouldRetry returns a boolean as to whether this err deserves to be
+// retried.  It returns the err as a convenience
+func shouldRetry(ctx context.Context, err error) (bool, error) {
+	if fserrors.ContextError(ctx, &err) {
+		return false, err
+	}
+	return fserrors.ShouldRetry(err), err
+}
+
+// NewFs constructs an Fs from the path, share:path
+func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, error) {
+	// Parse config into Options struct
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	pass, err := obscure.Reveal(opt.Pass)
+	if err != nil {
+		return nil, fmt.Errorf("NewFs decrypt password: %w", err)
+	}
+	user, domain := opt.User, opt.Domain
+	// Allow the user to be given as DOMAIN\user
+	if i := strings.IndexRune(user, '\\'); i >= 0 {
+		domain, user = user[:i], user[i+1:]
+	}
+	port := opt.Port
+	if port == "" {
+		port = "445"
+	}
+
+	root = strings.Trim(root, "/")
+	f := &Fs{
+		name:     name,
+		root:     root,
+		opt:      *opt,
+		dialAddr: opt.Host + ":" + port,
+		user:     user,
+		pass:     pass,
+		domain:   domain,
+		pacer:    fs.NewPacer(ctx, pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
+	}
+	f.features = (&fs.Features{
+		CaseInsensitive:         opt.CaseInsensitive,
+		CanHaveEmptyDirectories: true,
+		BucketBased:             true,
+		PartialUploads:          true,
+	}).Fill(ctx, f)
+	// set the pool drainer timer going
+	if f.opt.IdleTimeout > 0 {
+		f.drain = time.AfterFunc(time.Duration(opt.IdleTimeout), func() { _ = f.drainPool(ctx) })
+	}
+
+	// Make a connection and pool it to return errors early
+	c, err := f.getConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("NewFs: %w", err)
+	}
+	f.putConnection(&c, nil)
+
+	// Check to see if the root is actually an existing file
+	share, dir := f.split("")
+	if share == "" || dir == "" {
+		return f, nil
+	}
+	c, sh, err := f.getShare(ctx, share)
+	if err != nil {
+		// Share not found will be reported later
+		return f, nil
+	}
+	fi, err := sh.Stat(f.toSambaPath(dir))
+	f.putConnection(&c, err)
+	if err != nil || fi.IsDir() {
+		// File doesn't exist or is a directory so return old f
+		return f, nil
+	}
+	f.root = path.Dir(f.root)
+	if f.root == "." {
+		f.root = ""
+	}
+	return f, fs.ErrorIsFile
+}
+
+// split returns share name and path in the share from the
+// rootRelativePath relative to f.root
+func (f *Fs) split(rootRelativePath string) (shareName, filepath string) {
+	return bucket.Split(path.Join(f.root, rootRelativePath))
+}
+
+// toSambaPath converts a path in the share to the encoded form used
+// on the server
+func (f *Fs) toSambaPath(p string) string {
+	return f.opt.Enc.FromStandardPath(p)
+}
+
+// toNativePath converts a name from the server to the standard form
+func (f *Fs) toNativePath(p string) string {
+	return f.opt.Enc.ToStandardName(p)
+}
+
+// translateError turns SMB errors into rclone errors if possible
+func translateError(err error, dir bool) error {
+	if err == nil {
+		return nil
+	}
+	notFound := errors.Is(err, os.ErrNotExist) ||
+		errors.Is(err, smb2.StatusNotADirectory) ||
+		errors.Is(err, smb2.StatusBadNetworkName)
+	switch {
+	case notFound && dir:
+		return fs.ErrorDirNotFound
+	case notFound:
+		return fs.ErrorObjectNotFound
+	case errors.Is(err, smb2.StatusDirectoryNotEmpty):
+		return fs.ErrorDirectoryNotEmpty
+	}
+	return err
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	share, filepath := f.split(remote)
+	if share == "" || filepath == "" {
+		return nil, fs.ErrorIsDir
+	}
+	c, sh, err := f.getShare(ctx, share)
+	if err != nil {
+		return nil, translateError(err, false)
+	}
+	fi, err := sh.Stat(f.toSambaPath(filepath))
+	f.putConnection(&c, err)
+	if err != nil {
+		return nil, translateError(err, false)
+	}
+	if fi.IsDir() {
+		return nil, fs.ErrorIsDir
+	}
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	o.setMetadata(fi)
+	return o, nil
+}
+
+// listShares lists the shares on the server as directories
+func (f *Fs) listShares(ctx context.Context) (entries fs.DirEntries, err error) {
+	c, err := f.getConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := c.session.ListShares()
+	f.putConnection(&c, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shares: %w", err)
+	}
+	for _, share := range shares {
+		if !share.IsDisk() {
+			continue
+		}
+		if f.opt.HideSpecial && (share.IsSpecial() || strings.HasSuffix(share.Name, "$")) {
+			continue
+		}
+		entries = append(entries, fs.NewDir(f.toNativePath(share.Name), time.Time{}))
+	}
+	return entries, nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	share, dirPath := f.split(dir)
+	if share == "" {
+		return f.listShares(ctx)
+	}
+	c, sh, err := f.getShare(ctx, share)
+	if err != nil {
+		return nil, translateError(err, true)
+	}
+	fis, err := sh.ReadDir(f.toSambaPath(dirPath))
+	f.putConnection(&c, err)
+	if err != nil {
+		return nil, translateError(err, true)
+	}
+	for _, fi := range fis {
+		remote := path.Join(dir, f.toNativePath(fi.Name))
+		if fi.IsDir() {
+			entries = append(entries, fs.NewDir(remote, fi.ModTime))
+		} else {
+			o := &Object{
+				fs:     f,
+				remote: remote,
+			}
+			o.setMetadata(fi)
+			entries = append(entries, o)
+		}
+	}
+	return entries, nil
+}
+
+// Put the object
+//
+// Copy the reader in to the new object which is returned.
+//
+// The new object may have been created if an error is returned
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o := &Object{
+		fs:     f,
+		remote: src.Remote(),
+	}
+	return o, o.Update(ctx, in, src, options...)
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(ctx, in, src, options...)
+}
+
+// mkdirAll makes the directory dirPath and any parents in the share
+func (f *Fs) mkdirAll(ctx context.Context, share, dirPath string) (err error) {
+	dirPath = strings.Trim(dirPath, "/")
+	if dirPath == "" || dirPath == "." {
+		return nil
+	}
+	c, sh, err := f.getShare(ctx, share)
+	if err != nil {
+		return translateError(err, true)
+	}
+	defer func() {
+		f.putConnection(&c, err)
+	}()
+	// Try to make the directory directly first
+	err = sh.Mkdir(f.toSambaPath(dirPath))
+	if err == nil || errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// If the parent didn't exist make each directory in turn
+	parts := strings.Split(dirPath, "/")
+	for i := range parts {
+		err = sh.Mkdir(f.toSambaPath(path.Join(parts[:i+1]...)))
+		if err != nil && !errors.Is(err, os.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mkdir creates the directory if it doesn't exist
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	share, dirPath := f.split(dir)
+	if share == "" {
+		// The root always exists
+		return nil
+	}
+	if dirPath == "" {
+		// Check the share exists
+		c, _, err := f.getShare(ctx, share)
+		if err != nil {
+			return fmt.Errorf("can't create share %q - shares must be created on the server: %w", share, err)
+		}
+		f.putConnection(&c, nil)
+		return nil
+	}
+	return f.mkdirAll(ctx, share, dirPath)
+}
+
+// Rmdir removes the directory (container, bucket) if empty
+//
+// Return an error if it doesn't exist or isn't empty
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	share, dirPath := f.split(dir)
+	if share == "" || dirPath == "" {
+		return errors.New("can't remove the root or a share")
+	}
+	c, sh, err := f.getShare(ctx, share)
+	if err != nil {
+		return translateError(err, true)
+	}
+	err = sh.Rmdir(f.toSambaPath(dirPath))
+	f.putConnection(&c, err)
+	return translateError(err, true)
+}
+
+// Precision of the ModTimes in this Fs
+func (f *Fs) Precision() time.Duration {
+	// SMB times are in units of 100ns
+	return 100 * time.Nanosecond
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(hash.None)
+}
+
+// sameServer returns true if the two Fs use the same server and user
+// so objects can be renamed between them
+func (f *Fs) sameServer(other *Fs) bool {
+	return f.dialAddr == other.dialAddr && f.user == other.user && f.domain == other.domain
+}
+
+// Move src to this remote using server-side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't move - not same remote type")
+		return nil, fs.ErrorCantMove
+	}
+	srcShare, srcPath := srcObj.split()
+	dstShare, dstPath := f.split(remote)
+	if !f.sameServer(srcObj.fs) || srcShare != dstShare || dstPath == "" {
+		fs.Debugf(src, "Can't move - not on the same share")
+		return nil, fs.ErrorCantMove
+	}
+	err := f.mkdirAll(ctx, dstShare, path.Dir(dstPath))
+	if err != nil {
+		return nil, fmt.Errorf("Move mkdir failed: %w", err)
+	}
+	c, sh, err := f.getShare(ctx, dstShare)
+	if err != nil {
+		return nil, err
+	}
+	err = sh.Rename(f.toSambaPath(srcPath), f.toSambaPath(dstPath), true)
+	f.putConnection(&c, err)
+	if err != nil {
+		return nil, fmt.Errorf("Move Rename failed: %w", translateError(err, false))
+	}
+	return f.NewObject(ctx, remote)
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server-side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(src, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	srcShare, srcPath := srcFs.split(srcRemote)
+	dstShare, dstPath := f.split(dstRemote)
+	if !f.sameServer(srcFs) || srcShare != dstShare || srcPath == "" || dstPath == "" {
+		fs.Debugf(src, "Can't move directory - not on the same share")
+		return fs.ErrorCantDirMove
+	}
+
+	c, sh, err := f.getShare(ctx, dstShare)
+	if err != nil {
+		return err
+	}
+	_, err = sh.Stat(f.toSambaPath(dstPath))
+	f.putConnection(&c, nil)
+	if err == nil {
+		return fs.ErrorDirExists
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("DirMove stat failed: %w", err)
+	}
+
+	err = f.mkdirAll(ctx, dstShare, path.Dir(dstPath))
+	if err != nil {
+		return fmt.Errorf("DirMove mkdir failed: %w", err)
+	}
+	c, sh, err = f.getShare(ctx, dstShare)
+	if err != nil {
+		return err
+	}
+	err = sh.Rename(f.toSambaPath(srcPath), f.toSambaPath(dstPath), false)
+	f.putConnection(&c, err)
+	if err != nil {
+		return fmt.Errorf("DirMove Rename failed: %w", translateError(err, true))
+	}
+	return nil
+}
+
+// About gets quota information about the share
+func (f *Fs) About(ctx context.Context) (usage *fs.Usage, err error) {
+	share, dirPath := f.split("")
+	if share == "" {
+		return nil, errors.New("about isn't supported at the root - use a share")
+	}
+	c, sh, err := f.getShare(ctx, share)
+	if err != nil {
+		return nil, translateError(err, true)
+	}
+	info, err := sh.Statfs(f.toSambaPath(dirPath))
+	f.putConnection(&c, err)
+	if err != nil {
+		return nil, fmt.Errorf("about failed: %w", translateError(err, true))
+	}
+	used := info.Total - info.Free
+	return &fs.Usage{
+		Total: fs.NewUsageValue(info.Total),
+		Used:  fs.NewUsageValue(used),
+		Free:  fs.NewUsageValue(info.Available),
+	}, nil
+}
+
+// Shutdown the backend, closing any background tasks and any
+// cached connections.
+func (f *Fs) Shutdown(ctx context.Context) error {
+	return f.drainPool(ctx)
+}
+
+// ------------------------------------------------------------
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// String returns a description of the Object
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// split returns the share and path in the share of the object
+func (o *Object) split() (share, filepath string) {
+	return o.fs.split(o.remote)
+}
+
+// setMetadata sets the metadata from fi
+func (o *Object) setMetadata(fi *smb2.FileInfo) {
+	o.size = fi.Size
+	o.modTime = fi.ModTime
+}
+
+// stat updates the metadata of the object from the server
+func (o *Object) stat(ctx context.Context) error {
+	share, filepath := o.split()
+	c, sh, err := o.fs.getShare(ctx, share)
+	if err != nil {
+		return translateError(err, false)
+	}
+	fi, err := sh.Stat(o.fs.toSambaPath(filepath))
+	o.fs.putConnection(&c, err)
+	if err != nil {
+		return translateError(err, false)
+	}
+	if fi.IsDir() {
+		return fs.ErrorIsDir
+	}
+	o.setMetadata(fi)
+	return nil
+}
+
+// Hash returns the hash of an object returning a lowercase hex string
+func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
+	return "", hash.ErrUnsupported
+}
+
+// Size returns the size of an object in bytes
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// ModTime returns the modification time of the object
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.modTime
+}
+
+// SetModTime sets the modification time of the object
+func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	share, filepath := o.split()
+	c, sh, err := o.fs.getShare(ctx, share)
+	if err != nil {
+		return err
+	}
+	err = sh.Chtimes(o.fs.toSambaPath(filepath), modTime)
+	o.fs.putConnection(&c, err)
+	if err != nil {
+		return translateError(err, false)
+	}
+	return o.stat(ctx)
+}
+
+// Storable returns a boolean as to whether this object is storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// smbReadCloser reads an open file and returns the connection to
+// the pool on Close
+type smbReadCloser struct {
+	f   *Fs
+	c   *conn
+	fl  *smb2.File
+	in  io.Reader
+	err error // errors found during read
+}
+
+// Read bytes into p
+func (r *smbReadCloser) Read(p []byte) (n int, err error) {
+	n, err = r.in.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err // store any errors for Close to examine
+	}
+	return n, err
+}
+
+// Close the file and return the connection to the pool
+func (r *smbReadCloser) Close() error {
+	err := r.fl.Close()
+	if r.err != nil {
+		r.f.putConnection(&r.c, r.err)
+	} else {
+		r.f.putConnection(&r.c, err)
+	}
+	return err
+}
+
+// Open an object for read
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.Size())
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	share, filepath := o.split()
+	c, sh, err := o.fs.getShare(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+	fl, err := sh.OpenFile(o.fs.toSambaPath(filepath), os.O_RDONLY)
+	if err != nil {
+		o.fs.putConnection(&c, err)
+		return nil, fmt.Errorf("failed to open: %w", translateError(err, false))
+	}
+	_, err = fl.Seek(offset, io.SeekStart)
+	if err != nil {
+		_ = fl.Close()
+		o.fs.putConnection(&c, err)
+		return nil, fmt.Errorf("failed to seek: %w", err)
+	}
+	return &smbReadCloser{
+		f:  o.fs,
+		c:  c,
+		fl: fl,
+		in: readers.NewLimitedReadCloser(io.NopCloser(bufio.NewReaderSize(fl, bufferSize)), limit),
+	}, nil
+}
+
+// Update the Object from in with modTime and size
+//
+// The new object may have been created if an error is returned
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+	share, filepath := o.split()
+	if share == "" || filepath == "" {
+		return errors.New("can't upload files to the root or a share")
+	}
+	err = o.fs.mkdirAll(ctx, share, path.Dir(filepath))
+	if err != nil {
+		return fmt.Errorf("update mkdir failed: %w", err)
+	}
+
+	c, sh, err := o.fs.getShare(ctx, share)
+	if err != nil {
+		return err
+	}
+	fl, err := sh.OpenFile(o.fs.toSambaPath(filepath), os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		o.fs.putConnection(&c, err)
+		return fmt.Errorf("failed to open: %w", err)
+	}
+
+	buf := bufio.NewWriterSize(fl, bufferSize)
+	_, err = io.Copy(buf, in)
+	if err == nil {
+		err = buf.Flush()
+	}
+	closeErr := fl.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// remove the file if upload failed
+		removeErr := sh.Remove(o.fs.toSambaPath(filepath))
+		if removeErr != nil {
+			fs.Debugf(o, "Failed to remove: %v", removeErr)
+		} else {
+			fs.Debugf(o, "Removed after failed upload: %v", err)
+		}
+		o.fs.putConnection(&c, err)
+		return fmt.Errorf("update failed: %w", err)
+	}
+	o.fs.putConnection(&c, nil)
+
+	return o.SetModTime(ctx, src.ModTime(ctx))
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) error {
+	share, filepath := o.split()
+	c, sh, err := o.fs.getShare(ctx, share)
+	if err != nil {
+		return err
+	}
+	err = sh.Remove(o.fs.toSambaPath(filepath))
+	o.fs.putConnection(&c, err)
+	return translateError(err, false)
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs          = &Fs{}
+	_ fs.PutStreamer = &Fs{}
+	_ fs.Mover       = &Fs{}
+	_ fs.DirMover    = &Fs{}
+	_ fs.Abouter     = &Fs{}
+	_ fs.Shutdowner  = &Fs{}
+	_ fs.Object      = &Object{}
+)
@@ -0,0 +1,81 @@
+package smb2
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// cmac computes the AES-CMAC of msg as described in RFC 4493
+func cmac(block cipher.Block, msg []byte) []byte {
+	const bs = aes.BlockSize
+	// Make the subkeys
+	k1 := make([]byte, bs)
+	block.Encrypt(k1, k1)
+	shiftLeft(k1)
+	k2 := append([]byte(nil), k1...)
+	shiftLeft(k2)
+
+	n := (len(msg) + bs - 1) / bs
+	complete := n > 0 && len(msg)%bs == 0
+	if n == 0 {
+		n = 1
+	}
+	last := make([]byte, bs)
+	if complete {
+		copy(last, msg[(n-1)*bs:])
+		xor(last, k1)
+	} else {
+		rest := msg[(n-1)*bs:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xor(last, k2)
+	}
+
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		xor(x, msg[i*bs:(i+1)*bs])
+		block.Encrypt(x, x)
+	}
+	xor(x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+// shiftLeft does the subkey generation step of CMAC on k in place,
+// shifting it left one bit and xoring in Rb if the top bit was set
+func shiftLeft(k []byte) {
+	const rb = 0x87
+	carry := k[0] >> 7
+	for i := 0; i < len(k)-1; i++ {
+		k[i] = k[i]<<1 | k[i+1]>>7
+	}
+	k[len(k)-1] <<= 1
+	if carry != 0 {
+		k[len(k)-1] ^= rb
+	}
+}
+
+// xor sets dst to dst ^ src
+func xor(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// kdf is the SP800-108 counter mode key derivation function using
+// HMAC-SHA256 which SMB 3 uses to make a 128 bit key
+func kdf(key, label, context []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], 1)
+	_, _ = mac.Write(buf[:])
+	_, _ = mac.Write(label)
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write(context)
+	binary.BigEndian.PutUint32(buf[:], 128)
+	_, _ = mac.Write(buf[:])
+	return mac.Sum(nil)[:16]
+}
@@ -0,0 +1,211 @@
+package smb2
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// File is an open file on a share
+//
+// It implements io.Reader, io.ReaderAt, io.Writer, io.Seeker and
+// io.Closer.
+type File struct {
+	sh     *Share
+	id     fileID
+	info   FileInfo // info when the file was opened
+	offset int64    // offset for Read, Write and Seek
+	closed bool
+}
+
+var errFileClosed = errors.New("smb2: file already closed")
+
+// ReadAt reads len(p) bytes from the file starting at off
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	for n < len(p) {
+		var nn int
+		nn, err = f.readChunk(p[n:], off+int64(n))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		if nn == 0 {
+			return n, io.ErrUnexpectedEOF
+		}
+	}
+	return n, nil
+}
+
+// readChunk does a single READ request for as much of p as possible
+func (f *File) readChunk(p []byte, off int64) (n int, err error) {
+	size := f.sh.s.ioSize(f.sh.s.maxRead)
+	if size > len(p) {
+		size = len(p)
+	}
+	b := make([]byte, 48+1)
+	binary.LittleEndian.PutUint16(b[0:], 49) // StructureSize
+	b[2] = headerSize + 16                   // Padding
+	binary.LittleEndian.PutUint32(b[4:], uint32(size))
+	binary.LittleEndian.PutUint64(b[8:], uint64(off))
+	copy(b[16:], f.id[:])
+	r, err := f.sh.s.call(cmdRead, f.sh.treeID, b, size)
+	if err == StatusEndOfFile {
+		return 0, io.EOF
+	}
+	if err != nil && err != StatusBufferOverflow {
+		return 0, err
+	}
+	if err = r.check(16); err != nil {
+		return 0, err
+	}
+	dataOffset := r.body[2]
+	dataLength := binary.LittleEndian.Uint32(r.body[4:])
+	data, err := r.buf(int(dataOffset), int(dataLength))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > len(p) {
+		return 0, errBadResponse
+	}
+	return copy(p, data), nil
+}
+
+// Read reads up to len(p) bytes from the current offset
+func (f *File) Read(p []byte) (n int, err error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err = f.readChunk(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// Write writes p at the current offset
+func (f *File) Write(p []byte) (n int, err error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	for n < len(p) {
+		var nn int
+		nn, err = f.writeChunk(p[n:], f.offset)
+		n += nn
+		f.offset += int64(nn)
+		if err != nil {
+			return n, err
+		}
+		if nn == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
+// writeChunk does a single WRITE request for as much of p as possible
+func (f *File) writeChunk(p []byte, off int64) (n int, err error) {
+	size := f.sh.s.ioSize(f.sh.s.maxWrite)
+	if size > len(p) {
+		size = len(p)
+	}
+	b := make([]byte, 48+size)
+	binary.LittleEndian.PutUint16(b[0:], 49) // StructureSize
+	binary.LittleEndian.PutUint16(b[2:], headerSize+48)
+	binary.LittleEndian.PutUint32(b[4:], uint32(size))
+	binary.LittleEndian.PutUint64(b[8:], uint64(off))
+	copy(b[16:], f.id[:])
+	copy(b[48:], p[:size])
+	r, err := f.sh.s.call(cmdWrite, f.sh.treeID, b, size)
+	if err != nil {
+		return 0, err
+	}
+	if err = r.check(8); err != nil {
+		return 0, err
+	}
+	count := int(binary.LittleEndian.Uint32(r.body[4:]))
+	if count > size {
+		return 0, errBadResponse
+	}
+	return count, nil
+}
+
+// Seek sets the offset for the next Read or Write
+//
+// io.SeekEnd uses the size of the file when it was opened.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size
+	default:
+		return f.offset, errors.New("smb2: bad whence")
+	}
+	if offset < 0 {
+		return f.offset, errors.New("smb2: negative offset")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Stat returns the current info about the open file
+func (f *File) Stat() (*FileInfo, error) {
+	if f.closed {
+		return nil, errFileClosed
+	}
+	info, err := f.sh.queryInfo(f.id, infoFile, fileNetworkOpenInformation, 56)
+	if err != nil {
+		return nil, err
+	}
+	return &FileInfo{
+		Name:       f.info.Name,
+		ModTime:    filetimeToTime(binary.LittleEndian.Uint64(info[16:])),
+		Size:       int64(binary.LittleEndian.Uint64(info[40:])),
+		Attributes: binary.LittleEndian.Uint32(info[48:]),
+	}, nil
+}
+
+// Truncate sets the size of the file
+func (f *File) Truncate(size int64) error {
+	if f.closed {
+		return errFileClosed
+	}
+	info := make([]byte, 8)
+	binary.LittleEndian.PutUint64(info, uint64(size))
+	return f.sh.setInfo(f.id, infoFile, fileEndOfFileInformation, info)
+}
+
+// Chtimes sets the modification time of the open file
+//
+// The server won't change the modification time on subsequent
+// writes to this handle.
+func (f *File) Chtimes(modTime time.Time) error {
+	if f.closed {
+		return errFileClosed
+	}
+	return f.sh.setModTime(f.id, modTime)
+}
+
+// Close the file
+func (f *File) Close() error {
+	if f.closed {
+		return errFileClosed
+	}
+	f.closed = true
+	return f.sh.close(f.id)
+}
+
+// check interfaces
+var (
+	_ io.Reader   = (*File)(nil)
+	_ io.ReaderAt = (*File)(nil)
+	_ io.Writer   = (*File)(nil)
+	_ io.Seeker   = (*File)(nil)
+	_ io.Closer   = (*File)(nil)
+)
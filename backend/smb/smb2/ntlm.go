@@ -0,0 +1,229 @@
+package smb2
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLMv2 authentication (MS-NLMP)
+//
+// Only the parts needed to authenticate an SMB session and derive
+// the session key for signing are implemented.
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// NTLM message types
+const (
+	ntlmNegotiate    = 1
+	ntlmChallenge    = 2
+	ntlmAuthenticate = 3
+)
+
+// NTLM negotiate flags (MS-NLMP 2.2.2.5)
+const (
+	ntlmNegotiateUnicode                 = 0x00000001
+	ntlmRequestTarget                    = 0x00000004
+	ntlmNegotiateSign                    = 0x00000010
+	ntlmNegotiateNTLM                    = 0x00000200
+	ntlmAnonymous                        = 0x00000800
+	ntlmNegotiateAlwaysSign              = 0x00008000
+	ntlmNegotiateExtendedSessionSecurity = 0x00080000
+	ntlmNegotiateTargetInfo              = 0x00800000
+	ntlmNegotiate128                     = 0x20000000
+	ntlmNegotiate56                      = 0x80000000
+
+	ntlmClientFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateSign |
+		ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSessionSecurity |
+		ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+)
+
+// AV pair IDs used in the target info
+const (
+	avEOL       = 0
+	avTimestamp = 7
+)
+
+// ntlmClient holds the state of an NTLM authentication
+type ntlmClient struct {
+	user        string
+	password    string
+	domain      string
+	workstation string
+	sessionKey  []byte // available after authenticate
+}
+
+// encodeUTF16 encodes s as UTF-16LE
+func encodeUTF16(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+// decodeUTF16 decodes UTF-16LE in b
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// ntowfv2 computes the NTLMv2 one way function of the password
+func ntowfv2(user, password, domain string) []byte {
+	h := md4.New()
+	_, _ = h.Write(encodeUTF16(password))
+	mac := hmac.New(md5.New, h.Sum(nil))
+	_, _ = mac.Write(encodeUTF16(strings.ToUpper(user) + domain))
+	return mac.Sum(nil)
+}
+
+// hmacMD5 returns the HMAC_MD5 of the concatenation of data using key
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		_, _ = mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// lmv2Response computes the LMv2 challenge response
+func lmv2Response(ntowf, serverChallenge, clientChallenge []byte) []byte {
+	return append(hmacMD5(ntowf, serverChallenge, clientChallenge), clientChallenge...)
+}
+
+// ntlmField writes the length and offset of data at b and appends
+// data to the payload which starts at base
+func ntlmField(b []byte, payload *bytes.Buffer, base int, data []byte) {
+	binary.LittleEndian.PutUint16(b[0:], uint16(len(data)))
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(data)))
+	binary.LittleEndian.PutUint32(b[4:], uint32(base+payload.Len()))
+	payload.Write(data)
+}
+
+// readNTLMField reads the payload field described at b from msg
+func readNTLMField(msg, b []byte) ([]byte, error) {
+	length := int(binary.LittleEndian.Uint16(b[0:]))
+	offset := int(binary.LittleEndian.Uint32(b[4:]))
+	if offset+length > len(msg) || offset < 0 {
+		return nil, errors.New("ntlm: field out of range")
+	}
+	return msg[offset : offset+length], nil
+}
+
+// negotiate returns the NEGOTIATE_MESSAGE
+func (n *ntlmClient) negotiate() []byte {
+	b := make([]byte, 32)
+	copy(b, ntlmSignature)
+	binary.LittleEndian.PutUint32(b[8:], ntlmNegotiate)
+	binary.LittleEndian.PutUint32(b[12:], ntlmClientFlags)
+	// Domain and workstation fields are left empty
+	return b
+}
+
+// authenticate parses the CHALLENGE_MESSAGE and returns the
+// AUTHENTICATE_MESSAGE, setting the session key
+func (n *ntlmClient) authenticate(challenge []byte) ([]byte, error) {
+	if len(challenge) < 48 || !bytes.Equal(challenge[:8], ntlmSignature) {
+		return nil, errors.New("ntlm: bad challenge message")
+	}
+	if binary.LittleEndian.Uint32(challenge[8:]) != ntlmChallenge {
+		return nil, errors.New("ntlm: expecting challenge message")
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:]) & ntlmClientFlags
+	serverChallenge := challenge[24:32]
+	targetInfo, err := readNTLMField(challenge, challenge[40:48])
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the server time if provided
+	var timestamp []byte
+	hasTimestamp := false
+	for info := targetInfo; len(info) >= 4; {
+		id := binary.LittleEndian.Uint16(info[0:])
+		length := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == avEOL || 4+length > len(info) {
+			break
+		}
+		if id == avTimestamp && length == 8 {
+			timestamp = info[4:12]
+			hasTimestamp = true
+		}
+		info = info[4+length:]
+	}
+	if timestamp == nil {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, timeToFiletime(time.Now()))
+	}
+
+	if n.user == "" && n.password == "" {
+		return n.anonymous(flags), nil
+	}
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, fmt.Errorf("ntlm: failed to make client challenge: %w", err)
+	}
+
+	ntowf := ntowfv2(n.user, n.password, n.domain)
+
+	// temp is the NTLMv2_CLIENT_CHALLENGE structure
+	var temp bytes.Buffer
+	temp.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	temp.Write(timestamp)
+	temp.Write(clientChallenge)
+	temp.Write([]byte{0, 0, 0, 0})
+	temp.Write(targetInfo)
+	temp.Write([]byte{0, 0, 0, 0})
+
+	ntProofStr := hmacMD5(ntowf, serverChallenge, temp.Bytes())
+	ntResponse := append(append([]byte{}, ntProofStr...), temp.Bytes()...)
+	var lmResponse []byte
+	if hasTimestamp {
+		// The LM response should be zeroed if the server sent a timestamp
+		lmResponse = make([]byte, 24)
+	} else {
+		lmResponse = lmv2Response(ntowf, serverChallenge, clientChallenge)
+	}
+	n.sessionKey = hmacMD5(ntowf, ntProofStr)
+
+	return n.authenticateMessage(flags, lmResponse, ntResponse), nil
+}
+
+// anonymous returns an AUTHENTICATE_MESSAGE for anonymous login
+//
+// No session key is made so the session can't be signed.
+func (n *ntlmClient) anonymous(flags uint32) []byte {
+	n.sessionKey = nil
+	return n.authenticateMessage(flags|ntlmAnonymous, []byte{0}, nil)
+}
+
+// authenticateMessage builds the AUTHENTICATE_MESSAGE
+func (n *ntlmClient) authenticateMessage(flags uint32, lmResponse, ntResponse []byte) []byte {
+	const headerSize = 64
+	b := make([]byte, headerSize)
+	copy(b, ntlmSignature)
+	binary.LittleEndian.PutUint32(b[8:], ntlmAuthenticate)
+	var payload bytes.Buffer
+	ntlmField(b[12:], &payload, headerSize, lmResponse)
+	ntlmField(b[20:], &payload, headerSize, ntResponse)
+	ntlmField(b[28:], &payload, headerSize, encodeUTF16(n.domain))
+	ntlmField(b[36:], &payload, headerSize, encodeUTF16(n.user))
+	ntlmField(b[44:], &payload, headerSize, encodeUTF16(n.workstation))
+	ntlmField(b[52:], &payload, headerSize, nil) // no key exchange
+	binary.LittleEndian.PutUint32(b[60:], flags)
+	return append(b, payload.Bytes()...)
+}
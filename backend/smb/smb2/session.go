@@ -0,0 +1,594 @@
+// Package smb2 implements a minimal SMB2/3 client
+//
+// It implements enough of MS-SMB2 for rclone to use file shares:
+// negotiating dialects 2.0.2 to 3.0.2, NTLMv2 authentication, message
+// signing, share enumeration and the file operations.
+//
+// Encryption isn't supported so shares which require it can't be
+// used. Messages are signed whenever the session allows it and the
+// signatures of responses are checked.
+package smb2
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// SMB2 packet header (MS-SMB2 2.2.1)
+const (
+	headerSize = 64
+	protocolID = "\xfeSMB"
+
+	// Maximum size of message we will read
+	maxMessageSize = 16 * 1024 * 1024
+)
+
+// Commands
+const (
+	cmdNegotiate      uint16 = 0x0000
+	cmdSessionSetup   uint16 = 0x0001
+	cmdLogoff         uint16 = 0x0002
+	cmdTreeConnect    uint16 = 0x0003
+	cmdTreeDisconnect uint16 = 0x0004
+	cmdCreate         uint16 = 0x0005
+	cmdClose          uint16 = 0x0006
+	cmdFlush          uint16 = 0x0007
+	cmdRead           uint16 = 0x0008
+	cmdWrite          uint16 = 0x0009
+	cmdEcho           uint16 = 0x000D
+	cmdQueryDirectory uint16 = 0x000E
+	cmdQueryInfo      uint16 = 0x0010
+	cmdSetInfo        uint16 = 0x0011
+)
+
+// Header flags
+const (
+	flagServerToRedir = 0x00000001
+	flagAsyncCommand  = 0x00000002
+	flagSigned        = 0x00000008
+)
+
+// Dialects
+const (
+	dialect202 uint16 = 0x0202
+	dialect210 uint16 = 0x0210
+	dialect300 uint16 = 0x0300
+	dialect302 uint16 = 0x0302
+)
+
+var dialects = []uint16{dialect202, dialect210, dialect300, dialect302}
+
+// Negotiate security modes and capabilities
+const (
+	negotiateSigningEnabled  = 0x0001
+	negotiateSigningRequired = 0x0002
+
+	globalCapLargeMTU = 0x00000004
+)
+
+// Session flags from the SESSION_SETUP response
+const (
+	sessionFlagIsGuest     = 0x0001
+	sessionFlagIsNull      = 0x0002
+	sessionFlagEncryptData = 0x0004
+)
+
+// The message ID used by the server for oplock breaks
+const oplockBreakMessageID = 0xFFFFFFFFFFFFFFFF
+
+// Size of a single credit
+const creditSize = 64 * 1024
+
+// Options for making a new Session
+type Options struct {
+	Server      string // name of the server used in share paths
+	User        string // user name - if empty an anonymous login is made
+	Password    string // password for User
+	Domain      string // domain for User
+	Workstation string // name of this computer - may be empty
+}
+
+// Session is an authenticated SMB2 session on a connection
+//
+// A Session may be used from multiple go routines but requests are
+// sent one at a time.
+type Session struct {
+	mu          sync.Mutex
+	conn        net.Conn
+	opt         Options
+	err         error  // set if the connection has failed
+	dialect     uint16 // negotiated dialect
+	maxRead     uint32 // maximum read size
+	maxWrite    uint32 // maximum write size
+	maxTransact uint32 // maximum size for query and set info
+	largeMTU    bool   // set if multi credit requests are supported
+	messageID   uint64 // next message ID to use
+	credits     int    // credits granted by the server
+	sessionID   uint64
+	signing     bool         // set if messages are signed
+	signingKey  []byte       // key used for HMAC-SHA256 signing
+	signer      cipher.Block // block cipher used for AES-CMAC signing
+}
+
+// response is a parsed response from the server
+type response struct {
+	status    Status
+	command   uint16
+	flags     uint32
+	messageID uint64
+	treeID    uint32
+	sessionID uint64
+	raw       []byte // the whole message, offsets are relative to this
+	body      []byte // the message after the header
+}
+
+// buf returns length bytes at offset in the response, checking the
+// bounds
+func (r *response) buf(offset, length int) ([]byte, error) {
+	if offset < 0 || length < 0 || offset+length > len(r.raw) {
+		return nil, errBadResponse
+	}
+	return r.raw[offset : offset+length], nil
+}
+
+// check that the body is at least n bytes long
+func (r *response) check(n int) error {
+	if len(r.body) < n {
+		return errBadResponse
+	}
+	return nil
+}
+
+var errBadResponse = errors.New("smb2: malformed response from server")
+
+// NewSession negotiates with the server on conn and authenticates
+// using NTLM
+//
+// The Session takes ownership of conn and will close it when the
+// Session is closed.
+func NewSession(conn net.Conn, opt *Options) (s *Session, err error) {
+	s = &Session{
+		conn:    conn,
+		opt:     *opt,
+		credits: 1,
+	}
+	defer func() {
+		if err != nil {
+			_ = conn.Close()
+		}
+	}()
+	err = s.negotiate()
+	if err != nil {
+		return nil, fmt.Errorf("smb2: negotiate failed: %w", err)
+	}
+	err = s.sessionSetup()
+	if err != nil {
+		return nil, fmt.Errorf("smb2: session setup failed: %w", err)
+	}
+	return s, nil
+}
+
+// negotiate the dialect with the server
+func (s *Session) negotiate() error {
+	b := make([]byte, 36+2*len(dialects))
+	binary.LittleEndian.PutUint16(b[0:], 36) // StructureSize
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(dialects)))
+	binary.LittleEndian.PutUint16(b[4:], negotiateSigningEnabled)
+	binary.LittleEndian.PutUint32(b[8:], globalCapLargeMTU)
+	if _, err := rand.Read(b[12:28]); err != nil { // ClientGuid
+		return err
+	}
+	for i, d := range dialects {
+		binary.LittleEndian.PutUint16(b[36+2*i:], d)
+	}
+	r, err := s.call(cmdNegotiate, 0, b, 0)
+	if err != nil {
+		return err
+	}
+	if err = r.check(64); err != nil {
+		return err
+	}
+	securityMode := binary.LittleEndian.Uint16(r.body[2:])
+	s.dialect = binary.LittleEndian.Uint16(r.body[4:])
+	capabilities := binary.LittleEndian.Uint32(r.body[24:])
+	s.maxTransact = binary.LittleEndian.Uint32(r.body[28:])
+	s.maxRead = binary.LittleEndian.Uint32(r.body[32:])
+	s.maxWrite = binary.LittleEndian.Uint32(r.body[36:])
+	found := false
+	for _, d := range dialects {
+		if d == s.dialect {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("server chose unsupported dialect 0x%04X", s.dialect)
+	}
+	// Sign messages whenever the server supports it, not just when
+	// it insists, so responses can't be tampered with
+	s.signing = securityMode&(negotiateSigningEnabled|negotiateSigningRequired) != 0
+	s.largeMTU = s.dialect != dialect202 && capabilities&globalCapLargeMTU != 0
+	return nil
+}
+
+// sessionSetup authenticates the session with NTLM wrapped in SPNEGO
+func (s *Session) sessionSetup() error {
+	n := &ntlmClient{
+		user:        s.opt.User,
+		password:    s.opt.Password,
+		domain:      s.opt.Domain,
+		workstation: s.opt.Workstation,
+	}
+	token, err := encodeNegTokenInit(n.negotiate())
+	if err != nil {
+		return err
+	}
+	r, err := s.sessionSetupRequest(token)
+	if err != StatusMoreProcessingRequired {
+		if err == nil {
+			err = errors.New("expecting NTLM challenge")
+		}
+		return err
+	}
+	s.sessionID = r.sessionID
+	secBuf, err := sessionSetupSecurityBuffer(r)
+	if err != nil {
+		return err
+	}
+	resp, err := decodeNegTokenResp(secBuf)
+	if err != nil {
+		return err
+	}
+	auth, err := n.authenticate(resp.ResponseToken)
+	if err != nil {
+		return err
+	}
+	token, err = encodeNegTokenResp(auth)
+	if err != nil {
+		return err
+	}
+	r, err = s.sessionSetupRequest(token)
+	if err != nil {
+		return err
+	}
+	if err = r.check(8); err != nil {
+		return err
+	}
+	sessionFlags := binary.LittleEndian.Uint16(r.body[2:])
+	if sessionFlags&sessionFlagEncryptData != 0 {
+		return errors.New("server requires encryption which isn't supported")
+	}
+	if sessionFlags&(sessionFlagIsGuest|sessionFlagIsNull) != 0 || n.sessionKey == nil {
+		// Guest and anonymous sessions can't be signed
+		s.signing = false
+		return nil
+	}
+	if !s.signing {
+		return nil
+	}
+	err = s.setSigningKey(n.sessionKey)
+	if err != nil {
+		return err
+	}
+	// The final response is signed with the new key by servers
+	// which sign it
+	if r.flags&flagSigned != 0 {
+		return s.verify(r.raw)
+	}
+	return nil
+}
+
+// sessionSetupRequest sends a SESSION_SETUP request with token
+func (s *Session) sessionSetupRequest(token []byte) (*response, error) {
+	b := make([]byte, 24+len(token))
+	binary.LittleEndian.PutUint16(b[0:], 25) // StructureSize
+	b[3] = negotiateSigningEnabled           // SecurityMode
+	binary.LittleEndian.PutUint16(b[12:], headerSize+24)
+	binary.LittleEndian.PutUint16(b[14:], uint16(len(token)))
+	copy(b[24:], token)
+	return s.call(cmdSessionSetup, 0, b, 0)
+}
+
+// sessionSetupSecurityBuffer returns the security buffer from a
+// SESSION_SETUP response
+func sessionSetupSecurityBuffer(r *response) ([]byte, error) {
+	if err := r.check(8); err != nil {
+		return nil, err
+	}
+	offset := binary.LittleEndian.Uint16(r.body[4:])
+	length := binary.LittleEndian.Uint16(r.body[6:])
+	return r.buf(int(offset), int(length))
+}
+
+// setSigningKey derives the signing key from the session key
+func (s *Session) setSigningKey(sessionKey []byte) error {
+	if s.dialect < dialect300 {
+		s.signingKey = sessionKey
+		return nil
+	}
+	key := kdf(sessionKey, []byte("SMB2AESCMAC\x00"), []byte("SmbSign\x00"))
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	s.signer = block
+	return nil
+}
+
+// signed returns whether messages for command are signed
+func (s *Session) signed(command uint16) bool {
+	return s.signing && s.sessionID != 0 && command != cmdSessionSetup && (s.signer != nil || s.signingKey != nil)
+}
+
+// signature returns the signature of msg which must have its
+// signature field zeroed
+func (s *Session) signature(msg []byte) []byte {
+	var sig []byte
+	if s.signer != nil {
+		sig = cmac(s.signer, msg)
+	} else {
+		mac := hmac.New(sha256.New, s.signingKey)
+		_, _ = mac.Write(msg)
+		sig = mac.Sum(nil)
+	}
+	return sig[:16]
+}
+
+// sign the message in place
+func (s *Session) sign(msg []byte) {
+	flags := binary.LittleEndian.Uint32(msg[16:])
+	binary.LittleEndian.PutUint32(msg[16:], flags|flagSigned)
+	for i := 48; i < 64; i++ {
+		msg[i] = 0
+	}
+	copy(msg[48:64], s.signature(msg))
+}
+
+var (
+	errNotSigned    = errors.New("smb2: response isn't signed")
+	errBadSignature = errors.New("smb2: bad signature on response")
+)
+
+// verify the signature of the received message msg
+func (s *Session) verify(msg []byte) error {
+	if len(msg) < headerSize {
+		return errBadResponse
+	}
+	if binary.LittleEndian.Uint32(msg[16:])&flagSigned == 0 {
+		return errNotSigned
+	}
+	tmp := make([]byte, len(msg))
+	copy(tmp, msg)
+	for i := 48; i < 64; i++ {
+		tmp[i] = 0
+	}
+	if !hmac.Equal(msg[48:64], s.signature(tmp)) {
+		return errBadSignature
+	}
+	return nil
+}
+
+// creditCharge returns the number of credits a request with a payload
+// of size n needs
+func (s *Session) creditCharge(n int) uint16 {
+	if !s.largeMTU || n <= creditSize {
+		return 1
+	}
+	return uint16((n-1)/creditSize + 1)
+}
+
+// ioSize returns the largest read or write which can be done with
+// the credits available given the server maximum
+func (s *Session) ioSize(max uint32) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size := creditSize
+	if s.largeMTU {
+		size = s.credits * creditSize
+		if size > 1024*1024 {
+			size = 1024 * 1024
+		}
+		if size < creditSize {
+			size = creditSize
+		}
+	}
+	if max > 0 && size > int(max) {
+		size = int(max)
+	}
+	return size
+}
+
+// call sends a request and waits for its response
+//
+// If the response status isn't StatusOK then it is returned as the
+// error along with the response.
+func (s *Session) call(command uint16, treeID uint32, body []byte, payload int) (*response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	r, err := s.roundTrip(command, treeID, body, payload)
+	if err != nil {
+		if _, ok := err.(Status); !ok {
+			// Connection has failed so don't use it again
+			s.err = err
+			_ = s.conn.Close()
+		}
+		return r, err
+	}
+	return r, nil
+}
+
+// roundTrip does the work of call with the lock held
+func (s *Session) roundTrip(command uint16, treeID uint32, body []byte, payload int) (*response, error) {
+	charge := s.creditCharge(payload)
+	if s.dialect == dialect202 || command == cmdNegotiate {
+		charge = 0
+	}
+	messageID := s.messageID
+	if charge > 1 {
+		s.messageID += uint64(charge)
+	} else {
+		s.messageID++
+	}
+
+	msg := make([]byte, 4+headerSize+len(body))
+	h := msg[4 : 4+headerSize]
+	copy(h[0:], protocolID)
+	binary.LittleEndian.PutUint16(h[4:], headerSize)
+	binary.LittleEndian.PutUint16(h[6:], charge)
+	binary.LittleEndian.PutUint16(h[12:], command)
+	binary.LittleEndian.PutUint16(h[14:], 64) // CreditRequest
+	binary.LittleEndian.PutUint64(h[24:], messageID)
+	binary.LittleEndian.PutUint32(h[36:], treeID)
+	binary.LittleEndian.PutUint64(h[40:], s.sessionID)
+	copy(msg[4+headerSize:], body)
+	signed := s.signed(command)
+	if signed {
+		s.sign(msg[4:])
+	}
+
+	// Direct TCP transport header
+	binary.BigEndian.PutUint32(msg[0:], uint32(len(msg)-4))
+	if _, err := s.conn.Write(msg); err != nil {
+		return nil, err
+	}
+	if charge > 0 {
+		s.credits -= int(charge)
+	}
+
+	for {
+		r, err := s.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if r.messageID == oplockBreakMessageID {
+			continue
+		}
+		if r.messageID != messageID {
+			return nil, fmt.Errorf("smb2: unexpected message ID %d in response, expecting %d", r.messageID, messageID)
+		}
+		if r.status == StatusPending && r.flags&flagAsyncCommand != 0 {
+			// Interim response - wait for the real one
+			continue
+		}
+		if signed {
+			if err := s.verify(r.raw); err != nil {
+				return nil, err
+			}
+		}
+		if r.command != command {
+			return nil, fmt.Errorf("smb2: unexpected command 0x%04X in response, expecting 0x%04X", r.command, command)
+		}
+		if r.status != StatusOK {
+			return r, r.status
+		}
+		return r, nil
+	}
+}
+
+// readResponse reads a single response from the server
+func (s *Session) readResponse() (*response, error) {
+	var transport [4]byte
+	for {
+		if _, err := io.ReadFull(s.conn, transport[:]); err != nil {
+			return nil, err
+		}
+		if transport[0] == 0 {
+			break
+		}
+		// Ignore NetBIOS keepalives and other session messages
+		length := int(binary.BigEndian.Uint32(transport[:]) & 0x00FFFFFF)
+		if _, err := io.CopyN(io.Discard, s.conn, int64(length)); err != nil {
+			return nil, err
+		}
+	}
+	length := int(binary.BigEndian.Uint32(transport[:]))
+	if length < headerSize || length > maxMessageSize {
+		return nil, fmt.Errorf("smb2: bad message length %d", length)
+	}
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(s.conn, raw); err != nil {
+		return nil, err
+	}
+	if string(raw[0:4]) != protocolID {
+		return nil, errors.New("smb2: bad protocol ID in response")
+	}
+	r := &response{
+		status:    Status(binary.LittleEndian.Uint32(raw[8:])),
+		command:   binary.LittleEndian.Uint16(raw[12:]),
+		flags:     binary.LittleEndian.Uint32(raw[16:]),
+		messageID: binary.LittleEndian.Uint64(raw[24:]),
+		treeID:    binary.LittleEndian.Uint32(raw[36:]),
+		sessionID: binary.LittleEndian.Uint64(raw[40:]),
+		raw:       raw,
+		body:      raw[headerSize:],
+	}
+	if r.flags&flagServerToRedir == 0 {
+		return nil, errors.New("smb2: received request instead of response")
+	}
+	s.credits += int(binary.LittleEndian.Uint16(raw[14:]))
+	return r, nil
+}
+
+// Echo sends an ECHO request to check the connection is alive
+func (s *Session) Echo() error {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint16(b[0:], 4) // StructureSize
+	_, err := s.call(cmdEcho, 0, b, 0)
+	return err
+}
+
+// Logoff ends the session and closes the connection
+func (s *Session) Logoff() error {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint16(b[0:], 4) // StructureSize
+	_, err := s.call(cmdLogoff, 0, b, 0)
+	closeErr := s.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// Close the connection without logging off
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		// Already closed or failed
+		s.err = errSessionClosed
+		return nil
+	}
+	s.err = errSessionClosed
+	return s.conn.Close()
+}
+
+var errSessionClosed = errors.New("smb2: session closed")
+
+// FILETIME is the number of 100ns intervals since 1601-01-01
+const filetimeEpochOffset = 116444736000000000
+
+// timeToFiletime converts t into a FILETIME
+func timeToFiletime(t time.Time) uint64 {
+	return uint64(t.Unix()*10000000+int64(t.Nanosecond())/100) + filetimeEpochOffset
+}
+
+// filetimeToTime converts a FILETIME into a time.Time
+func filetimeToTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	d := int64(ft - filetimeEpochOffset)
+	return time.Unix(d/10000000, (d%10000000)*100)
+}
@@ -0,0 +1,165 @@
+package smb2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer plays the server side of a signed session over conn
+type testServer struct {
+	t       *testing.T
+	conn    net.Conn
+	dialect uint16
+	signer  *Session // holds the signing key once the session is set up
+}
+
+// read a request from the client
+func (ts *testServer) read() []byte {
+	var transport [4]byte
+	_, err := io.ReadFull(ts.conn, transport[:])
+	require.NoError(ts.t, err)
+	msg := make([]byte, binary.BigEndian.Uint32(transport[:]))
+	_, err = io.ReadFull(ts.conn, msg)
+	require.NoError(ts.t, err)
+	return msg
+}
+
+// reply to req with status and body, signing it if sign is set and
+// then passing it through tamper if it isn't nil
+func (ts *testServer) reply(req []byte, status Status, body []byte, sign bool, tamper func(msg []byte)) {
+	msg := make([]byte, 4+headerSize+len(body))
+	binary.BigEndian.PutUint32(msg[0:], uint32(len(msg)-4))
+	h := msg[4:]
+	copy(h[0:], protocolID)
+	binary.LittleEndian.PutUint16(h[4:], headerSize)
+	binary.LittleEndian.PutUint32(h[8:], uint32(status))
+	copy(h[12:14], req[12:14])                // Command
+	binary.LittleEndian.PutUint16(h[14:], 64) // CreditResponse
+	binary.LittleEndian.PutUint32(h[16:], flagServerToRedir)
+	copy(h[24:32], req[24:32]) // MessageID
+	binary.LittleEndian.PutUint64(h[40:], 0x1234)
+	copy(h[headerSize:], body)
+	if sign {
+		ts.signer.sign(h)
+	}
+	if tamper != nil {
+		tamper(h)
+	}
+	_, err := ts.conn.Write(msg)
+	require.NoError(ts.t, err)
+}
+
+// setup negotiates and authenticates the session with user, pass
+func (ts *testServer) setup(user, pass string) {
+	// NEGOTIATE with signing enabled but not required
+	req := ts.read()
+	body := make([]byte, 64)
+	binary.LittleEndian.PutUint16(body[0:], 65)
+	binary.LittleEndian.PutUint16(body[2:], negotiateSigningEnabled)
+	binary.LittleEndian.PutUint16(body[4:], ts.dialect)
+	binary.LittleEndian.PutUint32(body[28:], 65536)
+	binary.LittleEndian.PutUint32(body[32:], 65536)
+	binary.LittleEndian.PutUint32(body[36:], 65536)
+	ts.reply(req, StatusOK, body, false, nil)
+
+	// SESSION_SETUP with the NTLM negotiate - reply with a challenge
+	req = ts.read()
+	challenge := make([]byte, 48)
+	copy(challenge, ntlmSignature)
+	challenge[8] = ntlmChallenge
+	binary.LittleEndian.PutUint32(challenge[20:], ntlmClientFlags)
+	copy(challenge[24:], "01234567")
+	challenge[44] = 48 // target info offset
+	token, err := encodeNegTokenResp(challenge)
+	require.NoError(ts.t, err)
+	ts.reply(req, StatusMoreProcessingRequired, sessionSetupResponse(token), false, nil)
+
+	// SESSION_SETUP with the NTLM authenticate - derive the session key
+	req = ts.read()
+	offset := int(binary.LittleEndian.Uint16(req[headerSize+12:]))
+	length := int(binary.LittleEndian.Uint16(req[headerSize+14:]))
+	resp, err := decodeNegTokenResp(req[offset : offset+length])
+	require.NoError(ts.t, err)
+	auth := resp.ResponseToken
+	ntResponse, err := readNTLMField(auth, auth[20:28])
+	require.NoError(ts.t, err)
+	sessionKey := hmacMD5(ntowfv2(user, pass, ""), ntResponse[:16])
+	ts.signer = &Session{dialect: ts.dialect}
+	require.NoError(ts.t, ts.signer.setSigningKey(sessionKey))
+	ts.reply(req, StatusOK, sessionSetupResponse(nil), true, nil)
+}
+
+// sessionSetupResponse makes a SESSION_SETUP response body with token
+func sessionSetupResponse(token []byte) []byte {
+	body := make([]byte, 8+len(token))
+	binary.LittleEndian.PutUint16(body[0:], 9)
+	binary.LittleEndian.PutUint16(body[4:], headerSize+8)
+	binary.LittleEndian.PutUint16(body[6:], uint16(len(token)))
+	copy(body[8:], token)
+	return body
+}
+
+// echoResponse is the body of an ECHO response
+var echoResponse = []byte{4, 0, 0, 0}
+
+func TestSessionSigning(t *testing.T) {
+	for _, dialect := range []uint16{dialect210, dialect302} {
+		t.Run(fmt.Sprintf("0x%04X", dialect), func(t *testing.T) {
+			client, server := net.Pipe()
+			ts := &testServer{t: t, conn: server, dialect: dialect}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				ts.setup("user", "pass")
+
+				// requests are signed and signed responses accepted
+				req := ts.read()
+				assert.NoError(t, ts.signer.verify(req))
+				ts.reply(req, StatusOK, echoResponse, true, nil)
+
+				// a tampered response is rejected
+				req = ts.read()
+				ts.reply(req, StatusOK, echoResponse, true, func(msg []byte) {
+					msg[headerSize+2] ^= 0xFF
+				})
+			}()
+
+			s, err := NewSession(client, &Options{User: "user", Password: "pass"})
+			require.NoError(t, err)
+			assert.True(t, s.signing)
+			require.NoError(t, s.Echo())
+			assert.Equal(t, errBadSignature, s.Echo())
+			<-done
+			_ = server.Close()
+
+			// the connection isn't used after a bad signature
+			assert.Equal(t, errBadSignature, s.Echo())
+		})
+	}
+}
+
+func TestSessionSigningUnsigned(t *testing.T) {
+	client, server := net.Pipe()
+	ts := &testServer{t: t, conn: server, dialect: dialect302}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ts.setup("user", "pass")
+
+		// a response with the signature removed is rejected
+		req := ts.read()
+		ts.reply(req, StatusOK, echoResponse, false, nil)
+	}()
+
+	s, err := NewSession(client, &Options{User: "user", Password: "pass"})
+	require.NoError(t, err)
+	assert.Equal(t, errNotSigned, s.Echo())
+	<-done
+	_ = server.Close()
+}
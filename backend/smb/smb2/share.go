@@ -0,0 +1,510 @@
+package smb2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Access masks (MS-SMB2 2.2.13.1)
+const (
+	accessReadData        = 0x00000001
+	accessWriteData       = 0x00000002
+	accessAppendData      = 0x00000004
+	accessReadEA          = 0x00000008
+	accessWriteEA         = 0x00000010
+	accessReadAttributes  = 0x00000080
+	accessWriteAttributes = 0x00000100
+	accessDelete          = 0x00010000
+	accessReadControl     = 0x00020000
+	accessSynchronize     = 0x00100000
+
+	accessListDirectory = accessReadData
+)
+
+// Share access
+const (
+	shareRead   = 0x00000001
+	shareWrite  = 0x00000002
+	shareDelete = 0x00000004
+	shareAll    = shareRead | shareWrite | shareDelete
+)
+
+// Create dispositions
+const (
+	fileOpen        = 0x00000001
+	fileCreate      = 0x00000002
+	fileOpenIf      = 0x00000003
+	fileOverwrite   = 0x00000004
+	fileOverwriteIf = 0x00000005
+)
+
+// Create options
+const (
+	fileDirectoryFile    = 0x00000001
+	fileNonDirectoryFile = 0x00000040
+)
+
+// File attributes (MS-FSCC 2.6)
+const (
+	FileAttributeReadonly  = 0x00000001
+	FileAttributeHidden    = 0x00000002
+	FileAttributeSystem    = 0x00000004
+	FileAttributeDirectory = 0x00000010
+	FileAttributeNormal    = 0x00000080
+)
+
+// Info types and classes for QUERY_INFO and SET_INFO (MS-FSCC 2.4, 2.5)
+const (
+	infoFile       = 0x01
+	infoFilesystem = 0x02
+
+	fileDirectoryInformation   = 0x01
+	fileBasicInformation       = 0x04
+	fileRenameInformation      = 0x0A
+	fileDispositionInformation = 0x0D
+	fileEndOfFileInformation   = 0x14
+	fileNetworkOpenInformation = 0x22
+
+	fileFsFullSizeInformation = 0x07
+)
+
+// Share types from TREE_CONNECT
+const (
+	shareTypeDisk = 0x01
+	shareTypePipe = 0x02
+)
+
+// Share flags from TREE_CONNECT
+const shareFlagEncryptData = 0x00008000
+
+// Share is a connection to a share on the server
+type Share struct {
+	s      *Session
+	name   string
+	treeID uint32
+}
+
+// FileInfo describes a file or directory on a share
+type FileInfo struct {
+	Name       string    // base name of the file
+	Size       int64     // length in bytes
+	ModTime    time.Time // last write time
+	Attributes uint32    // FileAttribute flags
+}
+
+// IsDir returns true if this is a directory
+func (fi *FileInfo) IsDir() bool {
+	return fi.Attributes&FileAttributeDirectory != 0
+}
+
+// FsInfo describes the space on a share
+type FsInfo struct {
+	Total     int64 // total size of the share
+	Free      int64 // space free on the share
+	Available int64 // space available to the user
+}
+
+// Mount connects to the named share
+func (s *Session) Mount(name string) (*Share, error) {
+	sh, err := s.treeConnect(name)
+	if err != nil {
+		return nil, err
+	}
+	if sh.shareType != shareTypeDisk {
+		_ = sh.Umount()
+		return nil, fmt.Errorf("smb2: %q is not a disk share", name)
+	}
+	return sh.Share, nil
+}
+
+// treeShare is a Share with the information from the TREE_CONNECT
+type treeShare struct {
+	*Share
+	shareType uint8
+}
+
+// treeConnect connects to the named share
+func (s *Session) treeConnect(name string) (*treeShare, error) {
+	p := encodeUTF16(`\\` + s.opt.Server + `\` + name)
+	b := make([]byte, 8+len(p))
+	binary.LittleEndian.PutUint16(b[0:], 9) // StructureSize
+	binary.LittleEndian.PutUint16(b[4:], headerSize+8)
+	binary.LittleEndian.PutUint16(b[6:], uint16(len(p)))
+	copy(b[8:], p)
+	r, err := s.call(cmdTreeConnect, 0, b, 0)
+	if err != nil {
+		return nil, fmt.Errorf("smb2: failed to connect to share %q: %w", name, err)
+	}
+	if err = r.check(16); err != nil {
+		return nil, err
+	}
+	shareFlags := binary.LittleEndian.Uint32(r.body[4:])
+	if shareFlags&shareFlagEncryptData != 0 {
+		return nil, fmt.Errorf("smb2: share %q requires encryption which isn't supported", name)
+	}
+	return &treeShare{
+		Share: &Share{
+			s:      s,
+			name:   name,
+			treeID: r.treeID,
+		},
+		shareType: r.body[2],
+	}, nil
+}
+
+// Name returns the name of the share
+func (sh *Share) Name() string {
+	return sh.name
+}
+
+// Umount disconnects from the share
+func (sh *Share) Umount() error {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint16(b[0:], 4) // StructureSize
+	_, err := sh.s.call(cmdTreeDisconnect, sh.treeID, b, 0)
+	return err
+}
+
+// smbPath converts a slash separated path into an SMB path
+func smbPath(name string) string {
+	name = strings.Trim(name, "/")
+	if name == "." {
+		return ""
+	}
+	return strings.Replace(name, "/", `\`, -1)
+}
+
+// fileID identifies an open file
+type fileID [16]byte
+
+// createResponse is the decoded response to a CREATE request
+type createResponse struct {
+	id   fileID
+	info FileInfo
+}
+
+// create opens or creates the file name
+func (sh *Share) create(name string, access, attributes, disposition, options uint32) (*createResponse, error) {
+	p := encodeUTF16(smbPath(name))
+	bufLen := len(p)
+	if bufLen == 0 {
+		// The buffer must contain at least one byte
+		bufLen = 1
+	}
+	b := make([]byte, 56+bufLen)
+	binary.LittleEndian.PutUint16(b[0:], 57)              // StructureSize
+	binary.LittleEndian.PutUint32(b[4:], 2)               // ImpersonationLevel = Impersonation
+	binary.LittleEndian.PutUint32(b[24:], access)         // DesiredAccess
+	binary.LittleEndian.PutUint32(b[28:], attributes)     // FileAttributes
+	binary.LittleEndian.PutUint32(b[32:], shareAll)       // ShareAccess
+	binary.LittleEndian.PutUint32(b[36:], disposition)    // CreateDisposition
+	binary.LittleEndian.PutUint32(b[40:], options)        // CreateOptions
+	binary.LittleEndian.PutUint16(b[44:], headerSize+56)  // NameOffset
+	binary.LittleEndian.PutUint16(b[46:], uint16(len(p))) // NameLength
+	copy(b[56:], p)
+	r, err := sh.s.call(cmdCreate, sh.treeID, b, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.check(88); err != nil {
+		return nil, err
+	}
+	cr := &createResponse{
+		info: FileInfo{
+			Name:       path.Base("/" + strings.Trim(name, "/")),
+			ModTime:    filetimeToTime(binary.LittleEndian.Uint64(r.body[24:])),
+			Size:       int64(binary.LittleEndian.Uint64(r.body[48:])),
+			Attributes: binary.LittleEndian.Uint32(r.body[56:]),
+		},
+	}
+	copy(cr.id[:], r.body[64:80])
+	return cr, nil
+}
+
+// close the file
+func (sh *Share) close(id fileID) error {
+	b := make([]byte, 24)
+	binary.LittleEndian.PutUint16(b[0:], 24) // StructureSize
+	copy(b[8:], id[:])
+	_, err := sh.s.call(cmdClose, sh.treeID, b, 0)
+	return err
+}
+
+// setInfo sets information on an open file
+func (sh *Share) setInfo(id fileID, infoType, class uint8, info []byte) error {
+	b := make([]byte, 32+len(info))
+	binary.LittleEndian.PutUint16(b[0:], 33) // StructureSize
+	b[2] = infoType
+	b[3] = class
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(info)))
+	binary.LittleEndian.PutUint16(b[8:], headerSize+32)
+	copy(b[16:], id[:])
+	copy(b[32:], info)
+	_, err := sh.s.call(cmdSetInfo, sh.treeID, b, len(info))
+	return err
+}
+
+// queryInfo reads information about an open file
+func (sh *Share) queryInfo(id fileID, infoType, class uint8, size int) ([]byte, error) {
+	b := make([]byte, 40+1)
+	binary.LittleEndian.PutUint16(b[0:], 41) // StructureSize
+	b[2] = infoType
+	b[3] = class
+	binary.LittleEndian.PutUint32(b[4:], uint32(size)) // OutputBufferLength
+	copy(b[24:], id[:])
+	r, err := sh.s.call(cmdQueryInfo, sh.treeID, b, size)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.check(8); err != nil {
+		return nil, err
+	}
+	offset := binary.LittleEndian.Uint16(r.body[2:])
+	length := binary.LittleEndian.Uint32(r.body[4:])
+	info, err := r.buf(int(offset), int(length))
+	if err != nil {
+		return nil, err
+	}
+	if len(info) < size {
+		return nil, errBadResponse
+	}
+	return info, nil
+}
+
+// withFile opens name, calls fn with the file ID then closes it
+func (sh *Share) withFile(name string, access, options uint32, fn func(id fileID) error) (err error) {
+	cr, err := sh.create(name, access, 0, fileOpen, options)
+	if err != nil {
+		return err
+	}
+	err = fn(cr.id)
+	closeErr := sh.close(cr.id)
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Stat returns info about the file or directory name
+func (sh *Share) Stat(name string) (*FileInfo, error) {
+	cr, err := sh.create(name, accessReadAttributes|accessSynchronize, 0, fileOpen, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = sh.close(cr.id)
+	if err != nil {
+		return nil, err
+	}
+	return &cr.info, nil
+}
+
+// ReadDir reads the directory name and returns its entries
+//
+// The "." and ".." entries aren't returned.
+func (sh *Share) ReadDir(name string) (entries []*FileInfo, err error) {
+	cr, err := sh.create(name, accessListDirectory|accessReadAttributes|accessSynchronize, 0, fileOpen, fileDirectoryFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		closeErr := sh.close(cr.id)
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	pattern := encodeUTF16("*")
+	for {
+		size := sh.s.ioSize(sh.s.maxTransact)
+		b := make([]byte, 32+len(pattern))
+		binary.LittleEndian.PutUint16(b[0:], 33) // StructureSize
+		b[2] = fileDirectoryInformation
+		copy(b[8:], cr.id[:])
+		binary.LittleEndian.PutUint16(b[24:], headerSize+32)
+		binary.LittleEndian.PutUint16(b[26:], uint16(len(pattern)))
+		binary.LittleEndian.PutUint32(b[28:], uint32(size))
+		copy(b[32:], pattern)
+		r, err := sh.s.call(cmdQueryDirectory, sh.treeID, b, size)
+		if err == StatusNoMoreFiles {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = r.check(8); err != nil {
+			return nil, err
+		}
+		offset := binary.LittleEndian.Uint16(r.body[2:])
+		length := binary.LittleEndian.Uint32(r.body[4:])
+		buf, err := r.buf(int(offset), int(length))
+		if err != nil {
+			return nil, err
+		}
+		entries, err = parseDirectoryInformation(buf, entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseDirectoryInformation decodes FILE_DIRECTORY_INFORMATION
+// entries appending them to entries
+func parseDirectoryInformation(buf []byte, entries []*FileInfo) ([]*FileInfo, error) {
+	for len(buf) > 0 {
+		if len(buf) < 64 {
+			return nil, errBadResponse
+		}
+		next := binary.LittleEndian.Uint32(buf[0:])
+		nameLength := int(binary.LittleEndian.Uint32(buf[60:]))
+		if 64+nameLength > len(buf) {
+			return nil, errBadResponse
+		}
+		name := decodeUTF16(buf[64 : 64+nameLength])
+		if name != "." && name != ".." {
+			entries = append(entries, &FileInfo{
+				Name:       name,
+				ModTime:    filetimeToTime(binary.LittleEndian.Uint64(buf[24:])),
+				Size:       int64(binary.LittleEndian.Uint64(buf[40:])),
+				Attributes: binary.LittleEndian.Uint32(buf[56:]),
+			})
+		}
+		if next == 0 {
+			break
+		}
+		if int(next) > len(buf) {
+			return nil, errBadResponse
+		}
+		buf = buf[next:]
+	}
+	return entries, nil
+}
+
+// Mkdir makes the directory name
+//
+// The parent directory must exist.
+func (sh *Share) Mkdir(name string) error {
+	cr, err := sh.create(name, accessReadAttributes|accessSynchronize, FileAttributeDirectory, fileCreate, fileDirectoryFile)
+	if err != nil {
+		return err
+	}
+	return sh.close(cr.id)
+}
+
+// remove marks the file for deletion on close
+func (sh *Share) remove(name string, options uint32) error {
+	return sh.withFile(name, accessDelete|accessReadAttributes|accessSynchronize, options, func(id fileID) error {
+		return sh.setInfo(id, infoFile, fileDispositionInformation, []byte{1})
+	})
+}
+
+// Remove removes the file name
+func (sh *Share) Remove(name string) error {
+	return sh.remove(name, fileNonDirectoryFile)
+}
+
+// Rmdir removes the empty directory name
+func (sh *Share) Rmdir(name string) error {
+	return sh.remove(name, fileDirectoryFile)
+}
+
+// Rename renames oldName to newName replacing newName if it exists
+// and replace is set
+//
+// This works for files and directories but both names must be on
+// this share.
+func (sh *Share) Rename(oldName, newName string, replace bool) error {
+	p := encodeUTF16(smbPath(newName))
+	info := make([]byte, 20+len(p))
+	if replace {
+		info[0] = 1 // ReplaceIfExists
+	}
+	binary.LittleEndian.PutUint32(info[16:], uint32(len(p)))
+	copy(info[20:], p)
+	return sh.withFile(oldName, accessDelete|accessReadAttributes|accessSynchronize, 0, func(id fileID) error {
+		return sh.setInfo(id, infoFile, fileRenameInformation, info)
+	})
+}
+
+// setModTime sets the last write time on an open file
+func (sh *Share) setModTime(id fileID, modTime time.Time) error {
+	info := make([]byte, 40)
+	// Zero values mean don't change the time
+	binary.LittleEndian.PutUint64(info[16:], timeToFiletime(modTime)) // LastWriteTime
+	return sh.setInfo(id, infoFile, fileBasicInformation, info)
+}
+
+// Chtimes sets the modification time of name
+func (sh *Share) Chtimes(name string, modTime time.Time) error {
+	return sh.withFile(name, accessWriteAttributes|accessReadAttributes|accessSynchronize, 0, func(id fileID) error {
+		return sh.setModTime(id, modTime)
+	})
+}
+
+// Statfs returns the space information for the share
+func (sh *Share) Statfs(name string) (fsInfo *FsInfo, err error) {
+	err = sh.withFile(name, accessReadAttributes|accessSynchronize, 0, func(id fileID) error {
+		info, err := sh.queryInfo(id, infoFilesystem, fileFsFullSizeInformation, 32)
+		if err != nil {
+			return err
+		}
+		total := binary.LittleEndian.Uint64(info[0:])
+		callerAvailable := binary.LittleEndian.Uint64(info[8:])
+		actualAvailable := binary.LittleEndian.Uint64(info[16:])
+		unit := uint64(binary.LittleEndian.Uint32(info[24:])) * uint64(binary.LittleEndian.Uint32(info[28:]))
+		fsInfo = &FsInfo{
+			Total:     int64(total * unit),
+			Free:      int64(actualAvailable * unit),
+			Available: int64(callerAvailable * unit),
+		}
+		return nil
+	})
+	return fsInfo, err
+}
+
+// OpenFile opens the file name with flag which is a combination of
+// the os.O_ flags
+//
+// os.O_APPEND isn't supported.
+func (sh *Share) OpenFile(name string, flag int) (*File, error) {
+	if flag&os.O_APPEND != 0 {
+		return nil, errors.New("smb2: O_APPEND is not supported")
+	}
+	access := uint32(accessReadAttributes | accessSynchronize)
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		access |= accessReadData | accessReadEA
+	case os.O_WRONLY:
+		access |= accessWriteData | accessAppendData | accessWriteEA | accessWriteAttributes
+	case os.O_RDWR:
+		access |= accessReadData | accessReadEA | accessWriteData | accessAppendData | accessWriteEA | accessWriteAttributes
+	}
+	if flag&os.O_TRUNC != 0 {
+		access |= accessWriteData
+	}
+	var disposition uint32
+	switch {
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		disposition = fileCreate
+	case flag&(os.O_CREATE|os.O_TRUNC) == os.O_CREATE|os.O_TRUNC:
+		disposition = fileOverwriteIf
+	case flag&os.O_CREATE != 0:
+		disposition = fileOpenIf
+	case flag&os.O_TRUNC != 0:
+		disposition = fileOverwrite
+	default:
+		disposition = fileOpen
+	}
+	cr, err := sh.create(name, access, FileAttributeNormal, disposition, fileNonDirectoryFile)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		sh:   sh,
+		id:   cr.id,
+		info: cr.info,
+	}, nil
+}
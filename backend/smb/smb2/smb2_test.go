@@ -0,0 +1,192 @@
+package smb2
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// Test vectors from RFC 4493
+func TestCMAC(t *testing.T) {
+	block, err := aes.NewCipher(unhex(t, "2b7e151628aed2a6abf7158809cf4f3c"))
+	require.NoError(t, err)
+	msg := unhex(t, "6bc1bee22e409f96e93d7e117393172a"+
+		"ae2d8a571e03ac9c9eb76fac45af8e51"+
+		"30c81c46a35ce411e5fbc1191a0a52ef"+
+		"f69f2445df4f9b17ad2b417be66c3710")
+	for _, test := range []struct {
+		n    int
+		want string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	} {
+		got := cmac(block, msg[:test.n])
+		assert.Equal(t, test.want, hex.EncodeToString(got), test.n)
+	}
+}
+
+// Test vectors from MS-NLMP 4.2.4
+func TestNTLMv2(t *testing.T) {
+	ntowf := ntowfv2("User", "Password", "Domain")
+	assert.Equal(t, "0c868a403bfd7a93a3001ef22ef02e3f", hex.EncodeToString(ntowf))
+
+	serverChallenge := unhex(t, "0123456789abcdef")
+	clientChallenge := unhex(t, "aaaaaaaaaaaaaaaa")
+	lm := lmv2Response(ntowf, serverChallenge, clientChallenge)
+	assert.Equal(t, "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa", hex.EncodeToString(lm))
+}
+
+func TestNTLMAuthenticate(t *testing.T) {
+	n := &ntlmClient{user: "user", password: "pass", domain: "DOMAIN"}
+	neg := n.negotiate()
+	assert.Equal(t, ntlmSignature, neg[:8])
+
+	// Make a minimal challenge with empty target info
+	challenge := make([]byte, 48)
+	copy(challenge, ntlmSignature)
+	challenge[8] = ntlmChallenge
+	copy(challenge[24:], "01234567")
+	challenge[44] = 48 // target info offset
+
+	auth, err := n.authenticate(challenge)
+	require.NoError(t, err)
+	assert.Equal(t, ntlmSignature, auth[:8])
+	assert.Equal(t, byte(ntlmAuthenticate), auth[8])
+	assert.Len(t, n.sessionKey, 16)
+	user, err := readNTLMField(auth, auth[36:44])
+	require.NoError(t, err)
+	assert.Equal(t, "user", decodeUTF16(user))
+
+	_, err = n.authenticate(challenge[:40])
+	assert.Error(t, err)
+
+	// Anonymous
+	n = &ntlmClient{}
+	_, err = n.authenticate(challenge)
+	require.NoError(t, err)
+	assert.Nil(t, n.sessionKey)
+}
+
+func TestSPNEGO(t *testing.T) {
+	init, err := encodeNegTokenInit([]byte("token"))
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x60), init[0]) // [APPLICATION 0]
+
+	resp, err := encodeNegTokenResp([]byte("response"))
+	require.NoError(t, err)
+	got, err := decodeNegTokenResp(resp)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("response"), got.ResponseToken)
+
+	_, err = decodeNegTokenResp(init)
+	assert.Error(t, err)
+}
+
+func TestKDF(t *testing.T) {
+	key := kdf(make([]byte, 16), []byte("SMB2AESCMAC\x00"), []byte("SmbSign\x00"))
+	assert.Len(t, key, 16)
+}
+
+func TestFiletime(t *testing.T) {
+	assert.Equal(t, uint64(filetimeEpochOffset), timeToFiletime(time.Unix(0, 0)))
+	assert.True(t, filetimeToTime(0).IsZero())
+	for _, tm := range []time.Time{
+		time.Date(2022, 4, 1, 12, 34, 56, 123456700, time.UTC),
+		time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1900, 6, 1, 0, 0, 0, 100, time.UTC),
+	} {
+		got := filetimeToTime(timeToFiletime(tm))
+		assert.True(t, tm.Equal(got), "%v != %v", tm, got)
+	}
+}
+
+func TestParseDirectoryInformation(t *testing.T) {
+	makeEntry := func(name string, size uint64, attr uint32, last bool) []byte {
+		u := encodeUTF16(name)
+		b := make([]byte, 64+len(u))
+		b[40] = byte(size)
+		b[56] = byte(attr)
+		b[60] = byte(len(u))
+		copy(b[64:], u)
+		for len(b)%8 != 0 {
+			b = append(b, 0)
+		}
+		if !last {
+			b[0] = byte(len(b))
+		}
+		return b
+	}
+	var buf []byte
+	buf = append(buf, makeEntry(".", 0, FileAttributeDirectory, false)...)
+	buf = append(buf, makeEntry("..", 0, FileAttributeDirectory, false)...)
+	buf = append(buf, makeEntry("file.txt", 42, FileAttributeNormal, false)...)
+	buf = append(buf, makeEntry("dir", 0, FileAttributeDirectory, true)...)
+	entries, err := parseDirectoryInformation(buf, nil)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "file.txt", entries[0].Name)
+	assert.Equal(t, int64(42), entries[0].Size)
+	assert.False(t, entries[0].IsDir())
+	assert.Equal(t, "dir", entries[1].Name)
+	assert.True(t, entries[1].IsDir())
+
+	_, err = parseDirectoryInformation(buf[:70], nil)
+	assert.Error(t, err)
+}
+
+func TestParseNetrShareEnum(t *testing.T) {
+	var w ndrWriter
+	w.uint32(1)          // Level
+	w.uint32(1)          // union switch
+	w.uint32(0x00020000) // container referent
+	w.uint32(2)          // EntriesRead
+	w.uint32(0x00020004) // Buffer referent
+	w.uint32(2)          // MaxCount
+	w.uint32(0x00020008) // netname
+	w.uint32(ShareTypeDisk)
+	w.uint32(0x0002000C) // remark
+	w.uint32(0x00020010) // netname
+	w.uint32(ShareTypeIPC | ShareTypeSpecial)
+	w.uint32(0) // no remark
+	w.string("files")
+	w.string("My files")
+	w.string("IPC$")
+	w.uint32(2)          // TotalEntries
+	w.uint32(0x00020014) // ResumeHandle referent
+	w.uint32(0)          // ResumeHandle
+	w.uint32(0)          // WERROR
+	shares, err := parseNetrShareEnum(w.buf)
+	require.NoError(t, err)
+	require.Len(t, shares, 2)
+	assert.Equal(t, "files", shares[0].Name)
+	assert.Equal(t, "My files", shares[0].Comment)
+	assert.True(t, shares[0].IsDisk())
+	assert.False(t, shares[0].IsSpecial())
+	assert.Equal(t, "IPC$", shares[1].Name)
+	assert.Equal(t, "", shares[1].Comment)
+	assert.False(t, shares[1].IsDisk())
+	assert.True(t, shares[1].IsSpecial())
+
+	_, err = parseNetrShareEnum(w.buf[:20])
+	assert.Error(t, err)
+}
+
+func TestSmbPath(t *testing.T) {
+	assert.Equal(t, "", smbPath(""))
+	assert.Equal(t, "", smbPath("/"))
+	assert.Equal(t, `dir\file.txt`, smbPath("dir/file.txt"))
+	assert.Equal(t, `dir\sub`, smbPath("/dir/sub/"))
+}
@@ -0,0 +1,105 @@
+package smb2
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// SPNEGO (RFC 4178) wrapping of the NTLM tokens
+//
+// Only NTLM is offered as a mechanism.
+
+var (
+	spnegoOID = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 2}
+	ntlmOID   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 2, 10}
+)
+
+// negTokenInit is the initial token sent by the client
+type negTokenInit struct {
+	MechTypes []asn1.ObjectIdentifier `asn1:"explicit,tag:0"`
+	MechToken []byte                  `asn1:"explicit,optional,tag:2"`
+}
+
+// negTokenResp is used for the subsequent tokens in both directions
+type negTokenResp struct {
+	NegState      asn1.Enumerated       `asn1:"explicit,optional,tag:0"`
+	SupportedMech asn1.ObjectIdentifier `asn1:"explicit,optional,tag:1"`
+	ResponseToken []byte                `asn1:"explicit,optional,tag:2"`
+	MechListMIC   []byte                `asn1:"explicit,optional,tag:3"`
+}
+
+// SPNEGO negotiation states
+const (
+	negStateAcceptCompleted  = 0
+	negStateAcceptIncomplete = 1
+	negStateReject           = 2
+)
+
+// wrapContext wraps b in a constructed context specific tag
+func wrapContext(tag int, b []byte) ([]byte, error) {
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        tag,
+		IsCompound: true,
+		Bytes:      b,
+	})
+}
+
+// encodeNegTokenInit wraps the NTLM negotiate message for sending
+func encodeNegTokenInit(mechToken []byte) ([]byte, error) {
+	init, err := asn1.Marshal(negTokenInit{
+		MechTypes: []asn1.ObjectIdentifier{ntlmOID},
+		MechToken: mechToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	token, err := wrapContext(0, init)
+	if err != nil {
+		return nil, err
+	}
+	oid, err := asn1.Marshal(spnegoOID)
+	if err != nil {
+		return nil, err
+	}
+	// InitialContextToken from RFC 2743
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassApplication,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      append(oid, token...),
+	})
+}
+
+// encodeNegTokenResp wraps the NTLM authenticate message for sending
+func encodeNegTokenResp(responseToken []byte) ([]byte, error) {
+	resp, err := asn1.Marshal(negTokenResp{
+		ResponseToken: responseToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wrapContext(1, resp)
+}
+
+// decodeNegTokenResp unwraps the server's response
+func decodeNegTokenResp(b []byte) (*negTokenResp, error) {
+	var raw asn1.RawValue
+	rest, err := asn1.Unmarshal(b, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("spnego: failed to decode response: %w", err)
+	}
+	if len(rest) != 0 || raw.Class != asn1.ClassContextSpecific || raw.Tag != 1 {
+		return nil, errors.New("spnego: expecting negTokenResp")
+	}
+	resp := new(negTokenResp)
+	_, err = asn1.Unmarshal(raw.Bytes, resp)
+	if err != nil {
+		return nil, fmt.Errorf("spnego: failed to decode negTokenResp: %w", err)
+	}
+	if resp.NegState == negStateReject {
+		return nil, errors.New("spnego: authentication rejected")
+	}
+	return resp, nil
+}
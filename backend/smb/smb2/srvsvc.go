@@ -0,0 +1,369 @@
+package smb2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Share enumeration using NetrShareEnum from the srvsvc RPC interface
+// (MS-SRVS) over the \pipe\srvsvc named pipe on the IPC$ share.
+
+// DCE/RPC connection oriented PDU types (C706 chapter 12)
+const (
+	rpcRequest  = 0
+	rpcResponse = 2
+	rpcFault    = 3
+	rpcBind     = 11
+	rpcBindAck  = 12
+)
+
+const (
+	rpcFirstFrag   = 0x01
+	rpcLastFrag    = 0x02
+	rpcHeaderSize  = 16
+	rpcMaxFragSize = 4280
+
+	opNetrShareEnum = 15
+)
+
+// Share types from MS-SRVS 2.2.2.4
+const (
+	ShareTypeDisk    = 0x00000000
+	ShareTypePrint   = 0x00000001
+	ShareTypeDevice  = 0x00000002
+	ShareTypeIPC     = 0x00000003
+	ShareTypeSpecial = 0x80000000
+)
+
+// ShareInfo describes a share on the server
+type ShareInfo struct {
+	Name    string
+	Type    uint32
+	Comment string
+}
+
+// IsDisk returns true if the share is a disk share
+func (si *ShareInfo) IsDisk() bool {
+	return si.Type&0xFFFF == ShareTypeDisk
+}
+
+// IsSpecial returns true if the share is an administrative share,
+// e.g. C$ or IPC$
+func (si *ShareInfo) IsSpecial() bool {
+	return si.Type&ShareTypeSpecial != 0
+}
+
+var (
+	// srvsvc interface 4b324fc8-1670-01d3-1278-5a47bf6ee188 version 3.0
+	srvsvcSyntax = []byte{
+		0xc8, 0x4f, 0x32, 0x4b, 0x70, 0x16, 0xd3, 0x01,
+		0x12, 0x78, 0x5a, 0x47, 0xbf, 0x6e, 0xe1, 0x88,
+		3, 0, 0, 0,
+	}
+	// NDR transfer syntax 8a885d04-1ceb-11c9-9fe8-08002b104860 version 2
+	ndrSyntax = []byte{
+		0x04, 0x5d, 0x88, 0x8a, 0xeb, 0x1c, 0xc9, 0x11,
+		0x9f, 0xe8, 0x08, 0x00, 0x2b, 0x10, 0x48, 0x60,
+		2, 0, 0, 0,
+	}
+)
+
+// rpcHeader makes a PDU header for ptype with body appended
+func rpcHeader(ptype uint8, callID uint32, body []byte) []byte {
+	b := make([]byte, rpcHeaderSize, rpcHeaderSize+len(body))
+	b[0] = 5 // rpc_vers
+	b[1] = 0 // rpc_vers_minor
+	b[2] = ptype
+	b[3] = rpcFirstFrag | rpcLastFrag
+	b[4] = 0x10 // little endian, ASCII, IEEE float
+	binary.LittleEndian.PutUint16(b[8:], uint16(rpcHeaderSize+len(body)))
+	binary.LittleEndian.PutUint32(b[12:], callID)
+	return append(b, body...)
+}
+
+// ndrWriter encodes NDR data
+type ndrWriter struct {
+	buf []byte
+}
+
+func (w *ndrWriter) uint32(x uint32) {
+	w.buf = append(w.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(w.buf[len(w.buf)-4:], x)
+}
+
+func (w *ndrWriter) align(n int) {
+	for len(w.buf)%n != 0 {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// string writes a conformant varying null terminated UTF-16 string
+func (w *ndrWriter) string(s string) {
+	u := encodeUTF16(s + "\x00")
+	w.uint32(uint32(len(u) / 2)) // MaxCount
+	w.uint32(0)                  // Offset
+	w.uint32(uint32(len(u) / 2)) // ActualCount
+	w.buf = append(w.buf, u...)
+	w.align(4)
+}
+
+// ndrReader decodes NDR data with a sticky error
+type ndrReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *ndrReader) uint32() uint32 {
+	r.align(4)
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+4 > len(r.buf) {
+		r.err = errBadRPCResponse
+		return 0
+	}
+	x := binary.LittleEndian.Uint32(r.buf[r.pos:])
+	r.pos += 4
+	return x
+}
+
+func (r *ndrReader) align(n int) {
+	for r.pos%n != 0 {
+		r.pos++
+	}
+}
+
+// string reads a conformant varying UTF-16 string
+func (r *ndrReader) string() string {
+	_ = r.uint32() // MaxCount
+	_ = r.uint32() // Offset
+	count := int(r.uint32())
+	if r.err != nil {
+		return ""
+	}
+	if count < 0 || r.pos+2*count > len(r.buf) {
+		r.err = errBadRPCResponse
+		return ""
+	}
+	u := r.buf[r.pos : r.pos+2*count]
+	r.pos += 2 * count
+	// Remove the terminating null
+	if len(u) >= 2 && u[len(u)-2] == 0 && u[len(u)-1] == 0 {
+		u = u[:len(u)-2]
+	}
+	return decodeUTF16(u)
+}
+
+var errBadRPCResponse = errors.New("smb2: malformed RPC response")
+
+// pipe is an open named pipe used for RPC
+type pipe struct {
+	f   *File
+	buf []byte // data read but not used yet
+}
+
+// transact writes the PDU then reads a response PDU
+func (p *pipe) transact(pdu []byte) ([]byte, error) {
+	if _, err := p.f.writeChunk(pdu, 0); err != nil {
+		return nil, err
+	}
+	return p.readPDU()
+}
+
+// readPDU reads a complete PDU from the pipe
+func (p *pipe) readPDU() ([]byte, error) {
+	for {
+		if len(p.buf) >= rpcHeaderSize {
+			fragLength := int(binary.LittleEndian.Uint16(p.buf[8:]))
+			if fragLength < rpcHeaderSize {
+				return nil, errBadRPCResponse
+			}
+			if len(p.buf) >= fragLength {
+				pdu := p.buf[:fragLength]
+				p.buf = p.buf[fragLength:]
+				return pdu, nil
+			}
+		}
+		chunk := make([]byte, rpcMaxFragSize)
+		n, err := p.f.readChunk(chunk, 0)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, errBadRPCResponse
+		}
+		p.buf = append(p.buf, chunk[:n]...)
+	}
+}
+
+// bind to the srvsvc interface
+func (p *pipe) bind() error {
+	w := ndrWriter{buf: make([]byte, 4, 56)}
+	binary.LittleEndian.PutUint16(w.buf[0:], rpcMaxFragSize) // max_xmit_frag
+	binary.LittleEndian.PutUint16(w.buf[2:], rpcMaxFragSize) // max_recv_frag
+	w.uint32(0)                                              // assoc_group_id
+	w.buf = append(w.buf, 1, 0, 0, 0)                        // n_context_elem and reserved
+	w.buf = append(w.buf, 0, 0, 1, 0)                        // p_cont_id, n_transfer_syn, reserved
+	w.buf = append(w.buf, srvsvcSyntax...)
+	w.buf = append(w.buf, ndrSyntax...)
+	resp, err := p.transact(rpcHeader(rpcBind, 1, w.buf))
+	if err != nil {
+		return fmt.Errorf("smb2: RPC bind failed: %w", err)
+	}
+	if resp[2] != rpcBindAck {
+		return fmt.Errorf("smb2: RPC bind failed: unexpected PDU type %d", resp[2])
+	}
+	// Skip max_xmit_frag, max_recv_frag, assoc_group_id and the
+	// secondary address to find the result list
+	if len(resp) < 26 {
+		return errBadRPCResponse
+	}
+	pos := 26 + int(binary.LittleEndian.Uint16(resp[24:]))
+	pos = (pos + 3) &^ 3
+	if pos+6 > len(resp) {
+		return errBadRPCResponse
+	}
+	if resp[pos] < 1 {
+		return errBadRPCResponse
+	}
+	result := binary.LittleEndian.Uint16(resp[pos+4:])
+	if result != 0 {
+		return fmt.Errorf("smb2: RPC bind rejected with result %d", result)
+	}
+	return nil
+}
+
+// call the operation opnum with stub and return the response stub
+func (p *pipe) call(callID uint32, opnum uint16, stub []byte) ([]byte, error) {
+	body := make([]byte, 8, 8+len(stub))
+	binary.LittleEndian.PutUint32(body[0:], uint32(len(stub))) // alloc_hint
+	binary.LittleEndian.PutUint16(body[6:], opnum)
+	body = append(body, stub...)
+	pdu, err := p.transact(rpcHeader(rpcRequest, callID, body))
+	var out []byte
+	for {
+		if err != nil {
+			return nil, err
+		}
+		if len(pdu) < rpcHeaderSize+8 {
+			return nil, errBadRPCResponse
+		}
+		switch pdu[2] {
+		case rpcResponse:
+		case rpcFault:
+			if len(pdu) < rpcHeaderSize+12 {
+				return nil, errBadRPCResponse
+			}
+			return nil, fmt.Errorf("smb2: RPC call failed with fault 0x%08X", binary.LittleEndian.Uint32(pdu[24:]))
+		default:
+			return nil, fmt.Errorf("smb2: RPC call failed: unexpected PDU type %d", pdu[2])
+		}
+		authLength := int(binary.LittleEndian.Uint16(pdu[10:]))
+		if authLength > 0 {
+			return nil, errors.New("smb2: RPC authentication not supported")
+		}
+		out = append(out, pdu[rpcHeaderSize+8:]...)
+		if pdu[3]&rpcLastFrag != 0 {
+			return out, nil
+		}
+		pdu, err = p.readPDU()
+	}
+}
+
+// ListShares returns the shares on the server
+func (s *Session) ListShares() (shares []ShareInfo, err error) {
+	ts, err := s.treeConnect("IPC$")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		umountErr := ts.Umount()
+		if err == nil {
+			err = umountErr
+		}
+	}()
+	cr, err := ts.create("srvsvc", accessReadData|accessWriteData|accessAppendData|accessReadEA|accessWriteEA|accessReadAttributes|accessWriteAttributes|accessReadControl|accessSynchronize, 0, fileOpen, 0)
+	if err != nil {
+		return nil, fmt.Errorf("smb2: failed to open srvsvc pipe: %w", err)
+	}
+	p := &pipe{f: &File{sh: ts.Share, id: cr.id, info: cr.info}}
+	defer func() {
+		closeErr := p.f.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	err = p.bind()
+	if err != nil {
+		return nil, err
+	}
+
+	// NetrShareEnum request with level 1 info
+	var w ndrWriter
+	w.uint32(0x00020000) // ServerName referent ID
+	w.string(`\\` + s.opt.Server)
+	w.uint32(1)          // Level
+	w.uint32(1)          // ShareInfo union switch
+	w.uint32(0x00020004) // SHARE_INFO_1_CONTAINER referent ID
+	w.uint32(0)          // EntriesRead
+	w.uint32(0)          // Buffer - NULL
+	w.uint32(0xFFFFFFFF) // PreferedMaximumLength
+	w.uint32(0x00020008) // ResumeHandle referent ID
+	w.uint32(0)          // ResumeHandle
+	stub, err := p.call(2, opNetrShareEnum, w.buf)
+	if err != nil {
+		return nil, err
+	}
+	return parseNetrShareEnum(stub)
+}
+
+// parseNetrShareEnum decodes the response stub of NetrShareEnum
+func parseNetrShareEnum(stub []byte) ([]ShareInfo, error) {
+	if len(stub) < 4 {
+		return nil, errBadRPCResponse
+	}
+	if werr := binary.LittleEndian.Uint32(stub[len(stub)-4:]); werr != 0 {
+		return nil, fmt.Errorf("smb2: NetrShareEnum failed with error %d", werr)
+	}
+	r := &ndrReader{buf: stub}
+	_ = r.uint32() // Level
+	_ = r.uint32() // union switch
+	if r.uint32() == 0 {
+		return nil, r.err
+	}
+	count := int(r.uint32()) // EntriesRead
+	if r.uint32() == 0 {     // Buffer referent ID
+		return nil, r.err
+	}
+	maxCount := int(r.uint32())
+	if r.err != nil {
+		return nil, r.err
+	}
+	if count > maxCount || 12*count > len(stub) {
+		return nil, errBadRPCResponse
+	}
+	type entry struct {
+		name, comment uint32
+	}
+	entries := make([]entry, count)
+	shares := make([]ShareInfo, count)
+	for i := range entries {
+		entries[i].name = r.uint32()
+		shares[i].Type = r.uint32()
+		entries[i].comment = r.uint32()
+	}
+	for i := range entries {
+		if entries[i].name != 0 {
+			shares[i].Name = r.string()
+		}
+		if entries[i].comment != 0 {
+			shares[i].Comment = r.string()
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return shares, nil
+}
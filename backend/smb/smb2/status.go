@@ -0,0 +1,157 @@
+package smb2
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Status is an NTSTATUS code returned by the server
+//
+// Any Status other than StatusOK is an error.
+type Status uint32
+
+// NTSTATUS codes used by the client (MS-ERREF 2.3)
+const (
+	StatusOK                      Status = 0x00000000
+	StatusPending                 Status = 0x00000103
+	StatusBufferOverflow          Status = 0x80000005
+	StatusNoMoreFiles             Status = 0x80000006
+	StatusStoppedOnSymlink        Status = 0x8000002D
+	StatusInvalidHandle           Status = 0xC0000008
+	StatusInvalidParameter        Status = 0xC000000D
+	StatusNoSuchFile              Status = 0xC000000F
+	StatusInvalidDeviceRequest    Status = 0xC0000010
+	StatusEndOfFile               Status = 0xC0000011
+	StatusMoreProcessingRequired  Status = 0xC0000016
+	StatusAccessDenied            Status = 0xC0000022
+	StatusObjectNameInvalid       Status = 0xC0000033
+	StatusObjectNameNotFound      Status = 0xC0000034
+	StatusObjectNameCollision     Status = 0xC0000035
+	StatusObjectPathNotFound      Status = 0xC000003A
+	StatusSharingViolation        Status = 0xC0000043
+	StatusDeletePending           Status = 0xC0000056
+	StatusNoSuchUser              Status = 0xC0000064
+	StatusWrongPassword           Status = 0xC000006A
+	StatusLogonFailure            Status = 0xC000006D
+	StatusAccountRestriction      Status = 0xC000006E
+	StatusPasswordExpired         Status = 0xC0000071
+	StatusAccountDisabled         Status = 0xC0000072
+	StatusDiskFull                Status = 0xC000007F
+	StatusInsufficientResources   Status = 0xC000009A
+	StatusFileIsADirectory        Status = 0xC00000BA
+	StatusNotSupported            Status = 0xC00000BB
+	StatusNetworkNameDeleted      Status = 0xC00000C9
+	StatusNetworkAccessDenied     Status = 0xC00000CA
+	StatusBadNetworkName          Status = 0xC00000CC
+	StatusNotSameDevice           Status = 0xC00000D4
+	StatusDirectoryNotEmpty       Status = 0xC0000101
+	StatusNotADirectory           Status = 0xC0000103
+	StatusCannotDelete            Status = 0xC0000121
+	StatusFileClosed              Status = 0xC0000128
+	StatusUserSessionDeleted      Status = 0xC0000203
+	StatusNotFound                Status = 0xC0000225
+	StatusNetworkSessionExpired   Status = 0xC000035C
+	StatusSMBTooManyUIDs          Status = 0xC000205A
+	StatusInsufficientServerCreds Status = 0xC0000205
+)
+
+var statusNames = map[Status]string{
+	StatusOK:                      "STATUS_SUCCESS",
+	StatusPending:                 "STATUS_PENDING",
+	StatusBufferOverflow:          "STATUS_BUFFER_OVERFLOW",
+	StatusNoMoreFiles:             "STATUS_NO_MORE_FILES",
+	StatusStoppedOnSymlink:        "STATUS_STOPPED_ON_SYMLINK",
+	StatusInvalidHandle:           "STATUS_INVALID_HANDLE",
+	StatusInvalidParameter:        "STATUS_INVALID_PARAMETER",
+	StatusNoSuchFile:              "STATUS_NO_SUCH_FILE",
+	StatusInvalidDeviceRequest:    "STATUS_INVALID_DEVICE_REQUEST",
+	StatusEndOfFile:               "STATUS_END_OF_FILE",
+	StatusMoreProcessingRequired:  "STATUS_MORE_PROCESSING_REQUIRED",
+	StatusAccessDenied:            "STATUS_ACCESS_DENIED",
+	StatusObjectNameInvalid:       "STATUS_OBJECT_NAME_INVALID",
+	StatusObjectNameNotFound:      "STATUS_OBJECT_NAME_NOT_FOUND",
+	StatusObjectNameCollision:     "STATUS_OBJECT_NAME_COLLISION",
+	StatusObjectPathNotFound:      "STATUS_OBJECT_PATH_NOT_FOUND",
+	StatusSharingViolation:        "STATUS_SHARING_VIOLATION",
+	StatusDeletePending:           "STATUS_DELETE_PENDING",
+	StatusNoSuchUser:              "STATUS_NO_SUCH_USER",
+	StatusWrongPassword:           "STATUS_WRONG_PASSWORD",
+	StatusLogonFailure:            "STATUS_LOGON_FAILURE",
+	StatusAccountRestriction:      "STATUS_ACCOUNT_RESTRICTION",
+	StatusPasswordExpired:         "STATUS_PASSWORD_EXPIRED",
+	StatusAccountDisabled:         "STATUS_ACCOUNT_DISABLED",
+	StatusDiskFull:                "STATUS_DISK_FULL",
+	StatusInsufficientResources:   "STATUS_INSUFFICIENT_RESOURCES",
+	StatusFileIsADirectory:        "STATUS_FILE_IS_A_DIRECTORY",
+	StatusNotSupported:            "STATUS_NOT_SUPPORTED",
+	StatusNetworkNameDeleted:      "STATUS_NETWORK_NAME_DELETED",
+	StatusNetworkAccessDenied:     "STATUS_NETWORK_ACCESS_DENIED",
+	StatusBadNetworkName:          "STATUS_BAD_NETWORK_NAME",
+	StatusNotSameDevice:           "STATUS_NOT_SAME_DEVICE",
+	StatusDirectoryNotEmpty:       "STATUS_DIRECTORY_NOT_EMPTY",
+	StatusNotADirectory:           "STATUS_NOT_A_DIRECTORY",
+	StatusCannotDelete:            "STATUS_CANNOT_DELETE",
+	StatusFileClosed:              "STATUS_FILE_CLOSED",
+	StatusUserSessionDeleted:      "STATUS_USER_SESSION_DELETED",
+	StatusNotFound:                "STATUS_NOT_FOUND",
+	StatusNetworkSessionExpired:   "STATUS_NETWORK_SESSION_EXPIRED",
+	StatusSMBTooManyUIDs:          "STATUS_SMB_TOO_MANY_UIDS",
+	StatusInsufficientServerCreds: "STATUS_INSUFFICIENT_SERVER_CREDENTIALS",
+}
+
+// Error satisfies the error interface
+func (s Status) Error() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("NTSTATUS 0x%08X", uint32(s))
+}
+
+// Is allows errors.Is to match the Status against the os errors
+func (s Status) Is(target error) bool {
+	switch target {
+	case os.ErrNotExist:
+		switch s {
+		case StatusNoSuchFile, StatusObjectNameNotFound, StatusObjectPathNotFound, StatusNotFound, StatusDeletePending:
+			return true
+		}
+	case os.ErrExist:
+		return s == StatusObjectNameCollision
+	case os.ErrPermission:
+		switch s {
+		case StatusAccessDenied, StatusNetworkAccessDenied, StatusCannotDelete:
+			return true
+		}
+	}
+	return false
+}
+
+// Temporary returns true if the request might succeed if retried
+func (s Status) Temporary() bool {
+	switch s {
+	case StatusInsufficientResources, StatusSharingViolation:
+		return true
+	}
+	return false
+}
+
+// sessionLost returns true if the status means the session or tree
+// connect has gone and the connection needs to be reopened
+func (s Status) sessionLost() bool {
+	switch s {
+	case StatusNetworkNameDeleted, StatusUserSessionDeleted, StatusNetworkSessionExpired:
+		return true
+	}
+	return false
+}
+
+// IsSessionLost returns true if err is a Status which means that the
+// session is no longer usable and should be discarded
+func IsSessionLost(err error) bool {
+	var s Status
+	if !errors.As(err, &s) {
+		return false
+	}
+	return s.sessionLost()
+}
@@ -49,6 +49,13 @@ func (o *Object) candidates() []upstream.Entry {
 	return o.co
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object.Object)
+}
+
 func (d *Directory) candidates() []upstream.Entry {
 	return d.cd
 }
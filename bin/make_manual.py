@@ -37,6 +37,7 @@ docs = [
     "chunker.md",
     "sharefile.md",
     "crypt.md",
+    "combine.md",
     "compress.md",
     "dropbox.md",
     "filefabric.md",
@@ -66,6 +67,7 @@ docs = [
     "putio.md",
     "seafile.md",
     "sftp.md",
+    "smb.md",
     "storj.md",
     "sugarsync.md",
     "tardigrade.md",            # stub only to redirect to storj.md
@@ -89,7 +89,7 @@ var logHoppers = []string{
 	// Test case `extended-filenames` detected difference in order of files
 	// with extended unicode names between Windows and Unix or GDrive,
 	// but the order is in fact not important for success.
-	`(?:INFO  |NOTICE): - Path[12] +File (?:was deleted|is new|is newer|is OLDER) +- .*`,
+	`(?:INFO  |NOTICE): - Path[12] +File (?:was deleted|is new|is newer|is OLDER|size changed|checksum changed) +- .*`,
 
 	// Test case `check-access-filters` detected listing miscompares due
 	// to indeterminate order of rclone operations in presence of multiple
@@ -609,6 +609,8 @@ func (b *bisyncTest) runBisync(ctx context.Context, args []string) (err error) {
 		switch arg {
 		case "resync":
 			opt.Resync = true
+		case "recover":
+			opt.Recover = true
 		case "dry-run":
 			ci.DryRun = true
 			opt.DryRun = true
@@ -631,6 +633,14 @@ func (b *bisyncTest) runBisync(ctx context.Context, args []string) (err error) {
 			require.NoError(b.t, err, "parsing max-delete=%q", val)
 		case "size-only":
 			ci.SizeOnly = true
+		case "conflict-resolve":
+			err = opt.ConflictResolve.Set(val)
+			require.NoError(b.t, err, "parsing conflict-resolve=%q", val)
+		case "conflict-suffix":
+			opt.ConflictSuffix = val
+		case "compare":
+			err = opt.Compare.Set(val)
+			require.NoError(b.t, err, "parsing compare=%q", val)
 		case "subdir":
 			fs1 = addSubdir(b.path1, val)
 			fs2 = addSubdir(b.path2, val)
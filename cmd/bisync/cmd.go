@@ -29,6 +29,7 @@ import (
 // Options keep bisync options
 type Options struct {
 	Resync          bool
+	Recover         bool
 	CheckAccess     bool
 	CheckFilename   string
 	CheckSync       CheckSyncMode
@@ -40,12 +41,18 @@ type Options struct {
 	DryRun          bool
 	NoCleanup       bool
 	SaveQueues      bool // save extra debugging files (test only flag)
+	ConflictResolve ConflictResolveMode
+	ConflictSuffix  string // template for renaming conflicting files
+	Compare         CompareOpt
 }
 
 // Default values
 const (
 	DefaultMaxDelete     int    = 50
 	DefaultCheckFilename string = "RCLONE_TEST"
+	// DefaultConflictSuffix is used to rename both versions of a
+	// conflict if --conflict-suffix isn't set
+	DefaultConflictSuffix string = "..path{n}"
 )
 
 // DefaultWorkdir is default working directory
@@ -93,6 +100,122 @@ func (x *CheckSyncMode) Type() string {
 	return "string"
 }
 
+// ConflictResolveMode controls how files changed on both paths are resolved
+type ConflictResolveMode int
+
+// ConflictResolve modes
+const (
+	ConflictResolveNone    ConflictResolveMode = iota // Keep both versions renamed with the conflict suffix (default)
+	ConflictResolveNewer                              // The newer version wins
+	ConflictResolveOlder                              // The older version wins
+	ConflictResolveLarger                             // The larger version wins
+	ConflictResolveSmaller                            // The smaller version wins
+	ConflictResolvePath1                              // The Path1 version wins
+	ConflictResolvePath2                              // The Path2 version wins
+)
+
+func (x ConflictResolveMode) String() string {
+	switch x {
+	case ConflictResolveNone:
+		return "none"
+	case ConflictResolveNewer:
+		return "newer"
+	case ConflictResolveOlder:
+		return "older"
+	case ConflictResolveLarger:
+		return "larger"
+	case ConflictResolveSmaller:
+		return "smaller"
+	case ConflictResolvePath1:
+		return "path1"
+	case ConflictResolvePath2:
+		return "path2"
+	}
+	return "unknown"
+}
+
+// Set a ConflictResolve mode from a string
+func (x *ConflictResolveMode) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "none", "":
+		*x = ConflictResolveNone
+	case "newer":
+		*x = ConflictResolveNewer
+	case "older":
+		*x = ConflictResolveOlder
+	case "larger":
+		*x = ConflictResolveLarger
+	case "smaller":
+		*x = ConflictResolveSmaller
+	case "path1":
+		*x = ConflictResolvePath1
+	case "path2":
+		*x = ConflictResolvePath2
+	default:
+		return fmt.Errorf("unknown conflict-resolve mode for bisync: %q", s)
+	}
+	return nil
+}
+
+// Type of the ConflictResolve value
+func (x *ConflictResolveMode) Type() string {
+	return "string"
+}
+
+// CompareOpt selects how files are compared to detect changes
+type CompareOpt struct {
+	Size     bool
+	Modtime  bool
+	Checksum bool
+}
+
+// DefaultCompare is used if no comparison is set
+var DefaultCompare = CompareOpt{Size: true, Modtime: true}
+
+// IsZero returns true if no comparison is set
+func (x CompareOpt) IsZero() bool {
+	return !x.Size && !x.Modtime && !x.Checksum
+}
+
+func (x CompareOpt) String() string {
+	var out []string
+	if x.Size {
+		out = append(out, "size")
+	}
+	if x.Modtime {
+		out = append(out, "modtime")
+	}
+	if x.Checksum {
+		out = append(out, "checksum")
+	}
+	return strings.Join(out, ",")
+}
+
+// Set the comparisons from a comma separated list
+func (x *CompareOpt) Set(s string) error {
+	var c CompareOpt
+	for _, part := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "size":
+			c.Size = true
+		case "modtime":
+			c.Modtime = true
+		case "checksum":
+			c.Checksum = true
+		case "":
+		default:
+			return fmt.Errorf("unknown compare option for bisync: %q", part)
+		}
+	}
+	*x = c
+	return nil
+}
+
+// Type of the Compare value
+func (x *CompareOpt) Type() string {
+	return "string"
+}
+
 // Opt keeps command line options
 var Opt Options
 
@@ -100,6 +223,7 @@ func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &Opt.Resync, "resync", "1", Opt.Resync, "Performs the resync run. Path1 files may overwrite Path2 versions. Consider using --verbose or --dry-run first.")
+	flags.BoolVarP(cmdFlags, &Opt.Recover, "recover", "", Opt.Recover, "Rebuild safe listings after an interrupted run and continue without a full resync.")
 	flags.BoolVarP(cmdFlags, &Opt.CheckAccess, "check-access", "", Opt.CheckAccess, makeHelp("Ensure expected {CHECKFILE} files are found on both Path1 and Path2 filesystems, else abort."))
 	flags.StringVarP(cmdFlags, &Opt.CheckFilename, "check-filename", "", Opt.CheckFilename, makeHelp("Filename for --check-access (default: {CHECKFILE})"))
 	flags.BoolVarP(cmdFlags, &Opt.Force, "force", "", Opt.Force, "Bypass --max-delete safety check and run the sync. Consider using with --verbose")
@@ -109,6 +233,9 @@ func init() {
 	flags.StringVarP(cmdFlags, &Opt.Workdir, "workdir", "", Opt.Workdir, makeHelp("Use custom working dir - useful for testing. (default: {WORKDIR})"))
 	flags.BoolVarP(cmdFlags, &tzLocal, "localtime", "", tzLocal, "Use local time in listings (default: UTC)")
 	flags.BoolVarP(cmdFlags, &Opt.NoCleanup, "no-cleanup", "", Opt.NoCleanup, "Retain working files (useful for troubleshooting and testing).")
+	flags.FVarP(cmdFlags, &Opt.ConflictResolve, "conflict-resolve", "", "Automatically resolve files changed on both paths: none|newer|older|larger|smaller|path1|path2 (default: none)")
+	flags.FVarP(cmdFlags, &Opt.Compare, "compare", "", "Comma separated list of size|modtime|checksum used to detect changes (default: size,modtime)")
+	flags.StringVarP(cmdFlags, &Opt.ConflictSuffix, "conflict-suffix", "", Opt.ConflictSuffix, makeHelp("Suffix template for renamed conflicting files, {n} is the path number and {date} the date (default: {CONFLICTSUFFIX})"))
 }
 
 // bisync command definition
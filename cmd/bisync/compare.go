@@ -0,0 +1,64 @@
+package bisync
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// compareSide describes how changes are detected on one path
+type compareSide struct {
+	size    bool
+	modtime bool
+	hash    hash.Type // hash.None if checksums are not compared
+}
+
+// setupCompare works out how changes are detected on f according to
+// --compare falling back to checksums or sizes if the remote can't
+// do what was asked.
+//
+// If modification times were asked for but f doesn't support them
+// then checksums are used instead, unless f has no hashes or they
+// are slow to compute, in which case only sizes are compared.
+func setupCompare(ctx context.Context, f fs.Fs, opt CompareOpt, msg string) (c compareSide, err error) {
+	ci := fs.GetConfig(ctx)
+	c.size = opt.Size
+	c.modtime = opt.Modtime
+	useHash := opt.Checksum
+	if c.modtime && f.Precision() == fs.ModTimeNotSupported {
+		c.modtime = false
+		if f.Hashes().GetOne() != hash.None && !f.Features().SlowHash && !ci.IgnoreChecksum {
+			fs.Logf(nil, "%s doesn't support modification times, comparing checksums instead", msg)
+			useHash = true
+		} else {
+			fs.Logf(nil, "%s doesn't support modification times, comparing sizes only", msg)
+			c.size = true
+		}
+	}
+	if useHash {
+		if ci.IgnoreChecksum {
+			return c, errors.New("can't compare checksums with --ignore-checksum")
+		}
+		c.hash = f.Hashes().GetOne()
+		if c.hash == hash.None {
+			fs.Logf(nil, "%s doesn't support checksums, comparing sizes instead", msg)
+			c.size = true
+		}
+	}
+	if !c.size && !c.modtime && c.hash == hash.None {
+		c.size = true
+	}
+	return c, nil
+}
+
+// compareHashes returns true if the hashes of file in the listings can
+// be compared
+func compareHashes(ls1, ls2 *fileList, file string) bool {
+	if ls1.hash == hash.None || ls1.hash != ls2.hash {
+		return false
+	}
+	fi1, fi2 := ls1.get(file), ls2.get(file)
+	return fi1 != nil && fi2 != nil && fi1.hash != "" && fi2.hash != ""
+}
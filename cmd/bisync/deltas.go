@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/rclone/rclone/cmd/bisync/bilib"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/operations"
 )
 
@@ -28,7 +31,7 @@ const (
 
 const (
 	deltaModified delta = deltaNewer | deltaOlder | deltaSize | deltaHash | deltaDeleted
-	deltaOther    delta = deltaNew | deltaNewer | deltaOlder
+	deltaOther    delta = deltaNew | deltaNewer | deltaOlder | deltaSize | deltaHash
 )
 
 func (d delta) is(cond delta) bool {
@@ -45,6 +48,9 @@ type deltaSet struct {
 	deleted    int    // number of deleted files (for "excess deletes" check)
 	foundSame  bool   // true if found at least one unchanged file
 	checkFiles bilib.Names
+	current    map[string]*fileInfo // current info of new and changed files
+	old        *fileList            // prior listing updated as changes are applied
+	listing    string               // file name of the prior listing
 }
 
 func (ds *deltaSet) empty() bool {
@@ -91,7 +97,7 @@ func (ds *deltaSet) printStats() {
 }
 
 // findDeltas
-func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newListing, msg string) (ds *deltaSet, err error) {
+func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, cmp compareSide, oldListing, newListing, msg string) (ds *deltaSet, err error) {
 	var old, now *fileList
 
 	old, err = b.loadListing(oldListing)
@@ -119,6 +125,9 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 		oldCount:   len(old.list),
 		opt:        b.opt,
 		checkFiles: bilib.Names{},
+		current:    map[string]*fileInfo{},
+		old:        old,
+		listing:    oldListing,
 	}
 
 	for _, file := range old.list {
@@ -128,7 +137,7 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 			ds.deleted++
 			d |= deltaDeleted
 		} else {
-			if old.getTime(file) != now.getTime(file) {
+			if cmp.modtime && old.getTime(file) != now.getTime(file) {
 				if old.beforeOther(now, file) {
 					b.indent(msg, file, "File is newer")
 					d |= deltaNewer
@@ -137,11 +146,21 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 					d |= deltaOlder
 				}
 			}
-			// TODO Compare sizes and hashes
+			if d == deltaZero && cmp.size && old.get(file).size != now.get(file).size {
+				b.indent(msg, file, "File size changed")
+				d |= deltaSize
+			}
+			if d == deltaZero && cmp.hash != hash.None && compareHashes(old, now, file) && old.get(file).hash != now.get(file).hash {
+				b.indent(msg, file, "File checksum changed")
+				d |= deltaHash
+			}
 		}
 
 		if d.is(deltaModified) {
 			ds.deltas[file] = d
+			if !d.is(deltaDeleted) {
+				ds.current[file] = now.get(file)
+			}
 		} else {
 			// Once we've found at least one unchanged file,
 			// we know that not everything has changed,
@@ -154,6 +173,7 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 		if !old.has(file) {
 			b.indent(msg, file, "File is new")
 			ds.deltas[file] = deltaNew
+			ds.current[file] = now.get(file)
 		}
 	}
 
@@ -183,6 +203,10 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 
 	ctxMove := b.opt.setDryRun(ctx)
 
+	if err = b.checkpoint(ctx, ds1, ds2); err != nil {
+		return
+	}
+
 	for _, file := range ds1.sort() {
 		p1 := path1 + file
 		p2 := path2 + file
@@ -197,24 +221,14 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 				b.indent("Path1", p2, "Queue copy to Path2")
 				copy1to2.Add(file)
 				handled.Add(file)
+			} else if d2.is(deltaOther) && b.identical(file, ds1, ds2) {
+				b.indent("Path1", file, "Identical on both paths")
+				handled.Add(file)
 			} else if d2.is(deltaOther) {
 				b.indent("!WARNING", file, "New or changed in both paths")
-				b.indent("!Path1", p1+"..path1", "Renaming Path1 copy")
-				if err = operations.MoveFile(ctxMove, b.fs1, b.fs1, file+"..path1", file); err != nil {
-					err = fmt.Errorf("path1 rename failed for %s: %w", p1, err)
-					b.critical = true
+				if err = b.resolveConflict(ctxMove, file, ds1, ds2, copy1to2, copy2to1); err != nil {
 					return
 				}
-				b.indent("!Path1", p2+"..path1", "Queue copy to Path2")
-				copy1to2.Add(file + "..path1")
-
-				b.indent("!Path2", p2+"..path2", "Renaming Path2 copy")
-				if err = operations.MoveFile(ctxMove, b.fs2, b.fs2, file+"..path2", file); err != nil {
-					err = fmt.Errorf("path2 rename failed for %s: %w", file, err)
-					return
-				}
-				b.indent("!Path2", p1+"..path2", "Queue copy to Path1")
-				copy2to1.Add(file + "..path2")
 				handled.Add(file)
 			}
 		} else {
@@ -258,6 +272,10 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 		if err != nil {
 			return
 		}
+		checkpointCopied(ds2, ds1, copy2to1)
+		if err = b.checkpoint(ctx, ds1, ds2); err != nil {
+			return
+		}
 	}
 
 	if copy1to2.NotEmpty() {
@@ -267,6 +285,10 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 		if err != nil {
 			return
 		}
+		checkpointCopied(ds1, ds2, copy1to2)
+		if err = b.checkpoint(ctx, ds1, ds2); err != nil {
+			return
+		}
 	}
 
 	if delete1.NotEmpty() {
@@ -276,6 +298,10 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 		if err != nil {
 			return
 		}
+		checkpointDeleted(ds1, ds2, delete1)
+		if err = b.checkpoint(ctx, ds1, ds2); err != nil {
+			return
+		}
 	}
 
 	if delete2.NotEmpty() {
@@ -285,11 +311,160 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 		if err != nil {
 			return
 		}
+		checkpointDeleted(ds1, ds2, delete2)
+		if err = b.checkpoint(ctx, ds1, ds2); err != nil {
+			return
+		}
 	}
 
 	return
 }
 
+// identical returns true if a file new or changed on both paths has
+// the same contents on both going by size and checksum. This can only
+// be known if checksums of the same type are compared on both paths.
+func (b *bisyncRun) identical(file string, ds1, ds2 *deltaSet) bool {
+	if b.cmp1.hash == hash.None || b.cmp1.hash != b.cmp2.hash {
+		return false
+	}
+	info1, info2 := ds1.current[file], ds2.current[file]
+	if info1 == nil || info2 == nil || info1.hash == "" || info2.hash == "" {
+		return false
+	}
+	return info1.size == info2.size && info1.hash == info2.hash
+}
+
+// Conflict describes how a file new or changed on both paths was handled
+type Conflict struct {
+	Name    string   `json:"name"`              // path of the file relative to the roots
+	Winner  string   `json:"winner"`            // "path1", "path2" or "" if both versions were kept
+	Reason  string   `json:"reason"`            // why the winner was chosen or both were kept
+	Renamed []string `json:"renamed,omitempty"` // names the kept versions were renamed to
+}
+
+// conflictName returns the name to rename the version of a conflicting
+// file on path n to using the suffix template given
+func (b *bisyncRun) conflictName(file string, n int, suffix string) string {
+	replacer := strings.NewReplacer(
+		"{n}", strconv.Itoa(n),
+		"{date}", b.started.Format("2006-01-02"),
+	)
+	return file + replacer.Replace(suffix)
+}
+
+// pickWinner decides which version of a conflicting file wins
+// according to --conflict-resolve. It returns 1 or 2 for the winning
+// path or 0 if both versions should be kept.
+func (b *bisyncRun) pickWinner(file string, ds1, ds2 *deltaSet) (winner int, reason string) {
+	info1, info2 := ds1.current[file], ds2.current[file]
+	if info1 == nil || info2 == nil {
+		return 0, "missing file info"
+	}
+	// pick returns the winner given whether path1 and path2 win
+	pick := func(win1, win2 bool, why string) (int, string) {
+		switch {
+		case win1:
+			return 1, why
+		case win2:
+			return 2, why
+		}
+		return 0, "tie on " + b.opt.ConflictResolve.String()
+	}
+	switch b.opt.ConflictResolve {
+	case ConflictResolveNewer:
+		return pick(info1.time.After(info2.time), info2.time.After(info1.time), "newer")
+	case ConflictResolveOlder:
+		return pick(info1.time.Before(info2.time), info2.time.Before(info1.time), "older")
+	case ConflictResolveLarger:
+		return pick(info1.size > info2.size, info2.size > info1.size, "larger")
+	case ConflictResolveSmaller:
+		return pick(info1.size < info2.size, info2.size < info1.size, "smaller")
+	case ConflictResolvePath1:
+		return 1, "path1 preferred"
+	case ConflictResolvePath2:
+		return 2, "path2 preferred"
+	}
+	return 0, "no conflict resolution"
+}
+
+// resolveConflict handles a file which is new or changed on both
+// paths. If --conflict-resolve picks a winner it is queued to be
+// copied over the loser, which is kept renamed only if
+// --conflict-suffix is set. Otherwise both versions are renamed with
+// the conflict suffix and queued to be copied to the other path.
+func (b *bisyncRun) resolveConflict(ctx context.Context, file string, ds1, ds2 *deltaSet, copy1to2, copy2to1 bilib.Names) (err error) {
+	path1 := bilib.FsPath(b.fs1)
+	path2 := bilib.FsPath(b.fs2)
+	winner, reason := b.pickWinner(file, ds1, ds2)
+	conflict := Conflict{
+		Name:   file,
+		Reason: reason,
+	}
+
+	// rename moves the version on path n out of the way and queues
+	// it to be copied to the other path
+	suffix := b.opt.ConflictSuffix
+	rename := func(n int) error {
+		f, ds, from, to, queue := b.fs1, ds1, path1, path2, copy1to2
+		if n == 2 {
+			f, ds, from, to, queue = b.fs2, ds2, path2, path1, copy2to1
+		}
+		tag := "!Path" + strconv.Itoa(n)
+		newName := b.conflictName(file, n, suffix)
+		b.indentf(tag, from+newName, "Renaming Path%d copy", n)
+		if err := operations.MoveFile(ctx, f, f, newName, file); err != nil {
+			return fmt.Errorf("path%d rename failed for %s: %w", n, from+file, err)
+		}
+		b.indentf(tag, to+newName, "Queue copy to Path%d", 3-n)
+		queue.Add(newName)
+		// the renamed version keeps the attributes of the original
+		ds.current[newName] = ds.current[file]
+		conflict.Renamed = append(conflict.Renamed, newName)
+		return nil
+	}
+
+	keepLoser := b.opt.ConflictSuffix != ""
+	switch winner {
+	case 1:
+		conflict.Winner = "path1"
+		b.indentf("!Path1", path1+file, "Conflict winner (%s)", reason)
+		if keepLoser {
+			if err = rename(2); err != nil {
+				return err
+			}
+		}
+		b.indent("!Path1", path2+file, "Queue copy to Path2")
+		copy1to2.Add(file)
+	case 2:
+		conflict.Winner = "path2"
+		b.indentf("!Path2", path2+file, "Conflict winner (%s)", reason)
+		if keepLoser {
+			if err = rename(1); err != nil {
+				return err
+			}
+		}
+		b.indent("!Path2", path1+file, "Queue copy to Path1")
+		copy2to1.Add(file)
+	default:
+		if b.opt.ConflictResolve != ConflictResolveNone {
+			b.indentf("!WARNING", file, "Keeping both versions (%s)", reason)
+		}
+		if !strings.Contains(suffix, "{n}") {
+			// both versions need distinct names
+			suffix = DefaultConflictSuffix
+		}
+		if err = rename(1); err != nil {
+			b.critical = true
+			return err
+		}
+		if err = rename(2); err != nil {
+			return err
+		}
+	}
+	b.conflicts = append(b.conflicts, conflict)
+	return nil
+}
+
 // exccessDeletes checks whether number of deletes is within allowed range
 func (ds *deltaSet) excessDeletes() bool {
 	maxDelete := ds.opt.MaxDelete
@@ -10,6 +10,7 @@ func makeHelp(help string) string {
 		"|", "`",
 		"{MAXDELETE}", strconv.Itoa(DefaultMaxDelete),
 		"{CHECKFILE}", DefaultCheckFilename,
+		"{CONFLICTSUFFIX}", DefaultConflictSuffix,
 		"{WORKDIR}", DefaultWorkdir,
 	)
 	return replacer.Replace(help)
@@ -23,6 +24,7 @@ var rcHelp = makeHelp(`This takes the following parameters
 - path2 - a remote directory string e.g. |drive:path2|
 - dryRun - dry-run mode
 - resync - performs the resync run
+- recover - rebuild safe listings after an interrupted run and continue
 - checkAccess - abort if {CHECKFILE} files are not found on both filesystems
 - checkFilename - file name for checkAccess (default: {CHECKFILE})
 - maxDelete - abort sync if percentage of deleted files is above
@@ -34,6 +36,19 @@ var rcHelp = makeHelp(`This takes the following parameters
 - filtersFile - read filtering patterns from a file
 - workdir - server directory for history files (default: {WORKDIR})
 - noCleanup - retain working files
+- compare - comma separated list of |size|, |modtime| and |checksum|
+  used to detect changes (default: |size,modtime|)
+- conflictResolve - resolve files changed on both paths automatically:
+  |none| (default), |newer|, |older|, |larger|, |smaller|, |path1| or |path2|
+- conflictSuffix - suffix template for renamed conflicting files
+  (default: |{CONFLICTSUFFIX}| when both versions are kept). If a winner is
+  picked the losing version is only kept if this is set.
+
+The output contains the log of the run in |output| and a list of
+the files changed on both paths in |conflicts|, each with the
+|name| of the file, the |winner| (|path1|, |path2| or empty if
+both versions were kept), the |reason| and the names any versions
+were |renamed| to.
 
 See [bisync command help](https://rclone.org/commands/rclone_bisync/)
 and [full bisync description](https://rclone.org/bisync/)
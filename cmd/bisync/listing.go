@@ -8,12 +8,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/rclone/rclone/cmd/bisync/bilib"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/walk"
@@ -81,6 +83,8 @@ func (ls *fileList) put(file string, size int64, time time.Time, hash, id string
 	if fi != nil {
 		fi.size = size
 		fi.time = time
+		fi.hash = hash
+		fi.id = id
 	} else {
 		fi = &fileInfo{
 			size: size,
@@ -93,6 +97,22 @@ func (ls *fileList) put(file string, size int64, time time.Time, hash, id string
 	}
 }
 
+// remove drops the files from the listing
+func (ls *fileList) remove(files bilib.Names) {
+	if !files.NotEmpty() {
+		return
+	}
+	list := ls.list[:0]
+	for _, file := range ls.list {
+		if files.Has(file) {
+			delete(ls.info, file)
+		} else {
+			list = append(list, file)
+		}
+	}
+	ls.list = list
+}
+
 func (ls *fileList) getTime(file string) time.Time {
 	fi := ls.get(file)
 	if fi == nil {
@@ -119,11 +139,24 @@ func (ls *fileList) afterTime(file string, time time.Time) bool {
 }
 
 // save will save listing to a file.
-func (ls *fileList) save(ctx context.Context, listing string) error {
-	file, err := os.Create(listing)
+//
+// The listing is written to a temporary file which is renamed over
+// the listing when complete, so an interrupted save never leaves a
+// truncated listing behind.
+func (ls *fileList) save(ctx context.Context, listing string) (err error) {
+	tmpListing := listing + ".tmp"
+	file, err := os.Create(tmpListing)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err == nil {
+			err = os.Rename(tmpListing, listing)
+		}
+		if err != nil {
+			_ = os.Remove(tmpListing)
+		}
+	}()
 
 	hashName := ""
 	if ls.hash != hash.None {
@@ -133,7 +166,6 @@ func (ls *fileList) save(ctx context.Context, listing string) error {
 	_, err = fmt.Fprintf(file, "%s %s\n", ListingHeader, time.Now().In(TZ).Format(timeFormat))
 	if err != nil {
 		_ = file.Close()
-		_ = os.Remove(listing)
 		return err
 	}
 
@@ -156,7 +188,6 @@ func (ls *fileList) save(ctx context.Context, listing string) error {
 		_, err = fmt.Fprintf(file, lineFormat, flags, fi.size, hash, id, time, remote)
 		if err != nil {
 			_ = file.Close()
-			_ = os.Remove(listing)
 			return err
 		}
 	}
@@ -302,3 +333,169 @@ func (b *bisyncRun) checkListing(ls *fileList, listing, msg string) error {
 	b.critical = true
 	return fmt.Errorf("empty %s listing: %s", msg, listing)
 }
+
+// checkpointSuffix is appended to a listing name for its checkpoint
+const checkpointSuffix = "-chk"
+
+// checkpoint saves the prior listings updated with the changes applied
+// so far, so an interrupted run can be recovered with --recover
+// rather than a full --resync.
+func (b *bisyncRun) checkpoint(ctx context.Context, ds1, ds2 *deltaSet) error {
+	if b.opt.DryRun {
+		return nil
+	}
+	if err := ds1.old.save(ctx, ds1.listing+checkpointSuffix); err != nil {
+		return fmt.Errorf("failed to save Path1 checkpoint: %w", err)
+	}
+	if err := ds2.old.save(ctx, ds2.listing+checkpointSuffix); err != nil {
+		return fmt.Errorf("failed to save Path2 checkpoint: %w", err)
+	}
+	return nil
+}
+
+// checkpointCopied updates the prior listings for files copied from
+// the src to the dst deltaSet
+func checkpointCopied(src, dst *deltaSet, files bilib.Names) {
+	for file := range files {
+		fi := src.current[file]
+		if fi == nil {
+			continue
+		}
+		src.old.put(file, fi.size, fi.time, fi.hash, fi.id)
+		hashVal := fi.hash
+		if src.old.hash != dst.old.hash {
+			hashVal = ""
+		}
+		dst.old.put(file, fi.size, fi.time, hashVal, fi.id)
+	}
+}
+
+// checkpointDeleted updates the prior listings for deleted files
+func checkpointDeleted(ds1, ds2 *deltaSet, files bilib.Names) {
+	ds1.old.remove(files)
+	ds2.old.remove(files)
+}
+
+// removeCheckpoints removes the checkpoints of a finished run
+func removeCheckpoints(listings ...string) {
+	for _, listing := range listings {
+		_ = os.Remove(listing + checkpointSuffix)
+	}
+}
+
+// hasCheckpoint returns true if any of the listings has a checkpoint
+// left by an interrupted run
+func hasCheckpoint(listings ...string) bool {
+	for _, listing := range listings {
+		if bilib.FileExists(listing + checkpointSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPriorListing loads the most recent prior state of a listing to
+// recover from, trying the checkpoint, the listing itself and then
+// the listing saved by a failed run in turn.
+func (b *bisyncRun) loadPriorListing(listing string) (*fileList, error) {
+	for _, name := range []string{listing + checkpointSuffix, listing, listing + "-err"} {
+		if bilib.FileExists(name) {
+			fs.Infof(nil, "Recovering from %s", filepath.Base(name))
+			return b.loadListing(name)
+		}
+	}
+	return nil, fmt.Errorf("no prior listing to recover from (must run --resync): %s", listing)
+}
+
+// sameFile returns true if file is the same in both listings going by
+// size, modification time within window if useTime is set and hash
+// if both have one.
+func sameFile(ls1, ls2 *fileList, file string, window time.Duration, useTime bool) bool {
+	fi1, fi2 := ls1.get(file), ls2.get(file)
+	if fi1 == nil || fi2 == nil || fi1.size != fi2.size {
+		return false
+	}
+	if useTime {
+		dt := fi1.time.Sub(fi2.time)
+		if dt < -window || dt > window {
+			return false
+		}
+	}
+	if compareHashes(ls1, ls2, file) {
+		return fi1.hash == fi2.hash
+	}
+	return useTime
+}
+
+// recoverListings implements the --recover mode.
+//
+// It rebuilds safe prior listings after an interrupted run. Files
+// which are the same on both paths now are taken as in sync and
+// everything else keeps its prior state, so a normal run afterwards
+// will propagate outstanding changes and treat files copied by the
+// interrupted run as unchanged rather than as conflicts.
+func (b *bisyncRun) recoverListings(fctx context.Context, priorListing1, priorListing2, listing1, listing2 string) error {
+	fs.Infof(nil, "Recovering listings of interrupted run")
+	prior1, err := b.loadPriorListing(priorListing1)
+	if err != nil {
+		return err
+	}
+	prior2, err := b.loadPriorListing(priorListing2)
+	if err != nil {
+		return err
+	}
+
+	now1, err := b.makeListing(fctx, b.fs1, listing1+"-new")
+	if err != nil {
+		return err
+	}
+	now2, err := b.makeListing(fctx, b.fs2, listing2+"-new")
+	if err != nil {
+		return err
+	}
+
+	window := fs.GetModifyWindow(fctx, b.fs1, b.fs2)
+	rec1, rec2 := newFileList(), newFileList()
+	rec1.hash, rec2.hash = now1.hash, now2.hash
+	synced := 0
+	for _, file := range now1.list {
+		if sameFile(now1, now2, file, window, b.cmp1.modtime && b.cmp2.modtime) {
+			fi1, fi2 := now1.get(file), now2.get(file)
+			rec1.put(file, fi1.size, fi1.time, fi1.hash, fi1.id)
+			rec2.put(file, fi2.size, fi2.time, fi2.hash, fi2.id)
+			synced++
+		}
+	}
+
+	// addPrior adds the prior state of files not in sync
+	addPrior := func(rec, prior *fileList) {
+		for _, file := range prior.list {
+			if rec.has(file) {
+				continue
+			}
+			fi := prior.get(file)
+			hashVal := fi.hash
+			if prior.hash != rec.hash {
+				hashVal = ""
+			}
+			rec.put(file, fi.size, fi.time, hashVal, fi.id)
+		}
+	}
+	addPrior(rec1, prior1)
+	addPrior(rec2, prior2)
+	fs.Infof(nil, "Recovered listings with %d files in sync on both paths", synced)
+
+	if err = rec1.save(fctx, listing1); err != nil {
+		return err
+	}
+	if err = rec2.save(fctx, listing2); err != nil {
+		return err
+	}
+	if !b.opt.DryRun {
+		for _, listing := range []string{priorListing1, priorListing2} {
+			_ = os.Remove(listing + "-err")
+		}
+		removeCheckpoints(priorListing1, priorListing2)
+	}
+	return nil
+}
@@ -11,7 +11,9 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	gosync "sync"
+	"time"
 
 	"github.com/rclone/rclone/cmd/bisync/bilib"
 	"github.com/rclone/rclone/fs"
@@ -26,22 +28,33 @@ var ErrBisyncAborted = errors.New("bisync aborted")
 
 // bisyncRun keeps bisync runtime state
 type bisyncRun struct {
-	fs1      fs.Fs
-	fs2      fs.Fs
-	abort    bool
-	critical bool
-	basePath string
-	workDir  string
-	opt      *Options
+	fs1       fs.Fs
+	fs2       fs.Fs
+	abort     bool
+	critical  bool
+	basePath  string
+	workDir   string
+	opt       *Options
+	started   time.Time   // when the run started
+	conflicts []Conflict  // files changed on both paths
+	cmp1      compareSide // how changes are detected on path1
+	cmp2      compareSide // how changes are detected on path2
 }
 
 // Bisync handles lock file, performs bisync run and checks exit status
 func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
+	_, err = bisync(ctx, fs1, fs2, optArg)
+	return err
+}
+
+// bisync does the work of Bisync also returning the conflicts found
+func bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (conflicts []Conflict, err error) {
 	opt := *optArg // ensure that input is never changed
 	b := &bisyncRun{
-		fs1: fs1,
-		fs2: fs2,
-		opt: &opt,
+		fs1:     fs1,
+		fs2:     fs2,
+		opt:     &opt,
+		started: time.Now(),
 	}
 
 	if opt.CheckFilename == "" {
@@ -50,21 +63,34 @@ func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
 	if opt.Workdir == "" {
 		opt.Workdir = DefaultWorkdir
 	}
+	if opt.Resync && opt.Recover {
+		return nil, errors.New("--resync and --recover can't be used together")
+	}
+	if opt.ConflictResolve == ConflictResolveNone && opt.ConflictSuffix == "" {
+		opt.ConflictSuffix = DefaultConflictSuffix
+	}
+	if opt.ConflictResolve == ConflictResolveNone && !strings.Contains(opt.ConflictSuffix, "{n}") {
+		return nil, errors.New("conflict suffix must contain {n} to keep both versions of a conflict")
+	}
+	if strings.ContainsAny(opt.ConflictSuffix, "/\\") {
+		return nil, errors.New("conflict suffix must not contain path separators")
+	}
 
-	if !opt.DryRun && !opt.Force {
-		if fs1.Precision() == fs.ModTimeNotSupported {
-			return errors.New("modification time support is missing on path1")
-		}
-		if fs2.Precision() == fs.ModTimeNotSupported {
-			return errors.New("modification time support is missing on path2")
-		}
+	if opt.Compare.IsZero() {
+		opt.Compare = DefaultCompare
+	}
+	if b.cmp1, err = setupCompare(ctx, fs1, opt.Compare, "Path1"); err != nil {
+		return nil, err
+	}
+	if b.cmp2, err = setupCompare(ctx, fs2, opt.Compare, "Path2"); err != nil {
+		return nil, err
 	}
 
 	if b.workDir, err = filepath.Abs(opt.Workdir); err != nil {
-		return fmt.Errorf("failed to make workdir absolute: %w", err)
+		return nil, fmt.Errorf("failed to make workdir absolute: %w", err)
 	}
 	if err = os.MkdirAll(b.workDir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create workdir: %w", err)
+		return nil, fmt.Errorf("failed to create workdir: %w", err)
 	}
 
 	// Produce a unique name for the sync operation
@@ -77,12 +103,12 @@ func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
 	if !opt.DryRun {
 		lockFile = b.basePath + ".lck"
 		if bilib.FileExists(lockFile) {
-			return fmt.Errorf("prior lock file found: %s", lockFile)
+			return nil, fmt.Errorf("prior lock file found: %s", lockFile)
 		}
 
 		pidStr := []byte(strconv.Itoa(os.Getpid()))
 		if err = ioutil.WriteFile(lockFile, pidStr, bilib.PermSecure); err != nil {
-			return fmt.Errorf("cannot create lock file: %s: %w", lockFile, err)
+			return nil, fmt.Errorf("cannot create lock file: %s: %w", lockFile, err)
 		}
 		fs.Debugf(nil, "Lock file created: %s", lockFile)
 	}
@@ -99,7 +125,7 @@ func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
 	finalise := func() {
 		finaliseOnce.Do(func() {
 			if atexit.Signalled() {
-				fs.Logf(nil, "Bisync interrupted. Must run --resync to recover.")
+				fs.Logf(nil, "Bisync interrupted. Must run --recover or --resync to recover.")
 				markFailed(listing1)
 				markFailed(listing2)
 				_ = os.Remove(lockFile)
@@ -132,7 +158,7 @@ func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
 		}
 		fs.Errorf(nil, "Bisync critical error: %v", err)
 		fs.Errorf(nil, "Bisync aborted. Must run --resync to recover.")
-		return ErrBisyncAborted
+		return b.conflicts, ErrBisyncAborted
 	}
 	if b.abort {
 		fs.Logf(nil, "Bisync aborted. Please try again.")
@@ -140,7 +166,7 @@ func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
 	if err == nil {
 		fs.Infof(nil, "Bisync successful")
 	}
-	return err
+	return b.conflicts, err
 }
 
 // runLocked performs a full bisync run
@@ -159,10 +185,10 @@ func (b *bisyncRun) runLocked(octx context.Context, listing1, listing2 string) (
 
 	fs.Infof(nil, "Synching Path1 %s with Path2 %s", quotePath(path1), quotePath(path2))
 
+	origListing1 := listing1
+	origListing2 := listing2
 	if opt.DryRun {
 		// In --dry-run mode, preserve original listings and save updates to the .lst-dry files
-		origListing1 := listing1
-		origListing2 := listing2
 		listing1 += "-dry"
 		listing2 += "-dry"
 		if err := bilib.CopyFileIfExists(origListing1, listing1); err != nil {
@@ -185,6 +211,16 @@ func (b *bisyncRun) runLocked(octx context.Context, listing1, listing2 string) (
 		return b.resync(octx, fctx, listing1, listing2)
 	}
 
+	// Rebuild the prior listings after an interrupted run
+	if opt.Recover {
+		if err = b.recoverListings(fctx, origListing1, origListing2, listing1, listing2); err != nil {
+			b.critical = true
+			return err
+		}
+	} else if hasCheckpoint(origListing1, origListing2) {
+		return errors.New("found checkpoint of an interrupted run, must run --recover or --resync to recover")
+	}
+
 	// Check for existence of prior Path1 and Path2 listings
 	if !bilib.FileExists(listing1) || !bilib.FileExists(listing2) {
 		// On prior critical error abort, the prior listings are renamed to .lst-err to lock out further runs
@@ -195,7 +231,7 @@ func (b *bisyncRun) runLocked(octx context.Context, listing1, listing2 string) (
 	// Check for Path1 deltas relative to the prior sync
 	fs.Infof(nil, "Path1 checking for diffs")
 	newListing1 := listing1 + "-new"
-	ds1, err := b.findDeltas(fctx, b.fs1, listing1, newListing1, "Path1")
+	ds1, err := b.findDeltas(fctx, b.fs1, b.cmp1, listing1, newListing1, "Path1")
 	if err != nil {
 		return err
 	}
@@ -204,7 +240,7 @@ func (b *bisyncRun) runLocked(octx context.Context, listing1, listing2 string) (
 	// Check for Path2 deltas relative to the prior sync
 	fs.Infof(nil, "Path2 checking for diffs")
 	newListing2 := listing2 + "-new"
-	ds2, err := b.findDeltas(fctx, b.fs2, listing2, newListing2, "Path2")
+	ds2, err := b.findDeltas(fctx, b.fs2, b.cmp2, listing2, newListing2, "Path2")
 	if err != nil {
 		return err
 	}
@@ -291,6 +327,7 @@ func (b *bisyncRun) runLocked(octx context.Context, listing1, listing2 string) (
 		_ = os.Remove(newListing1)
 		_ = os.Remove(newListing2)
 	}
+	removeCheckpoints(listing1, listing2)
 
 	if opt.CheckSync == CheckSyncTrue && !opt.DryRun {
 		fs.Infof(nil, "Validating listings for Path1 %s vs Path2 %s", quotePath(path1), quotePath(path2))
@@ -383,6 +420,7 @@ func (b *bisyncRun) resync(octx, fctx context.Context, listing1, listing2 string
 		b.critical = true
 		return err
 	}
+	removeCheckpoints(listing1, listing2)
 
 	if !b.opt.NoCleanup {
 		_ = os.Remove(newListing1)
@@ -42,6 +42,9 @@ func rcBisync(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	if opt.Resync, err = in.GetBool("resync"); rc.NotErrParamNotFound(err) {
 		return
 	}
+	if opt.Recover, err = in.GetBool("recover"); rc.NotErrParamNotFound(err) {
+		return
+	}
 	if opt.CheckAccess, err = in.GetBool("checkAccess"); rc.NotErrParamNotFound(err) {
 		return
 	}
@@ -64,6 +67,25 @@ func rcBisync(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	if opt.Workdir, err = in.GetString("workdir"); rc.NotErrParamNotFound(err) {
 		return
 	}
+	if opt.ConflictSuffix, err = in.GetString("conflictSuffix"); rc.NotErrParamNotFound(err) {
+		return
+	}
+
+	conflictResolve, err := in.GetString("conflictResolve")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if err := opt.ConflictResolve.Set(conflictResolve); err != nil {
+		return nil, rc.NewErrParamInvalid(err)
+	}
+
+	compare, err := in.GetString("compare")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if err := opt.Compare.Set(compare); err != nil {
+		return nil, rc.NewErrParamInvalid(err)
+	}
 
 	checkSync, err := in.GetString("checkSync")
 	if rc.NotErrParamNotFound(err) {
@@ -83,9 +105,13 @@ func rcBisync(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 		return nil, err
 	}
 
+	var conflicts []Conflict
 	output := bilib.CaptureOutput(func() {
-		err = Bisync(octx, fs1, fs2, opt)
+		conflicts, err = bisync(octx, fs1, fs2, opt)
 	})
 	_, _ = log.Writer().Write(output)
-	return rc.Params{"output": string(output)}, err
+	if conflicts == nil {
+		conflicts = []Conflict{}
+	}
+	return rc.Params{"output": string(output), "conflicts": conflicts}, err
 }
@@ -36,6 +36,7 @@ import (
 	"github.com/rclone/rclone/fs/rc/rcflags"
 	"github.com/rclone/rclone/fs/rc/rcserver"
 	"github.com/rclone/rclone/lib/atexit"
+	"github.com/rclone/rclone/lib/audit"
 	"github.com/rclone/rclone/lib/buildinfo"
 	"github.com/rclone/rclone/lib/exitcode"
 	"github.com/rclone/rclone/lib/random"
@@ -412,6 +413,13 @@ func initConfig() {
 		fs.Debugf("rclone", "systemd logging support activated")
 	}
 
+	// Start the audit log if configured
+	err = audit.Start(&audit.Opt)
+	if err != nil {
+		log.Fatalf("Failed to start audit log: %v", err)
+	}
+	atexit.Register(audit.Stop)
+
 	// Start the remote control server if configured
 	_, err = rcserver.Start(context.Background(), &rcflags.Opt)
 	if err != nil {
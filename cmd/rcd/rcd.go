@@ -7,14 +7,20 @@ import (
 
 	sysdnotify "github.com/iguanesolutions/go-systemd/v5/notify"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/rc/rcflags"
 	"github.com/rclone/rclone/fs/rc/rcserver"
+	"github.com/rclone/rclone/fs/rc/schedule"
 	"github.com/rclone/rclone/lib/atexit"
 	"github.com/spf13/cobra"
 )
 
+var scheduleFile string
+
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.StringVarP(cmdFlags, &scheduleFile, "schedule", "", scheduleFile, "JSON file of rc calls to run on a schedule")
 }
 
 var commandDefinition = &cobra.Command{
@@ -29,6 +35,25 @@ If you pass in a path to a directory, rclone will serve that directory
 for GET requests on the URL passed in.  It will also open the URL in
 the browser when rclone is run.
 
+If ` + "`--schedule`" + ` is given, rclone loads a list of rc calls to
+run on a schedule from that JSON file, for example to run a sync
+every night at 02:00:
+
+    [
+        {
+            "name": "nightly",
+            "schedule": "0 2 * * *",
+            "path": "sync/sync",
+            "params": {"srcFs": "src:", "dstFs": "dst:"},
+            "overlap": "skip"
+        }
+    ]
+
+Each run is started as an async job so shows up in ` + "`job/list`" + `.
+Schedules can be changed with the ` + "`schedule/add`" + `,
+` + "`schedule/list`" + ` and ` + "`schedule/remove`" + ` rc calls, which
+also update the file.
+
 See the [rc documentation](/rc/) for more info on the rc flags.
 `,
 	Run: func(command *cobra.Command, args []string) {
@@ -51,6 +76,12 @@ See the [rc documentation](/rc/) for more info on the rc flags.
 			log.Fatal("rc server not configured")
 		}
 
+		if scheduleFile != "" {
+			if err := schedule.Load(context.Background(), scheduleFile); err != nil {
+				log.Fatalf("Failed to load schedules: %v", err)
+			}
+		}
+
 		// Notify stopping on exit
 		var finaliseOnce sync.Once
 		finalise := func() {
@@ -25,23 +25,27 @@ import (
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/audit"
 	"github.com/rclone/rclone/vfs"
 	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	ftp "goftp.io/server/core"
+	"golang.org/x/time/rate"
 )
 
 // Options contains options for the http Server
 type Options struct {
 	//TODO add more options
-	ListenAddr   string // Port to listen on
-	PublicIP     string // Passive ports range
-	PassivePorts string // Passive ports range
-	BasicUser    string // single username for basic auth if not using Htpasswd
-	BasicPass    string // password for BasicUser
-	TLSCert      string // TLS PEM key (concatenation of certificate and CA certificate)
-	TLSKey       string // TLS PEM Private key
+	ListenAddr   string        // Port to listen on
+	PublicIP     string        // Passive ports range
+	PassivePorts string        // Passive ports range
+	BasicUser    string        // single username for basic auth if not using Htpasswd
+	BasicPass    string        // password for BasicUser
+	TLSCert      string        // TLS PEM key (concatenation of certificate and CA certificate)
+	TLSKey       string        // TLS PEM Private key
+	ExplicitTLS  bool          // use explicit FTPS instead of implicit
+	ConnBwLimit  fs.SizeSuffix // bandwidth limit for each connection in each direction
 }
 
 // DefaultOpt is the default values used for Options
@@ -51,6 +55,7 @@ var DefaultOpt = Options{
 	PassivePorts: "30000-32000",
 	BasicUser:    "anonymous",
 	BasicPass:    "",
+	ConnBwLimit:  -1,
 }
 
 // Opt is options set by command line flags
@@ -66,6 +71,8 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.StringVarP(flagSet, &Opt.BasicPass, "pass", "", Opt.BasicPass, "Password for authentication (empty value allow every password)")
 	flags.StringVarP(flagSet, &Opt.TLSCert, "cert", "", Opt.TLSCert, "TLS PEM key (concatenation of certificate and CA certificate)")
 	flags.StringVarP(flagSet, &Opt.TLSKey, "key", "", Opt.TLSKey, "TLS PEM Private key")
+	flags.BoolVarP(flagSet, &Opt.ExplicitTLS, "explicit-tls", "", Opt.ExplicitTLS, "Use explicit FTPS where connections are upgraded with AUTH TLS instead of implicit FTPS")
+	flags.FVarP(flagSet, &Opt.ConnBwLimit, "conn-bwlimit", "", "Bandwidth limit for each connection in each direction, e.g. 1M")
 }
 
 func init() {
@@ -98,6 +105,31 @@ then using Authentication is advised - see the next section for info.
 By default this will serve files without needing a login.
 
 You can set a single username and password with the --user and --pass flags.
+
+#### TLS
+
+Use --cert and --key to serve FTPS. This is implicit FTPS where
+connections use TLS from the start, as needed by some older clients
+and devices. These usually expect the server on port 990 so you will
+probably want --addr :990 too.
+
+Use --explicit-tls to serve explicit FTPS instead, where clients
+connect without TLS then upgrade the connection with the AUTH TLS
+command.
+
+#### Bandwidth limits
+
+Use --conn-bwlimit to limit the bandwidth of each connection in each
+direction, e.g. --conn-bwlimit 1M. This is applied on top of the
+global --bwlimit. When using --auth-proxy the proxy can set a
+different limit for each user with _bwlimit.
+
+#### Limitations
+
+Passive ports can't be allocated per user as the ftp library used
+doesn't allow it, so all users share the ports given by
+--passive-port. FTP has no public key authentication so --auth-proxy
+is only called with a password.
 ` + vfs.Help + proxy.Help,
 	Run: func(command *cobra.Command, args []string) {
 		var f fs.Fs
@@ -125,6 +157,7 @@ type server struct {
 	opt    Options
 	vfs    *vfs.VFS
 	proxy  *proxy.Proxy
+	access *proxy.AccessRules // access restrictions for users - may be nil
 	useTLS bool
 }
 
@@ -139,6 +172,10 @@ func newServer(ctx context.Context, f fs.Fs, opt *Options) (*server, error) {
 		return nil, errors.New("Failed to parse host:port")
 	}
 
+	if opt.ExplicitTLS && opt.TLSKey == "" {
+		return nil, errors.New("--explicit-tls needs --cert and --key")
+	}
+
 	s := &server{
 		f:   f,
 		ctx: ctx,
@@ -149,6 +186,13 @@ func newServer(ctx context.Context, f fs.Fs, opt *Options) (*server, error) {
 	} else {
 		s.vfs = vfs.New(f, &vfsflags.Opt)
 	}
+	s.access, err = proxy.NewAccessRules(&proxyflags.Opt)
+	if err != nil {
+		return nil, err
+	}
+	if s.proxy != nil {
+		s.proxy.SetAccessRules(s.access)
+	}
 	s.useTLS = s.opt.TLSKey != ""
 
 	ftpopt := &ftp.ServerOpts{
@@ -164,6 +208,7 @@ func newServer(ctx context.Context, f fs.Fs, opt *Options) (*server, error) {
 		TLS:            s.useTLS,
 		CertFile:       s.opt.TLSCert,
 		KeyFile:        s.opt.TLSKey,
+		ExplicitFTPS:   s.useTLS && s.opt.ExplicitTLS,
 		//TODO implement a maximum of https://godoc.org/goftp.io/server#ServerOpts
 	}
 	s.srv = ftp.NewServer(ftpopt)
@@ -230,29 +275,46 @@ func (s *server) NewDriver() (ftp.Driver, error) {
 
 //Driver implementation of ftp server
 type Driver struct {
-	s    *server
-	vfs  *vfs.VFS
-	lock sync.Mutex
+	s            *server
+	vfs          *vfs.VFS
+	auditor      *vfs.Auditor  // nil unless the audit log is in use
+	readLimiter  *rate.Limiter // limits downloads - nil for no limit
+	writeLimiter *rate.Limiter // limits uploads - nil for no limit
+	lock         sync.Mutex
 }
 
 // CheckPasswd handle auth based on configuration
 func (d *Driver) CheckPasswd(user, pass string) (ok bool, err error) {
 	s := d.s
+	bwlimit := s.opt.ConnBwLimit
 	if s.proxy != nil {
 		var VFS *vfs.VFS
-		VFS, _, err = s.proxy.Call(user, pass, false)
+		var userBwLimit fs.SizeSuffix
+		VFS, userBwLimit, err = s.proxy.CallBwLimit(user, pass, false)
 		if err != nil {
 			fs.Infof(nil, "proxy login failed: %v", err)
 			return false, nil
 		}
 		d.vfs = VFS
+		if userBwLimit >= 0 {
+			bwlimit = userBwLimit
+		}
 	} else {
 		ok = s.opt.BasicUser == user && (s.opt.BasicPass == "" || s.opt.BasicPass == pass)
 		if !ok {
 			fs.Infof(nil, "login failed: bad credentials")
 			return false, nil
 		}
+		if s.access != nil {
+			d.vfs = s.access.VFS(s.f, user)
+		}
 	}
+	if bwlimit > 0 {
+		d.readLimiter = newBwLimiter(bwlimit)
+		d.writeLimiter = newBwLimiter(bwlimit)
+	}
+	// the ftp library doesn't tell the driver the client address
+	d.auditor = vfs.NewAuditor("ftp", user, "")
 	return true, nil
 }
 
@@ -330,6 +392,7 @@ func (d *Driver) DeleteDir(path string) (err error) {
 		return errors.New("Not a directory")
 	}
 	err = node.Remove()
+	d.auditor.Log(audit.OpDelete, path, err)
 	if err != nil {
 		return err
 	}
@@ -349,6 +412,7 @@ func (d *Driver) DeleteFile(path string) (err error) {
 		return errors.New("Not a file")
 	}
 	err = node.Remove()
+	d.auditor.Log(audit.OpDelete, path, err)
 	if err != nil {
 		return err
 	}
@@ -360,7 +424,9 @@ func (d *Driver) Rename(oldName, newName string) (err error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(oldName, "newName=%q", newName)("err = %v", &err)
-	return d.vfs.Rename(oldName, newName)
+	err = d.vfs.Rename(oldName, newName)
+	d.auditor.Rename(oldName, newName, err)
+	return err
 }
 
 //MakeDir create a folder
@@ -373,6 +439,7 @@ func (d *Driver) MakeDir(path string) (err error) {
 		return err
 	}
 	_, err = dir.Mkdir(leaf)
+	d.auditor.Log(audit.OpMkdir, path, err)
 	return err
 }
 
@@ -393,6 +460,7 @@ func (d *Driver) GetFile(path string, offset int64) (size int64, fr io.ReadClose
 	}
 
 	handle, err := node.Open(os.O_RDONLY)
+	handle = d.auditor.Open(path, os.O_RDONLY, handle, err)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -405,6 +473,12 @@ func (d *Driver) GetFile(path string, offset int64) (size int64, fr io.ReadClose
 	tr := accounting.GlobalStats().NewTransferRemoteSize(path, node.Size())
 	defer tr.Done(d.s.ctx, nil)
 
+	if d.readLimiter != nil {
+		return node.Size(), &limitedReadCloser{
+			limitedReader: limitedReader{Reader: handle, ctx: d.s.ctx, limiter: d.readLimiter},
+			Closer:        handle,
+		}, nil
+	}
 	return node.Size(), handle, nil
 }
 
@@ -413,6 +487,9 @@ func (d *Driver) PutFile(path string, data io.Reader, appendData bool) (n int64,
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(path, "append=%v", appendData)("err = %v", &err)
+	if d.writeLimiter != nil {
+		data = &limitedReader{Reader: data, ctx: d.s.ctx, limiter: d.writeLimiter}
+	}
 	var isExist bool
 	node, err := d.vfs.Stat(path)
 	if err == nil {
@@ -440,6 +517,7 @@ func (d *Driver) PutFile(path string, data io.Reader, appendData bool) (n int64,
 			}
 		}
 		f, err := d.vfs.OpenFile(path, os.O_RDWR|os.O_CREATE, 0660)
+		f = d.auditor.Open(path, os.O_RDWR, f, err)
 		if err != nil {
 			return 0, err
 		}
@@ -452,6 +530,7 @@ func (d *Driver) PutFile(path string, data io.Reader, appendData bool) (n int64,
 	}
 
 	of, err := d.vfs.OpenFile(path, os.O_APPEND|os.O_RDWR, 0660)
+	of = d.auditor.Open(path, os.O_RDWR, of, err)
 	if err != nil {
 		return 0, err
 	}
@@ -515,3 +594,40 @@ func closeIO(path string, c io.Closer) {
 		log.Trace(path, "")("err = %v", &err)
 	}
 }
+
+// bwLimitBurst is the most bytes transferred at once when a
+// bandwidth limit is in use
+const bwLimitBurst = 64 * 1024
+
+// newBwLimiter makes a limiter for bwlimit bytes per second
+func newBwLimiter(bwlimit fs.SizeSuffix) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(bwlimit), bwLimitBurst)
+}
+
+// limitedReader limits the rate data can be read from the Reader
+type limitedReader struct {
+	io.Reader
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+// Read data then wait until the limit allows it
+func (r *limitedReader) Read(p []byte) (n int, err error) {
+	if len(p) > bwLimitBurst {
+		p = p[:bwLimitBurst]
+	}
+	n, err = r.Reader.Read(p)
+	if n > 0 {
+		waitErr := r.limiter.WaitN(r.ctx, n)
+		if err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// limitedReadCloser is a limitedReader which can be closed
+type limitedReadCloser struct {
+	limitedReader
+	io.Closer
+}
@@ -9,8 +9,11 @@
 package ftp
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"testing"
+	"time"
 
 	_ "github.com/rclone/rclone/backend/local"
 	"github.com/rclone/rclone/cmd/serve/servetest"
@@ -18,6 +21,7 @@ import (
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	ftp "goftp.io/server/core"
 )
 
@@ -70,3 +74,26 @@ func TestFTP(t *testing.T) {
 
 	servetest.Run(t, "ftp", start)
 }
+
+func TestExplicitTLSNeedsCert(t *testing.T) {
+	opt := DefaultOpt
+	opt.ListenAddr = testHOST + ":" + testPORT
+	opt.ExplicitTLS = true
+	_, err := newServer(context.Background(), nil, &opt)
+	assert.ErrorContains(t, err, "--explicit-tls")
+}
+
+func TestLimitedReader(t *testing.T) {
+	in := bytes.NewReader(make([]byte, 3*bwLimitBurst))
+	r := &limitedReader{
+		Reader:  in,
+		ctx:     context.Background(),
+		limiter: newBwLimiter(fs.SizeSuffix(2 * bwLimitBurst)),
+	}
+	start := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3*bwLimitBurst), n)
+	// the first burst is read straight away and the rest takes a second
+	assert.True(t, time.Since(start) >= 900*time.Millisecond)
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/rclone/rclone/cmd/serve/http/data"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/lib/audit"
 	httplib "github.com/rclone/rclone/lib/http"
 	"github.com/rclone/rclone/lib/http/auth"
 	"github.com/rclone/rclone/lib/http/serve"
@@ -207,6 +208,10 @@ func (s *server) serveFile(w http.ResponseWriter, r *http.Request, remote string
 
 	// open the object
 	in, err := file.Open(os.O_RDONLY)
+	if audit.Enabled() {
+		user, _ := r.Context().Value(auth.ContextUserKey).(string)
+		in = vfs.NewAuditor("http", user, r.RemoteAddr).Open(remote, os.O_RDONLY, in, err)
+	}
 	if err != nil {
 		serve.Error(remote, w, "Failed to open file", err)
 		return
@@ -26,6 +26,9 @@ func AddFlagsPrefix(flagSet *pflag.FlagSet, prefix string, Opt *httplib.Options)
 	flags.StringVarP(flagSet, &Opt.Realm, prefix+"realm", "", Opt.Realm, "Realm for authentication")
 	flags.StringVarP(flagSet, &Opt.BasicUser, prefix+"user", "", Opt.BasicUser, "User name for authentication")
 	flags.StringVarP(flagSet, &Opt.BasicPass, prefix+"pass", "", Opt.BasicPass, "Password for authentication")
+	flags.StringVarP(flagSet, &Opt.OIDCIssuer, prefix+"oidc-issuer", "", Opt.OIDCIssuer, "OpenID Connect issuer URL to accept bearer tokens from")
+	flags.StringVarP(flagSet, &Opt.OIDCAudience, prefix+"oidc-audience", "", Opt.OIDCAudience, "Only accept bearer tokens issued for this audience")
+	flags.StringVarP(flagSet, &Opt.OIDCUserClaim, prefix+"oidc-user-claim", "", Opt.OIDCUserClaim, "Bearer token claim to use as the user name")
 	flags.StringVarP(flagSet, &Opt.BaseURL, prefix+"baseurl", "", Opt.BaseURL, "Prefix for URLs - leave blank for root")
 	flags.StringVarP(flagSet, &Opt.Template, prefix+"template", "", Opt.Template, "User-specified template")
 
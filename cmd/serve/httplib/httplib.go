@@ -20,6 +20,8 @@ import (
 	auth "github.com/abbot/go-http-auth"
 	"github.com/rclone/rclone/cmd/serve/http/data"
 	"github.com/rclone/rclone/fs"
+	libauth "github.com/rclone/rclone/lib/http/auth"
+	"github.com/rclone/rclone/lib/jwtutil"
 )
 
 // Globals
@@ -97,6 +99,8 @@ The password file can be updated while rclone is running.
 
 Use --realm to set the authentication realm.
 
+#### OpenID Connect
+` + libauth.BearerHelp + `
 #### SSL/TLS
 
 By default this will serve over http.  If you want you can serve over
@@ -125,6 +129,10 @@ type Options struct {
 	BasicUser          string        // single username for basic auth if not using Htpasswd
 	BasicPass          string        // password for BasicUser
 	Auth               AuthFn        `json:"-"` // custom Auth (not set by command line flags)
+	OIDCIssuer         string        // OpenID Connect issuer to accept bearer tokens from
+	OIDCAudience       string        // audience bearer tokens must be issued for
+	OIDCUserClaim      string        // claim to read the user name from
+	ClaimsAuth         ClaimsAuthFn  `json:"-"` // custom Auth for bearer tokens (not set by command line flags)
 	Template           string        // User specified template
 }
 
@@ -134,6 +142,13 @@ type Options struct {
 // If a non nil value is returned then it is added to the context under the key
 type AuthFn func(user, pass string) (value interface{}, err error)
 
+// ClaimsAuthFn if used will be called with the user and claims of
+// each verified bearer token. If an error is returned then the user
+// is not authenticated.
+//
+// If a non nil value is returned then it is added to the context under the key
+type ClaimsAuthFn func(user string, claims jwtutil.Claims) (value interface{}, err error)
+
 // DefaultOpt is the default values used for Options
 var DefaultOpt = Options{
 	ListenAddr:         "localhost:8080",
@@ -141,6 +156,7 @@ var DefaultOpt = Options{
 	ServerReadTimeout:  1 * time.Hour,
 	ServerWriteTimeout: 1 * time.Hour,
 	MaxHeaderBytes:     4096,
+	OIDCUserClaim:      "sub",
 }
 
 // Server contains info about the running http server
@@ -156,15 +172,15 @@ type Server struct {
 	HTMLTemplate    *template.Template // HTML template for web interface
 }
 
-type contextUserType struct{}
-
 // ContextUserKey is a simple context key for storing the username of the request
-var ContextUserKey = &contextUserType{}
-
-type contextAuthType struct{}
+var ContextUserKey = libauth.ContextUserKey
 
 // ContextAuthKey is a simple context key for storing info returned by AuthFn
-var ContextAuthKey = &contextAuthType{}
+var ContextAuthKey = libauth.ContextAuthKey
+
+// ContextClaimsKey is a simple context key for storing the
+// jwtutil.Claims of a request authenticated with a bearer token
+var ContextClaimsKey = libauth.ContextClaimsKey
 
 // singleUserProvider provides the encrypted password for a single user
 func (s *Server) singleUserProvider(user, realm string) string {
@@ -265,6 +281,32 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 		s.usingAuth = true
 	}
 
+	// Accept bearer tokens if required, passing requests without
+	// one to the basic auth above
+	if s.Opt.OIDCIssuer != "" {
+		var basic func(http.Handler) http.Handler
+		if s.usingAuth {
+			basicHandler := handler
+			basic = func(http.Handler) http.Handler { return basicHandler }
+		}
+		bearerHandler := libauth.BearerAuth(libauth.Options{
+			Realm:         s.Opt.Realm,
+			OIDCIssuer:    s.Opt.OIDCIssuer,
+			OIDCAudience:  s.Opt.OIDCAudience,
+			OIDCUserClaim: s.Opt.OIDCUserClaim,
+			ClaimsAuth:    libauth.CustomClaimsAuthFn(s.Opt.ClaimsAuth),
+		}, basic)(s.handler)
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// No auth wanted for OPTIONS method
+			if r.Method == "OPTIONS" {
+				s.handler.ServeHTTP(w, r)
+				return
+			}
+			bearerHandler.ServeHTTP(w, r)
+		})
+		s.usingAuth = true
+	}
+
 	s.useSSL = s.Opt.SslKey != ""
 	if (s.Opt.SslCert != "") != s.useSSL {
 		log.Fatalf("Need both -cert and -key to use SSL")
@@ -0,0 +1,320 @@
+package nfs
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// NFS file handles are made from the generation of the server and an
+// id which is allocated the first time a path is seen. The id is also
+// used as the file id (inode number) of the path.
+//
+// The generation changes each time the server is started so handles
+// from a previous run are reported as stale to the client.
+const handleSize = 16
+
+// id of the root directory
+const rootID = 1
+
+var errStale = errors.New("stale file handle")
+
+// handleTable maps NFS file handles to paths in the VFS
+type handleTable struct {
+	mu     sync.Mutex
+	gen    uint64
+	nextID uint64
+	paths  map[uint64]string // path for each id
+	ids    map[string]uint64 // id for each path
+}
+
+// newHandleTable makes a handle table containing the root
+func newHandleTable() *handleTable {
+	t := &handleTable{
+		nextID: rootID + 1,
+		paths:  map[uint64]string{rootID: ""},
+		ids:    map[string]uint64{"": rootID},
+	}
+	var gen [8]byte
+	_, _ = rand.Read(gen[:])
+	t.gen = binary.BigEndian.Uint64(gen[:])
+	return t
+}
+
+// id returns the id for remote, allocating one if necessary
+func (t *handleTable) id(remote string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id, ok := t.ids[remote]
+	if !ok {
+		id = t.nextID
+		t.nextID++
+		t.ids[remote] = id
+		t.paths[id] = remote
+	}
+	return id
+}
+
+// toHandle returns the file handle for remote
+func (t *handleTable) toHandle(remote string) []byte {
+	fh := make([]byte, handleSize)
+	binary.BigEndian.PutUint64(fh, t.gen)
+	binary.BigEndian.PutUint64(fh[8:], t.id(remote))
+	return fh
+}
+
+// fromHandle returns the path and id of the file handle
+func (t *handleTable) fromHandle(fh []byte) (remote string, id uint64, err error) {
+	if len(fh) != handleSize || binary.BigEndian.Uint64(fh) != t.gen {
+		return "", 0, errStale
+	}
+	id = binary.BigEndian.Uint64(fh[8:])
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	remote, ok := t.paths[id]
+	if !ok {
+		return "", 0, errStale
+	}
+	return remote, id, nil
+}
+
+// rename moves the ids of oldPath and everything under it to newPath
+//
+// Any ids for newPath and below are forgotten.
+func (t *handleTable) rename(oldPath, newPath string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forgetLocked(newPath)
+	for remote, id := range t.ids {
+		if remote == oldPath || strings.HasPrefix(remote, oldPath+"/") {
+			delete(t.ids, remote)
+			remote = newPath + remote[len(oldPath):]
+			t.ids[remote] = id
+			t.paths[id] = remote
+		}
+	}
+}
+
+// forget removes the ids of remote and everything under it
+func (t *handleTable) forget(remote string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forgetLocked(remote)
+}
+
+// forgetLocked removes the ids of remote and everything under it
+//
+// Call with t.mu held
+func (t *handleTable) forgetLocked(remote string) {
+	if remote == "" {
+		return
+	}
+	for p, id := range t.ids {
+		if p == remote || strings.HasPrefix(p, remote+"/") {
+			delete(t.ids, p)
+			delete(t.paths, id)
+		}
+	}
+}
+
+// openFile is a VFS handle kept open between NFS calls
+type openFile struct {
+	handle   vfs.Handle
+	refs     int            // number of calls using the handle - protected by openFiles.mu
+	lastUsed time.Time      // when the handle was last released - protected by openFiles.mu
+	wg       sync.WaitGroup // calls using the handle
+}
+
+// openFiles caches VFS handles between NFS calls
+//
+// NFS is stateless so there is no open or close call. To avoid
+// opening the file for every READ and WRITE the handles are kept open
+// until the file is committed, removed or renamed, or they have been
+// idle for idleTimeout.
+type openFiles struct {
+	mu          sync.Mutex
+	vfs         *vfs.VFS
+	readers     map[uint64]*openFile
+	writers     map[uint64]*openFile
+	idleTimeout time.Duration
+	quit        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// newOpenFiles makes a new handle cache and starts the idle closer
+//
+// If idleTimeout is <= 0 idle handles are never closed.
+func newOpenFiles(VFS *vfs.VFS, idleTimeout time.Duration) *openFiles {
+	c := &openFiles{
+		vfs:         VFS,
+		readers:     make(map[uint64]*openFile),
+		writers:     make(map[uint64]*openFile),
+		idleTimeout: idleTimeout,
+		quit:        make(chan struct{}),
+	}
+	if idleTimeout > 0 {
+		c.wg.Add(1)
+		go c.closeIdle()
+	}
+	return c
+}
+
+// get returns an open handle from files for id opening it with open if
+// not found.
+//
+// open is called without c.mu held so a slow open doesn't hold up
+// the other handles.
+//
+// Call release on the result when finished with it.
+func (c *openFiles) get(files map[uint64]*openFile, id uint64, open func() (vfs.Handle, error)) (*openFile, error) {
+	c.mu.Lock()
+	of, ok := files[id]
+	if ok {
+		of.refs++
+		of.wg.Add(1)
+		c.mu.Unlock()
+		return of, nil
+	}
+	c.mu.Unlock()
+
+	handle, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	of, ok = files[id]
+	if ok {
+		// opened by someone else while we were opening it
+		if err := handle.Close(); err != nil {
+			fs.Errorf(handle.Node().Path(), "Failed to close duplicate handle: %v", err)
+		}
+	} else {
+		of = &openFile{handle: handle}
+		files[id] = of
+	}
+	of.refs++
+	of.wg.Add(1)
+	return of, nil
+}
+
+// getReader returns a handle for reading id
+func (c *openFiles) getReader(id uint64, remote string) (*openFile, error) {
+	return c.get(c.readers, id, func() (vfs.Handle, error) {
+		return c.vfs.OpenFile(remote, os.O_RDONLY, 0)
+	})
+}
+
+// getWriter returns a handle for writing id, opening it with flags if
+// it isn't open already
+func (c *openFiles) getWriter(id uint64, remote string, flags int) (*openFile, error) {
+	return c.get(c.writers, id, func() (vfs.Handle, error) {
+		return c.vfs.OpenFile(remote, flags, 0666)
+	})
+}
+
+// getOpenWriter returns the writer for id if it is open or nil if not
+//
+// Call release on the result if it isn't nil.
+func (c *openFiles) getOpenWriter(id uint64) *openFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	of := c.writers[id]
+	if of != nil {
+		of.refs++
+		of.wg.Add(1)
+	}
+	return of
+}
+
+// release marks of as no longer in use by the caller
+func (c *openFiles) release(of *openFile) {
+	c.mu.Lock()
+	of.refs--
+	of.lastUsed = time.Now()
+	c.mu.Unlock()
+	of.wg.Done()
+}
+
+// closeFile removes the handle for id from files and closes it once
+// any calls using it have finished.
+func (c *openFiles) closeFile(files map[uint64]*openFile, id uint64) error {
+	c.mu.Lock()
+	of, ok := files[id]
+	delete(files, id)
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	of.wg.Wait()
+	return of.handle.Close()
+}
+
+// closeWriter closes the writer for id, uploading the file if
+// necessary.
+func (c *openFiles) closeWriter(id uint64) error {
+	return c.closeFile(c.writers, id)
+}
+
+// closeAll closes the readers and writers for id
+func (c *openFiles) closeAll(id uint64) error {
+	readErr := c.closeFile(c.readers, id)
+	err := c.closeFile(c.writers, id)
+	if err == nil {
+		err = readErr
+	}
+	return err
+}
+
+// closeIdle closes handles which haven't been used for idleTimeout
+// until c.quit is closed.
+func (c *openFiles) closeIdle() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.closeExpired(time.Now().Add(-c.idleTimeout))
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// closeExpired closes all unused handles last used before cutoff
+func (c *openFiles) closeExpired(cutoff time.Time) {
+	var expired []*openFile
+	c.mu.Lock()
+	for _, files := range []map[uint64]*openFile{c.readers, c.writers} {
+		for id, of := range files {
+			if of.refs == 0 && of.lastUsed.Before(cutoff) {
+				delete(files, id)
+				expired = append(expired, of)
+			}
+		}
+	}
+	c.mu.Unlock()
+	for _, of := range expired {
+		node := of.handle.Node()
+		fs.Debugf(node.Path(), "Closing idle handle")
+		if err := of.handle.Close(); err != nil {
+			fs.Errorf(node.Path(), "Failed to close idle handle: %v", err)
+		}
+	}
+}
+
+// shutdown stops the idle closer and closes all the handles
+func (c *openFiles) shutdown() {
+	close(c.quit)
+	c.wg.Wait()
+	c.closeExpired(time.Now().Add(time.Hour))
+}
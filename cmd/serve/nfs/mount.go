@@ -0,0 +1,88 @@
+package nfs
+
+import (
+	"path"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// MOUNT protocol version 3 (RFC 1813 Appendix I)
+const (
+	mountProgram = 100005
+	mountVersion = 3
+
+	mountPathLen = 1024
+
+	mnt3OK        = 0
+	mnt3ErrNoEnt  = 2
+	mnt3ErrNotDir = 20
+)
+
+// mountProcedures are the MOUNT procedures indexed by number
+var mountProcedures = []procedure{
+	0: {"NULL", (*server).mountNull},
+	1: {"MNT", (*server).mountMnt},
+	2: {"DUMP", (*server).mountDump},
+	3: {"UMNT", (*server).mountUmnt},
+	4: {"UMNTALL", (*server).mountNull},
+	5: {"EXPORT", (*server).mountExport},
+}
+
+// mountNull does nothing
+func (s *server) mountNull(c *call, w *xdrWriter) error {
+	return nil
+}
+
+// mountMnt returns the file handle for the directory being mounted
+//
+// Any directory in the VFS can be mounted.
+func (s *server) mountMnt(c *call, w *xdrWriter) error {
+	dirPath := c.args.string(mountPathLen)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote := path.Clean("/" + dirPath)[1:]
+	fs.Infof(nil, "%v: mount %q", c, dirPath)
+	node, err := s.vfs.Stat(remote)
+	if err != nil {
+		fs.Debugf(nil, "%v: mount %q failed: %v", c, dirPath, err)
+		w.uint32(mnt3ErrNoEnt)
+		return nil
+	}
+	if !node.IsDir() {
+		w.uint32(mnt3ErrNotDir)
+		return nil
+	}
+	w.uint32(mnt3OK)
+	w.opaque(s.handles.toHandle(remote))
+	// auth flavors
+	w.uint32(1)
+	w.uint32(authUnix)
+	return nil
+}
+
+// mountDump lists the clients with mounts - we don't keep track of these
+func (s *server) mountDump(c *call, w *xdrWriter) error {
+	w.bool(false)
+	return nil
+}
+
+// mountUmnt is called when the client unmounts a directory
+func (s *server) mountUmnt(c *call, w *xdrWriter) error {
+	dirPath := c.args.string(mountPathLen)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	fs.Infof(nil, "%v: unmount %q", c, dirPath)
+	return nil
+}
+
+// mountExport lists the exports - the root of the VFS is exported to
+// everyone
+func (s *server) mountExport(c *call, w *xdrWriter) error {
+	w.bool(true)
+	w.string("/")
+	w.bool(false) // no groups
+	w.bool(false) // no more exports
+	return nil
+}
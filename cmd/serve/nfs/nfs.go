@@ -0,0 +1,106 @@
+// Package nfs implements an NFSv3 server to serve an rclone VFS
+package nfs
+
+import (
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/atexit"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Options contains options for the NFS Server
+type Options struct {
+	ListenAddr    string        // Port to listen on
+	HandleTimeout time.Duration // How long to keep idle files open
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ListenAddr:    "localhost:2049",
+	HandleTimeout: time.Minute,
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+// AddFlags adds flags for the nfs
+func AddFlags(flagSet *pflag.FlagSet, Opt *Options) {
+	rc.AddOption("nfs", Opt)
+	flags.StringVarP(flagSet, &Opt.ListenAddr, "addr", "", Opt.ListenAddr, "IPaddress:Port or :Port to bind server to")
+	flags.DurationVarP(flagSet, &Opt.HandleTimeout, "handle-timeout", "", Opt.HandleTimeout, "Time to keep idle files open between NFS calls")
+}
+
+func init() {
+	vfsflags.AddFlags(Command.Flags())
+	AddFlags(Command.Flags(), &Opt)
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "nfs remote:path",
+	Short: `Serve the remote as an NFS mount.`,
+	Long: `rclone serve nfs implements an NFSv3 server to serve any rclone
+remote.  This lets the NFS client built into the kernel mount the remote
+on systems where FUSE, and so "rclone mount", isn't available, for
+example in unprivileged containers.
+
+You can use the filter flags (e.g. --include, --exclude) to control what
+is served.
+
+The server will log errors.  Use -v to see the mounts and -vv to see
+every NFS call.
+
+By default the server binds to localhost:2049 - if you want it to be
+reachable externally then supply "--addr :2049" for example.  There is
+no authentication so only make the server reachable by clients you
+trust.
+
+The server provides both the NFS and MOUNT protocols over TCP on the
+same port and doesn't register with the portmapper, so the port needs
+to be given explicitly when mounting.  File locking isn't supported so
+use the "nolock" option.  On Linux, with the server on port 2049:
+
+    mount -t nfs -o port=2049,mountport=2049,nfsvers=3,tcp,nolock,noacl localhost:/ /mnt/rclone
+
+Any directory in the remote can be mounted by giving its path instead
+of "/".
+
+NFS has no open or close calls, so rclone keeps files open between
+calls and closes them when the client commits them, when they are
+removed or renamed, or when they haven't been used for
+--handle-timeout.  Set --handle-timeout 0 to never close idle files.
+File handles don't survive a restart of the server, and the client
+will report "Stale file handle" for any files it had open - remount
+the directory to fix this.
+
+It is recommended to use "--vfs-cache-mode writes" or "full" as NFS
+clients write files out of order and rewrite parts of existing files,
+which isn't possible without the cache.
+
+` + vfs.Help,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, true, command, func() error {
+			if vfsflags.Opt.CacheMode < vfscommon.CacheModeWrites {
+				fs.Logf(nil, "NFS clients may not be able to write files without --vfs-cache-mode writes or full")
+			}
+			s := newServer(f, &Opt)
+			err := s.Serve()
+			if err != nil {
+				return err
+			}
+			defer atexit.Unregister(atexit.Register(s.Close))
+			s.Wait()
+			return nil
+		})
+	},
+}
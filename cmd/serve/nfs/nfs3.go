@@ -0,0 +1,1029 @@
+package nfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// NFS version 3 (RFC 1813)
+const (
+	nfsProgram = 100003
+	nfsVersion = 3
+
+	fhSize     = 64      // maximum size of a file handle
+	maxPathLen = 1024    // maximum size of a name or path in the arguments
+	maxNameLen = 255     // longest file name allowed
+	maxData    = 1 << 20 // largest READ or WRITE
+	fsid       = 0x72636c6e
+
+	// nfsstat3
+	nfs3OK             = 0
+	nfs3ErrPerm        = 1
+	nfs3ErrNoEnt       = 2
+	nfs3ErrIO          = 5
+	nfs3ErrExist       = 17
+	nfs3ErrNotDir      = 20
+	nfs3ErrIsDir       = 21
+	nfs3ErrInval       = 22
+	nfs3ErrRoFs        = 30
+	nfs3ErrNameTooLong = 63
+	nfs3ErrNotEmpty    = 66
+	nfs3ErrStale       = 70
+	nfs3ErrNotSync     = 10002
+	nfs3ErrBadCookie   = 10003
+	nfs3ErrNotSupp     = 10004
+	nfs3ErrTooSmall    = 10005
+
+	// ftype3
+	nf3Reg = 1
+	nf3Dir = 2
+
+	// time_how
+	setToServerTime = 1
+	setToClientTime = 2
+
+	// stable_how
+	unstable = 0
+	fileSync = 2
+
+	// createmode3
+	createUnchecked = 0
+	createGuarded   = 1
+	createExclusive = 2
+
+	// ACCESS bits
+	accessRead    = 0x01
+	accessLookup  = 0x02
+	accessModify  = 0x04
+	accessExtend  = 0x08
+	accessDelete  = 0x10
+	accessExecute = 0x20
+
+	// FSINFO properties
+	fsfHomogeneous = 0x08
+	fsfCanSetTime  = 0x10
+)
+
+// Errors which map onto NFS statuses without a VFS equivalent
+var (
+	errNotDir       = errors.New("not a directory")
+	errIsDir        = errors.New("is a directory")
+	errNameTooLong  = errors.New("file name too long")
+	errNotSupported = errors.New("operation not supported")
+	errNotSync      = errors.New("guard ctime doesn't match")
+	errBadCookie    = errors.New("bad READDIR cookie")
+	errTooSmall     = errors.New("READDIR buffer too small")
+)
+
+// nfsErrors maps errors onto NFS statuses
+var nfsErrors = []struct {
+	err    error
+	status uint32
+}{
+	{vfs.ENOENT, nfs3ErrNoEnt},
+	{vfs.EEXIST, nfs3ErrExist},
+	{vfs.EPERM, nfs3ErrPerm},
+	{vfs.EINVAL, nfs3ErrInval},
+	{vfs.ENOTEMPTY, nfs3ErrNotEmpty},
+	{vfs.EROFS, nfs3ErrRoFs},
+	{vfs.ENOSYS, nfs3ErrNotSupp},
+	{errStale, nfs3ErrStale},
+	{errNotDir, nfs3ErrNotDir},
+	{errIsDir, nfs3ErrIsDir},
+	{errNameTooLong, nfs3ErrNameTooLong},
+	{errNotSupported, nfs3ErrNotSupp},
+	{errNotSync, nfs3ErrNotSync},
+	{errBadCookie, nfs3ErrBadCookie},
+	{errTooSmall, nfs3ErrTooSmall},
+}
+
+// nfsStatus converts err into an NFS status, logging any unexpected
+// errors against remote.
+func nfsStatus(remote string, err error) uint32 {
+	if err == nil {
+		return nfs3OK
+	}
+	for _, e := range nfsErrors {
+		if errors.Is(err, e.err) {
+			return e.status
+		}
+	}
+	fs.Errorf(remote, "NFS server: %v", err)
+	return nfs3ErrIO
+}
+
+// nfsProcedures are the NFS procedures indexed by number
+var nfsProcedures = []procedure{
+	0:  {"NULL", (*server).nfsNull},
+	1:  {"GETATTR", (*server).nfsGetattr},
+	2:  {"SETATTR", (*server).nfsSetattr},
+	3:  {"LOOKUP", (*server).nfsLookup},
+	4:  {"ACCESS", (*server).nfsAccess},
+	5:  {"READLINK", (*server).nfsReadlink},
+	6:  {"READ", (*server).nfsRead},
+	7:  {"WRITE", (*server).nfsWrite},
+	8:  {"CREATE", (*server).nfsCreate},
+	9:  {"MKDIR", (*server).nfsMkdir},
+	10: {"SYMLINK", (*server).nfsNotSuppDir},
+	11: {"MKNOD", (*server).nfsNotSuppDir},
+	12: {"REMOVE", (*server).nfsRemove},
+	13: {"RMDIR", (*server).nfsRmdir},
+	14: {"RENAME", (*server).nfsRename},
+	15: {"LINK", (*server).nfsLink},
+	16: {"READDIR", (*server).nfsReaddir},
+	17: {"READDIRPLUS", (*server).nfsReaddirplus},
+	18: {"FSSTAT", (*server).nfsFsstat},
+	19: {"FSINFO", (*server).nfsFsinfo},
+	20: {"PATHCONF", (*server).nfsPathconf},
+	21: {"COMMIT", (*server).nfsCommit},
+}
+
+// readTime reads an nfstime3
+func readTime(r *xdrReader) time.Time {
+	seconds := r.uint32()
+	nanos := r.uint32()
+	return time.Unix(int64(seconds), int64(nanos))
+}
+
+// writeTime writes an nfstime3
+func writeTime(w *xdrWriter, t time.Time) {
+	w.uint32(uint32(t.Unix()))
+	w.uint32(uint32(t.Nanosecond()))
+}
+
+// readSetTime reads a set_atime or set_mtime returning ok if the time
+// should be set
+func readSetTime(r *xdrReader) (t time.Time, ok bool) {
+	switch r.uint32() {
+	case setToServerTime:
+		return time.Now(), true
+	case setToClientTime:
+		return readTime(r), true
+	}
+	return t, false
+}
+
+// sattr is a decoded sattr3
+//
+// The mode, uid, gid and atime are read but ignored as the VFS can't
+// store them.
+type sattr struct {
+	setSize  bool
+	size     uint64
+	setMtime bool
+	mtime    time.Time
+}
+
+// readSattr reads an sattr3
+func readSattr(r *xdrReader) (a sattr) {
+	if r.bool() {
+		_ = r.uint32() // mode
+	}
+	if r.bool() {
+		_ = r.uint32() // uid
+	}
+	if r.bool() {
+		_ = r.uint32() // gid
+	}
+	if a.setSize = r.bool(); a.setSize {
+		a.size = r.uint64()
+	}
+	_, _ = readSetTime(r) // atime
+	a.mtime, a.setMtime = readSetTime(r)
+	return a
+}
+
+// readDirOp reads a diropargs3
+func readDirOp(r *xdrReader) (fh []byte, name string) {
+	fh = r.opaque(fhSize)
+	name = r.string(maxPathLen)
+	return fh, name
+}
+
+// checkName checks name is usable as a file name
+func checkName(name string) error {
+	if len(name) > maxNameLen {
+		return errNameTooLong
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '/') {
+		return vfs.EINVAL
+	}
+	return nil
+}
+
+// writeAttr writes the fattr3 for node
+func (s *server) writeAttr(w *xdrWriter, id uint64, node vfs.Node) {
+	var (
+		ftype uint32 = nf3Reg
+		nlink uint32 = 1
+	)
+	if node.IsDir() {
+		ftype = nf3Dir
+		nlink = 2
+	}
+	size := uint64(node.Size())
+	modTime := node.ModTime()
+	w.uint32(ftype)
+	w.uint32(uint32(node.Mode().Perm()))
+	w.uint32(nlink)
+	w.uint32(s.vfs.Opt.UID)
+	w.uint32(s.vfs.Opt.GID)
+	w.uint64(size)
+	w.uint64(size) // used
+	w.uint64(0)    // rdev
+	w.uint64(fsid)
+	w.uint64(id)
+	writeTime(w, modTime) // atime
+	writeTime(w, modTime) // mtime
+	writeTime(w, modTime) // ctime
+}
+
+// writePostOpAttr writes a post_op_attr for node which may be nil
+func (s *server) writePostOpAttr(w *xdrWriter, id uint64, node vfs.Node) {
+	w.bool(node != nil)
+	if node != nil {
+		s.writeAttr(w, id, node)
+	}
+}
+
+// writeWcc writes the wcc_data for node which may be nil
+//
+// The attributes from before the operation aren't sent as the VFS
+// can't supply them atomically.
+func (s *server) writeWcc(w *xdrWriter, id uint64, node vfs.Node) {
+	w.bool(false)
+	s.writePostOpAttr(w, id, node)
+}
+
+// writePostOpFH writes a post_op_fh3 for remote
+func (s *server) writePostOpFH(w *xdrWriter, remote string) {
+	w.bool(true)
+	w.opaque(s.handles.toHandle(remote))
+}
+
+// getNode returns the path, id and node for the file handle fh
+func (s *server) getNode(fh []byte) (remote string, id uint64, node vfs.Node, err error) {
+	remote, id, err = s.handles.fromHandle(fh)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	node, err = s.vfs.Stat(remote)
+	if errors.Is(err, vfs.ENOENT) {
+		s.handles.forget(remote)
+		return "", 0, nil, errStale
+	} else if err != nil {
+		return "", 0, nil, err
+	}
+	return remote, id, node, nil
+}
+
+// getDir returns the path, id and directory for the file handle fh
+func (s *server) getDir(fh []byte) (remote string, id uint64, dir *vfs.Dir, err error) {
+	remote, id, node, err := s.getNode(fh)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	dir, ok := node.(*vfs.Dir)
+	if !ok {
+		return "", 0, nil, errNotDir
+	}
+	return remote, id, dir, nil
+}
+
+// getFile returns the path, id and file for the file handle fh
+func (s *server) getFile(fh []byte) (remote string, id uint64, file *vfs.File, err error) {
+	remote, id, node, err := s.getNode(fh)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	file, ok := node.(*vfs.File)
+	if !ok {
+		return "", 0, nil, errIsDir
+	}
+	return remote, id, file, nil
+}
+
+// nodeOrNil returns node as a vfs.Node which is nil if node is nil
+//
+// This stops a nil *vfs.Dir or *vfs.File becoming a non nil interface.
+func nodeOrNil(node vfs.Node) vfs.Node {
+	switch x := node.(type) {
+	case *vfs.Dir:
+		if x == nil {
+			return nil
+		}
+	case *vfs.File:
+		if x == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// writeFlags returns the flags to open node for writing at offset
+//
+// Without --vfs-cache-mode writes or full files can only be written
+// sequentially from the start, so the file is truncated if the write
+// starts at the beginning or the file is empty.
+func (s *server) writeFlags(node vfs.Node, offset uint64) int {
+	if s.vfs.Opt.CacheMode >= vfscommon.CacheModeWrites {
+		return os.O_RDWR
+	}
+	if offset == 0 || node.Size() == 0 {
+		return os.O_WRONLY | os.O_TRUNC
+	}
+	return os.O_WRONLY
+}
+
+// createFlags returns the flags to create a file for writing
+func (s *server) createFlags() int {
+	if s.vfs.Opt.CacheMode >= vfscommon.CacheModeWrites {
+		return os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	}
+	return os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+}
+
+// setAttr applies the attributes in a to node
+func (s *server) setAttr(id uint64, node vfs.Node, a *sattr) error {
+	if a.setSize {
+		if node.IsDir() {
+			return errIsDir
+		}
+		var err error
+		if of := s.files.getOpenWriter(id); of != nil {
+			err = of.handle.Truncate(int64(a.size))
+			s.files.release(of)
+		} else {
+			err = node.Truncate(int64(a.size))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if a.setMtime {
+		return node.SetModTime(a.mtime)
+	}
+	return nil
+}
+
+// nfsNull does nothing
+func (s *server) nfsNull(c *call, w *xdrWriter) error {
+	return nil
+}
+
+// nfsGetattr returns the attributes of a file or directory
+func (s *server) nfsGetattr(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote, id, node, err := s.getNode(fh)
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	if status == nfs3OK {
+		s.writeAttr(w, id, node)
+	}
+	return nil
+}
+
+// nfsSetattr sets the size and modification time of a file or
+// directory
+func (s *server) nfsSetattr(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	a := readSattr(c.args)
+	checkGuard := c.args.bool()
+	var guard time.Time
+	if checkGuard {
+		guard = readTime(c.args)
+	}
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote, id, node, err := s.getNode(fh)
+	if err == nil && checkGuard && !node.ModTime().Truncate(time.Second).Equal(guard.Truncate(time.Second)) {
+		err = errNotSync
+	}
+	if err == nil {
+		err = s.setAttr(id, node, &a)
+	}
+	w.uint32(nfsStatus(remote, err))
+	s.writeWcc(w, id, nodeOrNil(node))
+	return nil
+}
+
+// nfsLookup finds a name in a directory
+func (s *server) nfsLookup(c *call, w *xdrWriter) error {
+	dirFh, name := readDirOp(c.args)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	dirRemote, dirID, dir, err := s.getDir(dirFh)
+	var (
+		remote string
+		node   vfs.Node
+	)
+	if err == nil {
+		switch name {
+		case ".":
+			remote, node = dirRemote, dir
+		case "..":
+			remote = path.Dir("/" + dirRemote)[1:]
+			node, err = s.vfs.Stat(remote)
+		default:
+			remote = path.Join(dirRemote, name)
+			if err = checkName(name); err == nil {
+				node, err = dir.Stat(name)
+			}
+		}
+	}
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	if status == nfs3OK {
+		w.opaque(s.handles.toHandle(remote))
+		s.writePostOpAttr(w, s.handles.id(remote), node)
+	}
+	s.writePostOpAttr(w, dirID, nodeOrNil(dir))
+	return nil
+}
+
+// nfsAccess checks the access permissions - everything is allowed
+// unless the VFS is read only.
+func (s *server) nfsAccess(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	access := c.args.uint32()
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote, id, node, err := s.getNode(fh)
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	s.writePostOpAttr(w, id, node)
+	if status == nfs3OK {
+		access &= accessRead | accessLookup | accessModify | accessExtend | accessDelete | accessExecute
+		if s.vfs.Opt.ReadOnly {
+			access &^= accessModify | accessExtend | accessDelete
+		}
+		w.uint32(access)
+	}
+	return nil
+}
+
+// nfsReadlink isn't supported as the VFS doesn't have symlinks
+func (s *server) nfsReadlink(c *call, w *xdrWriter) error {
+	w.uint32(nfs3ErrNotSupp)
+	w.bool(false)
+	return nil
+}
+
+// nfsRead reads data from a file
+func (s *server) nfsRead(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	offset := c.args.uint64()
+	count := c.args.uint32()
+	if c.args.err != nil {
+		return c.args.err
+	}
+	if count > maxData {
+		count = maxData
+	}
+	remote, id, file, err := s.getFile(fh)
+	var (
+		n    int
+		eof  bool
+		data []byte
+	)
+	if err == nil {
+		data = make([]byte, count)
+		n, err = s.readAt(id, remote, data, int64(offset))
+		if err == io.EOF {
+			err = nil
+			eof = true
+		}
+		if offset+uint64(n) >= uint64(file.Size()) {
+			eof = true
+		}
+	}
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	s.writePostOpAttr(w, id, nodeOrNil(file))
+	if status == nfs3OK {
+		w.uint32(uint32(n))
+		w.bool(eof)
+		w.opaque(data[:n])
+	}
+	return nil
+}
+
+// readAt reads from the file at offset using the writer if it is open
+// for reading or a reader otherwise
+func (s *server) readAt(id uint64, remote string, p []byte, offset int64) (n int, err error) {
+	if of := s.files.getOpenWriter(id); of != nil {
+		if s.vfs.Opt.CacheMode >= vfscommon.CacheModeWrites {
+			defer s.files.release(of)
+			return of.handle.ReadAt(p, offset)
+		}
+		// the writer can't be read from so finish the upload first
+		s.files.release(of)
+		if err = s.files.closeAll(id); err != nil {
+			return 0, err
+		}
+	}
+	of, err := s.files.getReader(id, remote)
+	if err != nil {
+		return 0, err
+	}
+	defer s.files.release(of)
+	return of.handle.ReadAt(p, offset)
+}
+
+// nfsWrite writes data to a file
+//
+// UNSTABLE writes are left in the open handle until COMMIT, other
+// writes close the handle before returning.
+func (s *server) nfsWrite(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	offset := c.args.uint64()
+	_ = c.args.uint32() // count
+	stable := c.args.uint32()
+	data := c.args.opaque(maxData)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote, id, file, err := s.getFile(fh)
+	var n int
+	if err == nil {
+		var of *openFile
+		of, err = s.files.getWriter(id, remote, s.writeFlags(file, offset))
+		if err == nil {
+			n, err = of.handle.WriteAt(data, int64(offset))
+			s.files.release(of)
+		}
+		if err == nil && stable != unstable {
+			err = s.files.closeAll(id)
+		}
+	}
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	s.writeWcc(w, id, nodeOrNil(file))
+	if status == nfs3OK {
+		w.uint32(uint32(n))
+		if stable == unstable {
+			w.uint32(unstable)
+		} else {
+			w.uint32(fileSync)
+		}
+		w.fixed(s.verifier[:])
+	}
+	return nil
+}
+
+// nfsCreate creates a file
+//
+// EXCLUSIVE creates are treated like GUARDED ones as the VFS has
+// nowhere to store the verifier.
+func (s *server) nfsCreate(c *call, w *xdrWriter) error {
+	dirFh, name := readDirOp(c.args)
+	how := c.args.uint32()
+	var a sattr
+	switch how {
+	case createUnchecked, createGuarded:
+		a = readSattr(c.args)
+	case createExclusive:
+		_ = c.args.fixed(8)
+	default:
+		return errGarbageArgs
+	}
+	if c.args.err != nil {
+		return c.args.err
+	}
+	dirRemote, dirID, dir, err := s.getDir(dirFh)
+	remote := path.Join(dirRemote, name)
+	var node vfs.Node
+	if err == nil {
+		err = checkName(name)
+	}
+	if err == nil {
+		node, err = dir.Stat(name)
+		switch {
+		case err == nil && (how != createUnchecked || node.IsDir()):
+			err = vfs.EEXIST
+		case err == nil:
+			// UNCHECKED create of an existing file
+			err = s.setAttr(s.handles.id(remote), node, &a)
+		case errors.Is(err, vfs.ENOENT):
+			// Keep the handle open ready for the WRITEs
+			var of *openFile
+			of, err = s.files.getWriter(s.handles.id(remote), remote, s.createFlags())
+			if err == nil {
+				node = of.handle.Node()
+				s.files.release(of)
+				if a.setMtime {
+					err = node.SetModTime(a.mtime)
+				}
+			}
+		}
+	}
+	s.writeCreateResult(w, remote, node, dirID, dir, err)
+	return nil
+}
+
+// writeCreateResult writes the result of CREATE or MKDIR
+func (s *server) writeCreateResult(w *xdrWriter, remote string, node vfs.Node, dirID uint64, dir *vfs.Dir, err error) {
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	if status == nfs3OK {
+		s.writePostOpFH(w, remote)
+		s.writePostOpAttr(w, s.handles.id(remote), node)
+	}
+	s.writeWcc(w, dirID, nodeOrNil(dir))
+}
+
+// nfsMkdir creates a directory
+func (s *server) nfsMkdir(c *call, w *xdrWriter) error {
+	dirFh, name := readDirOp(c.args)
+	a := readSattr(c.args)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	dirRemote, dirID, dir, err := s.getDir(dirFh)
+	remote := path.Join(dirRemote, name)
+	var node vfs.Node
+	if err == nil {
+		err = checkName(name)
+	}
+	if err == nil {
+		_, err = dir.Stat(name)
+		if err == nil {
+			err = vfs.EEXIST
+		} else if errors.Is(err, vfs.ENOENT) {
+			var newDir *vfs.Dir
+			newDir, err = dir.Mkdir(name)
+			if err == nil {
+				node = newDir
+				if a.setMtime {
+					err = node.SetModTime(a.mtime)
+				}
+			}
+		}
+	}
+	s.writeCreateResult(w, remote, node, dirID, dir, err)
+	return nil
+}
+
+// nfsNotSuppDir is used for SYMLINK and MKNOD which the VFS can't do
+func (s *server) nfsNotSuppDir(c *call, w *xdrWriter) error {
+	w.uint32(nfs3ErrNotSupp)
+	s.writeWcc(w, 0, nil)
+	return nil
+}
+
+// nfsLink isn't supported as the VFS can't make hard links
+func (s *server) nfsLink(c *call, w *xdrWriter) error {
+	w.uint32(nfs3ErrNotSupp)
+	w.bool(false)
+	s.writeWcc(w, 0, nil)
+	return nil
+}
+
+// nfsRemove removes a file
+func (s *server) nfsRemove(c *call, w *xdrWriter) error {
+	return s.remove(c, w, false)
+}
+
+// nfsRmdir removes an empty directory
+func (s *server) nfsRmdir(c *call, w *xdrWriter) error {
+	return s.remove(c, w, true)
+}
+
+// remove removes a file or a directory for REMOVE and RMDIR
+func (s *server) remove(c *call, w *xdrWriter, isDir bool) error {
+	dirFh, name := readDirOp(c.args)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	dirRemote, dirID, dir, err := s.getDir(dirFh)
+	remote := path.Join(dirRemote, name)
+	if err == nil {
+		err = checkName(name)
+	}
+	var node vfs.Node
+	if err == nil {
+		node, err = dir.Stat(name)
+	}
+	if err == nil {
+		switch {
+		case isDir && !node.IsDir():
+			err = errNotDir
+		case !isDir && node.IsDir():
+			err = errIsDir
+		case isDir:
+			var nodes vfs.Nodes
+			nodes, err = node.(*vfs.Dir).ReadDirAll()
+			if err == nil && len(nodes) > 0 {
+				err = vfs.ENOTEMPTY
+			}
+		default:
+			if closeErr := s.files.closeAll(s.handles.id(remote)); closeErr != nil {
+				fs.Debugf(remote, "Error closing file before removal: %v", closeErr)
+			}
+		}
+	}
+	if err == nil {
+		err = node.Remove()
+	}
+	if err == nil {
+		s.handles.forget(remote)
+	}
+	w.uint32(nfsStatus(remote, err))
+	s.writeWcc(w, dirID, nodeOrNil(dir))
+	return nil
+}
+
+// nfsRename renames a file or directory
+func (s *server) nfsRename(c *call, w *xdrWriter) error {
+	fromFh, fromName := readDirOp(c.args)
+	toFh, toName := readDirOp(c.args)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	fromRemote, fromID, fromDir, err := s.getDir(fromFh)
+	toRemote, toID, toDir, toErr := s.getDir(toFh)
+	if err == nil {
+		err = toErr
+	}
+	oldPath := path.Join(fromRemote, fromName)
+	newPath := path.Join(toRemote, toName)
+	if err == nil {
+		err = checkName(fromName)
+	}
+	if err == nil {
+		err = checkName(toName)
+	}
+	var node vfs.Node
+	if err == nil {
+		node, err = fromDir.Stat(fromName)
+	}
+	if err == nil && oldPath != newPath {
+		err = s.checkRenameTarget(node, toDir, toName, newPath)
+	}
+	if err == nil && oldPath != newPath {
+		// Finish any uploads before the rename
+		if node.IsFile() {
+			if closeErr := s.files.closeAll(s.handles.id(oldPath)); closeErr != nil {
+				fs.Debugf(oldPath, "Error closing file before rename: %v", closeErr)
+			}
+		}
+		err = fromDir.Rename(fromName, toName, toDir)
+		if err == nil {
+			s.handles.rename(oldPath, newPath)
+		}
+	}
+	w.uint32(nfsStatus(oldPath, err))
+	s.writeWcc(w, fromID, nodeOrNil(fromDir))
+	s.writeWcc(w, toID, nodeOrNil(toDir))
+	return nil
+}
+
+// checkRenameTarget checks node can be renamed to toName in toDir
+//
+// An existing file is replaced by the rename and an existing empty
+// directory is removed first.
+func (s *server) checkRenameTarget(node vfs.Node, toDir *vfs.Dir, toName, newPath string) error {
+	existing, err := toDir.Stat(toName)
+	if errors.Is(err, vfs.ENOENT) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	switch {
+	case existing.IsDir() && !node.IsDir():
+		return vfs.EEXIST
+	case !existing.IsDir() && node.IsDir():
+		return errNotDir
+	case existing.IsDir():
+		nodes, err := existing.(*vfs.Dir).ReadDirAll()
+		if err != nil {
+			return err
+		}
+		if len(nodes) > 0 {
+			return vfs.ENOTEMPTY
+		}
+		return existing.Remove()
+	}
+	if err := s.files.closeAll(s.handles.id(newPath)); err != nil {
+		fs.Debugf(newPath, "Error closing file before rename: %v", err)
+	}
+	return nil
+}
+
+// dirEntry is an entry returned by READDIR or READDIRPLUS
+type dirEntry struct {
+	name   string
+	remote string
+	node   vfs.Node
+}
+
+// nfsReaddir lists a directory
+func (s *server) nfsReaddir(c *call, w *xdrWriter) error {
+	return s.readDir(c, w, false)
+}
+
+// nfsReaddirplus lists a directory with the attributes and handles of
+// the entries
+func (s *server) nfsReaddirplus(c *call, w *xdrWriter) error {
+	return s.readDir(c, w, true)
+}
+
+// readDir lists a directory for READDIR and READDIRPLUS
+//
+// The cookie for each entry is its index in the listing plus one. The
+// cookie verifier isn't used.
+func (s *server) readDir(c *call, w *xdrWriter, plus bool) error {
+	fh := c.args.opaque(fhSize)
+	cookie := c.args.uint64()
+	_ = c.args.fixed(8) // cookie verifier
+	dirCount := c.args.uint32()
+	maxCount := dirCount
+	if plus {
+		maxCount = c.args.uint32()
+	}
+	if c.args.err != nil {
+		return c.args.err
+	}
+	dirRemote, dirID, dir, err := s.getDir(fh)
+	var entries []dirEntry
+	if err == nil {
+		var nodes vfs.Nodes
+		nodes, err = dir.ReadDirAll()
+		if err == nil {
+			parentRemote := path.Dir("/" + dirRemote)[1:]
+			parent, parentErr := s.vfs.Stat(parentRemote)
+			if parentErr != nil {
+				parent = nil
+			}
+			entries = append(entries,
+				dirEntry{name: ".", remote: dirRemote, node: dir},
+				dirEntry{name: "..", remote: parentRemote, node: parent},
+			)
+			for _, node := range nodes {
+				entries = append(entries, dirEntry{
+					name:   node.Name(),
+					remote: path.Join(dirRemote, node.Name()),
+					node:   node,
+				})
+			}
+			if cookie > uint64(len(entries)) {
+				err = errBadCookie
+			}
+		}
+	}
+	status := nfsStatus(dirRemote, err)
+	if status != nfs3OK {
+		w.uint32(status)
+		s.writePostOpAttr(w, dirID, nodeOrNil(dir))
+		return nil
+	}
+
+	// Encode as many entries as will fit
+	var (
+		list    xdrWriter
+		size    = 4 + 4 + 84 + 8 + 4 + 4 // status, dir attributes, verifier, end of list, eof
+		dirSize = 0
+		eof     = true
+	)
+	for i := int(cookie); i < len(entries); i++ {
+		entry := entries[i]
+		entrySize := 8 + xdrSize(len(entry.name)) + 8
+		dirSize += entrySize
+		entrySize += 4
+		if plus {
+			entrySize += 4 + 84 + 4 + xdrSize(handleSize)
+		}
+		if size+entrySize > int(maxCount) || dirSize > int(dirCount) {
+			eof = false
+			break
+		}
+		size += entrySize
+		id := s.handles.id(entry.remote)
+		list.bool(true)
+		list.uint64(id)
+		list.string(entry.name)
+		list.uint64(uint64(i + 1))
+		if plus {
+			s.writePostOpAttr(&list, id, entry.node)
+			s.writePostOpFH(&list, entry.remote)
+		}
+	}
+	if !eof && list.Len() == 0 {
+		w.uint32(nfs3ErrTooSmall)
+		s.writePostOpAttr(w, dirID, dir)
+		return nil
+	}
+	w.uint32(nfs3OK)
+	s.writePostOpAttr(w, dirID, dir)
+	w.fixed(make([]byte, 8)) // cookie verifier
+	_, _ = w.Write(list.Bytes())
+	w.bool(false)
+	w.bool(eof)
+	return nil
+}
+
+// nfsFsstat returns the space used and available
+func (s *server) nfsFsstat(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote, id, node, err := s.getNode(fh)
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	s.writePostOpAttr(w, id, node)
+	if status == nfs3OK {
+		const files = 1e9 // the number of files is unlimited
+		total, _, free := s.vfs.Statfs()
+		w.uint64(uint64(total))
+		w.uint64(uint64(free))
+		w.uint64(uint64(free))
+		w.uint64(files)
+		w.uint64(files)
+		w.uint64(files)
+		w.uint32(0) // invarsec
+	}
+	return nil
+}
+
+// nfsFsinfo returns the capabilities of the server
+func (s *server) nfsFsinfo(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote, id, node, err := s.getNode(fh)
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	s.writePostOpAttr(w, id, node)
+	if status == nfs3OK {
+		w.uint32(maxData) // rtmax
+		w.uint32(maxData) // rtpref
+		w.uint32(4096)    // rtmult
+		w.uint32(maxData) // wtmax
+		w.uint32(maxData) // wtpref
+		w.uint32(4096)    // wtmult
+		w.uint32(65536)   // dtpref
+		w.uint64(1<<63 - 1)
+		writeTime(w, time.Unix(0, 1)) // time_delta
+		w.uint32(fsfHomogeneous | fsfCanSetTime)
+	}
+	return nil
+}
+
+// nfsPathconf returns information about file names
+func (s *server) nfsPathconf(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote, id, node, err := s.getNode(fh)
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	s.writePostOpAttr(w, id, node)
+	if status == nfs3OK {
+		w.uint32(1)          // linkmax
+		w.uint32(maxNameLen) // name_max
+		w.bool(true)         // no_trunc
+		w.bool(true)         // chown_restricted
+		w.bool(s.vfs.Opt.CaseInsensitive)
+		w.bool(true) // case_preserving
+	}
+	return nil
+}
+
+// nfsCommit closes any open handles on the file which uploads it if
+// necessary
+func (s *server) nfsCommit(c *call, w *xdrWriter) error {
+	fh := c.args.opaque(fhSize)
+	_ = c.args.uint64() // offset
+	_ = c.args.uint32() // count
+	if c.args.err != nil {
+		return c.args.err
+	}
+	remote, id, node, err := s.getNode(fh)
+	if err == nil && node.IsFile() {
+		err = s.files.closeAll(id)
+	}
+	status := nfsStatus(remote, err)
+	w.uint32(status)
+	s.writeWcc(w, id, node)
+	if status == nfs3OK {
+		w.fixed(s.verifier[:])
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+package nfs
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXDR(t *testing.T) {
+	var w xdrWriter
+	w.uint32(1)
+	w.uint64(1 << 40)
+	w.bool(true)
+	w.string("hello")
+	w.opaque([]byte{1, 2, 3, 4})
+	w.fixed([]byte{5, 6})
+	assert.Equal(t, 4+8+4+xdrSize(5)+xdrSize(4)+4, w.Len())
+
+	r := newXDRReader(w.Bytes())
+	assert.Equal(t, uint32(1), r.uint32())
+	assert.Equal(t, uint64(1<<40), r.uint64())
+	assert.Equal(t, true, r.bool())
+	assert.Equal(t, "hello", r.string(16))
+	assert.Equal(t, []byte{1, 2, 3, 4}, r.opaque(16))
+	assert.Equal(t, []byte{5, 6}, r.fixed(2))
+	require.NoError(t, r.err)
+
+	// reading past the end is an error
+	assert.Equal(t, uint32(0), r.uint32())
+	assert.Equal(t, errGarbageArgs, r.err)
+
+	// strings longer than the maximum are an error
+	r = newXDRReader(w.Bytes()[16:])
+	assert.Equal(t, "", r.string(4))
+	assert.Equal(t, errGarbageArgs, r.err)
+}
+
+func TestHandleTable(t *testing.T) {
+	table := newHandleTable()
+	root := table.toHandle("")
+	remote, id, err := table.fromHandle(root)
+	require.NoError(t, err)
+	assert.Equal(t, "", remote)
+	assert.Equal(t, uint64(rootID), id)
+
+	fh := table.toHandle("dir/file")
+	_, fileID, err := table.fromHandle(fh)
+	require.NoError(t, err)
+	assert.Equal(t, fileID, table.id("dir/file"))
+
+	table.rename("dir", "newdir")
+	remote, id, err = table.fromHandle(fh)
+	require.NoError(t, err)
+	assert.Equal(t, "newdir/file", remote)
+	assert.Equal(t, fileID, id)
+
+	table.forget("newdir")
+	_, _, err = table.fromHandle(fh)
+	assert.Equal(t, errStale, err)
+
+	// handles from a different server are stale
+	_, _, err = newHandleTable().fromHandle(root)
+	assert.Equal(t, errStale, err)
+}
+
+// testClient makes NFS calls to the server
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	xid  uint32
+}
+
+// call makes an RPC call returning the reply after the accept status
+func (c *testClient) call(prog, vers, proc uint32, args func(w *xdrWriter)) (acceptStatus uint32, r *xdrReader) {
+	c.xid++
+	w := new(xdrWriter)
+	w.uint32(c.xid)
+	w.uint32(msgCall)
+	w.uint32(rpcVersion)
+	w.uint32(prog)
+	w.uint32(vers)
+	w.uint32(proc)
+	w.uint32(authUnix)
+	w.opaque(make([]byte, 20))
+	w.uint32(authNone)
+	w.opaque(nil)
+	if args != nil {
+		args(w)
+	}
+	require.NoError(c.t, writeRecord(c.conn, w.Bytes()))
+	record, err := readRecord(c.conn)
+	require.NoError(c.t, err)
+	r = newXDRReader(record)
+	assert.Equal(c.t, c.xid, r.uint32())
+	assert.Equal(c.t, uint32(msgReply), r.uint32())
+	assert.Equal(c.t, uint32(replyAccepted), r.uint32())
+	_ = r.uint32()
+	_ = r.opaque(maxAuthBytes)
+	acceptStatus = r.uint32()
+	require.NoError(c.t, r.err)
+	return acceptStatus, r
+}
+
+// nfs makes an NFS call returning the NFS status and the rest of the
+// reply
+func (c *testClient) nfs(proc uint32, args func(w *xdrWriter)) (status uint32, r *xdrReader) {
+	acceptStatus, r := c.call(nfsProgram, nfsVersion, proc, args)
+	require.Equal(c.t, uint32(acceptSuccess), acceptStatus)
+	return r.uint32(), r
+}
+
+// readAttr reads a post_op_attr returning the type and size
+func readAttr(r *xdrReader) (ftype uint32, size uint64) {
+	if !r.bool() {
+		return 0, 0
+	}
+	ftype = r.uint32()
+	_ = r.fixed(4 * 4) // mode, nlink, uid, gid
+	size = r.uint64()
+	_ = r.fixed(8*4 + 3*8) // used, rdev, fsid, fileid, times
+	return ftype, size
+}
+
+// skipWcc reads a wcc_data
+func skipWcc(r *xdrReader) {
+	if r.bool() {
+		_ = r.fixed(8 + 2*8)
+	}
+	_, _ = readAttr(r)
+}
+
+// dirOp returns a function to write a diropargs3
+func dirOp(fh []byte, name string) func(w *xdrWriter) {
+	return func(w *xdrWriter) {
+		w.opaque(fh)
+		w.string(name)
+	}
+}
+
+func TestOpenFilesNoTimeout(t *testing.T) {
+	// a zero timeout disables the idle closer rather than panicking
+	c := newOpenFiles(nil, 0)
+	c.shutdown()
+}
+
+func TestOpenFilesSlowOpen(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0666))
+	}
+	f, err := fs.NewFs(context.Background(), dir)
+	require.NoError(t, err)
+	c := newOpenFiles(vfs.New(f, nil), 0)
+	defer c.shutdown()
+
+	// a slow open doesn't hold up other handles
+	opening := make(chan struct{})
+	unblock := make(chan struct{})
+	done := make(chan *openFile)
+	go func() {
+		of, err := c.get(c.readers, 1, func() (vfs.Handle, error) {
+			close(opening)
+			<-unblock
+			return c.vfs.OpenFile("a", os.O_RDONLY, 0)
+		})
+		assert.NoError(t, err)
+		done <- of
+	}()
+	<-opening
+	of, err := c.getReader(2, "b")
+	require.NoError(t, err)
+	c.release(of)
+
+	// opening the same handle while it is being opened only keeps one
+	of, err = c.getReader(1, "a")
+	require.NoError(t, err)
+	close(unblock)
+	slowOf := <-done
+	assert.Same(t, of, slowOf)
+	assert.Equal(t, 2, of.refs)
+	c.release(of)
+	c.release(slowOf)
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "existing"), 0777))
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+
+	opt := DefaultOpt
+	opt.ListenAddr = "localhost:0"
+	s := newServer(f, &opt)
+	require.NoError(t, s.Serve())
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+	conn, err := net.Dial("tcp", s.Addr())
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	c := &testClient{t: t, conn: conn}
+
+	// Unknown programs and versions are rejected
+	acceptStatus, _ := c.call(100000, 2, 0, nil)
+	assert.Equal(t, uint32(acceptProgUnavail), acceptStatus)
+	acceptStatus, r := c.call(nfsProgram, 4, 0, nil)
+	assert.Equal(t, uint32(acceptProgMismatch), acceptStatus)
+	assert.Equal(t, uint32(nfsVersion), r.uint32())
+	assert.Equal(t, uint32(nfsVersion), r.uint32())
+	acceptStatus, _ = c.call(nfsProgram, nfsVersion, 99, nil)
+	assert.Equal(t, uint32(acceptProcUnavail), acceptStatus)
+
+	// Mount the root
+	acceptStatus, r = c.call(mountProgram, mountVersion, 1, func(w *xdrWriter) {
+		w.string("/")
+	})
+	require.Equal(t, uint32(acceptSuccess), acceptStatus)
+	require.Equal(t, uint32(mnt3OK), r.uint32())
+	root := r.opaque(fhSize)
+	require.NoError(t, r.err)
+
+	acceptStatus, r = c.call(mountProgram, mountVersion, 1, func(w *xdrWriter) {
+		w.string("/notfound")
+	})
+	require.Equal(t, uint32(acceptSuccess), acceptStatus)
+	assert.Equal(t, uint32(mnt3ErrNoEnt), r.uint32())
+
+	// GETATTR on the root
+	status, r := c.nfs(1, func(w *xdrWriter) { w.opaque(root) })
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, uint32(nf3Dir), r.uint32())
+
+	// Stale handles are detected
+	status, _ = c.nfs(1, func(w *xdrWriter) { w.opaque(make([]byte, handleSize)) })
+	assert.Equal(t, uint32(nfs3ErrStale), status)
+
+	// LOOKUP
+	status, r = c.nfs(3, dirOp(root, "existing"))
+	require.Equal(t, uint32(nfs3OK), status)
+	_ = r.opaque(fhSize)
+	ftype, _ := readAttr(r)
+	assert.Equal(t, uint32(nf3Dir), ftype)
+	status, _ = c.nfs(3, dirOp(root, "notfound"))
+	assert.Equal(t, uint32(nfs3ErrNoEnt), status)
+
+	// CREATE a file
+	status, r = c.nfs(8, func(w *xdrWriter) {
+		dirOp(root, "file.txt")(w)
+		w.uint32(createGuarded)
+		w.fixed(make([]byte, 6*4)) // empty sattr3
+	})
+	require.Equal(t, uint32(nfs3OK), status)
+	require.True(t, r.bool())
+	file := r.opaque(fhSize)
+	require.NoError(t, r.err)
+
+	// GUARDED CREATE of an existing file fails
+	status, _ = c.nfs(8, func(w *xdrWriter) {
+		dirOp(root, "existing")(w)
+		w.uint32(createGuarded)
+		w.fixed(make([]byte, 6*4))
+	})
+	assert.Equal(t, uint32(nfs3ErrExist), status)
+
+	// WRITE to it with UNSTABLE then COMMIT
+	const contents = "hello world"
+	status, r = c.nfs(7, func(w *xdrWriter) {
+		w.opaque(file)
+		w.uint64(0)
+		w.uint32(uint32(len(contents)))
+		w.uint32(unstable)
+		w.string(contents)
+	})
+	require.Equal(t, uint32(nfs3OK), status)
+	skipWcc(r)
+	assert.Equal(t, uint32(len(contents)), r.uint32())
+	assert.Equal(t, uint32(unstable), r.uint32())
+	verifier := r.fixed(8)
+	assert.Equal(t, s.verifier[:], verifier)
+
+	status, r = c.nfs(21, func(w *xdrWriter) {
+		w.opaque(file)
+		w.uint64(0)
+		w.uint32(0)
+	})
+	require.Equal(t, uint32(nfs3OK), status)
+	skipWcc(r)
+	assert.Equal(t, verifier, r.fixed(8))
+
+	data, err := os.ReadFile(filepath.Join(dir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, contents, string(data))
+
+	// READ it back
+	status, r = c.nfs(6, func(w *xdrWriter) {
+		w.opaque(file)
+		w.uint64(6)
+		w.uint32(100)
+	})
+	require.Equal(t, uint32(nfs3OK), status)
+	_, size := readAttr(r)
+	assert.Equal(t, uint64(len(contents)), size)
+	assert.Equal(t, uint32(5), r.uint32())
+	assert.True(t, r.bool())
+	assert.Equal(t, "world", string(r.opaque(maxData)))
+
+	// SETATTR the modification time
+	modTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	status, _ = c.nfs(2, func(w *xdrWriter) {
+		w.opaque(file)
+		w.fixed(make([]byte, 4*4))
+		w.uint32(0)
+		w.uint32(setToClientTime)
+		writeTime(w, modTime)
+		w.bool(false)
+	})
+	require.Equal(t, uint32(nfs3OK), status)
+	fi, err := os.Stat(filepath.Join(dir, "file.txt"))
+	require.NoError(t, err)
+	assert.True(t, modTime.Equal(fi.ModTime()))
+
+	// READDIR lists ".", ".." and the entries
+	status, r = c.nfs(16, func(w *xdrWriter) {
+		w.opaque(root)
+		w.uint64(0)
+		w.fixed(make([]byte, 8))
+		w.uint32(4096)
+	})
+	require.Equal(t, uint32(nfs3OK), status)
+	_, _ = readAttr(r)
+	_ = r.fixed(8)
+	var names []string
+	for r.bool() {
+		_ = r.uint64()
+		names = append(names, r.string(maxNameLen))
+		_ = r.uint64()
+	}
+	assert.True(t, r.bool())
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{".", "..", "existing", "file.txt"}, names)
+
+	// RENAME the file into the directory - the handle stays valid
+	status, _ = c.nfs(14, func(w *xdrWriter) {
+		dirOp(root, "file.txt")(w)
+		dirOp(root, "existing")(w)
+	})
+	assert.Equal(t, uint32(nfs3ErrExist), status, "renaming a file over a directory")
+	status, r = c.nfs(3, dirOp(root, "existing"))
+	require.Equal(t, uint32(nfs3OK), status)
+	existing := r.opaque(fhSize)
+	status, _ = c.nfs(14, func(w *xdrWriter) {
+		dirOp(root, "file.txt")(w)
+		dirOp(existing, "renamed.txt")(w)
+	})
+	require.Equal(t, uint32(nfs3OK), status)
+	status, r = c.nfs(1, func(w *xdrWriter) { w.opaque(file) })
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, uint32(nf3Reg), r.uint32())
+
+	// RMDIR of a non empty directory fails
+	status, _ = c.nfs(13, dirOp(root, "existing"))
+	assert.Equal(t, uint32(nfs3ErrNotEmpty), status)
+
+	// REMOVE the file then the directory
+	status, _ = c.nfs(12, dirOp(existing, "renamed.txt"))
+	require.Equal(t, uint32(nfs3OK), status)
+	status, _ = c.nfs(1, func(w *xdrWriter) { w.opaque(file) })
+	assert.Equal(t, uint32(nfs3ErrStale), status)
+	status, _ = c.nfs(13, dirOp(root, "existing"))
+	require.Equal(t, uint32(nfs3OK), status)
+	_, err = os.Stat(filepath.Join(dir, "existing"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,155 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ONC RPC version 2 (RFC 5531) constants
+const (
+	rpcVersion = 2
+
+	msgCall  = 0
+	msgReply = 1
+
+	replyAccepted = 0
+	replyDenied   = 1
+
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+	acceptSystemErr    = 5
+
+	rejectRPCMismatch = 0
+
+	authNone = 0
+	authUnix = 1
+
+	maxAuthBytes = 400
+)
+
+// Largest RPC record accepted - this must be bigger than the largest
+// WRITE we advertise in FSINFO plus the headers.
+const maxRecordSize = maxData + 64*1024
+
+// Flag set in the record marking header on the last fragment
+const lastFragment = 0x80000000
+
+var (
+	errRecordTooBig = errors.New("RPC record too big")
+	errRPCMismatch  = errors.New("unsupported RPC version")
+)
+
+// readRecord reads an RPC record split into fragments using the record
+// marking standard for RPC over TCP
+func readRecord(in io.Reader) (record []byte, err error) {
+	var header [4]byte
+	for {
+		_, err = io.ReadFull(in, header[:])
+		if err != nil {
+			return nil, err
+		}
+		marker := binary.BigEndian.Uint32(header[:])
+		size := int(marker &^ lastFragment)
+		if len(record)+size > maxRecordSize {
+			return nil, errRecordTooBig
+		}
+		start := len(record)
+		record = append(record, make([]byte, size)...)
+		_, err = io.ReadFull(in, record[start:])
+		if err != nil {
+			return nil, err
+		}
+		if marker&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+// writeRecord writes reply as a single fragment record
+func writeRecord(out io.Writer, reply []byte) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], lastFragment|uint32(len(reply)))
+	_, err := out.Write(append(header[:], reply...))
+	return err
+}
+
+// call is a decoded RPC call
+type call struct {
+	xid     uint32
+	prog    uint32
+	vers    uint32
+	proc    uint32
+	args    *xdrReader // the procedure arguments
+	remote  string     // address of the client
+	program string     // name of the program for logging
+}
+
+// String describes the call for logging
+func (c *call) String() string {
+	return fmt.Sprintf("%s xid=%08x from %s", c.program, c.xid, c.remote)
+}
+
+// parseCall decodes the RPC header of a call message
+func parseCall(record []byte) (*call, error) {
+	r := newXDRReader(record)
+	c := &call{
+		xid: r.uint32(),
+	}
+	if msgType := r.uint32(); r.err == nil && msgType != msgCall {
+		return nil, fmt.Errorf("unexpected RPC message type %d", msgType)
+	}
+	if vers := r.uint32(); r.err == nil && vers != rpcVersion {
+		return c, errRPCMismatch
+	}
+	c.prog = r.uint32()
+	c.vers = r.uint32()
+	c.proc = r.uint32()
+	// credentials and verifier - we accept any
+	_ = r.uint32()
+	_ = r.opaque(maxAuthBytes)
+	_ = r.uint32()
+	_ = r.opaque(maxAuthBytes)
+	if r.err != nil {
+		return nil, r.err
+	}
+	c.args = r
+	return c, nil
+}
+
+// replyHeader starts an accepted reply to c with the status given
+func replyHeader(c *call, status uint32) *xdrWriter {
+	w := new(xdrWriter)
+	w.uint32(c.xid)
+	w.uint32(msgReply)
+	w.uint32(replyAccepted)
+	w.uint32(authNone)
+	w.uint32(0)
+	w.uint32(status)
+	return w
+}
+
+// replyMismatch makes the reply for a call with an unsupported RPC version
+func replyMismatch(c *call) *xdrWriter {
+	w := new(xdrWriter)
+	w.uint32(c.xid)
+	w.uint32(msgReply)
+	w.uint32(replyDenied)
+	w.uint32(rejectRPCMismatch)
+	w.uint32(rpcVersion)
+	w.uint32(rpcVersion)
+	return w
+}
+
+// replyProgMismatch makes the reply for a call to a supported program
+// with an unsupported version
+func replyProgMismatch(c *call, low, high uint32) *xdrWriter {
+	w := replyHeader(c, acceptProgMismatch)
+	w.uint32(low)
+	w.uint32(high)
+	return w
+}
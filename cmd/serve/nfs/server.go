@@ -0,0 +1,210 @@
+package nfs
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+// Maximum number of calls from one connection being processed at
+// once. The Linux client sends the WRITEs for a file in parallel so
+// these need processing concurrently for the VFS to put them back in
+// order.
+const maxConcurrentCalls = 16
+
+// procedure describes an RPC procedure
+//
+// fn decodes the arguments of the call and writes the results to w.
+// It should return errGarbageArgs if the arguments can't be decoded in
+// which case w is discarded.
+type procedure struct {
+	name string
+	fn   func(s *server, c *call, w *xdrWriter) error
+}
+
+// server contains everything to run the server
+type server struct {
+	f        fs.Fs
+	opt      Options
+	vfs      *vfs.VFS
+	handles  *handleTable
+	files    *openFiles
+	verifier [8]byte // write verifier - changes when the server restarts
+	listener net.Listener
+	waitChan chan struct{} // for waiting on the listener to close
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{} // open connections
+}
+
+func newServer(f fs.Fs, opt *Options) *server {
+	s := &server{
+		f:        f,
+		opt:      *opt,
+		vfs:      vfs.New(f, &vfsflags.Opt),
+		handles:  newHandleTable(),
+		waitChan: make(chan struct{}),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.files = newOpenFiles(s.vfs, opt.HandleTimeout)
+	_, _ = rand.Read(s.verifier[:])
+	return s
+}
+
+// Serve starts the server listening
+func (s *server) Serve() (err error) {
+	s.listener, err = net.Listen("tcp", s.opt.ListenAddr)
+	if err != nil {
+		return err
+	}
+	fs.Logf(nil, "NFS server listening on %v", s.listener.Addr())
+	go s.acceptConnections()
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Wait blocks while the listener is open.
+func (s *server) Wait() {
+	<-s.waitChan
+}
+
+// Close shuts the running server down
+func (s *server) Close() {
+	err := s.listener.Close()
+	if err != nil {
+		fs.Errorf(nil, "Error on closing NFS server: %v", err)
+		return
+	}
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.files.shutdown()
+	close(s.waitChan)
+}
+
+// Accept connections and call them in a go routine
+func (s *server) acceptConnections() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if strings.Contains(err.Error(), "use of closed network connection") {
+				return
+			}
+			fs.Errorf(nil, "Failed to accept incoming connection: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads RPC calls from conn and sends the replies until the
+// connection is closed.
+func (s *server) serveConn(conn net.Conn) {
+	remote := conn.RemoteAddr().String()
+	fs.Debugf(nil, "NFS connection from %s", remote)
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+		fs.Debugf(nil, "NFS connection from %s closed", remote)
+	}()
+
+	var (
+		wg      sync.WaitGroup
+		writeMu sync.Mutex
+		tokens  = make(chan struct{}, maxConcurrentCalls)
+		in      = bufio.NewReader(conn)
+	)
+	defer wg.Wait()
+	for {
+		record, err := readRecord(in)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				fs.Errorf(nil, "NFS connection from %s: %v", remote, err)
+			}
+			return
+		}
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			reply := s.handleRecord(record, remote)
+			if reply == nil {
+				return
+			}
+			writeMu.Lock()
+			err := writeRecord(conn, reply)
+			writeMu.Unlock()
+			if err != nil {
+				fs.Debugf(nil, "NFS connection from %s: failed to send reply: %v", remote, err)
+			}
+		}()
+	}
+}
+
+// handleRecord decodes the RPC call in record, runs it and returns
+// the reply, or nil if there should be no reply.
+func (s *server) handleRecord(record []byte, remote string) []byte {
+	c, err := parseCall(record)
+	if err == errRPCMismatch {
+		return replyMismatch(c).Bytes()
+	} else if err != nil {
+		fs.Debugf(nil, "NFS connection from %s: ignoring bad RPC call: %v", remote, err)
+		return nil
+	}
+	c.remote = remote
+	var procedures []procedure
+	switch c.prog {
+	case mountProgram:
+		c.program = "MOUNT"
+		if c.vers != mountVersion {
+			return replyProgMismatch(c, mountVersion, mountVersion).Bytes()
+		}
+		procedures = mountProcedures
+	case nfsProgram:
+		c.program = "NFS"
+		if c.vers != nfsVersion {
+			return replyProgMismatch(c, nfsVersion, nfsVersion).Bytes()
+		}
+		procedures = nfsProcedures
+	default:
+		fs.Debugf(nil, "NFS connection from %s: program %d not supported", remote, c.prog)
+		return replyHeader(c, acceptProgUnavail).Bytes()
+	}
+	if int(c.proc) >= len(procedures) || procedures[c.proc].fn == nil {
+		fs.Debugf(nil, "%v: procedure %d not supported", c, c.proc)
+		return replyHeader(c, acceptProcUnavail).Bytes()
+	}
+	proc := procedures[c.proc]
+	results := new(xdrWriter)
+	err = proc.fn(s, c, results)
+	if err != nil {
+		fs.Debugf(nil, "%v: %s: %v", c, proc.name, err)
+		return replyHeader(c, acceptGarbageArgs).Bytes()
+	}
+	w := replyHeader(c, acceptSuccess)
+	_, _ = w.Write(results.Bytes())
+	return w.Bytes()
+}
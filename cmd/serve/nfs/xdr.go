@@ -0,0 +1,141 @@
+package nfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// errGarbageArgs is returned when the arguments of a call can't be decoded
+var errGarbageArgs = errors.New("can't decode arguments")
+
+// xdrReader decodes XDR (RFC 4506) encoded data
+//
+// The first error is remembered and all subsequent reads return zero
+// values, so it only needs to be checked once all the fields have
+// been read.
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+// newXDRReader makes a reader to decode buf
+func newXDRReader(buf []byte) *xdrReader {
+	return &xdrReader{buf: buf}
+}
+
+// next returns the next n bytes, padded to a multiple of 4
+func (r *xdrReader) next(n int) []byte {
+	padded := (n + 3) &^ 3
+	if r.err != nil || n < 0 || padded > len(r.buf) {
+		r.err = errGarbageArgs
+		return nil
+	}
+	p := r.buf[:n]
+	r.buf = r.buf[padded:]
+	return p
+}
+
+// uint32 reads an unsigned int
+func (r *xdrReader) uint32() uint32 {
+	p := r.next(4)
+	if p == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(p)
+}
+
+// uint64 reads an unsigned hyper
+func (r *xdrReader) uint64() uint64 {
+	p := r.next(8)
+	if p == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(p)
+}
+
+// bool reads a boolean
+func (r *xdrReader) bool() bool {
+	return r.uint32() != 0
+}
+
+// fixed reads fixed length opaque data
+func (r *xdrReader) fixed(n int) []byte {
+	return r.next(n)
+}
+
+// opaque reads variable length opaque data of at most max bytes
+func (r *xdrReader) opaque(max int) []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if n > uint32(max) {
+		r.err = errGarbageArgs
+		return nil
+	}
+	return r.next(int(n))
+}
+
+// string reads a string of at most max bytes
+func (r *xdrReader) string(max int) string {
+	return string(r.opaque(max))
+}
+
+// xdrWriter encodes XDR data
+type xdrWriter struct {
+	bytes.Buffer
+}
+
+// uint32 writes an unsigned int
+func (w *xdrWriter) uint32(v uint32) {
+	var p [4]byte
+	binary.BigEndian.PutUint32(p[:], v)
+	_, _ = w.Write(p[:])
+}
+
+// uint64 writes an unsigned hyper
+func (w *xdrWriter) uint64(v uint64) {
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], v)
+	_, _ = w.Write(p[:])
+}
+
+// bool writes a boolean
+func (w *xdrWriter) bool(v bool) {
+	if v {
+		w.uint32(1)
+	} else {
+		w.uint32(0)
+	}
+}
+
+// fixed writes fixed length opaque data
+func (w *xdrWriter) fixed(p []byte) {
+	_, _ = w.Write(p)
+	w.pad(len(p))
+}
+
+// opaque writes variable length opaque data
+func (w *xdrWriter) opaque(p []byte) {
+	w.uint32(uint32(len(p)))
+	w.fixed(p)
+}
+
+// string writes a string
+func (w *xdrWriter) string(s string) {
+	w.uint32(uint32(len(s)))
+	_, _ = w.WriteString(s)
+	w.pad(len(s))
+}
+
+// pad writes the zero bytes needed to align n bytes to 4 bytes
+func (w *xdrWriter) pad(n int) {
+	var zero [3]byte
+	_, _ = w.Write(zero[:(4-n%4)%4])
+}
+
+// xdrSize returns the encoded size of n bytes of opaque data
+func xdrSize(n int) int {
+	return 4 + (n+3)&^3
+}
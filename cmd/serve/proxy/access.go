@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+// defaultAccessUser is the user in the access rules file used for
+// users which aren't listed
+const defaultAccessUser = "*"
+
+// AccessRules are the access restrictions for each user read from
+// the --access-rules file
+type AccessRules struct {
+	users map[string]*vfscommon.Access
+
+	mu    sync.Mutex
+	vfses map[string]*vfs.VFS // VFS for each user indexed by user
+}
+
+// NewAccessRules reads the access rules file set in opt returning nil
+// if there isn't one
+func NewAccessRules(opt *Options) (*AccessRules, error) {
+	if opt.AccessRules == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(opt.AccessRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access rules: %w", err)
+	}
+	ar := &AccessRules{
+		vfses: make(map[string]*vfs.VFS),
+	}
+	err = json.Unmarshal(data, &ar.users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse access rules %q: %w", opt.AccessRules, err)
+	}
+	fs.Infof(nil, "Read access rules for %d users from %q", len(ar.users), opt.AccessRules)
+	return ar, nil
+}
+
+// Get returns the access restrictions for user
+//
+// Users which aren't in the file get a copy of the rules for "*" or
+// no access at all if that isn't set. The copy is needed so each user
+// gets their own VFS, and so their own quota, as vfs.New re-uses a VFS
+// with the same options.
+func (ar *AccessRules) Get(user string) *vfscommon.Access {
+	if access, ok := ar.users[user]; ok && access != nil {
+		return access
+	}
+	if access, ok := ar.users[defaultAccessUser]; ok && access != nil {
+		userAccess := *access
+		return &userAccess
+	}
+	return &vfscommon.Access{}
+}
+
+// VFS returns the VFS for f with the access restrictions for user
+func (ar *AccessRules) VFS(f fs.Fs, user string) *vfs.VFS {
+	access := ar.Get(user)
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	VFS := ar.vfses[user]
+	if VFS == nil || VFS.Fs() != f {
+		opt := vfsflags.Opt
+		opt.Access = access
+		VFS = vfs.New(f, &opt)
+		ar.vfses[user] = VFS
+	}
+	return VFS
+}
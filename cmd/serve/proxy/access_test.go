@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessRules(t *testing.T) {
+	ar, err := NewAccessRules(&Options{})
+	require.NoError(t, err)
+	assert.Nil(t, ar)
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+	"partner": {"rules": [{"path": "/inbox", "perms": "w"}], "max_files": 10},
+	"*": {"rules": [{"path": "/", "perms": "r"}]}
+}`), 0600))
+	ar, err = NewAccessRules(&Options{AccessRules: path})
+	require.NoError(t, err)
+
+	partner := ar.Get("partner")
+	assert.Equal(t, []vfscommon.AccessRule{{Path: "/inbox", Perms: vfscommon.PermWrite}}, partner.Rules)
+	assert.Equal(t, int64(10), partner.MaxFiles)
+	other := ar.Get("other")
+	assert.Equal(t, []vfscommon.AccessRule{{Path: "/", Perms: vfscommon.PermRead}}, other.Rules)
+
+	// Without a default users get no access
+	delete(ar.users, defaultAccessUser)
+	assert.Equal(t, vfscommon.PermNone, ar.Get("other").Perms("file"))
+
+	// The access rules are used for users of the proxy
+	opt := DefaultOpt
+	opt.AuthProxy = "go run proxy_code.go"
+	p := New(context.Background(), &opt)
+	p.SetAccessRules(ar)
+	VFS, _, err := p.Call("partner", "pass", false)
+	require.NoError(t, err)
+	assert.Equal(t, partner, VFS.Opt.Access)
+
+	// The proxy can return its own access rules
+	access := `{"rules": [{"path": "/", "perms": "rw"}]}`
+	config, err := p.run(map[string]string{
+		"user":    "me",
+		"_access": access,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, access, config["_access"])
+	value, err := p.callIn("me", "pass", false, map[string]string{
+		"user":    "me",
+		"pass":    "pass",
+		"_access": access,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, vfscommon.PermRead|vfscommon.PermWrite, value.(cacheEntry).vfs.Opt.Access.Perms("file"))
+	p.vfsCache.Clear()
+}
+
+func TestAccessRulesDefaultUsers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+	"*": {"rules": [{"path": "/", "perms": "rw"}], "max_size": "5"}
+}`), 0600))
+	ar, err := NewAccessRules(&Options{AccessRules: path})
+	require.NoError(t, err)
+	f, err := fs.NewFs(context.Background(), t.TempDir())
+	require.NoError(t, err)
+
+	// Users under "*" get their own VFS
+	alice := ar.VFS(f, "alice")
+	bob := ar.VFS(f, "bob")
+	assert.NotSame(t, alice, bob)
+	assert.NotSame(t, alice.Opt.Access, bob.Opt.Access)
+	assert.Equal(t, alice.Opt.Access, bob.Opt.Access)
+	assert.Same(t, alice, ar.VFS(f, "alice"))
+
+	write := func(VFS *vfs.VFS, name, contents string) error {
+		fd, err := VFS.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return err
+		}
+		_, err = fd.Write([]byte(contents))
+		require.NoError(t, err)
+		return fd.Close()
+	}
+
+	// and their own quota, so alice using up hers doesn't use up bob's
+	_, used, _ := bob.Statfs()
+	assert.Equal(t, int64(0), used)
+	require.NoError(t, write(alice, "alice1", "12345"))
+	assert.Equal(t, vfs.EPERM, write(alice, "alice2", "1"))
+	_, used, _ = bob.Statfs()
+	assert.Equal(t, int64(0), used)
+	require.NoError(t, write(bob, "bob1", "1"))
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/rclone/rclone/fs/config/obscure"
 	libcache "github.com/rclone/rclone/lib/cache"
 	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/rclone/rclone/vfs/vfsflags"
 )
 
@@ -49,8 +50,14 @@ config.
 This config generated must have this extra parameter
 - |_root| - root to use for the backend
 
-And it may have this parameter
+And it may have these parameters
 - |_obscure| - comma separated strings for parameters to obscure
+- |_access| - the access restrictions for the user, see below
+- |_vfs_cache_mode| - the |--vfs-cache-mode| to use for the user
+- |_vfs_cache_max_size| - the |--vfs-cache-max-size| to use for the user
+- |_vfs_cache_max_age| - the |--vfs-cache-max-age| to use for the user
+- |_bwlimit| - the bandwidth limit for each of the user's connections
+  in each direction, e.g. |1M| - only used by |serve ftp| at the moment
 
 If password authentication was used by the client, input to the proxy
 process (on STDIN) would look similar to this:
@@ -72,6 +79,24 @@ proxy process (on STDIN) would look similar to this:
 }
 |||
 
+If the client authenticated with an OpenID Connect bearer token (see
+|--oidc-issuer|) then the verified claims of the token are passed
+instead of a password, so the proxy can choose the backend from them,
+for example from the user's groups. The backend returned for the
+claims is kept apart from any returned for a password login of the
+same user.
+
+|||
+{
+	"user": "me",
+	"claims": {
+		"iss": "https://sso.example.com",
+		"sub": "me",
+		"groups": ["staff"]
+	}
+}
+|||
+
 And as an example return this on STDOUT
 
 |||
@@ -104,11 +129,66 @@ before it takes effect.
 
 This can be used to build general purpose proxies to any kind of
 backend that rclone supports.  
+
+### Access rules
+
+By default a logged in user can read, write and delete anything in
+the remote they are given. This can be restricted for each user with
+access rules. These are read from the JSON file given by
+|--access-rules /path/to/rules.json|, or can be returned by the auth
+proxy in |_access| which takes precedence.
+
+The file contains the access rules for each user, with |*| used for
+any user not listed. Users not listed get no access if |*| isn't set.
+
+|||
+{
+	"partner": {
+		"rules": [
+			{"path": "/", "perms": ""},
+			{"path": "/inbox", "perms": "w"}
+		],
+		"max_size": "10G",
+		"max_files": 1000
+	},
+	"*": {
+		"rules": [
+			{"path": "/", "perms": "r"},
+			{"path": "/shared", "perms": "rwdm"}
+		]
+	}
+}
+|||
+
+Each rule gives the permissions for a path and everything inside it,
+with the rule with the longest matching path being used. The
+permissions are any of these letters
+- |r| - read files and list directories
+- |w| - upload files, overwrite them and set their modification times
+- |d| - delete files and directories and move them away
+- |m| - make directories
+
+Paths not covered by any rule can't be seen, except for the
+directories leading to a rule so it can be reached. Directory
+listings only show files with |r| permission, so the |partner| user
+above can upload files to |/inbox| but can't see or download them.
+
+|max_size| and |max_files| are optional quotas on the total size and
+number of files in the paths the user can write to. These are
+counted from the remote when first needed and checked when a file is
+opened for writing. Writes which would take the file past the space
+left in the size quota when it was opened fail, so only uploading
+several files at once can take a user over it. The size quota is
+reported to clients as the size of the disk.
+
+The rules are enforced by the VFS layer so they apply to |serve sftp|,
+|serve ftp| and |serve webdav| whether |--auth-proxy| is used or not.
 `, "|", "`", -1)
 
 // Options is options for creating the proxy
 type Options struct {
-	AuthProxy string
+	AuthProxy   string
+	AccessRules string // file of access restrictions for each user
 }
 
 // DefaultOpt is the default values uses for Opt
@@ -122,12 +202,15 @@ type Proxy struct {
 	vfsCache *libcache.Cache
 	ctx      context.Context // for global config
 	Opt      Options
+	access   *AccessRules // access restrictions for users - may be nil
 }
 
 // cacheEntry is what is stored in the vfsCache
 type cacheEntry struct {
-	vfs    *vfs.VFS          // stored VFS
-	pwHash [sha256.Size]byte // sha256 hash of the password/publicKey
+	vfs     *vfs.VFS          // stored VFS
+	pwHash  [sha256.Size]byte // sha256 hash of the password/publicKey
+	claims  bool              // set if made from bearer token claims
+	bwlimit fs.SizeSuffix     // bandwidth limit for each connection or -1 if not set
 }
 
 // New creates a new proxy with the Options passed in
@@ -140,8 +223,14 @@ func New(ctx context.Context, opt *Options) *Proxy {
 	}
 }
 
+// SetAccessRules sets the access restrictions used for users if the
+// proxy doesn't return any
+func (p *Proxy) SetAccessRules(access *AccessRules) {
+	p.access = access
+}
+
 // run the proxy command returning a config map
-func (p *Proxy) run(in map[string]string) (config configmap.Simple, err error) {
+func (p *Proxy) run(in interface{}) (config configmap.Simple, err error) {
 	cmd := exec.Command(p.cmdLine[0], p.cmdLine[1:]...)
 	inBytes, err := json.MarshalIndent(in, "", "\t")
 	if err != nil {
@@ -158,10 +247,24 @@ func (p *Proxy) run(in map[string]string) (config configmap.Simple, err error) {
 	if err != nil {
 		return nil, fmt.Errorf("proxy: failed on %v: %q: %w", p.cmdLine, strings.TrimSpace(string(stderr.Bytes())), err)
 	}
-	err = json.Unmarshal(stdout.Bytes(), &config)
+	var out map[string]json.RawMessage
+	err = json.Unmarshal(stdout.Bytes(), &out)
 	if err != nil {
 		return nil, fmt.Errorf("proxy: failed to read output: %q: %w", string(stdout.Bytes()), err)
 	}
+	config = make(configmap.Simple, len(out))
+	for key, rawValue := range out {
+		var value string
+		err = json.Unmarshal(rawValue, &value)
+		if err != nil && key == "_access" {
+			// _access may be an object which is stored as JSON
+			value, err = string(rawValue), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("proxy: failed to read output: %q: %w", string(stdout.Bytes()), err)
+		}
+		config[key] = value
+	}
 	fs.Debugf(nil, "Proxy returned in %v", duration)
 
 	// Obscure any values in the config map that need it
@@ -181,22 +284,48 @@ func (p *Proxy) run(in map[string]string) (config configmap.Simple, err error) {
 	return config, nil
 }
 
+// setVFSOptions sets the VFS options for the user from the _vfs
+// parameters of config returned by the proxy
+func setVFSOptions(opt *vfscommon.Options, config configmap.Simple) error {
+	if value, ok := config.Get("_vfs_cache_mode"); ok {
+		if err := opt.CacheMode.Set(value); err != nil {
+			return fmt.Errorf("proxy: bad _vfs_cache_mode: %w", err)
+		}
+	}
+	if value, ok := config.Get("_vfs_cache_max_size"); ok {
+		if err := opt.CacheMaxSize.Set(value); err != nil {
+			return fmt.Errorf("proxy: bad _vfs_cache_max_size: %w", err)
+		}
+	}
+	if value, ok := config.Get("_vfs_cache_max_age"); ok {
+		age, err := fs.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("proxy: bad _vfs_cache_max_age: %w", err)
+		}
+		opt.CacheMaxAge = age
+	}
+	return nil
+}
+
 // call runs the auth proxy and returns a cacheEntry and an error
 func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, err error) {
-	var config configmap.Simple
-	// Contact the proxy
 	if isPublicKey {
-		config, err = p.run(map[string]string{
+		return p.callIn(user, auth, false, map[string]string{
 			"user":       user,
 			"public_key": auth,
 		})
-	} else {
-		config, err = p.run(map[string]string{
-			"user": user,
-			"pass": auth,
-		})
 	}
+	return p.callIn(user, auth, false, map[string]string{
+		"user": user,
+		"pass": auth,
+	})
+}
 
+// callIn runs the auth proxy with the input in and returns a
+// cacheEntry and an error
+func (p *Proxy) callIn(user, auth string, claims bool, in interface{}) (value interface{}, err error) {
+	// Contact the proxy
+	config, err := p.run(in)
 	if err != nil {
 		return nil, err
 	}
@@ -217,12 +346,48 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 		return nil, fmt.Errorf("proxy: couldn't find backend for %q: %w", fsName, err)
 	}
 
+	// Read the access restrictions
+	var access *vfscommon.Access
+	if p.access != nil {
+		access = p.access.Get(user)
+	}
+	if accessJSON, ok := config.Get("_access"); ok {
+		access = new(vfscommon.Access)
+		err = json.Unmarshal([]byte(accessJSON), access)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: failed to read _access: %w", err)
+		}
+	}
+
+	// Read the VFS options and bandwidth limit for the user
+	opt := vfsflags.Opt
+	opt.Access = access
+	err = setVFSOptions(&opt, config)
+	if err != nil {
+		return nil, err
+	}
+	bwlimit := fs.SizeSuffix(-1)
+	if value, ok := config.Get("_bwlimit"); ok {
+		err = bwlimit.Set(value)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: bad _bwlimit: %w", err)
+		}
+	}
+
 	// base name of config on user name.  This may appear in logs
 	name := "proxy-" + user
+	key := user
+	if claims {
+		// Keep logins with claims apart from those with a
+		// password or public key as the proxy may return a
+		// different backend for them
+		name = "bearer-" + user
+		key = claimsKey(user)
+	}
 	fsString := name + ":" + root
 
 	// Look for fs in the VFS cache
-	value, err = p.vfsCache.Get(user, func(key string) (value interface{}, ok bool, err error) {
+	value, err = p.vfsCache.Get(key, func(key string) (value interface{}, ok bool, err error) {
 		// Create the Fs from the cache
 		f, err := cache.GetFn(p.ctx, fsString, func(ctx context.Context, fsString string) (fs.Fs, error) {
 			// Update the config with the default values
@@ -242,8 +407,10 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 		// need to in memory. An attacker would find it easier to go
 		// after the unencrypted password in memory most likely.
 		entry := cacheEntry{
-			vfs:    vfs.New(f, &vfsflags.Opt),
-			pwHash: sha256.Sum256([]byte(auth)),
+			vfs:     vfs.New(f, &opt),
+			pwHash:  sha256.Sum256([]byte(auth)),
+			claims:  claims,
+			bwlimit: bwlimit,
 		}
 		return entry, true, nil
 	})
@@ -256,6 +423,27 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 // Call runs the auth proxy with the username and password/public key provided
 // returning a *vfs.VFS and the key used in the VFS cache.
 func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey string, err error) {
+	entry, err := p.callEntry(user, auth, isPublicKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry.vfs, user, nil
+}
+
+// CallBwLimit is like Call but returns the bandwidth limit for each
+// connection returned by the proxy in _bwlimit, or -1 if it wasn't
+// set, from the same cache entry as the *vfs.VFS.
+func (p *Proxy) CallBwLimit(user, auth string, isPublicKey bool) (VFS *vfs.VFS, bwlimit fs.SizeSuffix, err error) {
+	entry, err := p.callEntry(user, auth, isPublicKey)
+	if err != nil {
+		return nil, -1, err
+	}
+	return entry.vfs, entry.bwlimit, nil
+}
+
+// callEntry runs the auth proxy with the username and password/public
+// key provided returning the cacheEntry for the user.
+func (p *Proxy) callEntry(user, auth string, isPublicKey bool) (entry cacheEntry, err error) {
 	// Look in the cache first
 	value, ok := p.vfsCache.GetMaybe(user)
 
@@ -263,14 +451,18 @@ func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey
 	if !ok {
 		value, err = p.call(user, auth, isPublicKey)
 		if err != nil {
-			return nil, "", err
+			return cacheEntry{}, err
 		}
 	}
 
 	// check we got what we were expecting
-	entry, ok := value.(cacheEntry)
+	entry, ok = value.(cacheEntry)
 	if !ok {
-		return nil, "", fmt.Errorf("proxy: value is not cache entry: %#v", value)
+		return cacheEntry{}, fmt.Errorf("proxy: value is not cache entry: %#v", value)
+	}
+
+	if entry.claims {
+		return cacheEntry{}, errors.New("proxy: user is logged in with a bearer token")
 	}
 
 	// Check the password / public key is correct in the cached entry.  This
@@ -281,12 +473,54 @@ func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey
 	authHash := sha256.Sum256([]byte(auth))
 	if subtle.ConstantTimeCompare(authHash[:], entry.pwHash[:]) != 1 {
 		if isPublicKey {
-			return nil, "", errors.New("proxy: incorrect public key")
+			return cacheEntry{}, errors.New("proxy: incorrect public key")
 		}
-		return nil, "", errors.New("proxy: incorrect password")
+		return cacheEntry{}, errors.New("proxy: incorrect password")
 	}
 
-	return entry.vfs, user, nil
+	return entry, nil
+}
+
+// claimsKey returns the key in the VFS cache of user logged in with
+// the claims of a bearer token
+func claimsKey(user string) string {
+	return "bearer:" + user
+}
+
+// CallClaims runs the auth proxy with the username and the claims of
+// a verified bearer token returning a *vfs.VFS and the key used in the
+// VFS cache.
+//
+// The token must have been verified before calling this.
+func (p *Proxy) CallClaims(user string, claims map[string]interface{}) (VFS *vfs.VFS, vfsKey string, err error) {
+	// Look in the cache first
+	key := claimsKey(user)
+	value, ok := p.vfsCache.GetMaybe(key)
+
+	// If not found then call the proxy for a fresh answer
+	if !ok {
+		value, err = p.callIn(user, "", true, map[string]interface{}{
+			"user":   user,
+			"claims": claims,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	// check we got what we were expecting
+	entry, ok := value.(cacheEntry)
+	if !ok {
+		return nil, "", fmt.Errorf("proxy: value is not cache entry: %#v", value)
+	}
+
+	// Don't reuse an entry made from a password or public key as
+	// the proxy may have returned a different backend for the claims
+	if !entry.claims {
+		return nil, "", errors.New("proxy: user is logged in with a password or public key")
+	}
+
+	return entry.vfs, key, nil
 }
 
 // Get VFS from the cache using key - returns nil if not found
@@ -12,15 +12,19 @@ import (
 
 func main() {
 	// Read the input
-	var in map[string]string
+	var in map[string]interface{}
 	err := json.NewDecoder(os.Stdin).Decode(&in)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Write the output
+	// Write the output ignoring anything which isn't a string
 	var out = map[string]string{}
-	for k, v := range in {
+	for k, value := range in {
+		v, ok := value.(string)
+		if !ok {
+			continue
+		}
 		switch k {
 		case "user":
 			v += "-test"
@@ -9,10 +9,13 @@ import (
 	"log"
 	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/ssh"
@@ -232,4 +235,88 @@ func TestRun(t *testing.T) {
 		// check cache is at the same level
 		assert.Equal(t, 1, p.vfsCache.Entries())
 	})
+	t.Run("CallClaims", func(t *testing.T) {
+		// check cache empty
+		assert.Equal(t, 0, p.vfsCache.Entries())
+		defer p.vfsCache.Clear()
+
+		claims := map[string]interface{}{
+			"sub":    testUser,
+			"groups": []string{"staff"},
+		}
+		vfs, vfsKey, err := p.CallClaims(testUser, claims)
+		require.NoError(t, err)
+		require.NotNil(t, vfs)
+		assert.Equal(t, "bearer-"+testUser, vfs.Fs().Name())
+		assert.Equal(t, claimsKey(testUser), vfsKey)
+
+		// now try again from the cache
+		vfs2, vfsKey, err := p.CallClaims(testUser, claims)
+		require.NoError(t, err)
+		assert.Equal(t, vfs, vfs2)
+		assert.Equal(t, claimsKey(testUser), vfsKey)
+		assert.Equal(t, 1, p.vfsCache.Entries())
+
+		// a password login for the user doesn't get the entry
+		vfs2, vfsKey, err = p.Call(testUser, testPass, false)
+		require.NoError(t, err)
+		assert.NotEqual(t, vfs, vfs2)
+		assert.Equal(t, "proxy-"+testUser, vfs2.Fs().Name())
+		assert.Equal(t, testUser, vfsKey)
+		assert.Equal(t, 2, p.vfsCache.Entries())
+	})
+
+	t.Run("VFS options and bwlimit", func(t *testing.T) {
+		// check cache empty
+		assert.Equal(t, 0, p.vfsCache.Entries())
+		defer p.vfsCache.Clear()
+
+		_, err := p.callIn(testUser, testPass, false, map[string]string{
+			"user":                testUser,
+			"_vfs_cache_mode":     "writes",
+			"_vfs_cache_max_size": "10M",
+			"_vfs_cache_max_age":  "1h",
+			"_bwlimit":            "1M",
+		})
+		require.NoError(t, err)
+		vfs, bwlimit, err := p.CallBwLimit(testUser, testPass, false)
+		require.NoError(t, err)
+		require.NotNil(t, vfs)
+		assert.Equal(t, vfs, p.Get(testUser))
+		assert.Equal(t, vfscommon.CacheModeWrites, vfs.Opt.CacheMode)
+		assert.Equal(t, fs.SizeSuffix(10*1024*1024), vfs.Opt.CacheMaxSize)
+		assert.Equal(t, time.Hour, vfs.Opt.CacheMaxAge)
+		assert.Equal(t, fs.SizeSuffix(1024*1024), bwlimit)
+
+		// the password is checked before returning the bwlimit
+		_, bwlimit, err = p.CallBwLimit(testUser, testPass+"wrong", false)
+		assert.ErrorContains(t, err, "incorrect password")
+		assert.Equal(t, fs.SizeSuffix(-1), bwlimit)
+
+		_, err = p.callIn("other", testPass, false, map[string]string{
+			"user":            "other",
+			"_vfs_cache_mode": "potato",
+		})
+		assert.ErrorContains(t, err, "_vfs_cache_mode")
+	})
+
+	t.Run("CallClaims after password", func(t *testing.T) {
+		// check cache empty
+		assert.Equal(t, 0, p.vfsCache.Entries())
+		defer p.vfsCache.Clear()
+
+		vfs, _, err := p.Call(testUser, testPass, false)
+		require.NoError(t, err)
+
+		// the password login doesn't stop the claims login
+		vfs2, vfsKey, err := p.CallClaims(testUser, map[string]interface{}{"sub": testUser})
+		require.NoError(t, err)
+		assert.NotEqual(t, vfs, vfs2)
+		assert.Equal(t, claimsKey(testUser), vfsKey)
+
+		// and the password is still checked for password logins
+		_, _, err = p.Call(testUser, testPass+"wrong", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "incorrect password")
+	})
 }
@@ -15,4 +15,5 @@ var (
 // AddFlags adds the non filing system specific flags to the command
 func AddFlags(flagSet *pflag.FlagSet) {
 	flags.StringVarP(flagSet, &Opt.AuthProxy, "auth-proxy", "", Opt.AuthProxy, "A program to use to create the backend from the auth")
+	flags.StringVarP(flagSet, &Opt.AccessRules, "access-rules", "", Opt.AccessRules, "A JSON file of the paths, permissions and quotas for each user")
 }
@@ -0,0 +1,77 @@
+//go:build go1.17
+// +build go1.17
+
+package restic
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs/rc"
+)
+
+var (
+	liveServerMu sync.Mutex
+	liveServer   *Server // the running server, nil if none
+)
+
+// setLiveServer sets the server the rc calls use
+func setLiveServer(s *Server) {
+	liveServerMu.Lock()
+	liveServer = s
+	liveServerMu.Unlock()
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "restic/stats",
+		AuthRequired: true,
+		Fn:           rcStats,
+		Title:        "Show statistics for a repository served by serve restic.",
+		Help: `This shows the number and size of the files in a repository
+served by "rclone serve restic" running with "--rc".
+
+Parameters:
+
+- repo - path of the repository, e.g. "user1repo" - default is the root
+
+Returns:
+
+- repo - path of the repository
+- count - number of files in the repository
+- size - total size of the files in bytes
+- quota - size limit of the top level directory holding the
+  repository in bytes or -1 if none
+- types - count and size for each type of file: "config", "data",
+  "index", "keys", "locks", "snapshots" and "other"
+
+This lists the whole repository and resets the space counted against
+its quota.
+`,
+	})
+}
+
+// rcStats returns the statistics for a repository
+func rcStats(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	repo, err := in.GetString("repo")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	liveServerMu.Lock()
+	s := liveServer
+	liveServerMu.Unlock()
+	if s == nil {
+		return nil, errors.New("serve restic is not running")
+	}
+	stats, err := s.stats(ctx, strings.Trim(repo, "/"))
+	if err != nil {
+		return nil, err
+	}
+	err = rc.Reshape(&out, stats)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,276 @@
+//go:build go1.17
+// +build go1.17
+
+package restic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// resticTypes are the directories restic makes in a repository
+var resticTypes = map[string]bool{
+	"data":      true,
+	"index":     true,
+	"keys":      true,
+	"locks":     true,
+	"snapshots": true,
+}
+
+// repoPath returns the remote of the repository containing the URL
+// path urlPath
+//
+// The layout of a repository is <repo>/config, <repo>/<type>/ and
+// <repo>/<type>/<name>, and a repository is created with a POST to
+// <repo>/.
+func repoPath(urlPath string) string {
+	isDir := strings.HasSuffix(urlPath, "/")
+	dir, leaf := path.Split(strings.Trim(urlPath, "/"))
+	dir = strings.Trim(dir, "/")
+	switch {
+	case isDir && resticTypes[leaf]:
+		return dir
+	case isDir:
+		return strings.Trim(urlPath, "/")
+	case leaf == "config":
+		return dir
+	}
+	// <repo>/<type>/<name>
+	repo, typ := path.Split(dir)
+	if resticTypes[typ] {
+		return strings.Trim(repo, "/")
+	}
+	return dir
+}
+
+// quotaRoot returns the top level directory of repo whose usage is
+// counted against the quota. With --private-repos this is the user's
+// directory so all their repositories share one quota.
+func quotaRoot(repo string) string {
+	return strings.SplitN(repo, "/", 2)[0]
+}
+
+const (
+	maxLockSize   = 4 * 1024    // restic lock files are much smaller than this
+	lockAllowance = 1024 * 1024 // lock files may go over the quota by this much
+)
+
+// errQuotaExceeded is returned when an upload would take a
+// repository over its quota
+var errQuotaExceeded = errors.New("repository quota exceeded")
+
+// repoUsage is the space used by a repository
+type repoUsage struct {
+	mu      sync.Mutex
+	counted bool  // set if size has been counted
+	size    int64 // bytes used by the repository
+}
+
+// usage tracks the space used by each repository for the quotas
+type usage struct {
+	mu    sync.Mutex
+	repos map[string]*repoUsage
+}
+
+// newUsage makes a new usage tracker
+func newUsage() *usage {
+	return &usage{
+		repos: map[string]*repoUsage{},
+	}
+}
+
+// getRepo returns the usage for repo, creating it if needed
+func (u *usage) getRepo(repo string) *repoUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	ru := u.repos[repo]
+	if ru == nil {
+		ru = &repoUsage{}
+		u.repos[repo] = ru
+	}
+	return ru
+}
+
+// _count the bytes used by repo on f if they haven't been counted
+//
+// call with ru.mu held
+func (ru *repoUsage) _count(ctx context.Context, f fs.Fs, repo string) error {
+	if ru.counted {
+		return nil
+	}
+	stats, err := countRepo(ctx, f, repo)
+	if err != nil {
+		return err
+	}
+	ru.size, ru.counted = stats.Size, true
+	fs.Debugf(f, "restic: repository %q uses %v", repo, fs.SizeSuffix(ru.size))
+	return nil
+}
+
+// get returns the bytes used by repo on f counting them the first
+// time it is asked for
+func (u *usage) get(ctx context.Context, f fs.Fs, repo string) (int64, error) {
+	ru := u.getRepo(repo)
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	if err := ru._count(ctx, f, repo); err != nil {
+		return 0, err
+	}
+	return ru.size, nil
+}
+
+// reserve space in the quota of repo on f for an upload of size
+// bytes replacing an object of oldSize bytes, returning the bytes
+// reserved or errQuotaExceeded if there isn't room. The upload may go
+// over the quota by allowance bytes.
+//
+// If size is unknown (< 0) all the space left is reserved. The bytes
+// reserved are added to the usage straight away so concurrent uploads
+// can't use the same space, so they must be released with add if the
+// upload fails.
+func (u *usage) reserve(ctx context.Context, f fs.Fs, repo string, size, oldSize, allowance int64) (int64, error) {
+	ru := u.getRepo(repo)
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	if err := ru._count(ctx, f, repo); err != nil {
+		return 0, err
+	}
+	left := int64(repoQuota) + allowance - ru.size + oldSize
+	if size > left || (size < 0 && left <= 0) {
+		return 0, errQuotaExceeded
+	}
+	if size < 0 {
+		size = left
+	}
+	ru.size += size
+	return size, nil
+}
+
+// set the bytes used by repo to size
+func (u *usage) set(repo string, size int64) {
+	ru := u.getRepo(repo)
+	ru.mu.Lock()
+	ru.size, ru.counted = size, true
+	ru.mu.Unlock()
+}
+
+// add delta bytes to the usage of repo if it has been counted
+func (u *usage) add(repo string, delta int64) {
+	ru := u.getRepo(repo)
+	ru.mu.Lock()
+	if ru.counted {
+		ru.size += delta
+	}
+	ru.mu.Unlock()
+}
+
+// recount marks the usage of repo to be counted again when next
+// needed
+func (u *usage) recount(repo string) {
+	ru := u.getRepo(repo)
+	ru.mu.Lock()
+	ru.counted = false
+	ru.mu.Unlock()
+}
+
+// quotaReader returns errQuotaExceeded if more than left bytes are
+// read through it
+type quotaReader struct {
+	in   io.ReadCloser
+	left int64
+}
+
+// Read data checking the quota
+func (r *quotaReader) Read(p []byte) (n int, err error) {
+	n, err = r.in.Read(p)
+	r.left -= int64(n)
+	if r.left < 0 {
+		return n, errQuotaExceeded
+	}
+	return n, err
+}
+
+// Close the underlying reader
+func (r *quotaReader) Close() error {
+	return r.in.Close()
+}
+
+// objectType returns the type of the object at the URL path urlPath,
+// e.g. "locks", or "" if it isn't in one of the resticTypes
+func objectType(urlPath string) string {
+	typ := path.Base(path.Dir(strings.Trim(urlPath, "/")))
+	if resticTypes[typ] {
+		return typ
+	}
+	return ""
+}
+
+// typeStats are the statistics for one type of file in a repository
+type typeStats struct {
+	Count int64 `json:"count"`
+	Size  int64 `json:"size"`
+}
+
+// repoStats are the statistics for a repository
+type repoStats struct {
+	Repo  string               `json:"repo"`
+	Count int64                `json:"count"`
+	Size  int64                `json:"size"`
+	Quota int64                `json:"quota"` // -1 if no quota
+	Types map[string]typeStats `json:"types"` // stats for each type of file, "config" or "other"
+}
+
+// countRepo walks the repository repo on f and returns its stats
+func countRepo(ctx context.Context, f fs.Fs, repo string) (*repoStats, error) {
+	stats := &repoStats{
+		Repo:  repo,
+		Quota: -1,
+		Types: map[string]typeStats{},
+	}
+	err := walk.ListR(ctx, f, repo, true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			rel := strings.TrimPrefix(strings.TrimPrefix(o.Remote(), repo), "/")
+			typ := strings.SplitN(rel, "/", 2)[0]
+			if !resticTypes[typ] && typ != "config" {
+				typ = "other"
+			}
+			ts := stats.Types[typ]
+			ts.Count++
+			ts.Size += o.Size()
+			stats.Types[typ] = ts
+			stats.Count++
+			stats.Size += o.Size()
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrorDirNotFound) {
+		return nil, fmt.Errorf("failed to count repository %q: %w", repo, err)
+	}
+	return stats, nil
+}
+
+// stats returns the statistics for repo, updating the usage of its
+// quota
+func (s *Server) stats(ctx context.Context, repo string) (*repoStats, error) {
+	stats, err := countRepo(ctx, s.f, repo)
+	if err != nil {
+		return nil, err
+	}
+	if repoQuota > 0 {
+		stats.Quota = int64(repoQuota)
+		if root := quotaRoot(repo); root == repo {
+			s.usage.set(repo, stats.Size)
+		} else {
+			s.usage.recount(root)
+		}
+	}
+	return stats, nil
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,6 +36,9 @@ var (
 	appendOnly   bool
 	privateRepos bool
 	cacheObjects bool
+	repoQuota    = fs.SizeSuffix(-1)
+	immutable    bool
+	immutableAge = 24 * time.Hour
 )
 
 func init() {
@@ -44,6 +48,9 @@ func init() {
 	flags.BoolVarP(flagSet, &appendOnly, "append-only", "", false, "Disallow deletion of repository data")
 	flags.BoolVarP(flagSet, &privateRepos, "private-repos", "", false, "Users can only access their private repo")
 	flags.BoolVarP(flagSet, &cacheObjects, "cache-objects", "", true, "Cache listed objects")
+	flags.FVarP(flagSet, &repoQuota, "repo-quota", "", "Max size of each top level repository, or of each user's repositories with --private-repos")
+	flags.BoolVarP(flagSet, &immutable, "immutable", "", false, "Only allow deleting lock files and old index files")
+	flags.DurationVarP(flagSet, &immutableAge, "immutable-age", "", immutableAge, "Age index files must be before they can be deleted with --immutable")
 }
 
 // Command definition for cobra
@@ -131,12 +138,65 @@ these **must** end with /.  Eg
 
 The "--private-repos" flag can be used to limit users to repositories starting
 with a path of ` + "`/<username>/`" + `.
+
+#### Repository quotas ####
+
+The "--repo-quota" flag limits the space used under each top level
+directory served, for example "--repo-quota 100G". With
+"--private-repos" this is the space used by all the repositories of
+each user, so one user can't fill up the space for all the others.
+Without it each top level repository is counted separately, but as
+any client can create more of them, use "--private-repos" to stop one
+client using more than its share.
+
+The space a repository uses is counted by listing it the first time
+it is accessed, and then kept up to date as files are uploaded and
+deleted. An upload which would take a repository over its quota is
+refused with "507 Insufficient Storage" which restic reports as an
+error. Lock files may go up to 1 MiB over the quota so that restic
+can still lock a full repository to run "restic forget --prune" on
+it, but lock files bigger than 4 KiB are refused.
+
+When a quota is set, every response carries the headers
+"X-Rclone-Repo-Size" and "X-Rclone-Repo-Quota" with the bytes counted
+against the quota and the quota.
+
+#### Immutable mode ####
+
+The "--immutable" flag protects repositories against a compromised
+client deleting or encrypting its backups. Like "--append-only",
+existing files can't be overwritten, but in addition to lock files,
+index files which are older than "--immutable-age" (default 24h) may
+be deleted. This lets "restic prune" rewrite the index while
+snapshots, keys and data can never be deleted through the server.
+
+Note that as data files can't be deleted, prune won't free up any
+space. To do that run prune against the repository directly, without
+going through the server, for example
+
+    restic -r rclone:remote:backup/user1repo prune
+
+#### Repository statistics ####
+
+The number and size of the files in a repository, for each type of
+file, can be read with a GET request to the repository with "?stats"
+on the end, for example
+
+    curl http://localhost:8080/user1repo/?stats
+
+These are returned as JSON, with the quota set to -1 if there is no
+quota. They are also available from the "restic/stats" remote control
+call when the server is started with "--rc". Fetching the statistics
+lists the whole repository and resets the space counted against its
+quota.
 ` + httplib.Help,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
 		f := cmd.NewFsSrc(args)
 		cmd.Run(false, true, command, func() error {
 			s := NewServer(f, &httpflags.Opt)
+			setLiveServer(s)
+			defer setLiveServer(nil)
 			if stdio {
 				if terminal.IsTerminal(int(os.Stdout.Fd())) {
 					return errors.New("Refusing to run HTTP2 server directly on a terminal, please let restic start rclone")
@@ -173,6 +233,7 @@ type Server struct {
 	*httplib.Server
 	f     fs.Fs
 	cache *cache
+	usage *usage
 }
 
 // NewServer returns an HTTP server that speaks the rest protocol
@@ -182,6 +243,7 @@ func NewServer(f fs.Fs, opt *httplib.Options) *Server {
 		Server: httplib.NewServer(mux, opt),
 		f:      f,
 		cache:  newCache(),
+		usage:  newUsage(),
 	}
 	mux.HandleFunc(s.Opt.BaseURL+"/", s.ServeHTTP)
 	return s
@@ -235,11 +297,26 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 		return
 	}
+	repo := repoPath(path)
+	root := quotaRoot(repo)
+	if repoQuota > 0 {
+		used, err := s.usage.get(r.Context(), s.f, root)
+		if err != nil {
+			fs.Errorf(root, "failed to read repository size: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		setQuotaHeaders(w, used)
+	}
 
 	// Dispatch on path then method
 	if strings.HasSuffix(path, "/") {
 		switch r.Method {
 		case "GET":
+			if _, ok := r.URL.Query()["stats"]; ok {
+				s.serveStats(w, r, repo)
+				return
+			}
 			s.listObjects(w, r, remote)
 		case "POST":
 			s.createRepo(w, r, remote)
@@ -251,9 +328,9 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case "GET", "HEAD":
 			s.serveObject(w, r, remote)
 		case "POST":
-			s.postObject(w, r, remote)
+			s.postObject(w, r, remote, root)
 		case "DELETE":
-			s.deleteObject(w, r, remote)
+			s.deleteObject(w, r, remote, root)
 		default:
 			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		}
@@ -286,21 +363,82 @@ func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, remote stri
 	serve.Object(w, r, o)
 }
 
-// postObject posts an object to the repository
-func (s *Server) postObject(w http.ResponseWriter, r *http.Request, remote string) {
-	if appendOnly {
-		// make sure the file does not exist yet
-		_, err := s.newObject(r.Context(), remote)
+// setQuotaHeaders reports the space used by the repository and its
+// quota to the client
+func setQuotaHeaders(w http.ResponseWriter, used int64) {
+	w.Header().Set("X-Rclone-Repo-Size", strconv.FormatInt(used, 10))
+	w.Header().Set("X-Rclone-Repo-Quota", strconv.FormatInt(int64(repoQuota), 10))
+}
+
+// postObject posts an object to the repository counting it against
+// the quota of root
+func (s *Server) postObject(w http.ResponseWriter, r *http.Request, remote, root string) {
+	var (
+		oldSize   int64
+		overwrite bool
+	)
+	if appendOnly || immutable || repoQuota > 0 {
+		old, err := s.newObject(r.Context(), remote)
 		if err == nil {
-			fs.Errorf(remote, "Post request: file already exists, refusing to overwrite in append-only mode")
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			if appendOnly || immutable {
+				// make sure the file does not exist yet
+				fs.Errorf(remote, "Post request: file already exists, refusing to overwrite in append-only or immutable mode")
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+				return
+			}
+			oldSize, overwrite = old.Size(), true
+		}
+	}
 
+	in := r.Body
+	var (
+		qr       *quotaReader
+		reserved int64
+	)
+	if repoQuota > 0 {
+		// lock files may go over the quota by a small allowance
+		// so a full repository can still be pruned
+		size, allowance := r.ContentLength, int64(0)
+		if objectType(r.URL.Path) == "locks" {
+			if size > maxLockSize {
+				fs.Errorf(remote, "Post request: lock file of %d bytes is too big", size)
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if size < 0 {
+				size = maxLockSize
+			}
+			allowance = lockAllowance
+		}
+		var err error
+		reserved, err = s.usage.reserve(r.Context(), s.f, root, size, oldSize, allowance)
+		if err == errQuotaExceeded {
+			fs.Errorf(remote, "Post request: %v", err)
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		} else if err != nil {
+			fs.Errorf(remote, "Post request failed to read repository size: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
+		qr = &quotaReader{in: r.Body, left: reserved}
+		in = qr
 	}
 
-	o, err := operations.RcatSize(r.Context(), s.f, remote, r.Body, r.ContentLength, time.Now())
+	o, err := operations.RcatSize(r.Context(), s.f, remote, in, r.ContentLength, time.Now())
 	if err != nil {
+		// release the space reserved and count the repository
+		// again if the object being overwritten may have gone
+		s.usage.add(root, -reserved)
+		if overwrite {
+			s.usage.recount(root)
+		}
+		if qr != nil && qr.left < 0 {
+			fs.Errorf(remote, "Post request: %v", errQuotaExceeded)
+			http.Error(w, errQuotaExceeded.Error(), http.StatusInsufficientStorage)
+			return
+		}
 		err = accounting.Stats(r.Context()).Error(err)
 		fs.Errorf(remote, "Post request rcat error: %v", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -310,18 +448,35 @@ func (s *Server) postObject(w http.ResponseWriter, r *http.Request, remote strin
 
 	// if successfully uploaded add to cache
 	s.cache.add(remote, o)
+	s.usage.add(root, o.Size()-oldSize-reserved)
+	if repoQuota > 0 {
+		if used, err := s.usage.get(r.Context(), s.f, root); err == nil {
+			setQuotaHeaders(w, used)
+		}
+	}
 }
 
-// delete the remote
-func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, remote string) {
-	if appendOnly {
-		parts := strings.Split(r.URL.Path, "/")
+// canDelete returns whether the object o at urlPath may be deleted
+// in append-only or immutable mode
+func canDelete(ctx context.Context, urlPath string, o fs.Object) bool {
+	switch objectType(urlPath) {
+	case "locks":
+		return true
+	case "index":
+		// index files are rewritten by prune, but only allow
+		// deleting them once they are old enough that a rogue
+		// client can't use this to hide recent backups
+		return immutable && time.Since(o.ModTime(ctx)) >= immutableAge
+	}
+	return false
+}
 
+// delete the remote counting it against the quota of root
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, remote, root string) {
+	if appendOnly && objectType(r.URL.Path) != "locks" {
 		// if path doesn't end in "/locks/:name", disallow the operation
-		if len(parts) < 2 || parts[len(parts)-2] != "locks" {
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-			return
-		}
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
 	}
 
 	o, err := s.newObject(r.Context(), remote)
@@ -331,6 +486,12 @@ func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, remote str
 		return
 	}
 
+	if immutable && !canDelete(r.Context(), r.URL.Path, o) {
+		fs.Errorf(remote, "Delete request: refusing to delete in immutable mode")
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
 	if err := o.Remove(r.Context()); err != nil {
 		fs.Errorf(remote, "Delete request remove error: %v", err)
 		if err == fs.ErrorObjectNotFound {
@@ -343,6 +504,32 @@ func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, remote str
 
 	// remove object from cache
 	s.cache.remove(remote)
+	s.usage.add(root, -o.Size())
+	if repoQuota > 0 {
+		if used, err := s.usage.get(r.Context(), s.f, root); err == nil {
+			setQuotaHeaders(w, used)
+		}
+	}
+}
+
+// serveStats returns the statistics for the repository as JSON
+func (s *Server) serveStats(w http.ResponseWriter, r *http.Request, repo string) {
+	stats, err := s.stats(r.Context(), repo)
+	if err != nil {
+		fs.Errorf(repo, "stats request error: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if repoQuota > 0 {
+		if used, err := s.usage.get(r.Context(), s.f, quotaRoot(repo)); err == nil {
+			setQuotaHeaders(w, used)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(stats)
+	if err != nil {
+		fs.Errorf(repo, "failed to write stats: %v", err)
+	}
 }
 
 // listItem is an element returned for the restic v2 list response
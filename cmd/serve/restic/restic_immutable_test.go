@@ -0,0 +1,91 @@
+//go:build go1.17
+// +build go1.17
+
+package restic
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
+	"github.com/rclone/rclone/fs/config/configfile"
+)
+
+// TestResticImmutable runs tests on the restic handler code in immutable mode
+func TestResticImmutable(t *testing.T) {
+	configfile.Install()
+
+	// globally set immutable mode
+	prev, prevAge := immutable, immutableAge
+	immutable, immutableAge = true, time.Hour
+	defer func() {
+		immutable, immutableAge = prev, prevAge
+	}()
+
+	tempdir := t.TempDir()
+	f := cmd.NewFsSrc([]string{tempdir})
+	srv := NewServer(f, &httpflags.Opt)
+
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "POST", "/?create=true", nil),
+		[]wantFunc{wantCode(http.StatusOK)})
+
+	for i, seq := range []TestRequest{
+		{
+			req:  newRequest(t, "POST", "/data/0123456789", strings.NewReader("data")),
+			want: []wantFunc{wantCode(http.StatusOK)},
+		},
+		{
+			req:  newRequest(t, "POST", "/data/0123456789", strings.NewReader("other data")),
+			want: []wantFunc{wantCode(http.StatusForbidden)},
+		},
+		{
+			req:  newRequest(t, "DELETE", "/data/0123456789", nil),
+			want: []wantFunc{wantCode(http.StatusForbidden)},
+		},
+		{
+			req:  newRequest(t, "POST", "/snapshots/0123456789", strings.NewReader("snapshot")),
+			want: []wantFunc{wantCode(http.StatusOK)},
+		},
+		{
+			req:  newRequest(t, "DELETE", "/snapshots/0123456789", nil),
+			want: []wantFunc{wantCode(http.StatusForbidden)},
+		},
+		{
+			req:  newRequest(t, "POST", "/locks/0123456789", strings.NewReader("lock")),
+			want: []wantFunc{wantCode(http.StatusOK)},
+		},
+		{
+			req:  newRequest(t, "DELETE", "/locks/0123456789", nil),
+			want: []wantFunc{wantCode(http.StatusOK)},
+		},
+		{
+			req:  newRequest(t, "POST", "/index/0123456789", strings.NewReader("index")),
+			want: []wantFunc{wantCode(http.StatusOK)},
+		},
+		{
+			// index file is too new to delete
+			req:  newRequest(t, "DELETE", "/index/0123456789", nil),
+			want: []wantFunc{wantCode(http.StatusForbidden)},
+		},
+		{
+			req:  newRequest(t, "GET", "/index/0123456789", nil),
+			want: []wantFunc{wantCode(http.StatusOK), wantBody("index")},
+		},
+	} {
+		t.Logf("request %v: %v %v", i, seq.req.Method, seq.req.URL.Path)
+		checkRequest(t, srv.ServeHTTP, seq.req, seq.want)
+	}
+
+	// once old enough the index file can be deleted
+	immutableAge = 0
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "DELETE", "/index/0123456789", nil),
+		[]wantFunc{wantCode(http.StatusOK)})
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "GET", "/index/0123456789", nil),
+		[]wantFunc{wantCode(http.StatusNotFound)})
+}
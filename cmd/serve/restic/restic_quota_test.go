@@ -0,0 +1,298 @@
+//go:build go1.17
+// +build go1.17
+
+package restic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoPath(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"/", ""},
+		{"/config", ""},
+		{"/data/", ""},
+		{"/data/0123456789", ""},
+		{"/locks/0123456789", ""},
+		{"/repo/", "repo"},
+		{"/repo/config", "repo"},
+		{"/repo/index/", "repo"},
+		{"/repo/index/0123456789", "repo"},
+		{"/user/repo/", "user/repo"},
+		{"/user/repo/snapshots/0123456789", "user/repo"},
+	} {
+		assert.Equal(t, test.want, repoPath(test.in), test.in)
+	}
+}
+
+func TestQuotaRoot(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"repo", "repo"},
+		{"user/repo", "user"},
+		{"user/dir/repo", "user"},
+	} {
+		assert.Equal(t, test.want, quotaRoot(test.in), test.in)
+	}
+}
+
+// wantHeader returns a function which checks that the response has
+// the header key set to value
+func wantHeader(key, value string) wantFunc {
+	return func(t testing.TB, res *httptest.ResponseRecorder) {
+		assert.Equal(t, value, res.Header().Get(key), key)
+	}
+}
+
+// TestResticQuota checks the repository quotas are enforced
+func TestResticQuota(t *testing.T) {
+	configfile.Install()
+
+	prev := repoQuota
+	repoQuota = 20
+	defer func() {
+		repoQuota = prev
+	}()
+
+	tempdir := t.TempDir()
+	f := cmd.NewFsSrc([]string{tempdir})
+	srv := NewServer(f, &httpflags.Opt)
+
+	for _, repo := range []string{"/repo1/", "/repo2/"} {
+		checkRequest(t, srv.ServeHTTP,
+			newRequest(t, "POST", repo+"?create=true", nil),
+			[]wantFunc{wantCode(http.StatusOK)})
+	}
+
+	for i, seq := range []TestRequest{
+		{
+			req: newRequest(t, "POST", "/repo1/data/0123456789", strings.NewReader("0123456789")),
+			want: []wantFunc{
+				wantCode(http.StatusOK),
+				wantHeader("X-Rclone-Repo-Size", "10"),
+				wantHeader("X-Rclone-Repo-Quota", "20"),
+			},
+		},
+		{
+			// over the quota
+			req:  newRequest(t, "POST", "/repo1/data/1123456789", strings.NewReader("0123456789ABCDEF")),
+			want: []wantFunc{wantCode(http.StatusInsufficientStorage)},
+		},
+		{
+			req:  newRequest(t, "GET", "/repo1/data/1123456789", nil),
+			want: []wantFunc{wantCode(http.StatusNotFound)},
+		},
+		{
+			// other repositories have their own quota
+			req: newRequest(t, "POST", "/repo2/data/1123456789", strings.NewReader("0123456789ABCDEF")),
+			want: []wantFunc{
+				wantCode(http.StatusOK),
+				wantHeader("X-Rclone-Repo-Size", "16"),
+			},
+		},
+		{
+			// up to the quota
+			req: newRequest(t, "POST", "/repo1/data/2123456789", strings.NewReader("0123456789")),
+			want: []wantFunc{
+				wantCode(http.StatusOK),
+				wantHeader("X-Rclone-Repo-Size", "20"),
+			},
+		},
+		{
+			// lock files may go over the quota a little
+			req: newRequest(t, "POST", "/repo1/locks/0123456789", strings.NewReader("lock")),
+			want: []wantFunc{
+				wantCode(http.StatusOK),
+				wantHeader("X-Rclone-Repo-Size", "24"),
+			},
+		},
+		{
+			// but can't be big
+			req:  newRequest(t, "POST", "/repo1/locks/1123456789", strings.NewReader(strings.Repeat("x", maxLockSize+1))),
+			want: []wantFunc{wantCode(http.StatusRequestEntityTooLarge)},
+		},
+		{
+			req: newRequest(t, "DELETE", "/repo1/locks/0123456789", nil),
+			want: []wantFunc{
+				wantCode(http.StatusOK),
+				wantHeader("X-Rclone-Repo-Size", "20"),
+			},
+		},
+		{
+			// deleting frees up space
+			req: newRequest(t, "DELETE", "/repo1/data/0123456789", nil),
+			want: []wantFunc{
+				wantCode(http.StatusOK),
+				wantHeader("X-Rclone-Repo-Size", "10"),
+			},
+		},
+		{
+			req:  newRequest(t, "POST", "/repo1/data/1123456789", strings.NewReader("0123456789")),
+			want: []wantFunc{wantCode(http.StatusOK)},
+		},
+	} {
+		t.Logf("request %v: %v %v", i, seq.req.Method, seq.req.URL.Path)
+		checkRequest(t, srv.ServeHTTP, seq.req, seq.want)
+	}
+
+	// unknown length uploads are stopped at the quota
+	req := newRequest(t, "POST", "/repo2/data/2123456789", strings.NewReader("0123456789"))
+	req.ContentLength = -1
+	checkRequest(t, srv.ServeHTTP, req, []wantFunc{wantCode(http.StatusInsufficientStorage)})
+
+	// the repositories in a top level directory share its quota
+	for _, repo := range []string{"/user/a/", "/user/b/"} {
+		checkRequest(t, srv.ServeHTTP,
+			newRequest(t, "POST", repo+"?create=true", nil),
+			[]wantFunc{wantCode(http.StatusOK)})
+	}
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "POST", "/user/a/data/0123456789", strings.NewReader("0123456789ABCDEF")),
+		[]wantFunc{wantCode(http.StatusOK), wantHeader("X-Rclone-Repo-Size", "16")})
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "POST", "/user/b/data/0123456789", strings.NewReader("0123456789")),
+		[]wantFunc{wantCode(http.StatusInsufficientStorage)})
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "GET", "/user/b/?stats", nil),
+		[]wantFunc{wantCode(http.StatusOK), wantHeader("X-Rclone-Repo-Size", "16")})
+}
+
+// errorReader returns err after reading the data in r
+type errorReader struct {
+	r   io.Reader
+	err error
+}
+
+// Read data returning err at the end
+func (er *errorReader) Read(p []byte) (n int, err error) {
+	n, err = er.r.Read(p)
+	if err == io.EOF {
+		err = er.err
+	}
+	return n, err
+}
+
+// TestResticQuotaReserve checks uploads reserve their space in the
+// quota and release it if they fail
+func TestResticQuotaReserve(t *testing.T) {
+	configfile.Install()
+
+	prev := repoQuota
+	repoQuota = 20
+	defer func() {
+		repoQuota = prev
+	}()
+
+	tempdir := t.TempDir()
+	f := cmd.NewFsSrc([]string{tempdir})
+	srv := NewServer(f, &httpflags.Opt)
+	ctx := context.Background()
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "POST", "/repo/?create=true", nil),
+		[]wantFunc{wantCode(http.StatusOK)})
+
+	// concurrent uploads can't use the same space
+	reserved, err := srv.usage.reserve(ctx, f, "repo", 15, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), reserved)
+	_, err = srv.usage.reserve(ctx, f, "repo", 10, 0, 0)
+	assert.Equal(t, errQuotaExceeded, err)
+	reserved, err = srv.usage.reserve(ctx, f, "repo", -1, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), reserved)
+
+	// but may use the allowance if given one
+	reserved, err = srv.usage.reserve(ctx, f, "repo", 4, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), reserved)
+	srv.usage.add("repo", -24)
+
+	// a failed upload releases its space
+	req := newRequest(t, "POST", "/repo/data/0123456789", &errorReader{
+		r:   strings.NewReader("0123456789"),
+		err: errors.New("upload failed"),
+	})
+	req.ContentLength = 20
+	checkRequest(t, srv.ServeHTTP, req, []wantFunc{wantCode(http.StatusInternalServerError)})
+	used, err := srv.usage.get(ctx, f, "repo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), used)
+
+	// a failed overwrite counts the repository again
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "POST", "/repo/data/0123456789", strings.NewReader("0123456789")),
+		[]wantFunc{wantCode(http.StatusOK), wantHeader("X-Rclone-Repo-Size", "10")})
+	req = newRequest(t, "POST", "/repo/data/0123456789", &errorReader{
+		r:   strings.NewReader("01234"),
+		err: errors.New("upload failed"),
+	})
+	req.ContentLength = 15
+	checkRequest(t, srv.ServeHTTP, req, []wantFunc{wantCode(http.StatusInternalServerError)})
+	stats, err := countRepo(ctx, f, "repo")
+	require.NoError(t, err)
+	used, err = srv.usage.get(ctx, f, "repo")
+	require.NoError(t, err)
+	assert.Equal(t, stats.Size, used)
+}
+
+// TestResticStats checks the repository statistics
+func TestResticStats(t *testing.T) {
+	configfile.Install()
+
+	prev := repoQuota
+	repoQuota = fs.SizeSuffix(1024)
+	defer func() {
+		repoQuota = prev
+	}()
+
+	tempdir := t.TempDir()
+	f := cmd.NewFsSrc([]string{tempdir})
+	srv := NewServer(f, &httpflags.Opt)
+
+	for _, seq := range []TestRequest{
+		{newRequest(t, "POST", "/repo/?create=true", nil), []wantFunc{wantCode(http.StatusOK)}},
+		{newRequest(t, "POST", "/repo/config", strings.NewReader("config")), []wantFunc{wantCode(http.StatusOK)}},
+		{newRequest(t, "POST", "/repo/data/0123456789", strings.NewReader("0123456789")), []wantFunc{wantCode(http.StatusOK)}},
+		{newRequest(t, "POST", "/repo/data/1123456789", strings.NewReader("01234")), []wantFunc{wantCode(http.StatusOK)}},
+		{newRequest(t, "POST", "/repo/keys/0123456789", strings.NewReader("key")), []wantFunc{wantCode(http.StatusOK)}},
+	} {
+		checkRequest(t, srv.ServeHTTP, seq.req, seq.want)
+	}
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, newRequest(t, "GET", "/repo/?stats", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var stats repoStats
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.Equal(t, repoStats{
+		Repo:  "repo",
+		Count: 4,
+		Size:  24,
+		Quota: 1024,
+		Types: map[string]typeStats{
+			"config": {Count: 1, Size: 6},
+			"data":   {Count: 2, Size: 15},
+			"keys":   {Count: 1, Size: 3},
+		},
+	}, stats)
+}
@@ -0,0 +1,489 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Constants used in AWS Signature Version 4
+const (
+	signV4Algorithm       = "AWS4-HMAC-SHA256"
+	signV4ChunkAlgorithm  = "AWS4-HMAC-SHA256-PAYLOAD"
+	iso8601Format         = "20060102T150405Z"
+	yyyymmdd              = "20060102"
+	unsignedPayload       = "UNSIGNED-PAYLOAD"
+	streamingPayload      = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	emptySHA256           = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	maxClockSkew          = 15 * time.Minute
+	maxPresignExpiry      = 7 * 24 * time.Hour
+	maxChunkSize          = 16 * 1024 * 1024
+	amzContentSHA256      = "X-Amz-Content-Sha256"
+	amzDate               = "X-Amz-Date"
+	amzDecodedContentSize = "X-Amz-Decoded-Content-Length"
+)
+
+// credential is the parsed Credential field of a V4 signature
+type credential struct {
+	accessKey string
+	date      string
+	region    string
+	service   string
+}
+
+// scope returns the credential scope of c
+func (c *credential) scope() string {
+	return strings.Join([]string{c.date, c.region, c.service, "aws4_request"}, "/")
+}
+
+// parseCredential parses "AKID/20130524/us-east-1/s3/aws4_request"
+func parseCredential(s string) (c credential, err error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return c, errAuthorizationHeaderMalformed.withMessage("The Credential is malformed")
+	}
+	return credential{
+		accessKey: parts[0],
+		date:      parts[1],
+		region:    parts[2],
+		service:   parts[3],
+	}, nil
+}
+
+// parseKeys parses access_key_id,secret_access_key pairs
+func parseKeys(authKeys []string) (map[string]string, error) {
+	keys := make(map[string]string, len(authKeys))
+	for _, authKey := range authKeys {
+		parts := strings.SplitN(authKey, ",", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("auth key %q must be in the form access_key_id,secret_access_key", authKey)
+		}
+		keys[parts[0]] = parts[1]
+	}
+	return keys, nil
+}
+
+// accessKeyID returns the access key ID the request claims to be
+// signed with, without checking the signature
+func accessKeyID(r *http.Request) string {
+	credentialField := r.URL.Query().Get("X-Amz-Credential")
+	if credentialField == "" {
+		authHeader := strings.TrimPrefix(r.Header.Get("Authorization"), signV4Algorithm+" ")
+		for _, field := range strings.Split(authHeader, ",") {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "Credential=") {
+				credentialField = strings.TrimPrefix(field, "Credential=")
+			}
+		}
+	}
+	cred, err := parseCredential(credentialField)
+	if err != nil {
+		return ""
+	}
+	return cred.accessKey
+}
+
+// authenticate checks the V4 signature of the request
+//
+// If the payload is signed then r.Body is replaced by a reader which
+// checks the payload as it is read.
+func (s *Server) authenticate(r *http.Request) error {
+	if len(s.keys) == 0 {
+		return nil
+	}
+	if r.URL.Query().Get("X-Amz-Algorithm") != "" {
+		return s.authenticatePresigned(r)
+	}
+	authHeader := r.Header.Get("Authorization")
+	switch {
+	case authHeader == "":
+		return errAccessDenied
+	case strings.HasPrefix(authHeader, signV4Algorithm+" "):
+		return s.authenticateHeader(r, strings.TrimPrefix(authHeader, signV4Algorithm+" "))
+	case strings.HasPrefix(authHeader, "AWS "):
+		return errNotImplemented.withMessage("AWS Signature Version 2 is not supported, use Version 4")
+	}
+	return errAuthorizationHeaderMalformed.withMessage("Unsupported authorization type")
+}
+
+// authenticateHeader checks a signature passed in the Authorization header
+func (s *Server) authenticateHeader(r *http.Request, authHeader string) error {
+	var cred credential
+	var signedHeaders, signature string
+	var err error
+	for _, field := range strings.Split(authHeader, ",") {
+		field = strings.TrimSpace(field)
+		equals := strings.IndexRune(field, '=')
+		if equals < 0 {
+			return errAuthorizationHeaderMalformed
+		}
+		value := field[equals+1:]
+		switch field[:equals] {
+		case "Credential":
+			cred, err = parseCredential(value)
+			if err != nil {
+				return err
+			}
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			signature = value
+		}
+	}
+	if cred.accessKey == "" || signedHeaders == "" || signature == "" {
+		return errAuthorizationHeaderMalformed
+	}
+	secret, ok := s.keys[cred.accessKey]
+	if !ok {
+		return errInvalidAccessKeyID
+	}
+
+	// Check the request time
+	dateHeader := r.Header.Get(amzDate)
+	var date time.Time
+	if dateHeader != "" {
+		date, err = time.Parse(iso8601Format, dateHeader)
+	} else {
+		dateHeader = r.Header.Get("Date")
+		date, err = http.ParseTime(dateHeader)
+		dateHeader = date.UTC().Format(iso8601Format)
+	}
+	if err != nil {
+		return errMissingSecurityHeader.withMessage("Missing or malformed X-Amz-Date header")
+	}
+	if skew := time.Since(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return errRequestTimeTooSkewed
+	}
+
+	payloadHash := r.Header.Get(amzContentSHA256)
+	if payloadHash == "" {
+		return errMissingSecurityHeader.withMessage("Missing required header for this request: x-amz-content-sha256")
+	}
+
+	signingKey := deriveSigningKey(secret, cred)
+	canonical := canonicalRequest(r, strings.Split(signedHeaders, ";"), payloadHash, false)
+	want := hex.EncodeToString(hmacSHA256(signingKey, stringToSign(dateHeader, cred.scope(), canonical)))
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return errSignatureDoesNotMatch
+	}
+
+	// Check the payload if required
+	switch payloadHash {
+	case unsignedPayload:
+	case streamingPayload:
+		if size := r.Header.Get(amzDecodedContentSize); size != "" {
+			r.ContentLength, err = strconv.ParseInt(size, 10, 64)
+			if err != nil {
+				return errInvalidArgument.withMessage("Invalid " + amzDecodedContentSize)
+			}
+		} else {
+			r.ContentLength = -1
+		}
+		r.Body = &chunkedReader{
+			in:         bufio.NewReader(r.Body),
+			closer:     r.Body,
+			signingKey: signingKey,
+			date:       dateHeader,
+			scope:      cred.scope(),
+			prevSig:    signature,
+		}
+	default:
+		if _, err := hex.DecodeString(payloadHash); err != nil || len(payloadHash) != sha256.Size*2 {
+			return errInvalidArgument.withMessage("Invalid x-amz-content-sha256 header")
+		}
+		r.Body = &hashCheckReader{
+			ReadCloser: r.Body,
+			hasher:     sha256.New(),
+			want:       payloadHash,
+		}
+	}
+	return nil
+}
+
+// authenticatePresigned checks a signature passed in the query string
+func (s *Server) authenticatePresigned(r *http.Request) error {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != signV4Algorithm {
+		return errAuthorizationHeaderMalformed.withMessage("Unsupported algorithm")
+	}
+	cred, err := parseCredential(query.Get("X-Amz-Credential"))
+	if err != nil {
+		return err
+	}
+	secret, ok := s.keys[cred.accessKey]
+	if !ok {
+		return errInvalidAccessKeyID
+	}
+	signedHeaders := query.Get("X-Amz-SignedHeaders")
+	signature := query.Get("X-Amz-Signature")
+	if signedHeaders == "" || signature == "" {
+		return errAuthorizationHeaderMalformed
+	}
+
+	// Check the request hasn't expired
+	dateString := query.Get(amzDate)
+	date, err := time.Parse(iso8601Format, dateString)
+	if err != nil {
+		return errAuthorizationHeaderMalformed.withMessage("Missing or malformed X-Amz-Date")
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxPresignExpiry {
+		return errAuthorizationHeaderMalformed.withMessage("Missing or malformed X-Amz-Expires")
+	}
+	now := time.Now()
+	if date.Sub(now) > maxClockSkew {
+		return errRequestTimeTooSkewed
+	}
+	if now.After(date.Add(time.Duration(expires) * time.Second)) {
+		return errExpiredPresignRequest
+	}
+
+	payloadHash := query.Get(amzContentSHA256)
+	if payloadHash == "" {
+		payloadHash = unsignedPayload
+	}
+	signingKey := deriveSigningKey(secret, cred)
+	canonical := canonicalRequest(r, strings.Split(signedHeaders, ";"), payloadHash, true)
+	want := hex.EncodeToString(hmacSHA256(signingKey, stringToSign(dateString, cred.scope(), canonical)))
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return errSignatureDoesNotMatch
+	}
+	return nil
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sha256Hex returns the hex encoded SHA256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// deriveSigningKey makes the signing key for secret and cred
+func deriveSigningKey(secret string, cred credential) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), cred.date)
+	key = hmacSHA256(key, cred.region)
+	key = hmacSHA256(key, cred.service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// stringToSign makes the string to sign from the canonical request
+func stringToSign(date, scope, canonical string) string {
+	return strings.Join([]string{
+		signV4Algorithm,
+		date,
+		scope,
+		sha256Hex([]byte(canonical)),
+	}, "\n")
+}
+
+// canonicalRequest makes the canonical form of r for signing
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string, presigned bool) string {
+	// Canonical query string
+	query := r.URL.Query()
+	var params []string
+	for key, values := range query {
+		if presigned && key == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+
+	// Canonical headers
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = r.Header.Get("Content-Length")
+			if value == "" && r.ContentLength >= 0 {
+				value = strconv.FormatInt(r.ContentLength, 10)
+			}
+		case "transfer-encoding":
+			value = strings.Join(r.TransferEncoding, ",")
+		default:
+			values := r.Header.Values(name)
+			for i := range values {
+				values[i] = strings.Join(strings.Fields(values[i]), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		headers.WriteString(name)
+		headers.WriteRune(':')
+		headers.WriteString(value)
+		headers.WriteRune('\n')
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		strings.Join(params, "&"),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// uriEncode encodes s as described in the AWS Signature Version 4
+// documentation.
+//
+// Every byte is percent encoded except the unreserved characters,
+// and "/" unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+			continue
+		}
+		buf.WriteByte('%')
+		buf.WriteByte(hexDigits[c>>4])
+		buf.WriteByte(hexDigits[c&15])
+	}
+	return buf.String()
+}
+
+// hashCheckReader checks the SHA256 of the data read matches want
+// when it gets to the end of the data.
+type hashCheckReader struct {
+	io.ReadCloser
+	hasher hash.Hash
+	want   string
+}
+
+// Read bytes checking the hash at the end
+func (h *hashCheckReader) Read(p []byte) (n int, err error) {
+	n, err = h.ReadCloser.Read(p)
+	_, _ = h.hasher.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(h.hasher.Sum(nil)) != h.want {
+		err = errContentSHA256Mismatch
+	}
+	return n, err
+}
+
+// chunkedReader decodes and checks the signatures of an aws-chunked
+// upload, as used by STREAMING-AWS4-HMAC-SHA256-PAYLOAD.
+//
+// Each chunk looks like
+//
+//	hex(size);chunk-signature=signature\r\n
+//	data\r\n
+//
+// and the stream is ended with a chunk of size 0.
+type chunkedReader struct {
+	in         *bufio.Reader
+	closer     io.Closer
+	signingKey []byte
+	date       string
+	scope      string
+	prevSig    string
+	buf        []byte // unread data from the current chunk
+	done       bool   // set when the final chunk has been read
+	err        error  // sticky error
+}
+
+// errMalformedChunk is returned if the chunk encoding is wrong
+var errMalformedChunk = errIncompleteBody.withMessage("Malformed aws-chunked encoding")
+
+// readChunk reads and checks the next chunk into c.buf
+func (c *chunkedReader) readChunk() error {
+	line, err := c.in.ReadString('\n')
+	if err != nil {
+		return errMalformedChunk
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	semicolon := strings.IndexRune(line, ';')
+	if semicolon < 0 || !strings.HasPrefix(line[semicolon+1:], "chunk-signature=") {
+		return errMalformedChunk
+	}
+	size, err := strconv.ParseInt(line[:semicolon], 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return errMalformedChunk
+	}
+	signature := strings.TrimPrefix(line[semicolon+1:], "chunk-signature=")
+	if cap(c.buf) < int(size) {
+		c.buf = make([]byte, size)
+	}
+	c.buf = c.buf[:size]
+	if _, err = io.ReadFull(c.in, c.buf); err != nil {
+		return errMalformedChunk
+	}
+	var crlf [2]byte
+	if _, err = io.ReadFull(c.in, crlf[:]); err != nil || crlf != [2]byte{'\r', '\n'} {
+		return errMalformedChunk
+	}
+	toSign := strings.Join([]string{
+		signV4ChunkAlgorithm,
+		c.date,
+		c.scope,
+		c.prevSig,
+		emptySHA256,
+		sha256Hex(c.buf),
+	}, "\n")
+	want := hex.EncodeToString(hmacSHA256(c.signingKey, toSign))
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return errSignatureDoesNotMatch
+	}
+	c.prevSig = signature
+	if size == 0 {
+		c.done = true
+	}
+	return nil
+}
+
+// Read decoded bytes from the chunked stream
+func (c *chunkedReader) Read(p []byte) (n int, err error) {
+	for len(c.buf) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.readChunk()
+		if c.err != nil {
+			// never return the data of a chunk which failed
+			// its signature check
+			c.buf = c.buf[:0]
+			return 0, c.err
+		}
+	}
+	n = copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// Close the underlying stream
+func (c *chunkedReader) Close() error {
+	return c.closer.Close()
+}
+
+// check interfaces
+var (
+	_ io.ReadCloser = (*hashCheckReader)(nil)
+	_ io.ReadCloser = (*chunkedReader)(nil)
+)
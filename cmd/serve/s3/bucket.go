@@ -0,0 +1,333 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rclone/rclone/lib/audit"
+	"github.com/rclone/rclone/vfs"
+)
+
+// maximum number of keys returned in a listing
+const maxListKeys = 1000
+
+// errStopWalk is returned from a walk function to stop the walk
+var errStopWalk = errors.New("stop walk")
+
+// listBuckets lists the top level directories as buckets
+func (s *Server) listBuckets(w http.ResponseWriter, r *http.Request) error {
+	root, err := s.vfs.Root()
+	if err != nil {
+		return err
+	}
+	nodes, err := root.ReadDirAll()
+	if err != nil {
+		return err
+	}
+	result := &listAllMyBucketsResult{
+		Xmlns:   xmlNamespace,
+		Owner:   defaultOwner,
+		Buckets: []bucketInfo{},
+	}
+	for _, node := range nodes {
+		if !node.IsDir() || !validBucketName(node.Name()) {
+			continue
+		}
+		result.Buckets = append(result.Buckets, bucketInfo{
+			Name:         node.Name(),
+			CreationDate: xmlTime(node.ModTime()),
+		})
+	}
+	writeXML(w, r, http.StatusOK, result)
+	return nil
+}
+
+// headBucket checks the bucket exists
+func (s *Server) headBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if _, err := s.getBucket(bucket); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// getBucketLocation returns the region of the bucket
+func (s *Server) getBucketLocation(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if _, err := s.getBucket(bucket); err != nil {
+		return err
+	}
+	writeXML(w, r, http.StatusOK, &locationConstraint{Xmlns: xmlNamespace})
+	return nil
+}
+
+// createBucket makes a new top level directory
+func (s *Server) createBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	root, err := s.vfs.Root()
+	if err != nil {
+		return err
+	}
+	node, err := root.Stat(bucket)
+	if err == nil {
+		if node.IsDir() {
+			return errBucketAlreadyOwnedByYou
+		}
+		return errKeyConflict
+	} else if !errors.Is(err, vfs.ENOENT) {
+		return err
+	}
+	_, err = root.Mkdir(bucket)
+	getAuditor(r.Context()).Log(audit.OpMkdir, bucket, err)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// deleteBucket removes an empty top level directory
+func (s *Server) deleteBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	dir, err := s.getBucket(bucket)
+	if err != nil {
+		return err
+	}
+	if !isEmpty(dir) {
+		return errBucketNotEmpty
+	}
+	err = dir.Remove()
+	getAuditor(r.Context()).Log(audit.OpDelete, bucket, err)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// walk calls fn for each file and directory under dir in key order.
+//
+// The keys of directories end in "/". If fn returns true for a
+// directory then walk descends into it.
+func walk(dir *vfs.Dir, dirKey string, fn func(key string, node vfs.Node) (descend bool, err error)) error {
+	nodes, err := dir.ReadDirAll()
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		key  string
+		node vfs.Node
+	}
+	entries := make([]entry, 0, len(nodes))
+	for _, node := range nodes {
+		if !node.IsDir() && isUpload(node.Name()) {
+			continue
+		}
+		key := dirKey + node.Name()
+		if node.IsDir() {
+			key += "/"
+		}
+		entries = append(entries, entry{key: key, node: node})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	for _, e := range entries {
+		descend, err := fn(e.key, e.node)
+		if err != nil {
+			return err
+		}
+		if descend && e.node.IsDir() {
+			err = walk(e.node.(*vfs.Dir), e.key, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listObjects lists the objects in the bucket for ListObjects and
+// ListObjectsV2
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, bucket string, v2 bool) error {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	encodingType := query.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return errInvalidArgument.withMessage("Invalid Encoding Method specified in Request")
+	}
+	maxKeys := maxListKeys
+	if value := query.Get("max-keys"); value != "" {
+		var err error
+		maxKeys, err = strconv.Atoi(value)
+		if err != nil || maxKeys < 0 {
+			return errInvalidArgument.withMessage("Provided max-keys not an integer or within integer range")
+		}
+		if maxKeys > maxListKeys {
+			maxKeys = maxListKeys
+		}
+	}
+	encode := func(s string) string {
+		if encodingType == "url" {
+			return uriEncode(s, false)
+		}
+		return s
+	}
+	result := &listBucketResult{
+		Xmlns:        xmlNamespace,
+		Name:         bucket,
+		Prefix:       encode(prefix),
+		Delimiter:    encode(delimiter),
+		MaxKeys:      maxKeys,
+		EncodingType: encodingType,
+	}
+	var marker string
+	if v2 {
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = encode(query.Get("start-after"))
+		if result.ContinuationToken != "" {
+			decoded, err := base64.URLEncoding.DecodeString(result.ContinuationToken)
+			if err != nil {
+				return errInvalidArgument.withMessage("The continuation token provided is incorrect")
+			}
+			marker = string(decoded)
+		} else {
+			marker = query.Get("start-after")
+		}
+	} else {
+		marker = query.Get("marker")
+		encodedMarker := encode(marker)
+		result.Marker = &encodedMarker
+	}
+	dir, err := s.getBucket(bucket)
+	if err != nil {
+		return err
+	}
+
+	// add adds an object or common prefix to the result
+	var last string
+	add := func(key string, node vfs.Node) error {
+		if len(result.Contents)+len(result.CommonPrefixes) >= maxKeys {
+			result.IsTruncated = true
+			return errStopWalk
+		}
+		last = key
+		if node == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(key)})
+			return nil
+		}
+		info := objectInfo{
+			Key:          encode(key),
+			LastModified: xmlTime(node.ModTime()),
+			ETag:         s.etag(r.Context(), node),
+			Size:         node.Size(),
+			StorageClass: "STANDARD",
+		}
+		if !v2 || query.Get("fetch-owner") == "true" {
+			info.Owner = &defaultOwner
+		}
+		result.Contents = append(result.Contents, info)
+		return nil
+	}
+
+	// commonPrefixOf returns the common prefix of key or "" if none
+	commonPrefixOf := func(key string) string {
+		if delimiter == "" {
+			return ""
+		}
+		i := strings.Index(key[len(prefix):], delimiter)
+		if i < 0 {
+			return ""
+		}
+		return key[:len(prefix)+i+len(delimiter)]
+	}
+
+	var lastPrefix string
+	err = walk(dir, "", func(key string, node vfs.Node) (descend bool, err error) {
+		if node.IsDir() {
+			// skip directories which can't contain matching keys
+			if !strings.HasPrefix(key, prefix) && !strings.HasPrefix(prefix, key) {
+				return false, nil
+			}
+			if key < marker && !strings.HasPrefix(marker, key) {
+				return false, nil
+			}
+			// all the keys in this directory share a common prefix
+			if strings.HasPrefix(key, prefix) {
+				if cp := commonPrefixOf(key); cp != "" {
+					if cp > marker && cp != lastPrefix {
+						lastPrefix = cp
+						return false, add(cp, nil)
+					}
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return false, nil
+		}
+		if cp := commonPrefixOf(key); cp != "" {
+			if cp > marker && cp != lastPrefix {
+				lastPrefix = cp
+				return false, add(cp, nil)
+			}
+			return false, nil
+		}
+		if key <= marker {
+			return false, nil
+		}
+		return false, add(key, node)
+	})
+	if err != nil && err != errStopWalk {
+		return err
+	}
+	if result.IsTruncated {
+		if v2 {
+			result.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(last))
+		} else {
+			result.NextMarker = encode(last)
+		}
+	}
+	if v2 {
+		keyCount := len(result.Contents) + len(result.CommonPrefixes)
+		result.KeyCount = &keyCount
+	}
+	writeXML(w, r, http.StatusOK, result)
+	return nil
+}
+
+// deleteObjects deletes the objects in the request body
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if _, err := s.getBucket(bucket); err != nil {
+		return err
+	}
+	var request deleteRequest
+	if err := xml.NewDecoder(io.LimitReader(r.Body, 2*1024*1024)).Decode(&request); err != nil {
+		return errMalformedXML
+	}
+	if len(request.Objects) > maxListKeys {
+		return errMalformedXML
+	}
+	result := &deleteResult{Xmlns: xmlNamespace}
+	for _, object := range request.Objects {
+		err := s.deleteKey(r.Context(), bucket, object.Key)
+		if err != nil {
+			apiErr := toAPIError(r, err)
+			result.Errors = append(result.Errors, deleteError{
+				Key:     object.Key,
+				Code:    apiErr.Code,
+				Message: apiErr.Message,
+			})
+		} else if !request.Quiet {
+			result.Deleted = append(result.Deleted, deletedObject{Key: object.Key})
+		}
+	}
+	writeXML(w, r, http.StatusOK, result)
+	return nil
+}
@@ -0,0 +1,504 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/random"
+)
+
+// limits on multipart uploads
+const (
+	maxPartNumber = 10000
+	maxListParts  = 1000
+)
+
+// part is an uploaded part of a multipart upload
+type part struct {
+	size    int64
+	md5     []byte
+	modTime time.Time
+}
+
+// etag returns the quoted ETag of the part
+func (p *part) etag() string {
+	return `"` + hex.EncodeToString(p.md5) + `"`
+}
+
+// upload is a multipart upload in progress
+type upload struct {
+	id        string
+	bucket    string
+	key       string
+	dir       string    // directory the parts are stored in
+	initiated time.Time // when the upload was created
+	modTime   time.Time // modification time for the object or zero
+	mu        sync.Mutex
+	parts     map[int]*part
+}
+
+// partPath returns the path to store part n in
+func (u *upload) partPath(n int) string {
+	return filepath.Join(u.dir, strconv.Itoa(n))
+}
+
+// partNumbers returns the uploaded part numbers in order
+func (u *upload) partNumbers() []int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	numbers := make([]int, 0, len(u.parts))
+	for n := range u.parts {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// getPart returns part n or nil if not uploaded
+func (u *upload) getPart(n int) *part {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.parts[n]
+}
+
+// writePart stores part n reading it from in
+func (u *upload) writePart(n int, in io.Reader, wantMD5 []byte) (*part, error) {
+	tmp, err := ioutil.TempFile(u.dir, "tmp-")
+	if err != nil {
+		return nil, err
+	}
+	hasher := md5.New()
+	size, err := io.Copy(tmp, io.TeeReader(in, hasher))
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	p := &part{
+		size:    size,
+		md5:     hasher.Sum(nil),
+		modTime: time.Now(),
+	}
+	if err == nil && wantMD5 != nil && !bytes.Equal(p.md5, wantMD5) {
+		err = errBadDigest
+	}
+	if err == nil {
+		u.mu.Lock()
+		err = os.Rename(tmp.Name(), u.partPath(n))
+		if err == nil {
+			u.parts[n] = p
+		}
+		u.mu.Unlock()
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	return p, nil
+}
+
+// uploads holds the multipart uploads in progress
+type uploads struct {
+	mu      sync.Mutex
+	dir     string // directory to store the parts in
+	uploads map[string]*upload
+}
+
+// newUploads makes a new uploads storing the parts under dir
+func newUploads(dir string) *uploads {
+	return &uploads{
+		dir:     dir,
+		uploads: make(map[string]*upload),
+	}
+}
+
+// create starts a new upload for key in bucket
+func (us *uploads) create(bucket, key string, modTime time.Time) (*upload, error) {
+	u := &upload{
+		id:        random.String(32),
+		bucket:    bucket,
+		key:       key,
+		initiated: time.Now(),
+		modTime:   modTime,
+		parts:     make(map[int]*part),
+	}
+	u.dir = filepath.Join(us.dir, u.id)
+	if err := os.MkdirAll(u.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to make multipart upload directory: %w", err)
+	}
+	us.mu.Lock()
+	us.uploads[u.id] = u
+	us.mu.Unlock()
+	return u, nil
+}
+
+// get returns the upload with id for key in bucket
+func (us *uploads) get(bucket, key, id string) (*upload, error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	u := us.uploads[id]
+	if u == nil || u.bucket != bucket || u.key != key {
+		return nil, errNoSuchUpload
+	}
+	return u, nil
+}
+
+// list returns the uploads in bucket whose keys start with prefix
+// sorted by key and then by creation time.
+func (us *uploads) list(bucket, prefix string) []*upload {
+	us.mu.Lock()
+	var list []*upload
+	for _, u := range us.uploads {
+		if u.bucket == bucket && strings.HasPrefix(u.key, prefix) {
+			list = append(list, u)
+		}
+	}
+	us.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].key != list[j].key {
+			return list[i].key < list[j].key
+		}
+		return list[i].initiated.Before(list[j].initiated)
+	})
+	return list
+}
+
+// remove removes the upload and its parts
+func (us *uploads) remove(u *upload) {
+	us.mu.Lock()
+	delete(us.uploads, u.id)
+	us.mu.Unlock()
+	if err := os.RemoveAll(u.dir); err != nil {
+		fs.Errorf(nil, "Failed to remove multipart upload parts: %v", err)
+	}
+}
+
+// removeAll removes all the uploads and their parts
+func (us *uploads) removeAll() {
+	us.mu.Lock()
+	us.uploads = make(map[string]*upload)
+	us.mu.Unlock()
+	if err := os.RemoveAll(us.dir); err != nil {
+		fs.Errorf(nil, "Failed to remove multipart upload parts: %v", err)
+	}
+}
+
+// partsReader reads the concatenation of the part files, opening
+// them one at a time.
+type partsReader struct {
+	paths []string
+	in    *os.File
+}
+
+// Read bytes from the parts
+func (pr *partsReader) Read(p []byte) (n int, err error) {
+	for {
+		if pr.in == nil {
+			if len(pr.paths) == 0 {
+				return 0, io.EOF
+			}
+			pr.in, err = os.Open(pr.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			pr.paths = pr.paths[1:]
+		}
+		n, err = pr.in.Read(p)
+		if err == io.EOF {
+			err = pr.in.Close()
+			pr.in = nil
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close the part being read, if any
+func (pr *partsReader) Close() error {
+	if pr.in == nil {
+		return nil
+	}
+	return pr.in.Close()
+}
+
+// createMultipartUpload serves CreateMultipartUpload
+func (s *Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	if _, err := s.getBucket(bucket); err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, "/") {
+		return errInvalidObjectKey
+	}
+	modTime, _ := metaModTime(r.Header)
+	u, err := s.uploads.create(bucket, key, modTime)
+	if err != nil {
+		return err
+	}
+	writeXML(w, r, http.StatusOK, &initiateMultipartUploadResult{
+		Xmlns:    xmlNamespace,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: u.id,
+	})
+	return nil
+}
+
+// parseCopySourceRange parses the X-Amz-Copy-Source-Range header
+// "bytes=first-last" returning the offset and length.
+func parseCopySourceRange(value string, size int64) (offset, length int64, err error) {
+	if value == "" {
+		return 0, size, nil
+	}
+	var first, last int64
+	if _, err = fmt.Sscanf(value, "bytes=%d-%d", &first, &last); err != nil || first < 0 || last < first {
+		return 0, 0, errInvalidArgument.withMessage("The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+	}
+	if last >= size {
+		return 0, 0, errInvalidRange
+	}
+	return first, last - first + 1, nil
+}
+
+// uploadPart serves UploadPart and UploadPartCopy
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) error {
+	n, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		return errInvalidArgument.withMessage(fmt.Sprintf("Part number must be an integer between 1 and %d, inclusive", maxPartNumber))
+	}
+	u, err := s.uploads.get(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	// Upload the part from the body
+	if r.Header.Get("X-Amz-Copy-Source") == "" {
+		wantMD5, err := contentMD5(r.Header)
+		if err != nil {
+			return err
+		}
+		p, err := u.writePart(n, r.Body, wantMD5)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("ETag", p.etag())
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	// Upload the part by copying an existing object
+	src, err := s.getCopySource(r)
+	if err != nil {
+		return err
+	}
+	offset, length, err := parseCopySourceRange(r.Header.Get("X-Amz-Copy-Source-Range"), src.Size())
+	if err != nil {
+		return err
+	}
+	in, err := src.Open(os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	p, err := u.writePart(n, io.NewSectionReader(in, offset, length), nil)
+	closeErr := in.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	writeXML(w, r, http.StatusOK, &copyPartResult{
+		Xmlns:        xmlNamespace,
+		ETag:         p.etag(),
+		LastModified: xmlTime(p.modTime),
+	})
+	return nil
+}
+
+// completeMultipartUpload serves CompleteMultipartUpload
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) error {
+	u, err := s.uploads.get(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	var request completeMultipartUpload
+	if err := xml.NewDecoder(io.LimitReader(r.Body, 2*1024*1024)).Decode(&request); err != nil || len(request.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	// Check the parts and work out the multipart ETag
+	paths := make([]string, 0, len(request.Parts))
+	etagHasher := md5.New()
+	previous := 0
+	for _, completed := range request.Parts {
+		if completed.PartNumber <= previous {
+			return errInvalidPartOrder
+		}
+		previous = completed.PartNumber
+		p := u.getPart(completed.PartNumber)
+		if p == nil || strings.Trim(completed.ETag, `"`) != hex.EncodeToString(p.md5) {
+			return errInvalidPart
+		}
+		paths = append(paths, u.partPath(completed.PartNumber))
+		_, _ = etagHasher.Write(p.md5)
+	}
+
+	in := &partsReader{paths: paths}
+	_, err = s.writeObject(r.Context(), bucket, key, in, nil, u.modTime)
+	closeErr := in.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	s.uploads.remove(u)
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	writeXML(w, r, http.StatusOK, &completeMultipartUploadResult{
+		Xmlns:    xmlNamespace,
+		Location: scheme + "://" + r.Host + "/" + bucket + "/" + uriEncode(key, false),
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(etagHasher.Sum(nil)), len(paths)),
+	})
+	return nil
+}
+
+// abortMultipartUpload serves AbortMultipartUpload
+func (s *Server) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) error {
+	u, err := s.uploads.get(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	s.uploads.remove(u)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// listParts serves ListParts
+func (s *Server) listParts(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) error {
+	u, err := s.uploads.get(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	maxParts := maxListParts
+	if value := query.Get("max-parts"); value != "" {
+		maxParts, err = strconv.Atoi(value)
+		if err != nil || maxParts < 0 {
+			return errInvalidArgument.withMessage("Provided max-parts not an integer or within integer range")
+		}
+		if maxParts > maxListParts {
+			maxParts = maxListParts
+		}
+	}
+	marker := 0
+	if value := query.Get("part-number-marker"); value != "" {
+		marker, err = strconv.Atoi(value)
+		if err != nil || marker < 0 {
+			return errInvalidArgument.withMessage("Provided part-number-marker not an integer or within integer range")
+		}
+	}
+	result := &listPartsResult{
+		Xmlns:            xmlNamespace,
+		Bucket:           bucket,
+		Key:              key,
+		UploadID:         uploadID,
+		Initiator:        defaultOwner,
+		Owner:            defaultOwner,
+		StorageClass:     "STANDARD",
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+		Parts:            []partInfo{},
+	}
+	for _, n := range u.partNumbers() {
+		if n <= marker {
+			continue
+		}
+		if len(result.Parts) >= maxParts {
+			result.IsTruncated = true
+			break
+		}
+		p := u.getPart(n)
+		result.Parts = append(result.Parts, partInfo{
+			PartNumber:   n,
+			LastModified: xmlTime(p.modTime),
+			ETag:         p.etag(),
+			Size:         p.size,
+		})
+		result.NextPartNumberMarker = n
+	}
+	writeXML(w, r, http.StatusOK, result)
+	return nil
+}
+
+// listMultipartUploads serves ListMultipartUploads
+func (s *Server) listMultipartUploads(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if _, err := s.getBucket(bucket); err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	maxUploads := maxListKeys
+	if value := query.Get("max-uploads"); value != "" {
+		var err error
+		maxUploads, err = strconv.Atoi(value)
+		if err != nil || maxUploads < 0 {
+			return errInvalidArgument.withMessage("Provided max-uploads not an integer or within integer range")
+		}
+		if maxUploads > maxListKeys {
+			maxUploads = maxListKeys
+		}
+	}
+	result := &listMultipartUploadsResult{
+		Xmlns:          xmlNamespace,
+		Bucket:         bucket,
+		KeyMarker:      query.Get("key-marker"),
+		UploadIDMarker: query.Get("upload-id-marker"),
+		Prefix:         query.Get("prefix"),
+		MaxUploads:     maxUploads,
+		Uploads:        []uploadInfo{},
+	}
+	for _, u := range s.uploads.list(bucket, result.Prefix) {
+		if u.key < result.KeyMarker || (u.key == result.KeyMarker && (result.UploadIDMarker == "" || u.id <= result.UploadIDMarker)) {
+			continue
+		}
+		if len(result.Uploads) >= maxUploads {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, uploadInfo{
+			Key:          u.key,
+			UploadID:     u.id,
+			Initiator:    defaultOwner,
+			Owner:        defaultOwner,
+			StorageClass: "STANDARD",
+			Initiated:    xmlTime(u.initiated),
+		})
+		result.NextKeyMarker = u.key
+		result.NextUploadIDMarker = u.id
+	}
+	writeXML(w, r, http.StatusOK, result)
+	return nil
+}
+
+// check interfaces
+var _ io.ReadCloser = (*partsReader)(nil)
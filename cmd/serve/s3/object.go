@@ -0,0 +1,391 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/swift/v2"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/audit"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/rclone/rclone/vfs"
+)
+
+// header used to store the modification time, compatible with the s3 backend
+const metaMtime = "X-Amz-Meta-Mtime"
+
+// uploadSuffix is the suffix of the temporary files objects are
+// uploaded to
+const uploadSuffix = ".rclone-upload"
+
+// isUpload returns whether name is a temporary upload file
+func isUpload(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, uploadSuffix)
+}
+
+// metaModTime reads the modification time from the metadata in header
+func metaModTime(header http.Header) (modTime time.Time, ok bool) {
+	value := header.Get(metaMtime)
+	if value == "" {
+		return modTime, false
+	}
+	modTime, err := swift.FloatStringToTime(value)
+	if err != nil {
+		fs.Debugf(nil, "Ignoring invalid %s %q: %v", metaMtime, value, err)
+		return modTime, false
+	}
+	return modTime, true
+}
+
+// contentMD5 decodes the Content-MD5 header, returning nil if not set
+func contentMD5(header http.Header) ([]byte, error) {
+	value := header.Get("Content-MD5")
+	if value == "" {
+		return nil, nil
+	}
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sum) != md5.Size {
+		return nil, errInvalidDigest
+	}
+	return sum, nil
+}
+
+// etagMatches returns true if the If-Match style header matches etag
+func etagMatches(header, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.Trim(value, `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreconditions checks the conditional request headers against
+// the etag and modTime of the object.
+//
+// It returns errPreconditionFailed if a precondition doesn't hold or
+// errNotModified if the object hasn't changed.
+func checkPreconditions(r *http.Request, etag string, modTime time.Time) error {
+	modTime = modTime.Truncate(time.Second)
+	if value := r.Header.Get("If-Match"); value != "" {
+		if !etagMatches(value, etag) {
+			return errPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && modTime.After(t) {
+		return errPreconditionFailed
+	}
+	if value := r.Header.Get("If-None-Match"); value != "" {
+		if etagMatches(value, etag) {
+			return errNotModified
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modTime.After(t) {
+		return errNotModified
+	}
+	return nil
+}
+
+// getObject serves GetObject and HeadObject
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	node, err := s.getNode(bucket, key)
+	if err != nil {
+		return err
+	}
+	etag := s.etag(r.Context(), node)
+	modTime := node.ModTime()
+	err = checkPreconditions(r, etag, modTime)
+	if err == errNotModified {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	h.Set(metaMtime, swift.TimeToFloatString(modTime))
+	h.Set("Accept-Ranges", "bytes")
+	if node.IsDir() {
+		h.Set("Content-Type", "application/x-directory")
+		h.Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	obj, _ := node.DirEntry().(fs.Object)
+	if obj != nil {
+		h.Set("Content-Type", fs.MimeType(r.Context(), obj))
+	} else {
+		h.Set("Content-Type", fs.MimeTypeFromName(key))
+	}
+
+	// If HEAD no need to read the object since we have set the headers
+	if r.Method == "HEAD" {
+		h.Set("Content-Length", strconv.FormatInt(node.Size(), 10))
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	in, err := node.Open(os.O_RDONLY)
+	in = getAuditor(r.Context()).Open(node.Path(), os.O_RDONLY, in, err)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := in.Close()
+		if err != nil {
+			fs.Errorf(node.Path(), "Failed to close file: %v", err)
+		}
+	}()
+
+	// Account the transfer
+	if obj != nil {
+		tr := accounting.Stats(r.Context()).NewTransfer(obj)
+		defer tr.Done(r.Context(), nil)
+	}
+
+	http.ServeContent(w, r, key, modTime, in)
+	return nil
+}
+
+// putObject serves PutObject
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	if _, err := s.getBucket(bucket); err != nil {
+		return err
+	}
+	wantMD5, err := contentMD5(r.Header)
+	if err != nil {
+		return err
+	}
+	modTime, _ := metaModTime(r.Header)
+	var etag string
+	if strings.HasSuffix(key, "/") {
+		etag, err = s.putDirMarker(r.Context(), bucket, key, r.Body)
+	} else {
+		etag, err = s.writeObject(r.Context(), bucket, key, r.Body, wantMD5, modTime)
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// putDirMarker makes the directory for a key ending in "/"
+//
+// Only empty objects can be stored this way.
+func (s *Server) putDirMarker(ctx context.Context, bucket, key string, in io.Reader) (etag string, err error) {
+	n, err := io.Copy(io.Discard, in)
+	if err != nil {
+		return "", err
+	}
+	if n != 0 {
+		return "", errInvalidRequest.withMessage("Object keys ending in \"/\" must be empty.")
+	}
+	dir, err := s.mkdirAll(path.Join(bucket, key))
+	if err != nil {
+		return "", err
+	}
+	return s.etag(ctx, dir), nil
+}
+
+// writeObject writes the contents of in to key in bucket.
+//
+// If wantMD5 is set then the MD5 of the data must match it and if
+// modTime is set then it is set on the object. It returns the ETag of
+// the new object.
+//
+// The data is written to a temporary file next to the key which is
+// renamed over it once it has been checked, so an upload which fails
+// leaves any existing object untouched.
+func (s *Server) writeObject(ctx context.Context, bucket, key string, in io.Reader, wantMD5 []byte, modTime time.Time) (etag string, err error) {
+	remote := path.Join(bucket, key)
+	dir, err := s.mkdirAll(path.Dir(remote))
+	if err != nil {
+		return "", err
+	}
+	if node, err := dir.Stat(path.Base(remote)); err == nil && node.IsDir() {
+		return "", errKeyConflict
+	}
+	tmp := path.Join(path.Dir(remote), "."+path.Base(remote)+"."+random.String(8)+uploadSuffix)
+	handle, err := s.vfs.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	handle = getAuditor(ctx).Open(remote, os.O_WRONLY, handle, err)
+	if err != nil {
+		return "", err
+	}
+	hasher := md5.New()
+	_, err = io.Copy(handle, io.TeeReader(in, hasher))
+	closeErr := handle.Close()
+	if err == nil {
+		err = closeErr
+	}
+	sum := hasher.Sum(nil)
+	if err == nil && wantMD5 != nil && !bytes.Equal(sum, wantMD5) {
+		err = errBadDigest
+	}
+	if err == nil && !modTime.IsZero() {
+		err = s.vfs.Chtimes(tmp, modTime, modTime)
+	}
+	if err == nil {
+		err = s.vfs.Rename(tmp, remote)
+	}
+	if err != nil {
+		// The VFS can't abandon a write, so remove what was written
+		if removeErr := s.vfs.Remove(tmp); removeErr != nil && !errors.Is(removeErr, vfs.ENOENT) {
+			fs.Errorf(tmp, "Failed to remove failed upload: %v", removeErr)
+		}
+		return "", err
+	}
+	if s.hashType == hash.MD5 {
+		return `"` + hex.EncodeToString(sum) + `"`, nil
+	}
+	node, err := s.vfs.Stat(remote)
+	if err != nil {
+		return "", err
+	}
+	return s.etag(ctx, node), nil
+}
+
+// parseCopySource parses the X-Amz-Copy-Source header
+func parseCopySource(source string) (bucket, key string, err error) {
+	if i := strings.IndexRune(source, '?'); i >= 0 {
+		source = source[:i]
+	}
+	source, err = url.PathUnescape(source)
+	if err != nil {
+		return "", "", errInvalidArgument.withMessage("Invalid copy source encoding")
+	}
+	source = strings.TrimPrefix(source, "/")
+	slash := strings.IndexRune(source, '/')
+	if slash <= 0 || slash == len(source)-1 {
+		return "", "", errInvalidArgument.withMessage("Copy Source must mention the source bucket and key: sourcebucket/sourcekey")
+	}
+	bucket, key = source[:slash], source[slash+1:]
+	if !validBucketName(bucket) {
+		return "", "", errInvalidBucketName
+	}
+	if !validKey(key) || strings.HasSuffix(key, "/") {
+		return "", "", errInvalidObjectKey
+	}
+	return bucket, key, nil
+}
+
+// getCopySource returns the file referred to by X-Amz-Copy-Source
+func (s *Server) getCopySource(r *http.Request) (*vfs.File, error) {
+	srcBucket, srcKey, err := parseCopySource(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return nil, err
+	}
+	node, err := s.getNode(srcBucket, srcKey)
+	if err != nil {
+		return nil, err
+	}
+	return node.(*vfs.File), nil
+}
+
+// copyObject serves CopyObject
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	src, err := s.getCopySource(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.getBucket(bucket); err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, "/") {
+		return errInvalidObjectKey
+	}
+	modTime := src.ModTime()
+	switch r.Header.Get("X-Amz-Metadata-Directive") {
+	case "", "COPY":
+		if src.Path() == path.Join(bucket, key) {
+			return errInvalidRequest.withMessage("This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.")
+		}
+	case "REPLACE":
+		var ok bool
+		if modTime, ok = metaModTime(r.Header); !ok {
+			modTime = time.Now()
+		}
+	default:
+		return errInvalidArgument.withMessage("Unknown metadata directive.")
+	}
+
+	var etag string
+	if src.Path() == path.Join(bucket, key) {
+		if err = src.SetModTime(modTime); err != nil {
+			return err
+		}
+		etag = s.etag(r.Context(), src)
+	} else {
+		in, err := src.Open(os.O_RDONLY)
+		in = getAuditor(r.Context()).Open(src.Path(), os.O_RDONLY, in, err)
+		if err != nil {
+			return err
+		}
+		etag, err = s.writeObject(r.Context(), bucket, key, in, nil, modTime)
+		closeErr := in.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	writeXML(w, r, http.StatusOK, &copyObjectResult{
+		Xmlns:        xmlNamespace,
+		ETag:         etag,
+		LastModified: xmlTime(modTime),
+	})
+	return nil
+}
+
+// deleteKey deletes key from bucket
+//
+// It isn't an error if the key doesn't exist.
+func (s *Server) deleteKey(ctx context.Context, bucket, key string) error {
+	if !validKey(key) {
+		return errInvalidObjectKey
+	}
+	node, err := s.getNode(bucket, key)
+	if err == errNoSuchKey {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if dir, ok := node.(*vfs.Dir); ok && !isEmpty(dir) {
+		// a directory marker for a directory with contents
+		return nil
+	}
+	err = node.Remove()
+	getAuditor(ctx).Log(audit.OpDelete, node.Path(), err)
+	if err != nil {
+		return err
+	}
+	s.removeEmptyDirs(bucket, path.Dir(node.Path()))
+	return nil
+}
+
+// deleteObject serves DeleteObject
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	if err := s.deleteKey(r.Context(), bucket, key); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,135 @@
+// Package s3 implements an S3 compatible server backed by rclone VFS
+package s3
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/lib/atexit"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+)
+
+// Options contains options for the s3 server
+type Options struct {
+	AuthKeys       []string // access_key_id,secret_access_key pairs
+	HashName       string   // name of the hash to use for the ETag
+	ForcePathStyle bool     // if false also accept virtual hosted style requests
+	NoCleanup      bool     // don't remove empty directories after deleting objects
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	HashName:       "MD5",
+	ForcePathStyle: true,
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+func init() {
+	flagSet := Command.Flags()
+	httpflags.AddFlags(flagSet)
+	vfsflags.AddFlags(flagSet)
+	flags.StringArrayVarP(flagSet, &Opt.AuthKeys, "auth-key", "", Opt.AuthKeys, "Set key pair for v4 authorization: access_key_id,secret_access_key")
+	flags.StringVarP(flagSet, &Opt.HashName, "etag-hash", "", Opt.HashName, "Which hash to use for the ETag, or auto or blank for off")
+	flags.BoolVarP(flagSet, &Opt.ForcePathStyle, "force-path-style", "", Opt.ForcePathStyle, "If true use path style access if false use virtual hosted style")
+	flags.BoolVarP(flagSet, &Opt.NoCleanup, "no-cleanup", "", Opt.NoCleanup, "Don't remove empty directories after an object is deleted")
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "s3 remote:path",
+	Short: `Serve remote:path over s3.`,
+	Long: `
+rclone serve s3 implements a basic s3 server that serves the remote
+over HTTP via the S3 protocol. This can be used with any S3
+compatible client, such as the AWS CLI, or you can make a remote of
+type s3 to read and write it.
+
+The top level directories of remote:path are served as buckets and
+the files within them as objects. Object keys containing "/" are
+stored in sub directories.
+
+The following operations are supported: ListBuckets, CreateBucket,
+HeadBucket, DeleteBucket, GetBucketLocation, ListObjects,
+ListObjectsV2, GetObject, HeadObject, PutObject, CopyObject,
+DeleteObject, DeleteObjects and multipart uploads (including
+UploadPartCopy).
+
+Modification times are stored and returned using the
+"X-Amz-Meta-Mtime" metadata which is compatible with rclone's s3
+backend. Other user metadata is not stored.
+
+### S3 options
+
+#### --auth-key
+
+Use --auth-key access_key_id,secret_access_key to set a key pair which
+clients must use to sign their requests with AWS Signature Version 4.
+This flag can be repeated to allow more than one key pair. If no
+--auth-key is supplied then requests are not authenticated.
+
+Both header and query string (presigned URL) authentication are
+supported, as are signed streaming (aws-chunked) uploads.
+
+Note that the --user, --pass, --htpasswd and --oidc-issuer flags can't
+be used with this command as S3 clients use their own authentication
+scheme.
+
+#### --etag-hash
+
+This controls the ETag header.  By default the MD5 hash of the object
+is used.  If the hash isn't available, or this flag is set to blank,
+then the ETag will be based on the ModTime and Size of the object.
+
+If this flag is set to "auto" then rclone will choose the first
+supported hash on the backend or you can use a named hash such as
+"MD5" or "SHA-1".
+
+Use "rclone hashsum" to see the full list.
+
+#### --force-path-style
+
+By default the bucket name is read from the first element of the URL
+path ("path style" access). If --force-path-style=false is set then
+the bucket name will be read from the first label of the Host header
+when it contains a "." and isn't an IP address ("virtual hosted
+style" access), e.g. "bucket.s3.example.com".
+
+#### --no-cleanup
+
+After an object is deleted rclone removes any directories left empty
+in its bucket, so they don't show up in listings as common prefixes.
+Use --no-cleanup to disable this.
+
+### Multipart uploads
+
+Parts of multipart uploads are stored in rclone's cache directory
+(see --cache-dir) until the upload is completed or aborted. Uploads
+which are in progress when the server is stopped are discarded.
+
+` + httplib.Help + vfs.Help,
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, false, command, func() error {
+			s, err := newServer(context.Background(), f, &httpflags.Opt, &Opt)
+			if err != nil {
+				return err
+			}
+			err = s.serve()
+			if err != nil {
+				return err
+			}
+			atexit.Register(s.Close)
+			s.Wait()
+			return nil
+		})
+		return nil
+	},
+}
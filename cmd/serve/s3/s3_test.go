@@ -0,0 +1,429 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBindAddress = "localhost:0"
+	testAccessKey   = "access_key"
+	testSecretKey   = "secret_key"
+)
+
+// startServer starts a server serving a temporary local directory
+// and returns an S3 client connected to it.
+func startServer(t *testing.T) (*Server, *s3.S3) {
+	f, err := fs.NewFs(context.Background(), t.TempDir())
+	require.NoError(t, err)
+
+	httpOpt := httplib.DefaultOpt
+	httpOpt.ListenAddr = testBindAddress
+	opt := DefaultOpt
+	opt.AuthKeys = []string{testAccessKey + "," + testSecretKey}
+	s, err := newServer(context.Background(), f, &httpOpt, &opt)
+	require.NoError(t, err)
+	require.NoError(t, s.serve())
+	t.Cleanup(func() {
+		s.Close()
+		s.Wait()
+	})
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials(testAccessKey, testSecretKey, ""),
+		Endpoint:         aws.String(s.URL()),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	require.NoError(t, err)
+	return s, s3.New(sess)
+}
+
+// errCode returns the S3 error code of err
+func errCode(err error) string {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+func putObject(t *testing.T, client *s3.S3, bucket, key, contents string) {
+	_, err := client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(contents),
+	})
+	require.NoError(t, err)
+}
+
+func getObject(t *testing.T, client *s3.S3, bucket, key string) string {
+	out, err := client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, out.Body.Close())
+	}()
+	data, err := ioutil.ReadAll(out.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestBuckets(t *testing.T) {
+	_, client := startServer(t)
+
+	_, err := client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String("bucket")})
+	assert.Error(t, err)
+
+	_, err = client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	_, err = client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket")})
+	assert.Equal(t, "BucketAlreadyOwnedByYou", errCode(err))
+
+	_, err = client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+
+	list, err := client.ListBuckets(&s3.ListBucketsInput{})
+	require.NoError(t, err)
+	require.Len(t, list.Buckets, 1)
+	assert.Equal(t, "bucket", aws.StringValue(list.Buckets[0].Name))
+
+	putObject(t, client, "bucket", "file.txt", "hello")
+	_, err = client.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("bucket")})
+	assert.Equal(t, "BucketNotEmpty", errCode(err))
+
+	_, err = client.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String("bucket"), Key: aws.String("file.txt")})
+	require.NoError(t, err)
+	_, err = client.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+
+	_, err = client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("file.txt"),
+		Body:   strings.NewReader("hello"),
+	})
+	assert.Equal(t, "NoSuchBucket", errCode(err))
+}
+
+func TestObjects(t *testing.T) {
+	_, client := startServer(t)
+	_, err := client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+
+	modTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	_, err = client.PutObject(&s3.PutObjectInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("dir/file.txt"),
+		Body:     strings.NewReader("hello world"),
+		Metadata: map[string]*string{"Mtime": aws.String("981173106")},
+	})
+	require.NoError(t, err)
+
+	head, err := client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("dir/file.txt"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), aws.Int64Value(head.ContentLength))
+	assert.Equal(t, `"5eb63bbbe01eeed093cb22bb8f5acdc3"`, aws.StringValue(head.ETag))
+	assert.Equal(t, modTime, aws.TimeValue(head.LastModified).UTC())
+
+	assert.Equal(t, "hello world", getObject(t, client, "bucket", "dir/file.txt"))
+
+	// Ranged read
+	out, err := client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("dir/file.txt"),
+		Range:  aws.String("bytes=6-"),
+	})
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(out.Body)
+	require.NoError(t, err)
+	require.NoError(t, out.Body.Close())
+	assert.Equal(t, "world", string(data))
+
+	// Conditional read
+	_, err = client.GetObject(&s3.GetObjectInput{
+		Bucket:  aws.String("bucket"),
+		Key:     aws.String("dir/file.txt"),
+		IfMatch: aws.String(`"potato"`),
+	})
+	assert.Equal(t, "PreconditionFailed", errCode(err))
+
+	// Copy
+	_, err = client.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("copy.txt"),
+		CopySource: aws.String("bucket/dir/file.txt"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", getObject(t, client, "bucket", "copy.txt"))
+
+	_, err = client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("missing.txt"),
+	})
+	assert.Equal(t, "NoSuchKey", errCode(err))
+
+	// Content-MD5 mismatch
+	req, _ := client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("bad.txt"),
+		Body:       strings.NewReader("hello"),
+		ContentMD5: aws.String("XrY7u+Ae7tCTyyK7j1rNww=="),
+	})
+	assert.Equal(t, "BadDigest", errCode(req.Send()))
+
+	// A failed upload leaves the existing object alone
+	req, _ = client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("copy.txt"),
+		Body:       strings.NewReader("hello"),
+		ContentMD5: aws.String("XrY7u+Ae7tCTyyK7j1rNww=="),
+	})
+	assert.Equal(t, "BadDigest", errCode(req.Send()))
+	assert.Equal(t, "hello world", getObject(t, client, "bucket", "copy.txt"))
+
+	// Deleting the last object in a directory removes it
+	_, err = client.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String("bucket"),
+		Delete: &s3.Delete{Objects: []*s3.ObjectIdentifier{
+			{Key: aws.String("dir/file.txt")},
+			{Key: aws.String("bad.txt")},
+		}},
+	})
+	require.NoError(t, err)
+	list, err := client.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	require.Len(t, list.Contents, 1)
+	assert.Equal(t, "copy.txt", aws.StringValue(list.Contents[0].Key))
+	assert.Len(t, list.CommonPrefixes, 0)
+}
+
+func TestListObjects(t *testing.T) {
+	_, client := startServer(t)
+	_, err := client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	keys := []string{"a.txt", "a/b.txt", "a/c/d.txt", "a/c/e.txt", "b", "c d/e+f"}
+	for _, key := range keys {
+		putObject(t, client, "bucket", key, key)
+	}
+
+	// listV2 lists everything a page at a time
+	listV2 := func(prefix, delimiter string) (got []string) {
+		input := &s3.ListObjectsV2Input{
+			Bucket:       aws.String("bucket"),
+			Prefix:       aws.String(prefix),
+			Delimiter:    aws.String(delimiter),
+			MaxKeys:      aws.Int64(2),
+			EncodingType: aws.String("url"),
+		}
+		err := client.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, last bool) bool {
+			for _, object := range page.Contents {
+				got = append(got, aws.StringValue(object.Key))
+			}
+			for _, prefix := range page.CommonPrefixes {
+				got = append(got, aws.StringValue(prefix.Prefix))
+			}
+			return true
+		})
+		require.NoError(t, err)
+		sort.Strings(got)
+		return got
+	}
+	assert.Equal(t, []string{"a.txt", "a/b.txt", "a/c/d.txt", "a/c/e.txt", "b", "c%20d/e%2Bf"}, listV2("", ""))
+	assert.Equal(t, []string{"a.txt", "a/", "b", "c%20d/"}, listV2("", "/"))
+	assert.Equal(t, []string{"a/b.txt", "a/c/"}, listV2("a/", "/"))
+	assert.Equal(t, []string{"a/c/d.txt", "a/c/e.txt"}, listV2("a/c", ""))
+	assert.Equal(t, []string{"a.txt", "a/"}, listV2("a", "/"))
+
+	// listV1 lists everything a page at a time
+	var got []string
+	err = client.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket:  aws.String("bucket"),
+		MaxKeys: aws.Int64(4),
+	}, func(page *s3.ListObjectsOutput, last bool) bool {
+		for _, object := range page.Contents {
+			got = append(got, aws.StringValue(object.Key))
+		}
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, keys, got)
+}
+
+func TestMultipartUpload(t *testing.T) {
+	s, client := startServer(t)
+	_, err := client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	putObject(t, client, "bucket", "source.txt", "0123456789")
+
+	create, err := client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("multi.txt"),
+	})
+	require.NoError(t, err)
+
+	part1, err := client.UploadPart(&s3.UploadPartInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("multi.txt"),
+		UploadId:   create.UploadId,
+		PartNumber: aws.Int64(1),
+		Body:       bytes.NewReader([]byte("hello ")),
+	})
+	require.NoError(t, err)
+	part2, err := client.UploadPartCopy(&s3.UploadPartCopyInput{
+		Bucket:          aws.String("bucket"),
+		Key:             aws.String("multi.txt"),
+		UploadId:        create.UploadId,
+		PartNumber:      aws.Int64(2),
+		CopySource:      aws.String("bucket/source.txt"),
+		CopySourceRange: aws.String("bytes=2-5"),
+	})
+	require.NoError(t, err)
+
+	uploads, err := client.ListMultipartUploads(&s3.ListMultipartUploadsInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	require.Len(t, uploads.Uploads, 1)
+	assert.Equal(t, aws.StringValue(create.UploadId), aws.StringValue(uploads.Uploads[0].UploadId))
+
+	parts, err := client.ListParts(&s3.ListPartsInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("multi.txt"),
+		UploadId: create.UploadId,
+	})
+	require.NoError(t, err)
+	require.Len(t, parts.Parts, 2)
+	assert.Equal(t, int64(4), aws.Int64Value(parts.Parts[1].Size))
+
+	// Parts out of order are rejected
+	_, err = client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("multi.txt"),
+		UploadId: create.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: []*s3.CompletedPart{
+			{PartNumber: aws.Int64(2), ETag: part2.CopyPartResult.ETag},
+			{PartNumber: aws.Int64(1), ETag: part1.ETag},
+		}},
+	})
+	assert.Equal(t, "InvalidPartOrder", errCode(err))
+
+	complete, err := client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("multi.txt"),
+		UploadId: create.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: []*s3.CompletedPart{
+			{PartNumber: aws.Int64(1), ETag: part1.ETag},
+			{PartNumber: aws.Int64(2), ETag: part2.CopyPartResult.ETag},
+		}},
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(aws.StringValue(complete.ETag), `-2"`))
+	assert.Equal(t, "hello 2345", getObject(t, client, "bucket", "multi.txt"))
+	assert.Len(t, s.uploads.list("bucket", ""), 0)
+
+	_, err = client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String("bucket"),
+		Key:      aws.String("multi.txt"),
+		UploadId: create.UploadId,
+	})
+	assert.Equal(t, "NoSuchUpload", errCode(err))
+}
+
+func TestAuth(t *testing.T) {
+	s, client := startServer(t)
+
+	// Presigned URL
+	req, _ := client.ListBucketsRequest(&s3.ListBucketsInput{})
+	url, err := req.Presign(time.Minute)
+	require.NoError(t, err)
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Tampered presigned URL
+	resp, err = http.Get(strings.Replace(url, "X-Amz-Expires=60", "X-Amz-Expires=61", 1))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Unsigned request
+	resp, err = http.Get(s.URL())
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Wrong secret
+	badClient := s3.New(session.Must(session.NewSession(client.Config.Copy(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(testAccessKey, "wrong", ""),
+	}))))
+	_, err = badClient.ListBuckets(&s3.ListBucketsInput{})
+	assert.Equal(t, "SignatureDoesNotMatch", errCode(err))
+}
+
+func TestNewServerRejectsHTTPAuth(t *testing.T) {
+	f, err := fs.NewFs(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	for _, setOpt := range []func(opt *httplib.Options){
+		func(opt *httplib.Options) { opt.BasicUser = "user" },
+		func(opt *httplib.Options) { opt.HtPasswd = "htpasswd" },
+		func(opt *httplib.Options) { opt.OIDCIssuer = "https://sso.example.com" },
+	} {
+		httpOpt := httplib.DefaultOpt
+		setOpt(&httpOpt)
+		_, err := newServer(context.Background(), f, &httpOpt, &DefaultOpt)
+		assert.ErrorContains(t, err, "use --auth-key instead")
+	}
+}
+
+func TestChunkedReaderBadSignature(t *testing.T) {
+	c := &chunkedReader{
+		in:         bufio.NewReader(strings.NewReader("5;chunk-signature=potato\r\nhello\r\n0;chunk-signature=potato\r\n\r\n")),
+		closer:     ioutil.NopCloser(nil),
+		signingKey: []byte("key"),
+	}
+	buf := make([]byte, 16)
+	n, err := c.Read(buf)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, errSignatureDoesNotMatch, err)
+	n, err = c.Read(buf)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, errSignatureDoesNotMatch, err)
+}
+
+func TestValidKey(t *testing.T) {
+	for _, test := range []struct {
+		key  string
+		want bool
+	}{
+		{"file.txt", true},
+		{"dir/file.txt", true},
+		{"dir/", true},
+		{"/file.txt", false},
+		{"dir//file.txt", false},
+		{"dir/../file.txt", false},
+		{"./file.txt", false},
+	} {
+		assert.Equal(t, test.want, validKey(test.key), test.key)
+	}
+}
@@ -0,0 +1,351 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/audit"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+// Sub resources which aren't supported. Requests using these are
+// rejected rather than being mistaken for plain object requests.
+var unsupportedSubResources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "encryption",
+	"intelligent-tiering", "inventory", "legal-hold", "lifecycle",
+	"logging", "metrics", "notification", "object-lock",
+	"ownershipControls", "policy", "policyStatus", "publicAccessBlock",
+	"replication", "requestPayment", "restore", "retention", "select",
+	"tagging", "torrent", "versioning", "versions", "website",
+}
+
+// Server is an S3 compatible server backed by a VFS
+type Server struct {
+	*httplib.Server
+	f        fs.Fs
+	vfs      *vfs.VFS
+	opt      Options
+	keys     map[string]string // secret access keys by access key ID
+	hashType hash.Type         // hash to use for ETags
+	uploads  *uploads          // multipart uploads in progress
+}
+
+// Make a new S3 server to serve the remote
+func newServer(ctx context.Context, f fs.Fs, httpOpt *httplib.Options, opt *Options) (*Server, error) {
+	if httpOpt.HtPasswd != "" || httpOpt.BasicUser != "" || httpOpt.OIDCIssuer != "" {
+		return nil, errors.New("can't use --user, --pass, --htpasswd or --oidc-issuer with serve s3 - use --auth-key instead")
+	}
+	keys, err := parseKeys(opt.AuthKeys)
+	if err != nil {
+		return nil, err
+	}
+	hashType := hash.None
+	if opt.HashName == "auto" {
+		hashType = f.Hashes().GetOne()
+	} else if opt.HashName != "" {
+		err := hashType.Set(opt.HashName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hashType != hash.None {
+		fs.Debugf(f, "Using hash %v for ETag", hashType)
+	}
+	s := &Server{
+		f:        f,
+		vfs:      vfs.New(f, &vfsflags.Opt),
+		opt:      *opt,
+		keys:     keys,
+		hashType: hashType,
+		uploads:  newUploads(filepath.Join(config.GetCacheDir(), "serve-s3", random.String(8))),
+	}
+	s.Server = httplib.NewServer(http.HandlerFunc(s.handler), httpOpt)
+	return s, nil
+}
+
+// serve runs the http server in the background.
+//
+// Use s.Close() and s.Wait() to shutdown server
+func (s *Server) serve() error {
+	err := s.Serve()
+	if err != nil {
+		return err
+	}
+	fs.Logf(s.f, "S3 Server started on %s", s.URL())
+	return nil
+}
+
+// Close shuts the server down and discards any multipart uploads in
+// progress
+func (s *Server) Close() {
+	s.Server.Close()
+	s.uploads.removeAll()
+}
+
+// handler reads incoming requests and dispatches them
+func (s *Server) handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Amz-Request-Id", random.String(16))
+	w.Header().Set("Server", "rclone/"+fs.Version)
+	urlPath, ok := s.Path(w, r)
+	if !ok {
+		return
+	}
+	fs.Infof(urlPath, "%s from %s", r.Method, r.RemoteAddr)
+	err := s.authenticate(r)
+	if err == nil {
+		if audit.Enabled() {
+			auditor := vfs.NewAuditor("s3", accessKeyID(r), r.RemoteAddr)
+			r = r.WithContext(context.WithValue(r.Context(), contextAuditorKey, auditor))
+		}
+		bucket, key := s.splitPath(r, urlPath)
+		err = s.route(w, r, bucket, key)
+	}
+	if err != nil {
+		writeError(w, r, err)
+	}
+}
+
+// contextAuditorType is the type of contextAuditorKey
+type contextAuditorType struct{}
+
+// contextAuditorKey is the context key for storing the *vfs.Auditor
+// of the request
+var contextAuditorKey = &contextAuditorType{}
+
+// getAuditor returns the auditor for this request which may be nil
+func getAuditor(ctx context.Context) *vfs.Auditor {
+	auditor, _ := ctx.Value(contextAuditorKey).(*vfs.Auditor)
+	return auditor
+}
+
+// route calls the handler for the request
+//
+// If the handler returns an error then it hasn't written a response.
+func (s *Server) route(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	query := r.URL.Query()
+	for _, subResource := range unsupportedSubResources {
+		if _, found := query[subResource]; found {
+			return errNotImplemented.withMessage(fmt.Sprintf("The %q sub resource is not supported.", subResource))
+		}
+	}
+	_, isUploads := query["uploads"]
+
+	// Service
+	if bucket == "" {
+		if r.Method == "GET" {
+			return s.listBuckets(w, r)
+		}
+		return errMethodNotAllowed
+	}
+	if !validBucketName(bucket) {
+		return errInvalidBucketName
+	}
+
+	// Bucket
+	if key == "" {
+		switch r.Method {
+		case "GET":
+			if _, found := query["location"]; found {
+				return s.getBucketLocation(w, r, bucket)
+			}
+			if isUploads {
+				return s.listMultipartUploads(w, r, bucket)
+			}
+			return s.listObjects(w, r, bucket, query.Get("list-type") == "2")
+		case "HEAD":
+			return s.headBucket(w, r, bucket)
+		case "PUT":
+			return s.createBucket(w, r, bucket)
+		case "DELETE":
+			return s.deleteBucket(w, r, bucket)
+		case "POST":
+			if _, found := query["delete"]; found {
+				return s.deleteObjects(w, r, bucket)
+			}
+		}
+		return errMethodNotAllowed
+	}
+	if !validKey(key) {
+		return errInvalidObjectKey
+	}
+
+	// Object
+	uploadID := query.Get("uploadId")
+	switch r.Method {
+	case "GET", "HEAD":
+		if uploadID != "" && r.Method == "GET" {
+			return s.listParts(w, r, bucket, key, uploadID)
+		}
+		return s.getObject(w, r, bucket, key)
+	case "PUT":
+		if uploadID != "" {
+			return s.uploadPart(w, r, bucket, key, uploadID)
+		}
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			return s.copyObject(w, r, bucket, key)
+		}
+		return s.putObject(w, r, bucket, key)
+	case "DELETE":
+		if uploadID != "" {
+			return s.abortMultipartUpload(w, r, bucket, key, uploadID)
+		}
+		return s.deleteObject(w, r, bucket, key)
+	case "POST":
+		if isUploads {
+			return s.createMultipartUpload(w, r, bucket, key)
+		}
+		if uploadID != "" {
+			return s.completeMultipartUpload(w, r, bucket, key, uploadID)
+		}
+	}
+	return errMethodNotAllowed
+}
+
+// splitPath returns the bucket and key the request refers to
+func (s *Server) splitPath(r *http.Request, urlPath string) (bucket, key string) {
+	urlPath = strings.TrimPrefix(urlPath, "/")
+	if !s.opt.ForcePathStyle {
+		host := r.Host
+		if hostOnly, _, err := net.SplitHostPort(host); err == nil {
+			host = hostOnly
+		}
+		if dot := strings.IndexRune(host, '.'); dot > 0 && net.ParseIP(host) == nil {
+			return host[:dot], urlPath
+		}
+	}
+	slash := strings.IndexRune(urlPath, '/')
+	if slash < 0 {
+		return urlPath, ""
+	}
+	return urlPath[:slash], urlPath[slash+1:]
+}
+
+// validBucketName returns true if bucket can be used as a directory name
+func validBucketName(bucket string) bool {
+	return bucket != "." && bucket != ".." && len(bucket) <= 255 && !strings.ContainsRune(bucket, '/')
+}
+
+// validKey returns true if key can be stored in the VFS
+//
+// Keys with a trailing "/" are allowed as they are used as directory
+// markers.
+func validKey(key string) bool {
+	for _, segment := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// getBucket returns the directory for bucket
+func (s *Server) getBucket(bucket string) (*vfs.Dir, error) {
+	node, err := s.vfs.Stat(bucket)
+	if errors.Is(err, vfs.ENOENT) || (err == nil && !node.IsDir()) {
+		return nil, errNoSuchBucket
+	} else if err != nil {
+		return nil, err
+	}
+	return node.(*vfs.Dir), nil
+}
+
+// getNode returns the node for key in bucket
+//
+// Keys with a trailing "/" only match directories and other keys only
+// match files.
+func (s *Server) getNode(bucket, key string) (vfs.Node, error) {
+	if _, err := s.getBucket(bucket); err != nil {
+		return nil, err
+	}
+	node, err := s.vfs.Stat(path.Join(bucket, key))
+	if errors.Is(err, vfs.ENOENT) {
+		return nil, errNoSuchKey
+	} else if err != nil {
+		return nil, err
+	}
+	if node.IsDir() != strings.HasSuffix(key, "/") {
+		return nil, errNoSuchKey
+	}
+	return node, nil
+}
+
+// mkdirAll makes the directory dirPath and any missing parents
+func (s *Server) mkdirAll(dirPath string) (*vfs.Dir, error) {
+	dir, err := s.vfs.Root()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(dirPath, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+		node, err := dir.Stat(name)
+		if errors.Is(err, vfs.ENOENT) {
+			dir, err = dir.Mkdir(name)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !node.IsDir() {
+			return nil, errKeyConflict
+		}
+		dir = node.(*vfs.Dir)
+	}
+	return dir, nil
+}
+
+// removeEmptyDirs removes dirPath and its parents while they are
+// empty, stopping at the bucket.
+func (s *Server) removeEmptyDirs(bucket, dirPath string) {
+	if s.opt.NoCleanup {
+		return
+	}
+	for strings.HasPrefix(dirPath, bucket+"/") {
+		node, err := s.vfs.Stat(dirPath)
+		if err != nil || !node.IsDir() {
+			return
+		}
+		dir := node.(*vfs.Dir)
+		if !isEmpty(dir) {
+			return
+		}
+		if err = dir.Remove(); err != nil {
+			return
+		}
+		dirPath = path.Dir(dirPath)
+	}
+}
+
+// isEmpty returns true if dir can be listed and has no entries
+func isEmpty(dir *vfs.Dir) bool {
+	nodes, err := dir.ReadDirAll()
+	return err == nil && len(nodes) == 0
+}
+
+// etag returns the quoted ETag for node
+func (s *Server) etag(ctx context.Context, node vfs.Node) string {
+	if s.hashType != hash.None {
+		if o, ok := node.DirEntry().(fs.Object); ok {
+			sum, err := o.Hash(ctx, s.hashType)
+			if err == nil && sum != "" {
+				return `"` + sum + `"`
+			}
+		}
+	}
+	return fmt.Sprintf(`"%x%x"`, node.ModTime().UnixNano(), node.Size())
+}
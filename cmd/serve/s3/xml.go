@@ -0,0 +1,331 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// namespace for the S3 XML documents
+const xmlNamespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// format used for times in XML documents
+const xmlTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// format a time for an XML document
+func xmlTime(t time.Time) string {
+	return t.UTC().Format(xmlTimeFormat)
+}
+
+// apiError is an error which can be returned to the client
+type apiError struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+// Error satisfies the error interface
+func (e *apiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// withMessage returns a copy of e with a different message
+func (e *apiError) withMessage(message string) *apiError {
+	newErr := *e
+	newErr.Message = message
+	return &newErr
+}
+
+// Errors returned to the client
+var (
+	errAccessDenied                 = &apiError{"AccessDenied", "Access Denied", http.StatusForbidden}
+	errBadDigest                    = &apiError{"BadDigest", "The Content-MD5 you specified did not match what was received.", http.StatusBadRequest}
+	errBucketAlreadyOwnedByYou      = &apiError{"BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.", http.StatusConflict}
+	errBucketNotEmpty               = &apiError{"BucketNotEmpty", "The bucket you tried to delete is not empty.", http.StatusConflict}
+	errContentSHA256Mismatch        = &apiError{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	errExpiredPresignRequest        = &apiError{"AccessDenied", "Request has expired", http.StatusForbidden}
+	errIncompleteBody               = &apiError{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	errInternalError                = &apiError{"InternalError", "We encountered an internal error, please try again.", http.StatusInternalServerError}
+	errInvalidAccessKeyID           = &apiError{"InvalidAccessKeyId", "The AWS access key ID you provided does not exist in our records.", http.StatusForbidden}
+	errInvalidArgument              = &apiError{"InvalidArgument", "Invalid Argument", http.StatusBadRequest}
+	errInvalidBucketName            = &apiError{"InvalidBucketName", "The specified bucket is not valid.", http.StatusBadRequest}
+	errInvalidDigest                = &apiError{"InvalidDigest", "The Content-MD5 you specified is not valid.", http.StatusBadRequest}
+	errInvalidObjectKey             = &apiError{"InvalidArgument", "Object keys with empty, \".\" or \"..\" path segments are not supported.", http.StatusBadRequest}
+	errInvalidPart                  = &apiError{"InvalidPart", "One or more of the specified parts could not be found.", http.StatusBadRequest}
+	errInvalidPartOrder             = &apiError{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	errInvalidRange                 = &apiError{"InvalidRange", "The requested range is not satisfiable.", http.StatusRequestedRangeNotSatisfiable}
+	errInvalidRequest               = &apiError{"InvalidRequest", "Invalid Request", http.StatusBadRequest}
+	errKeyConflict                  = &apiError{"InvalidArgument", "The object key conflicts with an existing object or prefix.", http.StatusConflict}
+	errMalformedXML                 = &apiError{"MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.", http.StatusBadRequest}
+	errMethodNotAllowed             = &apiError{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	errMissingSecurityHeader        = &apiError{"MissingSecurityHeader", "Your request is missing a required header.", http.StatusBadRequest}
+	errNoSuchBucket                 = &apiError{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	errNoSuchKey                    = &apiError{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	errNoSuchUpload                 = &apiError{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	errNotModified                  = &apiError{"NotModified", "Not Modified", http.StatusNotModified}
+	errNotImplemented               = &apiError{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	errPreconditionFailed           = &apiError{"PreconditionFailed", "At least one of the preconditions you specified did not hold.", http.StatusPreconditionFailed}
+	errRequestTimeTooSkewed         = &apiError{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	errSignatureDoesNotMatch        = &apiError{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your key and signing method.", http.StatusForbidden}
+	errAuthorizationHeaderMalformed = &apiError{"AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest}
+)
+
+// errorResponse is the body of an error sent to the client
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string
+	Message   string
+	Resource  string
+	RequestID string `xml:"RequestId"`
+}
+
+// owner of buckets and objects
+type owner struct {
+	ID          string
+	DisplayName string
+}
+
+// the owner reported for everything
+var defaultOwner = owner{
+	ID:          "rclone",
+	DisplayName: "rclone",
+}
+
+// bucketInfo describes a bucket in listAllMyBucketsResult
+type bucketInfo struct {
+	Name         string
+	CreationDate string
+}
+
+// listAllMyBucketsResult is the response to ListBuckets
+type listAllMyBucketsResult struct {
+	XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Owner   owner
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+// locationConstraint is the response to GetBucketLocation
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:",chardata"`
+}
+
+// objectInfo describes an object in listBucketResult
+type objectInfo struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+	Owner        *owner `xml:",omitempty"`
+}
+
+// commonPrefix describes a common prefix in listBucketResult
+type commonPrefix struct {
+	Prefix string
+}
+
+// listBucketResult is the response to ListObjects and ListObjectsV2
+type listBucketResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Xmlns          string   `xml:"xmlns,attr"`
+	Name           string
+	Prefix         string
+	Delimiter      string `xml:",omitempty"`
+	MaxKeys        int
+	EncodingType   string `xml:",omitempty"`
+	IsTruncated    bool
+	Contents       []objectInfo
+	CommonPrefixes []commonPrefix
+
+	// ListObjects only
+	Marker     *string `xml:",omitempty"`
+	NextMarker string  `xml:",omitempty"`
+
+	// ListObjectsV2 only
+	KeyCount              *int   `xml:",omitempty"`
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+}
+
+// copyObjectResult is the response to CopyObject
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	ETag         string
+	LastModified string
+}
+
+// copyPartResult is the response to UploadPartCopy
+type copyPartResult struct {
+	XMLName      xml.Name `xml:"CopyPartResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	ETag         string
+	LastModified string
+}
+
+// objectIdentifier names an object to delete in deleteRequest
+type objectIdentifier struct {
+	Key string
+}
+
+// deleteRequest is the body of a DeleteObjects request
+type deleteRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool
+	Objects []objectIdentifier `xml:"Object"`
+}
+
+// deletedObject is a successful deletion in deleteResult
+type deletedObject struct {
+	Key string
+}
+
+// deleteError is a failed deletion in deleteResult
+type deleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+// deleteResult is the response to DeleteObjects
+type deleteResult struct {
+	XMLName xml.Name `xml:"DeleteResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Deleted []deletedObject
+	Errors  []deleteError `xml:"Error"`
+}
+
+// initiateMultipartUploadResult is the response to CreateMultipartUpload
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string
+	Key      string
+	UploadID string `xml:"UploadId"`
+}
+
+// completedPart names a part in completeMultipartUpload
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// completeMultipartUpload is the body of a CompleteMultipartUpload request
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// completeMultipartUploadResult is the response to CompleteMultipartUpload
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// partInfo describes a part in listPartsResult
+type partInfo struct {
+	PartNumber   int
+	LastModified string
+	ETag         string
+	Size         int64
+}
+
+// listPartsResult is the response to ListParts
+type listPartsResult struct {
+	XMLName              xml.Name `xml:"ListPartsResult"`
+	Xmlns                string   `xml:"xmlns,attr"`
+	Bucket               string
+	Key                  string
+	UploadID             string `xml:"UploadId"`
+	Initiator            owner
+	Owner                owner
+	StorageClass         string
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Parts                []partInfo `xml:"Part"`
+}
+
+// uploadInfo describes an upload in listMultipartUploadsResult
+type uploadInfo struct {
+	Key          string
+	UploadID     string `xml:"UploadId"`
+	Initiator    owner
+	Owner        owner
+	StorageClass string
+	Initiated    string
+}
+
+// listMultipartUploadsResult is the response to ListMultipartUploads
+type listMultipartUploadsResult struct {
+	XMLName            xml.Name `xml:"ListMultipartUploadsResult"`
+	Xmlns              string   `xml:"xmlns,attr"`
+	Bucket             string
+	KeyMarker          string
+	UploadIDMarker     string `xml:"UploadIdMarker"`
+	NextKeyMarker      string
+	NextUploadIDMarker string `xml:"NextUploadIdMarker"`
+	Prefix             string
+	MaxUploads         int
+	IsTruncated        bool
+	Uploads            []uploadInfo `xml:"Upload"`
+}
+
+// writeXML writes v to the client as an XML document with status code
+func writeXML(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == "HEAD" || status == http.StatusNotModified {
+		return
+	}
+	_, err := w.Write([]byte(xml.Header))
+	if err == nil {
+		err = xml.NewEncoder(w).Encode(v)
+	}
+	if err != nil {
+		fs.Errorf(r.URL.Path, "Failed to write XML response: %v", err)
+	}
+}
+
+// toAPIError translates err into an S3 error
+func toAPIError(r *http.Request, err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	switch {
+	case errors.Is(err, vfs.ENOENT):
+		return errNoSuchKey
+	case errors.Is(err, vfs.EPERM), errors.Is(err, vfs.EROFS):
+		return errAccessDenied
+	case errors.Is(err, vfs.ENOTEMPTY):
+		return errBucketNotEmpty
+	}
+	fs.Errorf(r.URL.Path, "%s request failed: %v", r.Method, err)
+	return errInternalError
+}
+
+// writeError translates err into an S3 error and writes it to the client
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := toAPIError(r, err)
+	fs.Debugf(r.URL.Path, "%s request from %s: %v", r.Method, r.RemoteAddr, apiErr)
+	writeXML(w, r, apiErr.StatusCode, &errorResponse{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("X-Amz-Request-Id"),
+	})
+}
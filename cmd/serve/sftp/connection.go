@@ -282,7 +282,13 @@ func serveStdio(f fs.Fs) error {
 		stdin:  os.Stdin,
 		stdout: os.Stdout,
 	}
-	handlers := newVFSHandler(vfs.New(f, &vfsflags.Opt))
+	// sshd puts the client address first in SSH_CLIENT
+	var client string
+	if fields := strings.Fields(os.Getenv("SSH_CLIENT")); len(fields) > 0 {
+		client = fields[0]
+	}
+	auditor := vfs.NewAuditor("sftp", os.Getenv("USER"), client)
+	handlers := newVFSHandler(vfs.New(f, &vfsflags.Opt), auditor)
 	return serveChannel(sshChannel, handlers, "stdio")
 }
 
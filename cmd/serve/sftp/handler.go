@@ -11,17 +11,21 @@ import (
 
 	"github.com/pkg/sftp"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/audit"
 	"github.com/rclone/rclone/vfs"
 )
 
 // vfsHandler converts the VFS to be served by SFTP
 type vfsHandler struct {
 	*vfs.VFS
+	auditor *vfs.Auditor
 }
 
 // vfsHandler returns a Handlers object with the test handlers.
-func newVFSHandler(vfs *vfs.VFS) sftp.Handlers {
-	v := vfsHandler{VFS: vfs}
+//
+// auditor may be nil if the audit log isn't in use.
+func newVFSHandler(vfs *vfs.VFS, auditor *vfs.Auditor) sftp.Handlers {
+	v := vfsHandler{VFS: vfs, auditor: auditor}
 	return sftp.Handlers{
 		FileGet:  v,
 		FilePut:  v,
@@ -32,6 +36,7 @@ func newVFSHandler(vfs *vfs.VFS) sftp.Handlers {
 
 func (v vfsHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
 	file, err := v.OpenFile(r.Filepath, os.O_RDONLY, 0777)
+	file = v.auditor.Open(r.Filepath, os.O_RDONLY, file, err)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +45,7 @@ func (v vfsHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
 
 func (v vfsHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
 	file, err := v.OpenFile(r.Filepath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	file = v.auditor.Open(r.Filepath, os.O_WRONLY, file, err)
 	if err != nil {
 		return nil, err
 	}
@@ -60,16 +66,19 @@ func (v vfsHandler) Filecmd(r *sftp.Request) error {
 		return nil
 	case "Rename":
 		err := v.Rename(r.Filepath, r.Target)
+		v.auditor.Rename(r.Filepath, r.Target, err)
 		if err != nil {
 			return err
 		}
 	case "Rmdir", "Remove":
 		err := v.Remove(r.Filepath)
+		v.auditor.Log(audit.OpDelete, r.Filepath, err)
 		if err != nil {
 			return err
 		}
 	case "Mkdir":
 		err := v.Mkdir(r.Filepath, 0777)
+		v.auditor.Log(audit.OpMkdir, r.Filepath, err)
 		if err != nil {
 			return err
 		}
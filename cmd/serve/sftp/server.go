@@ -44,6 +44,7 @@ type server struct {
 	listener net.Listener
 	waitChan chan struct{} // for waiting on the listener to close
 	proxy    *proxy.Proxy
+	access   *proxy.AccessRules // access restrictions for users - may be nil
 }
 
 func newServer(ctx context.Context, f fs.Fs, opt *Options) *server {
@@ -64,6 +65,9 @@ func newServer(ctx context.Context, f fs.Fs, opt *Options) *server {
 // getVFS gets the vfs from s or the proxy
 func (s *server) getVFS(what string, sshConn *ssh.ServerConn) (VFS *vfs.VFS) {
 	if s.proxy == nil {
+		if s.access != nil {
+			return s.access.VFS(s.f, sshConn.User())
+		}
 		return s.vfs
 	}
 	if sshConn.Permissions == nil && sshConn.Permissions.Extensions == nil {
@@ -109,7 +113,7 @@ func (s *server) acceptConnection(nConn net.Conn) {
 		_ = nConn.Close()
 		return
 	}
-	c.handlers = newVFSHandler(c.vfs)
+	c.handlers = newVFSHandler(c.vfs, vfs.NewAuditor("sftp", sshConn.User(), nConn.RemoteAddr().String()))
 
 	// Accept all channels
 	go c.handleChannels(chans)
@@ -139,6 +143,18 @@ func (s *server) serve() (err error) {
 		return errors.New("--auth-proxy and --authorized-keys cannot be used at the same time")
 	}
 
+	// Load the access rules
+	s.access, err = proxy.NewAccessRules(&proxyflags.Opt)
+	if err != nil {
+		return err
+	}
+	if s.access != nil && s.opt.NoAuth {
+		return errors.New("--access-rules can't be used with --no-auth as clients could log in as any user")
+	}
+	if s.proxy != nil {
+		s.proxy.SetAccessRules(s.access)
+	}
+
 	// Load the authorized keys
 	if s.opt.AuthorizedKeys != "" && proxyflags.Opt.AuthProxy == "" {
 		authKeysFile := env.ShellExpand(s.opt.AuthorizedKeys)
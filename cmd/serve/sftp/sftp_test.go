@@ -10,15 +10,19 @@ package sftp
 
 import (
 	"context"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/pkg/sftp"
 	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
 	"github.com/rclone/rclone/cmd/serve/servetest"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -71,3 +75,26 @@ func TestSftp(t *testing.T) {
 
 	servetest.Run(t, "sftp", start)
 }
+
+// TestAccessRulesNoAuth checks the server won't start with access
+// rules for users when anyone can log in as any user
+func TestAccessRulesNoAuth(t *testing.T) {
+	ctx := context.Background()
+	f, err := fs.NewFs(ctx, t.TempDir())
+	require.NoError(t, err)
+
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, ioutil.WriteFile(rules, []byte(`{"*": {"rules": [{"path": "/", "perms": "r"}]}}`), 0600))
+	oldAccessRules := proxyflags.Opt.AccessRules
+	proxyflags.Opt.AccessRules = rules
+	defer func() {
+		proxyflags.Opt.AccessRules = oldAccessRules
+	}()
+
+	opt := DefaultOpt
+	opt.ListenAddr = testBindAddress
+	opt.NoAuth = true
+	w := newServer(ctx, f, &opt)
+	err = w.serve()
+	assert.ErrorContains(t, err, "--no-auth")
+}
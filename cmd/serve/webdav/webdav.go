@@ -18,7 +18,9 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/audit"
 	"github.com/rclone/rclone/lib/http/serve"
+	"github.com/rclone/rclone/lib/jwtutil"
 	"github.com/rclone/rclone/vfs"
 	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/spf13/cobra"
@@ -115,7 +117,8 @@ type WebDAV struct {
 	_vfs          *vfs.VFS // don't use directly, use getVFS
 	webdavhandler *webdav.Handler
 	proxy         *proxy.Proxy
-	ctx           context.Context // for global config
+	access        *proxy.AccessRules // access restrictions for users - may be nil
+	ctx           context.Context    // for global config
 }
 
 // check interface
@@ -132,6 +135,7 @@ func newWebDAV(ctx context.Context, f fs.Fs, opt *httplib.Options) *WebDAV {
 		// override auth
 		copyOpt := *opt
 		copyOpt.Auth = w.auth
+		copyOpt.ClaimsAuth = w.claimsAuth
 		opt = &copyOpt
 	} else {
 		w._vfs = vfs.New(f, &vfsflags.Opt)
@@ -150,6 +154,10 @@ func newWebDAV(ctx context.Context, f fs.Fs, opt *httplib.Options) *WebDAV {
 // Gets the VFS in use for this request
 func (w *WebDAV) getVFS(ctx context.Context) (VFS *vfs.VFS, err error) {
 	if w._vfs != nil {
+		if w.access != nil {
+			user, _ := ctx.Value(httplib.ContextUserKey).(string)
+			return w.access.VFS(w.f, user), nil
+		}
 		return w._vfs, nil
 	}
 	value := ctx.Value(httplib.ContextAuthKey)
@@ -163,6 +171,19 @@ func (w *WebDAV) getVFS(ctx context.Context) (VFS *vfs.VFS, err error) {
 	return VFS, nil
 }
 
+// contextAuditorType is the type of contextAuditorKey
+type contextAuditorType struct{}
+
+// contextAuditorKey is the context key for storing the *vfs.Auditor
+// of the request
+var contextAuditorKey = &contextAuditorType{}
+
+// getAuditor returns the auditor for this request which may be nil
+func getAuditor(ctx context.Context) *vfs.Auditor {
+	auditor, _ := ctx.Value(contextAuditorKey).(*vfs.Auditor)
+	return auditor
+}
+
 // auth does proxy authorization
 func (w *WebDAV) auth(user, pass string) (value interface{}, err error) {
 	VFS, _, err := w.proxy.Call(user, pass, false)
@@ -172,6 +193,15 @@ func (w *WebDAV) auth(user, pass string) (value interface{}, err error) {
 	return VFS, err
 }
 
+// claimsAuth does proxy authorization for bearer tokens
+func (w *WebDAV) claimsAuth(user string, claims jwtutil.Claims) (value interface{}, err error) {
+	VFS, _, err := w.proxy.CallClaims(user, claims)
+	if err != nil {
+		return nil, err
+	}
+	return VFS, err
+}
+
 func (w *WebDAV) handler(rw http.ResponseWriter, r *http.Request) {
 	urlPath, ok := w.Path(rw, r)
 	if !ok {
@@ -179,6 +209,11 @@ func (w *WebDAV) handler(rw http.ResponseWriter, r *http.Request) {
 	}
 	isDir := strings.HasSuffix(urlPath, "/")
 	remote := strings.Trim(urlPath, "/")
+	if audit.Enabled() {
+		user, _ := r.Context().Value(httplib.ContextUserKey).(string)
+		auditor := vfs.NewAuditor("webdav", user, r.RemoteAddr)
+		r = r.WithContext(context.WithValue(r.Context(), contextAuditorKey, auditor))
+	}
 	if !disableGETDir && (r.Method == "GET" || r.Method == "HEAD") && isDir {
 		w.serveDir(rw, r, remote)
 		return
@@ -236,8 +271,15 @@ func (w *WebDAV) serveDir(rw http.ResponseWriter, r *http.Request, dirRemote str
 // serve runs the http server in the background.
 //
 // Use s.Close() and s.Wait() to shutdown server
-func (w *WebDAV) serve() error {
-	err := w.Serve()
+func (w *WebDAV) serve() (err error) {
+	w.access, err = proxy.NewAccessRules(&proxyflags.Opt)
+	if err != nil {
+		return err
+	}
+	if w.proxy != nil {
+		w.proxy.SetAccessRules(w.access)
+	}
+	err = w.Serve()
 	if err != nil {
 		return err
 	}
@@ -262,6 +304,7 @@ func (w *WebDAV) Mkdir(ctx context.Context, name string, perm os.FileMode) (err
 		return err
 	}
 	_, err = dir.Mkdir(leaf)
+	getAuditor(ctx).Log(audit.OpMkdir, name, err)
 	return err
 }
 
@@ -273,6 +316,7 @@ func (w *WebDAV) OpenFile(ctx context.Context, name string, flags int, perm os.F
 		return nil, err
 	}
 	f, err := VFS.OpenFile(name, flags, perm)
+	f = getAuditor(ctx).Open(name, flags, f, err)
 	if err != nil {
 		return nil, err
 	}
@@ -291,6 +335,7 @@ func (w *WebDAV) RemoveAll(ctx context.Context, name string) (err error) {
 		return err
 	}
 	err = node.RemoveAll()
+	getAuditor(ctx).Log(audit.OpDelete, name, err)
 	if err != nil {
 		return err
 	}
@@ -304,7 +349,9 @@ func (w *WebDAV) Rename(ctx context.Context, oldName, newName string) (err error
 	if err != nil {
 		return err
 	}
-	return VFS.Rename(oldName, newName)
+	err = VFS.Rename(oldName, newName)
+	getAuditor(ctx).Rename(oldName, newName, err)
+	return err
 }
 
 // Stat returns info about the file or directory
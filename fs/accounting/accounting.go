@@ -15,6 +15,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/asyncreader"
 	"github.com/rclone/rclone/fs/fserrors"
+	"golang.org/x/time/rate"
 )
 
 // ErrorMaxTransferLimitReached defines error when transfer limit is reached.
@@ -61,7 +62,8 @@ type Account struct {
 	exit    chan struct{} // channel that will be closed when transfer is finished
 	withBuf bool          // is using a buffered in
 
-	tokenBucket buckets // per file bandwidth limiter (may be nil)
+	tokenBucket buckets         // per file bandwidth limiter (may be nil)
+	limiters    []*rate.Limiter // per remote and per job bandwidth limiters
 
 	values accountValues
 }
@@ -106,6 +108,7 @@ func newAccountSizeName(ctx context.Context, stats *StatsInfo, in io.ReadCloser,
 		fs.Debugf(acc.name, "Limiting file transfer to %v", currLimit.Bandwidth)
 		acc.tokenBucket = newTokenBucket(currLimit.Bandwidth)
 	}
+	acc.limiters = bwLimitsFromContext(ctx)
 
 	go acc.averageLoop()
 	stats.inProgress.set(acc.name, acc)
@@ -198,6 +201,7 @@ func (acc *Account) UpdateReader(ctx context.Context, in io.ReadCloser) {
 
 	// Reset counter to stop percentage going over 100%
 	acc.values.mu.Lock()
+	acc.limiters = bwLimitsFromContext(ctx)
 	acc.values.lpBytes = 0
 	acc.values.bytes = 0
 	acc.values.mu.Unlock()
@@ -314,6 +318,21 @@ func (acc *Account) limitPerFileBandwidth(n int) {
 	}
 }
 
+// Account for n bytes from the per remote and per job bandwidth
+// limits (if any)
+func (acc *Account) limitExtraBandwidth(n int) {
+	acc.values.mu.Lock()
+	limiters := acc.limiters
+	acc.values.mu.Unlock()
+
+	for _, tokenBucket := range limiters {
+		err := tokenBucket.WaitN(context.Background(), n)
+		if err != nil {
+			fs.Errorf(nil, "Token bucket error: %v", err)
+		}
+	}
+}
+
 // Account the read and limit bandwidth
 func (acc *Account) accountRead(n int) {
 	// Update Stats
@@ -326,6 +345,7 @@ func (acc *Account) accountRead(n int) {
 
 	TokenBucket.LimitBandwidth(TokenBucketSlotAccounting, n)
 	acc.limitPerFileBandwidth(n)
+	acc.limitExtraBandwidth(n)
 }
 
 // read bytes from the io.Reader passed in and account them
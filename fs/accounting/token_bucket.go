@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -179,6 +180,110 @@ func (tb *tokenBucket) LimitBandwidth(i TokenBucketSlot, n int) {
 	tb.mu.RUnlock()
 }
 
+// bwLimitsKey is the context key for the extra bandwidth limiters
+type bwLimitsKey struct{}
+
+// bwLimitsFromContext returns the extra bandwidth limiters for the
+// transfers made with ctx
+func bwLimitsFromContext(ctx context.Context) []*rate.Limiter {
+	if ctx == nil {
+		return nil
+	}
+	limiters, _ := ctx.Value(bwLimitsKey{}).([]*rate.Limiter)
+	return limiters
+}
+
+// withLimiter returns a new context with tb added to its bandwidth
+// limiters
+func withLimiter(ctx context.Context, tb *rate.Limiter) context.Context {
+	if tb == nil {
+		return ctx
+	}
+	limiters := bwLimitsFromContext(ctx)
+	for _, limiter := range limiters {
+		if limiter == tb {
+			return ctx
+		}
+	}
+	newLimiters := make([]*rate.Limiter, len(limiters), len(limiters)+1)
+	copy(newLimiters, limiters)
+	newLimiters = append(newLimiters, tb)
+	return context.WithValue(ctx, bwLimitsKey{}, newLimiters)
+}
+
+// WithBwLimit returns a new context which limits the transfers made
+// with it to bandwidth in total, on top of any other limits.
+//
+// This is used for the _bwlimit parameter of rc jobs.
+func WithBwLimit(ctx context.Context, bandwidth fs.BwPair) context.Context {
+	if !bandwidth.IsSet() {
+		return ctx
+	}
+	tbs := newTokenBucket(bandwidth)
+	tb := tbs[TokenBucketSlotAccounting]
+	if tb == nil {
+		// Only one direction is limited
+		tb = tbs[TokenBucketSlotTransportTx]
+	}
+	if tb == nil {
+		tb = tbs[TokenBucketSlotTransportRx]
+	}
+	return withLimiter(ctx, tb)
+}
+
+// remoteBwLimits holds the token buckets for the bwlimit set in the
+// config section of each remote
+var remoteBwLimits = struct {
+	mu      sync.Mutex
+	buckets map[string]buckets
+}{
+	buckets: map[string]buckets{},
+}
+
+// remoteBuckets returns the token buckets for the remote called name
+// which are unset if it has no bwlimit
+func remoteBuckets(name string) buckets {
+	// Remove the suffix added to remotes with overridden config
+	if i := strings.IndexRune(name, '{'); i >= 0 {
+		name = name[:i]
+	}
+	remoteBwLimits.mu.Lock()
+	defer remoteBwLimits.mu.Unlock()
+	tbs, found := remoteBwLimits.buckets[name]
+	if found {
+		return tbs
+	}
+	if value, ok := fs.ConfigFileGet(name, "bwlimit"); ok && value != "" {
+		var bws fs.BwTimetable
+		err := bws.Set(value)
+		if err == nil && len(bws) != 1 {
+			err = errors.New("need exactly 1 bandwidth setting")
+		}
+		if err != nil {
+			fs.Errorf(nil, "Ignoring bad bwlimit %q for remote %q: %v", value, name, err)
+		} else if bws[0].Bandwidth.IsSet() {
+			fs.Infof(nil, "Limiting bandwidth of remote %q to %v Byte/s", name, &bws[0].Bandwidth)
+			tbs = newTokenBucket(bws[0].Bandwidth)
+		}
+	}
+	remoteBwLimits.buckets[name] = tbs
+	return tbs
+}
+
+// WithRemoteBwLimit returns a new context which limits the transfers
+// made with it by the bwlimit in the config of the remotes. Downloads
+// from src use its download limit and uploads to dst its upload
+// limit. Either may be nil.
+func WithRemoteBwLimit(ctx context.Context, src, dst fs.Info) context.Context {
+	if src != nil {
+		ctx = withLimiter(ctx, remoteBuckets(src.Name())[TokenBucketSlotTransportRx])
+	}
+	if dst != nil {
+		ctx = withLimiter(ctx, remoteBuckets(dst.Name())[TokenBucketSlotTransportTx])
+	}
+	return ctx
+}
+
 // SetBwLimit sets the current bandwidth limit
 func (tb *tokenBucket) SetBwLimit(bandwidth fs.BwPair) {
 	tb.mu.Lock()
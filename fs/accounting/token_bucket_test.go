@@ -1,10 +1,14 @@
 package accounting
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
 	"testing"
 
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fstest/mockfs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/time/rate"
@@ -90,3 +94,73 @@ func TestRcBwLimit(t *testing.T) {
 	}, out)
 
 }
+
+func TestWithBwLimit(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, bwLimitsFromContext(ctx))
+
+	ctx2 := WithBwLimit(ctx, fs.BwPair{Tx: -1, Rx: -1})
+	assert.Nil(t, bwLimitsFromContext(ctx2))
+
+	ctx2 = WithBwLimit(ctx, fs.BwPair{Tx: 1024 * 1024, Rx: 1024 * 1024})
+	limiters := bwLimitsFromContext(ctx2)
+	require.Equal(t, 1, len(limiters))
+	assert.Equal(t, rate.Limit(1024*1024), limiters[0].Limit())
+
+	// Only one direction limited
+	ctx3 := WithBwLimit(ctx2, fs.BwPair{Tx: 2048, Rx: -1})
+	limiters = bwLimitsFromContext(ctx3)
+	require.Equal(t, 2, len(limiters))
+	assert.Equal(t, rate.Limit(2048), limiters[1].Limit())
+	assert.Equal(t, 1, len(bwLimitsFromContext(ctx2)))
+
+	// Accounts pick up the limiters from the context
+	acc := newAccountSizeName(ctx3, GlobalStats(), ioutil.NopCloser(bytes.NewBuffer(nil)), 0, "test")
+	assert.Equal(t, limiters, acc.limiters)
+	require.NoError(t, acc.Close())
+}
+
+func TestWithRemoteBwLimit(t *testing.T) {
+	oldConfigFileGet := fs.ConfigFileGet
+	defer func() {
+		fs.ConfigFileGet = oldConfigFileGet
+	}()
+	fs.ConfigFileGet = func(section, key string) (string, bool) {
+		if key != "bwlimit" {
+			return "", false
+		}
+		switch section {
+		case "limited":
+			return "1M:2M", true
+		case "bad":
+			return "potato", true
+		}
+		return "", false
+	}
+	ctx := context.Background()
+
+	limited := mockfs.NewFs(ctx, "limited", "")
+	overridden := mockfs.NewFs(ctx, "limited{AbCdE}", "")
+	bad := mockfs.NewFs(ctx, "bad", "")
+	unlimited := mockfs.NewFs(ctx, "unlimited", "")
+
+	assert.Nil(t, bwLimitsFromContext(WithRemoteBwLimit(ctx, bad, unlimited)))
+	assert.Nil(t, bwLimitsFromContext(WithRemoteBwLimit(ctx, nil, nil)))
+
+	// Downloads use the Rx limit and uploads the Tx limit
+	limiters := bwLimitsFromContext(WithRemoteBwLimit(ctx, limited, unlimited))
+	require.Equal(t, 1, len(limiters))
+	assert.Equal(t, rate.Limit(2*1024*1024), limiters[0].Limit())
+	limiters = bwLimitsFromContext(WithRemoteBwLimit(ctx, unlimited, limited))
+	require.Equal(t, 1, len(limiters))
+	assert.Equal(t, rate.Limit(1024*1024), limiters[0].Limit())
+
+	// Remotes with overridden config share the buckets
+	limiters2 := bwLimitsFromContext(WithRemoteBwLimit(ctx, nil, overridden))
+	assert.Equal(t, limiters, limiters2)
+
+	// Adding the same limiter again does nothing
+	ctx2 := WithRemoteBwLimit(ctx, nil, limited)
+	assert.Equal(t, ctx2, WithRemoteBwLimit(ctx2, nil, overridden))
+	assert.Equal(t, 2, len(bwLimitsFromContext(WithRemoteBwLimit(ctx2, limited, limited))))
+}
@@ -132,6 +132,8 @@ type ConfigInfo struct {
 	DisableHTTP2           bool
 	HumanReadable          bool
 	KvLockTime             time.Duration // maximum time to keep key-value database locked by process
+	Metadata               bool          // preserve object metadata when copying
+	ResumeUploads          bool          // save the state of chunked uploads so they can be resumed
 }
 
 // NewConfig creates a new config with everything set to the default
@@ -26,6 +26,9 @@ type Features struct {
 	IsLocal                 bool // is the local backend
 	SlowModTime             bool // if calling ModTime() generally takes an extra transaction
 	SlowHash                bool // if calling Hash() generally takes an extra transaction
+	ReadMetadata            bool // can read metadata from objects
+	WriteMetadata           bool // can write metadata to objects
+	UserMetadata            bool // can read/write general purpose metadata
 
 	// Purge all files in the directory specified
 	//
@@ -143,6 +146,12 @@ type Features struct {
 	// It truncates any existing object
 	OpenWriterAt func(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 
+	// OpenChunkWriter returns the size of chunks to write and a
+	// ChunkWriter which can upload chunks of the object concurrently
+	//
+	// Pass in the remote and the src object
+	OpenChunkWriter func(ctx context.Context, remote string, src ObjectInfo, options ...OpenOption) (info ChunkWriterInfo, writer ChunkWriter, err error)
+
 	// UserInfo returns info about the connected user
 	UserInfo func(ctx context.Context) (map[string]string, error)
 
@@ -278,6 +287,9 @@ func (ft *Features) Fill(ctx context.Context, f Fs) *Features {
 	if do, ok := f.(OpenWriterAter); ok {
 		ft.OpenWriterAt = do.OpenWriterAt
 	}
+	if do, ok := f.(OpenChunkWriter); ok {
+		ft.OpenChunkWriter = do.OpenChunkWriter
+	}
 	if do, ok := f.(UserInfoer); ok {
 		ft.UserInfo = do.UserInfo
 	}
@@ -314,6 +326,9 @@ func (ft *Features) Mask(ctx context.Context, f Fs) *Features {
 	// ft.IsLocal = ft.IsLocal && mask.IsLocal Don't propagate IsLocal
 	ft.SlowModTime = ft.SlowModTime && mask.SlowModTime
 	ft.SlowHash = ft.SlowHash && mask.SlowHash
+	ft.ReadMetadata = ft.ReadMetadata && mask.ReadMetadata
+	ft.WriteMetadata = ft.WriteMetadata && mask.WriteMetadata
+	ft.UserMetadata = ft.UserMetadata && mask.UserMetadata
 
 	if mask.Purge == nil {
 		ft.Purge = nil
@@ -363,6 +378,9 @@ func (ft *Features) Mask(ctx context.Context, f Fs) *Features {
 	if mask.OpenWriterAt == nil {
 		ft.OpenWriterAt = nil
 	}
+	if mask.OpenChunkWriter == nil {
+		ft.OpenChunkWriter = nil
+	}
 	if mask.UserInfo == nil {
 		ft.UserInfo = nil
 	}
@@ -593,6 +611,60 @@ type OpenWriterAter interface {
 	OpenWriterAt(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 }
 
+// ChunkWriterInfo describes how a ChunkWriter wants its chunks
+type ChunkWriterInfo struct {
+	ChunkSize         int64 // preferred size of each chunk - all chunks but the last must be this size
+	Concurrency       int   // how many chunks the backend would like written at once
+	LeavePartsOnError bool  // if set then Abort shouldn't be called on error
+}
+
+// OpenChunkWriter is an optional interface for Fs to implement
+// concurrent chunked uploads
+type OpenChunkWriter interface {
+	// OpenChunkWriter returns the size of chunks to write and a
+	// ChunkWriter which can upload chunks of the object concurrently
+	//
+	// Pass in the remote and the src object
+	OpenChunkWriter(ctx context.Context, remote string, src ObjectInfo, options ...OpenOption) (info ChunkWriterInfo, writer ChunkWriter, err error)
+}
+
+// ChunkWriter is returned by OpenChunkWriter to upload the chunks of
+// a single object
+//
+// WriteChunk may be called concurrently and in any order. Once all
+// the chunks have been written Close must be called to finalise the
+// object, or Abort to discard it.
+type ChunkWriter interface {
+	// WriteChunk writes chunk number chunkNumber (starting from 0)
+	// with the data in reader returning the number of bytes
+	// written.
+	//
+	// The reader may be seeked back to the start to retry the
+	// upload.
+	WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (bytesWritten int64, err error)
+
+	// Close completes the upload, making the object visible
+	Close(ctx context.Context) error
+
+	// Abort cancels the upload, removing any chunks written so far
+	Abort(ctx context.Context) error
+}
+
+// ChunkWriterSession is an optional interface for a ChunkWriter whose
+// upload can be carried on by a later run of rclone
+//
+// Passing the session to OpenChunkWriter in a ResumeOption returns a
+// ChunkWriter for the same upload, which only needs the chunks which
+// weren't written to be written before it is closed.
+type ChunkWriterSession interface {
+	// Session returns the state of the upload, including the
+	// chunks written so far
+	//
+	// A ChunkWriter opened with a ResumeOption must return the
+	// session it was opened with until more chunks are written.
+	Session() (session string, err error)
+}
+
 // UserInfoer is an optional interface for Fs
 type UserInfoer interface {
 	// UserInfo returns info about the connected user
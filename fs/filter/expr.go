@@ -0,0 +1,489 @@
+// Boolean filter expressions for --filter-expr
+
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// tri is the result of evaluating an expression which may be unknown,
+// as it is for the size of the files in a directory
+type tri int8
+
+const (
+	triFalse tri = iota
+	triTrue
+	triUnknown
+)
+
+// triOf converts a bool to a tri
+func triOf(b bool) tri {
+	if b {
+		return triTrue
+	}
+	return triFalse
+}
+
+// exprEnv is what an expression is evaluated against
+type exprEnv struct {
+	dir     bool      // set if remote is a directory
+	self    bool      // set to evaluate the directory itself, not its files
+	remote  string    // path of the file or directory
+	size    int64     // size of the file
+	modTime time.Time // modification time of the file
+	now     time.Time // time to work out the age from
+}
+
+// exprNode is a node in a parsed expression
+type exprNode interface {
+	eval(env *exprEnv) tri
+	String() string
+}
+
+// exprNot is "!x"
+type exprNot struct {
+	x exprNode
+}
+
+func (e *exprNot) eval(env *exprEnv) tri {
+	switch e.x.eval(env) {
+	case triTrue:
+		return triFalse
+	case triFalse:
+		return triTrue
+	}
+	return triUnknown
+}
+
+func (e *exprNot) String() string {
+	return "!" + e.x.String()
+}
+
+// exprAnd is "x && y"
+type exprAnd struct {
+	x, y exprNode
+}
+
+func (e *exprAnd) eval(env *exprEnv) tri {
+	x := e.x.eval(env)
+	if x == triFalse {
+		return triFalse
+	}
+	y := e.y.eval(env)
+	if y == triFalse {
+		return triFalse
+	}
+	if x == triTrue && y == triTrue {
+		return triTrue
+	}
+	return triUnknown
+}
+
+func (e *exprAnd) String() string {
+	return "(" + e.x.String() + " && " + e.y.String() + ")"
+}
+
+// exprOr is "x || y"
+type exprOr struct {
+	x, y exprNode
+}
+
+func (e *exprOr) eval(env *exprEnv) tri {
+	x := e.x.eval(env)
+	if x == triTrue {
+		return triTrue
+	}
+	y := e.y.eval(env)
+	if y == triTrue {
+		return triTrue
+	}
+	if x == triFalse && y == triFalse {
+		return triFalse
+	}
+	return triUnknown
+}
+
+func (e *exprOr) String() string {
+	return "(" + e.x.String() + " || " + e.y.String() + ")"
+}
+
+// exprCompare is "field op value"
+type exprCompare struct {
+	field      string
+	op         string
+	value      string
+	ignoreCase bool
+	re         *regexp.Regexp   // for =~ and !~
+	dirRes     []*regexp.Regexp // for path =~ on directories
+	size       int64            // for size
+	age        time.Duration    // for age
+}
+
+func (e *exprCompare) String() string {
+	return fmt.Sprintf("%s %s %q", e.field, e.op, e.value)
+}
+
+func (e *exprCompare) eval(env *exprEnv) tri {
+	switch e.field {
+	case "name":
+		if env.dir && !env.self {
+			return triUnknown
+		}
+		return e.compareString(path.Base(env.remote))
+	case "path":
+		if env.dir && !env.self {
+			return e.compareDir(env.remote)
+		}
+		return e.compareString(env.remote)
+	case "size":
+		if env.dir {
+			return triUnknown
+		}
+		return e.compareInt(env.size, e.size)
+	case "age":
+		if env.dir {
+			return triUnknown
+		}
+		return e.compareInt(int64(env.now.Sub(env.modTime)), int64(e.age))
+	}
+	return triUnknown
+}
+
+// compareString compares s with the value
+func (e *exprCompare) compareString(s string) tri {
+	switch e.op {
+	case "==":
+		return triOf(e.equal(s))
+	case "!=":
+		return triOf(!e.equal(s))
+	case "=~":
+		return triOf(e.re.MatchString(s))
+	case "!~":
+		return triOf(!e.re.MatchString(s))
+	}
+	return triUnknown
+}
+
+// equal returns true if s is equal to the value
+func (e *exprCompare) equal(s string) bool {
+	if e.ignoreCase {
+		return strings.EqualFold(s, e.value)
+	}
+	return s == e.value
+}
+
+// compareDir returns false if the path of no file in the directory
+// dir could match and unknown otherwise
+func (e *exprCompare) compareDir(dir string) tri {
+	switch e.op {
+	case "==":
+		value := strings.TrimPrefix(e.value, "/")
+		prefix := dir + "/"
+		if len(value) > len(prefix) && (value[:len(prefix)] == prefix || (e.ignoreCase && strings.EqualFold(value[:len(prefix)], prefix))) {
+			return triUnknown
+		}
+		return triFalse
+	case "=~":
+		for _, re := range e.dirRes {
+			if re.MatchString(dir + "/") {
+				return triUnknown
+			}
+		}
+		return triFalse
+	}
+	return triUnknown
+}
+
+// compareInt compares a with b
+func (e *exprCompare) compareInt(a, b int64) tri {
+	switch e.op {
+	case "==":
+		return triOf(a == b)
+	case "!=":
+		return triOf(a != b)
+	case "<":
+		return triOf(a < b)
+	case "<=":
+		return triOf(a <= b)
+	case ">":
+		return triOf(a > b)
+	case ">=":
+		return triOf(a >= b)
+	}
+	return triUnknown
+}
+
+// expr is a compiled --filter-expr
+type expr struct {
+	root       exprNode
+	usesPath   bool // set if the expression looks at the path
+	usesAge    bool // set if the expression needs the modification time
+	ignoreCase bool
+}
+
+// include returns whether the file passes the expression
+func (x *expr) include(remote string, size int64, modTime time.Time) bool {
+	return x.root.eval(&exprEnv{
+		remote:  remote,
+		size:    size,
+		modTime: modTime,
+		now:     time.Now(),
+	}) == triTrue
+}
+
+// includeDir returns false only if no file in the directory can pass
+// the expression
+func (x *expr) includeDir(dir string) bool {
+	return x.root.eval(&exprEnv{
+		dir:    true,
+		remote: dir,
+	}) != triFalse
+}
+
+// includeDirSelf returns whether the directory dir itself passes the
+// expression. Only the name and path are looked at as a directory has
+// no size or age, so other comparisons can't exclude it.
+func (x *expr) includeDirSelf(dir string) bool {
+	return x.root.eval(&exprEnv{
+		dir:    true,
+		self:   true,
+		remote: dir,
+	}) != triFalse
+}
+
+// String returns the expression fully bracketed
+func (x *expr) String() string {
+	return x.root.String()
+}
+
+// exprParser parses expressions with the grammar
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" or ")" | compare
+//	compare = field op value
+//
+// where field is one of name, path, size or age, op is one of == !=
+// =~ !~ < <= > >= and value is a word or a string in single or double
+// quotes in which only the quote may be escaped with \.
+type exprParser struct {
+	in  string
+	pos int
+	x   *expr
+}
+
+// parseExpr compiles a --filter-expr
+func parseExpr(in string, ignoreCase bool) (x *expr, err error) {
+	p := &exprParser{
+		in: in,
+		x:  &expr{ignoreCase: ignoreCase},
+	}
+	p.x.root, err = p.parseOr()
+	if err == nil {
+		p.skipSpace()
+		if p.pos < len(p.in) {
+			err = p.errorf("unexpected %q", p.in[p.pos:])
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bad filter expression %q: %w", in, err)
+	}
+	return p.x, nil
+}
+
+// errorf makes an error with the current position
+func (p *exprParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("at position %d: %s", p.pos+1, fmt.Sprintf(format, a...))
+}
+
+// skipSpace skips any white space
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.in) && unicode.IsSpace(rune(p.in[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept skips tok and returns true if it is next
+func (p *exprParser) accept(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.in[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &exprOr{x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &exprAnd{x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	switch {
+	case p.accept("!"):
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNot{x: x}, nil
+	case p.accept("("):
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return x, nil
+	}
+	return p.parseCompare()
+}
+
+// exprOps are the comparison operators, longest first
+var exprOps = []string{"==", "!=", "=~", "!~", "<=", ">=", "<", ">"}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.in) && (unicode.IsLetter(rune(p.in[p.pos])) || p.in[p.pos] == '_') {
+		p.pos++
+	}
+	e := &exprCompare{
+		field:      strings.ToLower(p.in[start:p.pos]),
+		ignoreCase: p.x.ignoreCase,
+	}
+	if e.field == "" {
+		if p.pos >= len(p.in) {
+			return nil, p.errorf("expecting field name but found end")
+		}
+		return nil, p.errorf("expecting field name but found %q", p.in[p.pos:])
+	}
+	for _, op := range exprOps {
+		if p.accept(op) {
+			e.op = op
+			break
+		}
+	}
+	if e.op == "" {
+		return nil, p.errorf("expecting operator after %q", e.field)
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	e.value = value
+	isStringOp := e.op == "==" || e.op == "!=" || e.op == "=~" || e.op == "!~"
+	switch e.field {
+	case "name", "path":
+		if !isStringOp {
+			return nil, p.errorf("can't use %s with %s", e.op, e.field)
+		}
+		if e.field == "path" {
+			p.x.usesPath = true
+		}
+		if e.op == "=~" || e.op == "!~" {
+			e.re, err = GlobToRegexp(value, e.ignoreCase)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if e.field == "path" && e.op == "=~" {
+			for _, dirGlob := range globToDirGlobs(value) {
+				re, err := GlobToRegexp(dirGlob, e.ignoreCase)
+				if err != nil {
+					return nil, err
+				}
+				e.dirRes = append(e.dirRes, re)
+			}
+		}
+	case "size":
+		if e.op == "=~" || e.op == "!~" {
+			return nil, p.errorf("can't use %s with %s", e.op, e.field)
+		}
+		var size fs.SizeSuffix
+		if err = size.Set(value); err != nil {
+			return nil, p.errorf("bad size %q: %v", value, err)
+		}
+		e.size = int64(size)
+	case "age":
+		if e.op == "=~" || e.op == "!~" {
+			return nil, p.errorf("can't use %s with %s", e.op, e.field)
+		}
+		p.x.usesAge = true
+		if e.age, err = fs.ParseDuration(value); err != nil {
+			return nil, p.errorf("bad age %q: %v", value, err)
+		}
+	default:
+		return nil, p.errorf("unknown field %q - must be name, path, size or age", e.field)
+	}
+	return e, nil
+}
+
+// parseValue parses a quoted string or a word ending at white space,
+// an operator or a bracket
+func (p *exprParser) parseValue() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.in) {
+		return "", p.errorf("expecting value but found end")
+	}
+	if quote := p.in[p.pos]; quote == '"' || quote == '\'' {
+		// Only the quote needs escaping so \ can be used in globs
+		var value strings.Builder
+		for p.pos++; p.pos < len(p.in); p.pos++ {
+			c := p.in[p.pos]
+			if c == '\\' && p.pos+1 < len(p.in) && p.in[p.pos+1] == quote {
+				p.pos++
+				c = quote
+			} else if c == quote {
+				p.pos++
+				return value.String(), nil
+			}
+			_ = value.WriteByte(c)
+		}
+		return "", p.errorf("unterminated string")
+	}
+	start := p.pos
+	for p.pos < len(p.in) {
+		c := p.in[p.pos]
+		if unicode.IsSpace(rune(c)) || c == '(' || c == ')' || strings.HasPrefix(p.in[p.pos:], "&&") || strings.HasPrefix(p.in[p.pos:], "||") {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expecting value")
+	}
+	return p.in[start:p.pos], nil
+}
@@ -0,0 +1,128 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpr(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{in: `name =~ "*.log"`, want: `name =~ "*.log"`},
+		{in: `(name =~ "*.log" && size > 10M) || age < 7d`, want: `((name =~ "*.log" && size > "10M") || age < "7d")`},
+		{in: `a == 1 || b == 2`, err: `unknown field "a"`},
+		{in: `name=~*.jpg||name=~'*.png'&&!(size<=1k)`, want: `(name =~ "*.jpg" || (name =~ "*.png" && !size <= "1k"))`},
+		{in: `path == "dir/it's \"quoted\""`, want: `path == "dir/it's \"quoted\""`},
+		{in: `path =~ 'a\'b\*'`, want: `path =~ "a'b\\*"`},
+		{in: ``, err: "expecting field name but found end"},
+		{in: `name`, err: `expecting operator after "name"`},
+		{in: `name ==`, err: "expecting value but found end"},
+		{in: `name == "x`, err: "unterminated string"},
+		{in: `name < x`, err: "can't use < with name"},
+		{in: `size =~ 1`, err: "can't use =~ with size"},
+		{in: `size > potato`, err: `bad size "potato"`},
+		{in: `age > potato`, err: `bad age "potato"`},
+		{in: `(name == x`, err: "missing )"},
+		{in: `name == x)`, err: `unexpected ")"`},
+		{in: `name == x y`, err: `unexpected "y"`},
+		{in: `name =~ "***"`, err: "too many stars"},
+	} {
+		x, err := parseExpr(test.in, false)
+		if test.err != "" {
+			require.Error(t, err, test.in)
+			assert.Contains(t, err.Error(), test.err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, x.String(), test.in)
+	}
+}
+
+func TestExprInclude(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-30 * 24 * time.Hour)
+	x, err := parseExpr(`(name =~ "*.log" && size > 10M) || age < 7d`, false)
+	require.NoError(t, err)
+	assert.True(t, x.usesAge)
+	assert.False(t, x.usesPath)
+	for _, test := range []struct {
+		remote  string
+		size    int64
+		modTime time.Time
+		want    bool
+	}{
+		{"big.log", 20 << 20, old, true},
+		{"dir/big.log", 20 << 20, old, true},
+		{"small.log", 1 << 20, old, false},
+		{"small.log", 1 << 20, now, true},
+		{"big.txt", 20 << 20, old, false},
+		{"big.log.txt", 20 << 20, now.Add(-time.Hour), true},
+		{"big.LOG", 20 << 20, old, false},
+	} {
+		got := x.include(test.remote, test.size, test.modTime)
+		assert.Equal(t, test.want, got, test.remote)
+	}
+
+	x, err = parseExpr(`name == BIG.log && path !~ "/dir/**"`, true)
+	require.NoError(t, err)
+	assert.True(t, x.include("big.log", 0, now))
+	assert.True(t, x.include("other/Big.Log", 0, now))
+	assert.False(t, x.include("dir/big.log", 0, now))
+	assert.False(t, x.include("small.log", 0, now))
+}
+
+func TestExprIncludeDir(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		dir  string
+		want bool
+	}{
+		{`size > 1M`, "dir", true},
+		{`path =~ "/dir/sub/*.jpg"`, "dir", true},
+		{`path =~ "/dir/sub/*.jpg"`, "dir/sub", true},
+		{`path =~ "/dir/sub/*.jpg"`, "dir/other", false},
+		{`path =~ "/dir/sub/*.jpg"`, "other", false},
+		{`path =~ "/dir/**.jpg"`, "dir/a/b/c", true},
+		{`path =~ "/dir/sub/*.jpg" || size > 1M`, "other", true},
+		{`path =~ "/dir/sub/*.jpg" && size > 1M`, "other", false},
+		{`!(path =~ "/dir/sub/*.jpg")`, "other", true},
+		{`path == "dir/sub/file.txt"`, "dir", true},
+		{`path == "dir/sub/file.txt"`, "dir/sub", true},
+		{`path == "dir/sub/file.txt"`, "di", false},
+		{`path == "dir/sub/file.txt"`, "dir/sub/file.txt", false},
+		{`path != "dir/sub/file.txt"`, "other", true},
+		{`name == x`, "other", true},
+	} {
+		x, err := parseExpr(test.in, false)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, x.includeDir(test.dir), "%s on %s", test.in, test.dir)
+	}
+}
+
+func TestExprIncludeDirSelf(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		dir  string
+		want bool
+	}{
+		{`size > 1M`, "dir", true},
+		{`age < 1d`, "dir", true},
+		{`path =~ "/keep/**"`, "keep/sub", true},
+		{`path =~ "/keep/**"`, "other", false},
+		{`path != "dir/sub"`, "dir/sub", false},
+		{`name == tmp`, "dir/tmp", true},
+		{`name == tmp`, "dir/other", false},
+		{`name == tmp && size > 1M`, "dir/tmp", true},
+		{`name == tmp && size > 1M`, "dir/other", false},
+	} {
+		x, err := parseExpr(test.in, false)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, x.includeDirSelf(test.dir), "%s on %s", test.in, test.dir)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -96,6 +97,11 @@ type Opt struct {
 	MinSize        fs.SizeSuffix
 	MaxSize        fs.SizeSuffix
 	IgnoreCase     bool
+	IncludeMime    []string
+	ExcludeMime    []string
+	FilterHashFrom []string
+	IncludeTier    []string
+	FilterExpr     string
 }
 
 // DefaultOpt is the default config for the filter
@@ -113,8 +119,14 @@ type Filter struct {
 	ModTimeTo   time.Time
 	fileRules   rules
 	dirRules    rules
-	files       FilesMap // files if filesFrom
-	dirs        FilesMap // dirs from filesFrom
+	files       FilesMap            // files if filesFrom
+	dirs        FilesMap            // dirs from filesFrom
+	includeMime []string            // lower case mime type patterns to include
+	excludeMime []string            // lower case mime type patterns to exclude
+	includeTier []string            // tiers to include
+	hashes      map[string]struct{} // lower case hashes to exclude
+	hashWidths  map[int]struct{}
+	expr        *expr // compiled FilterExpr if set
 }
 
 // NewFilter parses the command line options and creates a Filter
@@ -195,6 +207,36 @@ func NewFilter(opt *Opt) (f *Filter, err error) {
 		}
 	}
 
+	for _, pattern := range f.Opt.IncludeMime {
+		if f.includeMime, err = addMimePattern(f.includeMime, pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, pattern := range f.Opt.ExcludeMime {
+		if f.excludeMime, err = addMimePattern(f.excludeMime, pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, tier := range f.Opt.IncludeTier {
+		for _, tier := range strings.Split(tier, ",") {
+			if tier = strings.TrimSpace(tier); tier != "" {
+				f.includeTier = append(f.includeTier, tier)
+			}
+		}
+	}
+	if f.Opt.FilterExpr != "" {
+		f.expr, err = parseExpr(f.Opt.FilterExpr, f.Opt.IgnoreCase)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, rule := range f.Opt.FilterHashFrom {
+		err := forEachLine(rule, false, f.AddHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	inActive := f.InActive()
 
 	for _, rule := range f.Opt.FilesFrom {
@@ -321,6 +363,49 @@ func (f *Filter) AddRule(rule string) error {
 	return fmt.Errorf("malformed rule %q", rule)
 }
 
+// addMimePattern checks pattern and adds it to patterns
+//
+// A pattern with no "/" such as "image" matches all the subtypes.
+func addMimePattern(patterns []string, pattern string) ([]string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if !strings.Contains(pattern, "/") {
+		pattern += "/*"
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("bad mime type pattern %q: %w", pattern, err)
+	}
+	return append(patterns, pattern), nil
+}
+
+// matchMime returns true if mimeType matches any of the patterns
+func matchMime(patterns []string, mimeType string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, mimeType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AddHash adds a hash to the list of hashes to exclude
+//
+// Only the first field of line is used so the output of md5sum and
+// similar tools can be used directly.
+func (f *Filter) AddHash(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	sum := strings.ToLower(strings.TrimPrefix(fields[0], "*"))
+	if f.hashes == nil {
+		f.hashes = make(map[string]struct{})
+		f.hashWidths = make(map[int]struct{})
+	}
+	f.hashes[sum] = struct{}{}
+	f.hashWidths[len(sum)] = struct{}{}
+	return nil
+}
+
 // initAddFile creates f.files and f.dirs
 func (f *Filter) initAddFile() {
 	if f.files == nil {
@@ -370,7 +455,19 @@ func (f *Filter) InActive() bool {
 		f.Opt.MaxSize < 0 &&
 		f.fileRules.len() == 0 &&
 		f.dirRules.len() == 0 &&
-		len(f.Opt.ExcludeFile) == 0)
+		len(f.Opt.ExcludeFile) == 0 &&
+		f.expr == nil &&
+		!f.UsesMetadata())
+}
+
+// UsesMetadata returns true if the filter needs more than the path,
+// size and modification time of an object, so only IncludeObject
+// can apply all of it.
+func (f *Filter) UsesMetadata() bool {
+	return len(f.includeMime) != 0 ||
+		len(f.excludeMime) != 0 ||
+		len(f.includeTier) != 0 ||
+		f.hashes != nil
 }
 
 // IncludeRemote returns whether this remote passes the filter rules.
@@ -420,6 +517,9 @@ func (f *Filter) IncludeDirectory(ctx context.Context, fs fs.Fs) func(string) (b
 			_, include := f.dirs[remote]
 			return include, nil
 		}
+		if f.expr != nil && remote != "" && !f.expr.includeDir(remote) {
+			return false, nil
+		}
 		remote += "/"
 		for _, rule := range f.dirRules.rules {
 			if rule.Match(remote) {
@@ -449,6 +549,9 @@ func (f *Filter) DirContainsExcludeFile(ctx context.Context, fremote fs.Fs, remo
 
 // Include returns whether this object should be included into the
 // sync or not
+//
+// A remote ending in "/" is a directory, for which only the name and
+// path comparisons in --filter-expr are used.
 func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
 	// filesFrom takes precedence
 	if f.files != nil {
@@ -467,6 +570,15 @@ func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
 	if f.Opt.MaxSize >= 0 && size > int64(f.Opt.MaxSize) {
 		return false
 	}
+	if f.expr != nil {
+		if dir := strings.TrimSuffix(remote, "/"); dir != remote {
+			if !f.expr.includeDirSelf(dir) {
+				return false
+			}
+		} else if !f.expr.include(remote, size, modTime) {
+			return false
+		}
+	}
 	return f.IncludeRemote(remote)
 }
 
@@ -476,13 +588,71 @@ func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
 func (f *Filter) IncludeObject(ctx context.Context, o fs.Object) bool {
 	var modTime time.Time
 
-	if !f.ModTimeFrom.IsZero() || !f.ModTimeTo.IsZero() {
+	if !f.ModTimeFrom.IsZero() || !f.ModTimeTo.IsZero() || (f.expr != nil && f.expr.usesAge) {
 		modTime = o.ModTime(ctx)
 	} else {
 		modTime = time.Unix(0, 0)
 	}
 
-	return f.Include(o.Remote(), o.Size(), modTime)
+	if !f.Include(o.Remote(), o.Size(), modTime) {
+		return false
+	}
+	if f.files != nil || !f.UsesMetadata() {
+		return true
+	}
+	return f.includeMetadata(ctx, o)
+}
+
+// includeMetadata returns whether the mime type, tier and hashes of
+// the object pass the filters. Filters which need data the backend
+// doesn't provide don't exclude the object.
+func (f *Filter) includeMetadata(ctx context.Context, o fs.Object) bool {
+	if len(f.includeMime) != 0 || len(f.excludeMime) != 0 {
+		mimeType := fs.MimeType(ctx, o)
+		if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+			mimeType = mimeType[:i]
+		}
+		mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+		if matchMime(f.excludeMime, mimeType) {
+			return false
+		}
+		if len(f.includeMime) != 0 && !matchMime(f.includeMime, mimeType) {
+			return false
+		}
+	}
+	if len(f.includeTier) != 0 {
+		if do, ok := o.(fs.GetTierer); ok {
+			if tier := do.GetTier(); tier != "" && !containsFold(f.includeTier, tier) {
+				return false
+			}
+		}
+	}
+	if f.hashes != nil && o.Fs() != nil {
+		for _, ht := range o.Fs().Hashes().Array() {
+			if _, ok := f.hashWidths[hash.Width(ht, false)]; !ok {
+				continue
+			}
+			sum, err := o.Hash(ctx, ht)
+			if err != nil {
+				fs.Debugf(o, "Failed to read %v hash for --filter-hash-from: %v", ht, err)
+				continue
+			}
+			if _, found := f.hashes[strings.ToLower(sum)]; found && sum != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// containsFold returns true if s is in list ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
 }
 
 // forEachLine calls fn on every line in the file pointed to by path
@@ -525,6 +695,21 @@ func (f *Filter) DumpFilters() string {
 	if !f.ModTimeTo.IsZero() {
 		rules = append(rules, fmt.Sprintf("Last-modified date must be equal or less than: %s", f.ModTimeTo.String()))
 	}
+	if f.expr != nil {
+		rules = append(rules, fmt.Sprintf("Filter expression: %s", f.expr))
+	}
+	for _, pattern := range f.includeMime {
+		rules = append(rules, fmt.Sprintf("Include mime type: %s", pattern))
+	}
+	for _, pattern := range f.excludeMime {
+		rules = append(rules, fmt.Sprintf("Exclude mime type: %s", pattern))
+	}
+	if len(f.includeTier) != 0 {
+		rules = append(rules, fmt.Sprintf("Include tier: %s", strings.Join(f.includeTier, ", ")))
+	}
+	if f.hashes != nil {
+		rules = append(rules, fmt.Sprintf("Exclude files with %d hashes", len(f.hashes)))
+	}
 	rules = append(rules, "--- File filter rules ---")
 	for _, rule := range f.fileRules.rules {
 		rules = append(rules, rule.String())
@@ -587,6 +772,9 @@ func (f *Filter) MakeListR(ctx context.Context, NewObject func(ctx context.Conte
 //
 // This is used in deciding whether to walk directories or use ListR
 func (f *Filter) UsesDirectoryFilters() bool {
+	if f.expr != nil && f.expr.usesPath {
+		return true
+	}
 	if len(f.dirRules.rules) == 0 {
 		return false
 	}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fstest/mockfs"
 	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -429,6 +431,124 @@ func TestNewFilterMaxAge(t *testing.T) {
 	assert.False(t, f.InActive())
 }
 
+// tierObject is an object with a storage tier
+type tierObject struct {
+	*mockobject.ContentMockObject
+	tier string
+}
+
+// GetTier returns the storage tier of the object
+func (o tierObject) GetTier() string {
+	return o.tier
+}
+
+func TestNewFilterMetadata(t *testing.T) {
+	ctx := context.Background()
+	f := mockfs.NewFs(ctx, "mock", "")
+	f.SetHashes(hash.NewHashSet(hash.MD5))
+	newObject := func(remote, contents, tier string) fs.Object {
+		o := mockobject.New(remote).WithContent([]byte(contents), mockobject.SeekModeNone)
+		o.SetFs(f)
+		if tier == "" {
+			return o
+		}
+		return tierObject{ContentMockObject: o, tier: tier}
+	}
+	photo := newObject("photo.jpg", "photo", "STANDARD")
+	archived := newObject("dir/archived.png", "archived", "GLACIER")
+	video := newObject("video.mp4", "video", "")
+	doc := newObject("doc.txt", "hello", "")
+
+	for _, test := range []struct {
+		name string
+		opt  func(opt *Opt)
+		want []fs.Object
+	}{
+		{
+			name: "include-mime",
+			opt:  func(opt *Opt) { opt.IncludeMime = []string{"image/*", "VIDEO"} },
+			want: []fs.Object{photo, archived, video},
+		},
+		{
+			name: "exclude-mime",
+			opt:  func(opt *Opt) { opt.ExcludeMime = []string{"image/png", "text/*"} },
+			want: []fs.Object{photo, video},
+		},
+		{
+			name: "include-tier",
+			opt:  func(opt *Opt) { opt.IncludeTier = []string{"standard,deep_archive"} },
+			want: []fs.Object{photo, video, doc},
+		},
+		{
+			name: "filter-hash-from",
+			opt: func(opt *Opt) {
+				// MD5 of "hello" as output by md5sum and SHA-1 of
+				// "video" which the remote doesn't support
+				opt.FilterHashFrom = []string{testFile(t, "# comment\n5D41402ABC4B2A76B9719D911017C592  doc.txt\n"+
+					"ffbaf58f1231628f9ac2a583f038b51719006ec6\nnot-a-hash\n")}
+			},
+			want: []fs.Object{photo, archived, video},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opt := DefaultOpt
+			test.opt(&opt)
+			fi, err := NewFilter(&opt)
+			require.NoError(t, err)
+			for _, file := range opt.FilterHashFrom {
+				_ = os.Remove(file)
+			}
+			assert.False(t, fi.InActive())
+			assert.True(t, fi.UsesMetadata())
+			var got []fs.Object
+			for _, o := range []fs.Object{photo, archived, video, doc} {
+				if fi.IncludeObject(ctx, o) {
+					got = append(got, o)
+				}
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	_, err := NewFilter(&Opt{IncludeMime: []string{"image/[jpeg"}})
+	assert.Error(t, err)
+}
+
+func TestNewFilterExpr(t *testing.T) {
+	opt := DefaultOpt
+	opt.FilterExpr = `path =~ "/photos/**.jpg" && size > 1k`
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.InActive())
+	assert.True(t, f.UsesDirectoryFilters())
+	testInclude(t, f, []includeTest{
+		{"photos/a.jpg", 2048, 0, true},
+		{"photos/2022/b.jpg", 2048, 0, true},
+		{"photos/small.jpg", 1024, 0, false},
+		{"photos/c.png", 2048, 0, false},
+		{"other/d.jpg", 2048, 0, false},
+	})
+	testDirInclude(t, f, []includeDirTest{
+		{"photos", true},
+		{"photos/2022", true},
+		{"other", false},
+	})
+	assert.Equal(t, `Filter expression: (path =~ "/photos/**.jpg" && size > "1k")
+--- File filter rules ---
+--- Directory filter rules ---`, f.DumpFilters())
+
+	// Directories, as passed by Rmdirs, only use the path parts
+	opt.FilterExpr = `size > 1k && path !~ "/keep/**"`
+	f, err = NewFilter(&opt)
+	require.NoError(t, err)
+	assert.True(t, f.Include("empty/", 0, time.Now()))
+	assert.False(t, f.Include("keep/empty/", 0, time.Now()))
+
+	opt.FilterExpr = "size >"
+	_, err = NewFilter(&opt)
+	assert.Error(t, err)
+}
+
 func TestNewFilterMatches(t *testing.T) {
 	f, err := NewFilter(nil)
 	require.NoError(t, err)
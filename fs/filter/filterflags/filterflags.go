@@ -43,6 +43,11 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.FVarP(flagSet, &Opt.MaxAge, "max-age", "", "Only transfer files younger than this in s or suffix ms|s|m|h|d|w|M|y")
 	flags.FVarP(flagSet, &Opt.MinSize, "min-size", "", "Only transfer files bigger than this in KiB or suffix B|K|M|G|T|P")
 	flags.FVarP(flagSet, &Opt.MaxSize, "max-size", "", "Only transfer files smaller than this in KiB or suffix B|K|M|G|T|P")
+	flags.StringVarP(flagSet, &Opt.FilterExpr, "filter-expr", "", "", "Only include files matching this boolean expression, e.g. 'name =~ \"*.log\" && size > 10M'")
+	flags.StringArrayVarP(flagSet, &Opt.IncludeMime, "include-mime", "", nil, "Only include files with this mime type, e.g. image/* (may be repeated)")
+	flags.StringArrayVarP(flagSet, &Opt.ExcludeMime, "exclude-mime", "", nil, "Exclude files with this mime type, e.g. video/* (may be repeated)")
+	flags.StringArrayVarP(flagSet, &Opt.FilterHashFrom, "filter-hash-from", "", nil, "Exclude files whose hash is listed in this file (use - to read from stdin)")
+	flags.StringArrayVarP(flagSet, &Opt.IncludeTier, "include-tier", "", nil, "Only include objects in this storage tier, e.g. STANDARD (may be repeated)")
 	flags.BoolVarP(flagSet, &Opt.IgnoreCase, "ignore-case", "", false, "Ignore case in filters (case insensitive)")
 	//cvsExclude     = BoolP("cvs-exclude", "C", false, "Exclude files in the same way CVS does")
 }
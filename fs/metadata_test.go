@@ -0,0 +1,110 @@
+package fs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataSet(t *testing.T) {
+	var m Metadata
+	assert.Nil(t, m)
+	m.Set("key", "value")
+	assert.NotNil(t, m)
+	assert.Equal(t, "value", m["key"])
+	m.Set("key", "value2")
+	assert.Equal(t, "value2", m["key"])
+}
+
+func TestMetadataMerge(t *testing.T) {
+	for _, test := range []struct {
+		in    Metadata
+		other Metadata
+		want  Metadata
+	}{
+		{
+			in:    Metadata{},
+			other: Metadata{},
+			want:  Metadata{},
+		}, {
+			in:    nil,
+			other: nil,
+			want:  nil,
+		}, {
+			in: nil,
+			other: Metadata{
+				"a": "1",
+			},
+			want: Metadata{
+				"a": "1",
+			},
+		}, {
+			in: Metadata{
+				"a": "1",
+			},
+			other: nil,
+			want: Metadata{
+				"a": "1",
+			},
+		}, {
+			in: Metadata{
+				"a": "1",
+			},
+			other: Metadata{
+				"a": "2",
+				"b": "2",
+			},
+			want: Metadata{
+				"a": "2",
+				"b": "2",
+			},
+		},
+	} {
+		test.in.Merge(test.other)
+		assert.Equal(t, test.want, test.in)
+	}
+}
+
+func TestMetadataMergeOptions(t *testing.T) {
+	m := Metadata{"a": "1"}
+	m.MergeOptions([]OpenOption{
+		&HTTPOption{Key: "ignored", Value: "yes"},
+		MetadataOption{"b": "2"},
+		MetadataOption{"a": "3"},
+	})
+	assert.Equal(t, Metadata{"a": "3", "b": "2"}, m)
+}
+
+type testMetadataObject struct {
+	ObjectInfo
+	metadata Metadata
+}
+
+func (o testMetadataObject) Metadata(ctx context.Context) (Metadata, error) {
+	return o.metadata, nil
+}
+
+func TestGetMetadataOptions(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := AddConfig(ctx)
+	o := testMetadataObject{metadata: Metadata{"a": "1"}}
+	options := []OpenOption{MetadataOption{"b": "2"}}
+
+	// No --metadata
+	m, err := GetMetadataOptions(ctx, o, options)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	// With --metadata
+	ci.Metadata = true
+	m, err = GetMetadataOptions(ctx, o, options)
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{"a": "1", "b": "2"}, m)
+
+	// Object without metadata support
+	m, err = GetMetadata(ctx, nil)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+}
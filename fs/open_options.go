@@ -230,6 +230,31 @@ func (o *HashesOption) Mandatory() bool {
 	return false
 }
 
+// ResumeOption asks OpenChunkWriter to carry on the upload described
+// by Session, as returned by ChunkWriterSession.Session, rather than
+// starting a new one.
+//
+// It is mandatory as only the chunks missing from the upload are
+// written after it is resumed.
+type ResumeOption struct {
+	Session string
+}
+
+// Header formats the option as an http header
+func (o *ResumeOption) Header() (key string, value string) {
+	return "", ""
+}
+
+// String formats the option into human-readable form
+func (o *ResumeOption) String() string {
+	return fmt.Sprintf("ResumeOption(%q)", o.Session)
+}
+
+// Mandatory returns whether the option must be parsed or can be ignored
+func (o *ResumeOption) Mandatory() bool {
+	return true
+}
+
 // NullOption defines an Option which does nothing
 type NullOption struct {
 }
@@ -249,6 +274,25 @@ func (o NullOption) Mandatory() bool {
 	return false
 }
 
+// MetadataOption defines an Option which sets metadata on the object
+// being uploaded
+type MetadataOption Metadata
+
+// Header formats the option as an http header
+func (o MetadataOption) Header() (key string, value string) {
+	return "", ""
+}
+
+// String formats the option into human-readable form
+func (o MetadataOption) String() string {
+	return fmt.Sprintf("MetadataOption(%v)", Metadata(o))
+}
+
+// Mandatory returns whether the option must be parsed or can be ignored
+func (o MetadataOption) Mandatory() bool {
+	return false
+}
+
 // OpenOptionAddHeaders adds each header found in options to the
 // headers map provided the key was non empty.
 func OpenOptionAddHeaders(options []OpenOption, headers map[string]string) {
@@ -29,6 +29,7 @@ type ListJSONItem struct {
 	OrigID        string            `json:",omitempty"`
 	Tier          string            `json:",omitempty"`
 	IsBucket      bool              `json:",omitempty"`
+	Metadata      fs.Metadata       `json:",omitempty"`
 }
 
 // Timestamp a time in the provided format
@@ -78,6 +79,7 @@ type ListJSONOpt struct {
 	ShowEncrypted bool     `json:"showEncrypted"`
 	ShowOrigIDs   bool     `json:"showOrigIDs"`
 	ShowHash      bool     `json:"showHash"`
+	Metadata      bool     `json:"metadata"`
 	DirsOnly      bool     `json:"dirsOnly"`
 	FilesOnly     bool     `json:"filesOnly"`
 	HashTypes     []string `json:"hashTypes"` // hash types to show if ShowHash is set, e.g. "MD5", "SHA-1"
@@ -222,6 +224,14 @@ func (lj *listJSON) entry(ctx context.Context, entry fs.DirEntry) (*ListJSONItem
 				item.Tier = do.GetTier()
 			}
 		}
+		if lj.opt.Metadata {
+			metadata, err := fs.GetMetadata(ctx, x)
+			if err != nil {
+				fs.Errorf(x, "Failed to read metadata: %v", err)
+			} else if metadata != nil {
+				item.Metadata = metadata
+			}
+		}
 	default:
 		fs.Errorf(nil, "Unknown type %T in listing in ListJSON", entry)
 	}
@@ -1,13 +1,17 @@
 package operations
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/resume"
+	"github.com/rclone/rclone/lib/pacer"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -32,9 +36,9 @@ func doMultiThreadCopy(ctx context.Context, f fs.Fs, src fs.Object) bool {
 	if src.Size() < int64(ci.MultiThreadCutoff) {
 		return false
 	}
-	// ...source doesn't support it
+	// ...destination doesn't support it
 	dstFeatures := f.Features()
-	if dstFeatures.OpenWriterAt == nil {
+	if dstFeatures.OpenChunkWriter == nil && dstFeatures.OpenWriterAt == nil {
 		return false
 	}
 	// ...if --multi-thread-streams not in use and source and
@@ -42,6 +46,11 @@ func doMultiThreadCopy(ctx context.Context, f fs.Fs, src fs.Object) bool {
 	if !ci.MultiThreadSet && dstFeatures.IsLocal && src.Fs().Features().IsLocal {
 		return false
 	}
+	// ...if --metadata is in use and the destination can store
+	// it, as OpenWriterAt has no way of passing it on
+	if ci.Metadata && dstFeatures.WriteMetadata && dstFeatures.OpenChunkWriter == nil {
+		return false
+	}
 	return true
 }
 
@@ -139,18 +148,23 @@ func (mc *multiThreadCopyState) calculateChunks() {
 	}
 }
 
-// Copy src to (f, remote) using streams download threads and the OpenWriterAt feature
+// Copy src to (f, remote) using streams download threads and the
+// OpenChunkWriter feature if available or the OpenWriterAt feature
+// if not
 func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object, streams int, tr *accounting.Transfer) (newDst fs.Object, err error) {
-	openWriterAt := f.Features().OpenWriterAt
-	if openWriterAt == nil {
-		return nil, errors.New("multi-thread copy: OpenWriterAt not supported")
-	}
 	if src.Size() < 0 {
 		return nil, errors.New("multi-thread copy: can't copy unknown sized file")
 	}
 	if src.Size() == 0 {
 		return nil, errors.New("multi-thread copy: can't copy zero sized file")
 	}
+	if f.Features().OpenChunkWriter != nil {
+		return multiThreadCopyChunks(ctx, f, remote, src, streams, tr)
+	}
+	openWriterAt := f.Features().OpenWriterAt
+	if openWriterAt == nil {
+		return nil, errors.New("multi-thread copy: neither OpenChunkWriter nor OpenWriterAt supported")
+	}
 
 	g, gCtx := errgroup.WithContext(ctx)
 	mc := &multiThreadCopyState{
@@ -201,3 +215,318 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 	fs.Debugf(src, "Finished multi-thread copy with %d parts of size %v", mc.streams, fs.SizeSuffix(mc.partSize))
 	return obj, nil
 }
+
+// state for a multi-thread copy using OpenChunkWriter
+type multiThreadChunkState struct {
+	ctx       context.Context
+	chunkSize int64
+	size      int64
+	chunks    int
+	cw        fs.ChunkWriter
+	src       fs.Object
+	acc       *accounting.Account
+}
+
+// Read a single chunk from the source and write it with the ChunkWriter
+func (mc *multiThreadChunkState) copyChunk(ctx context.Context, chunk int) (err error) {
+	ci := fs.GetConfig(ctx)
+	defer func() {
+		if err != nil {
+			fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d failed: %v", chunk+1, mc.chunks, err)
+		}
+	}()
+	start := int64(chunk) * mc.chunkSize
+	end := start + mc.chunkSize
+	if end > mc.size {
+		end = mc.size
+	}
+
+	fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v starting", chunk+1, mc.chunks, start, end, fs.SizeSuffix(end-start))
+
+	rc, err := NewReOpen(ctx, mc.src, ci.LowLevelRetries, &fs.RangeOption{Start: start, End: end - 1})
+	if err != nil {
+		return fmt.Errorf("multipart copy: failed to open source: %w", err)
+	}
+	defer fs.CheckClose(rc, &err)
+
+	// Read the whole chunk into memory so it can be retried
+	buf := make([]byte, end-start)
+	n, err := io.ReadFull(rc, buf)
+	if n > 0 {
+		if accErr := mc.acc.AccountRead(n); accErr != nil {
+			return fmt.Errorf("multipart copy: accounting failed: %w", accErr)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("multipart copy: read failed: %w", err)
+	}
+
+	written, err := mc.cw.WriteChunk(mc.ctx, chunk, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("multipart copy: write failed: %w", err)
+	}
+	if written != end-start {
+		return fmt.Errorf("multipart copy: wrote %d bytes but expected to write %d", written, end-start)
+	}
+
+	fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v finished", chunk+1, mc.chunks, start, end, fs.SizeSuffix(end-start))
+	return nil
+}
+
+// chunkResumer saves the state of a multi-thread copy using
+// OpenChunkWriter with --resume-uploads so that it can be carried on
+// if it is interrupted
+type chunkResumer struct {
+	mu      sync.Mutex
+	store   *resume.Store
+	remote  string
+	state   *resume.State
+	session fs.ChunkWriterSession
+	written map[int]bool
+}
+
+// newChunkResumer opens the resume store for the upload of src to
+// (f, remote) and reads the state of any previous upload
+//
+// It returns nil if the store can't be used.
+func newChunkResumer(ctx context.Context, f fs.Fs, remote string, src fs.Object) *chunkResumer {
+	store, err := resume.Open(ctx, f)
+	if err != nil {
+		fs.Errorf(src, "multi-thread copy: can't resume uploads: %v", err)
+		return nil
+	}
+	r := &chunkResumer{
+		store:  store,
+		remote: remote,
+	}
+	r.state, err = store.Get(remote)
+	if err != nil {
+		fs.Debugf(src, "multi-thread copy: failed to read resume state: %v", err)
+	}
+	return r
+}
+
+// close the resume store
+func (r *chunkResumer) close() {
+	_ = r.store.Close()
+}
+
+// open the ChunkWriter, carrying on the previous upload if the
+// source hasn't changed since it was started
+func (r *chunkResumer) open(ctx context.Context, f fs.Fs, remote string, src fs.Object) (info fs.ChunkWriterInfo, cw fs.ChunkWriter, err error) {
+	openChunkWriter := f.Features().OpenChunkWriter
+	prev := r.state
+	r.state = resume.NewState(src.Size(), src.ModTime(ctx))
+	r.written = map[int]bool{}
+	if prev != nil && prev.Session != "" {
+		info, cw, err = openChunkWriter(ctx, remote, src, &fs.ResumeOption{Session: prev.Session})
+		switch {
+		case err != nil:
+			fs.Logf(src, "multi-thread copy: can't resume upload - starting again: %v", err)
+		case !resumedSession(cw, prev.Session):
+			fs.Logf(src, "multi-thread copy: backend didn't resume upload - starting again")
+			if abortErr := cw.Abort(ctx); abortErr != nil {
+				fs.Debugf(src, "multi-thread copy: failed to abort upload: %v", abortErr)
+			}
+		case !prev.Matches(src.Size(), src.ModTime(ctx)):
+			fs.Logf(src, "multi-thread copy: source changed since upload was interrupted - starting again")
+			if abortErr := cw.Abort(ctx); abortErr != nil {
+				fs.Debugf(src, "multi-thread copy: failed to abort previous upload: %v", abortErr)
+			}
+		case info.ChunkSize != prev.ChunkSize:
+			fs.Logf(src, "multi-thread copy: chunk size changed since upload was interrupted - starting again")
+			if abortErr := cw.Abort(ctx); abortErr != nil {
+				fs.Debugf(src, "multi-thread copy: failed to abort previous upload: %v", abortErr)
+			}
+		default:
+			r.state.ChunkSize = prev.ChunkSize
+			r.state.Session = prev.Session
+			r.state.Written = prev.Written
+			for _, chunk := range prev.Written {
+				r.written[chunk] = true
+			}
+			r.session, _ = cw.(fs.ChunkWriterSession)
+			fs.Infof(src, "multi-thread copy: resuming upload with %d chunks already written", len(r.written))
+			return info, cw, nil
+		}
+		_ = r.store.Delete(r.remote)
+	}
+	info, cw, err = openChunkWriter(ctx, remote, src)
+	if err != nil {
+		return info, cw, err
+	}
+	r.session, _ = cw.(fs.ChunkWriterSession)
+	if r.session == nil {
+		fs.Debugf(src, "multi-thread copy: can't resume uploads to %v", f)
+		return info, cw, nil
+	}
+	r.state.ChunkSize = info.ChunkSize
+	r.save(src)
+	return info, cw, nil
+}
+
+// resumedSession returns true if cw carries on the upload saved in
+// session, rather than having ignored the ResumeOption and started a
+// new upload which would be missing the chunks written before
+func resumedSession(cw fs.ChunkWriter, session string) bool {
+	cwSession, ok := cw.(fs.ChunkWriterSession)
+	if !ok {
+		return false
+	}
+	got, err := cwSession.Session()
+	return err == nil && got == session
+}
+
+// canResume returns true if the upload can be resumed
+func (r *chunkResumer) canResume() bool {
+	return r != nil && r.session != nil
+}
+
+// isWritten returns true if chunk was written by a previous upload
+func (r *chunkResumer) isWritten(chunk int) bool {
+	if !r.canResume() {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.written[chunk]
+}
+
+// wrote records that chunk has been written
+func (r *chunkResumer) wrote(src fs.Object, chunk int) {
+	if !r.canResume() {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.written[chunk] = true
+	r.state.Written = append(r.state.Written, chunk)
+	r.save(src)
+}
+
+// save the state of the upload
+//
+// Call with mu held or before the upload has started
+func (r *chunkResumer) save(src fs.Object) {
+	session, err := r.session.Session()
+	if err == nil {
+		r.state.Session = session
+		err = r.store.Put(r.remote, r.state)
+	}
+	if err != nil {
+		fs.Debugf(src, "multi-thread copy: failed to save resume state: %v", err)
+	}
+}
+
+// remove the state of the upload once it can't be resumed
+func (r *chunkResumer) remove(src fs.Object) {
+	if !r.canResume() {
+		return
+	}
+	if err := r.store.Delete(r.remote); err != nil {
+		fs.Debugf(src, "multi-thread copy: failed to remove resume state: %v", err)
+	}
+}
+
+// Copy src to (f, remote) using the OpenChunkWriter feature, running
+// up to streams chunk uploads at once
+//
+// The ChunkWriter is passed src so it is responsible for setting the
+// modification time and any metadata on the new object.
+func multiThreadCopyChunks(ctx context.Context, f fs.Fs, remote string, src fs.Object, streams int, tr *accounting.Transfer) (newDst fs.Object, err error) {
+	var (
+		info fs.ChunkWriterInfo
+		cw   fs.ChunkWriter
+		r    *chunkResumer
+	)
+	if fs.GetConfig(ctx).ResumeUploads {
+		r = newChunkResumer(ctx, f, remote, src)
+	}
+	if r != nil {
+		defer r.close()
+		info, cw, err = r.open(ctx, f, remote, src)
+	} else {
+		info, cw, err = f.Features().OpenChunkWriter(ctx, remote, src)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("multi-thread copy: failed to open chunk writer: %w", err)
+	}
+	abort := func() {
+		if abortErr := cw.Abort(ctx); abortErr != nil {
+			fs.Debugf(src, "multi-thread copy: failed to abort upload: %v", abortErr)
+		}
+	}
+	if info.ChunkSize <= 0 {
+		abort()
+		r.remove(src)
+		return nil, fmt.Errorf("multi-thread copy: invalid chunk size %d", info.ChunkSize)
+	}
+	if streams < 1 {
+		streams = info.Concurrency
+	}
+	if streams < 1 {
+		streams = 1
+	}
+
+	g, gCtx := errgroup.WithContext(ctx)
+	mc := &multiThreadChunkState{
+		ctx:       gCtx,
+		chunkSize: info.ChunkSize,
+		size:      src.Size(),
+		cw:        cw,
+		src:       src,
+		acc:       tr.Account(ctx, nil),
+	}
+	mc.chunks = int(mc.size / mc.chunkSize)
+	if mc.size%mc.chunkSize != 0 {
+		mc.chunks++
+	}
+
+	fs.Debugf(src, "Starting multi-thread copy with %d chunks of size %v with %d streams", mc.chunks, fs.SizeSuffix(mc.chunkSize), streams)
+	tokens := pacer.NewTokenDispenser(streams)
+	for chunk := 0; chunk < mc.chunks; chunk++ {
+		if r.isWritten(chunk) {
+			fs.Debugf(src, "multi-thread copy: chunk %d/%d already written", chunk+1, mc.chunks)
+			continue
+		}
+		tokens.Get()
+		// Fail fast if a chunk has failed already
+		if gCtx.Err() != nil {
+			tokens.Put()
+			break
+		}
+		chunk := chunk
+		g.Go(func() (err error) {
+			defer tokens.Put()
+			err = mc.copyChunk(gCtx, chunk)
+			if err == nil {
+				r.wrote(src, chunk)
+			}
+			return err
+		})
+	}
+	err = g.Wait()
+	if err != nil {
+		if r.canResume() {
+			fs.Infof(src, "multi-thread copy: leaving upload to be resumed by the next run")
+		} else if !info.LeavePartsOnError {
+			abort()
+		}
+		return nil, err
+	}
+	err = cw.Close(ctx)
+	// The upload is either complete or can't be completed now
+	r.remove(src)
+	if err != nil {
+		abort()
+		return nil, fmt.Errorf("multi-thread copy: failed to finalise object after copy: %w", err)
+	}
+
+	obj, err := f.NewObject(ctx, remote)
+	if err != nil {
+		return nil, fmt.Errorf("multi-thread copy: failed to find object after copy: %w", err)
+	}
+
+	fs.Debugf(src, "Finished multi-thread copy with %d chunks of size %v", mc.chunks, fs.SizeSuffix(mc.chunkSize))
+	return obj, nil
+}
@@ -1,13 +1,21 @@
 package operations
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/object"
 	"github.com/rclone/rclone/fstest/mockfs"
 	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/rclone/rclone/lib/kv"
 	"github.com/rclone/rclone/lib/random"
 
 	"github.com/rclone/rclone/fs"
@@ -77,6 +85,24 @@ func TestDoMultiThreadCopy(t *testing.T) {
 	assert.True(t, doMultiThreadCopy(ctx, f, src))
 	srcFs.Features().IsLocal = false
 	assert.True(t, doMultiThreadCopy(ctx, f, src))
+
+	nullChunkWriter := func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		panic("don't call me")
+	}
+	f.Features().OpenWriterAt = nil
+	f.Features().OpenChunkWriter = nullChunkWriter
+	assert.True(t, doMultiThreadCopy(ctx, f, src))
+
+	oldMetadata := ci.Metadata
+	defer func() {
+		ci.Metadata = oldMetadata
+	}()
+	ci.Metadata = true
+	f.Features().WriteMetadata = true
+	assert.True(t, doMultiThreadCopy(ctx, f, src))
+	f.Features().OpenChunkWriter = nil
+	f.Features().OpenWriterAt = nullWriterAt
+	assert.False(t, doMultiThreadCopy(ctx, f, src))
 }
 
 func TestMultithreadCalculateChunks(t *testing.T) {
@@ -147,3 +173,298 @@ func TestMultithreadCopy(t *testing.T) {
 	}
 
 }
+
+// writerAtChunkWriter implements fs.ChunkWriter using an fs.WriterAtCloser
+type writerAtChunkWriter struct {
+	chunkSize int64
+	wc        fs.WriterAtCloser
+	mu        sync.Mutex
+	written   map[int]int64
+	aborted   bool
+}
+
+func (w *writerAtChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.wc.WriteAt(buf, int64(chunkNumber)*w.chunkSize)
+	w.mu.Lock()
+	w.written[chunkNumber] = int64(n)
+	w.mu.Unlock()
+	return int64(n), err
+}
+
+func (w *writerAtChunkWriter) Close(ctx context.Context) error {
+	return w.wc.Close()
+}
+
+func (w *writerAtChunkWriter) Abort(ctx context.Context) error {
+	w.aborted = true
+	return w.wc.Close()
+}
+
+func TestMultithreadCopyChunks(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+
+	openWriterAt := r.Flocal.Features().OpenWriterAt
+	require.NotNil(t, openWriterAt)
+	defer func() {
+		r.Flocal.Features().OpenChunkWriter = nil
+	}()
+
+	for _, test := range []struct {
+		size      int
+		chunkSize int64
+		streams   int
+		wantParts int
+	}{
+		{size: 1, chunkSize: 4096, streams: 2, wantParts: 1},
+		{size: 4096, chunkSize: 4096, streams: 2, wantParts: 1},
+		{size: 4097, chunkSize: 4096, streams: 2, wantParts: 2},
+		{size: 10000, chunkSize: 1000, streams: 3, wantParts: 10},
+	} {
+		t.Run(fmt.Sprintf("%+v", test), func(t *testing.T) {
+			var err error
+			var cw *writerAtChunkWriter
+			r.Flocal.Features().OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+				wc, err := openWriterAt(ctx, remote, src.Size())
+				if err != nil {
+					return fs.ChunkWriterInfo{}, nil, err
+				}
+				cw = &writerAtChunkWriter{
+					chunkSize: test.chunkSize,
+					wc:        wc,
+					written:   map[int]int64{},
+				}
+				return fs.ChunkWriterInfo{ChunkSize: test.chunkSize}, cw, nil
+			}
+
+			contents := random.String(test.size)
+			t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+			file1 := r.WriteObject(ctx, "file1", contents, t1)
+			r.CheckRemoteItems(t, file1)
+
+			src, err := r.Fremote.NewObject(ctx, "file1")
+			require.NoError(t, err)
+			accounting.GlobalStats().ResetCounters()
+			tr := accounting.GlobalStats().NewTransfer(src)
+
+			defer func() {
+				tr.Done(ctx, err)
+			}()
+			dst, err := multiThreadCopy(ctx, r.Flocal, "file1", src, test.streams, tr)
+			require.NoError(t, err)
+			assert.Equal(t, src.Size(), dst.Size())
+			assert.Equal(t, "file1", dst.Remote())
+			assert.Equal(t, test.wantParts, len(cw.written))
+
+			in, err := dst.Open(ctx)
+			require.NoError(t, err)
+			got, err := ioutil.ReadAll(in)
+			require.NoError(t, err)
+			require.NoError(t, in.Close())
+			assert.Equal(t, contents, string(got))
+
+			require.NoError(t, dst.Remove(ctx))
+		})
+	}
+}
+
+func TestMultithreadCopyChunksInvalidChunkSize(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+
+	openWriterAt := r.Flocal.Features().OpenWriterAt
+	require.NotNil(t, openWriterAt)
+	defer func() {
+		r.Flocal.Features().OpenChunkWriter = nil
+	}()
+	var cw *writerAtChunkWriter
+	r.Flocal.Features().OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		wc, err := openWriterAt(ctx, remote, src.Size())
+		if err != nil {
+			return fs.ChunkWriterInfo{}, nil, err
+		}
+		cw = &writerAtChunkWriter{wc: wc, written: map[int]int64{}}
+		return fs.ChunkWriterInfo{ChunkSize: 0}, cw, nil
+	}
+
+	file1 := r.WriteObject(ctx, "file1", "contents", fstest.Time("2001-02-03T04:05:06.499999999Z"))
+	r.CheckRemoteItems(t, file1)
+	src, err := r.Fremote.NewObject(ctx, "file1")
+	require.NoError(t, err)
+	tr := accounting.GlobalStats().NewTransfer(src)
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+	_, err = multiThreadCopy(ctx, r.Flocal, "file1", src, 2, tr)
+	require.Error(t, err)
+	assert.True(t, cw.aborted, "upload should be aborted")
+}
+
+// sessionChunkWriter is an fs.ChunkWriter which can be resumed
+type sessionChunkWriter struct {
+	f         fs.Fs
+	remote    string
+	src       fs.ObjectInfo
+	id        string
+	failChunk int
+	mu        sync.Mutex
+	chunks    map[int][]byte
+	writes    []int
+}
+
+func (w *sessionChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if chunkNumber == w.failChunk {
+		return 0, errors.New("chunk failed")
+	}
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+	w.mu.Lock()
+	w.chunks[chunkNumber] = buf
+	w.writes = append(w.writes, chunkNumber)
+	w.mu.Unlock()
+	return int64(len(buf)), nil
+}
+
+func (w *sessionChunkWriter) Session() (string, error) {
+	return w.id, nil
+}
+
+func (w *sessionChunkWriter) Close(ctx context.Context) error {
+	var data []byte
+	for i := 0; i < len(w.chunks); i++ {
+		data = append(data, w.chunks[i]...)
+	}
+	info := object.NewStaticObjectInfo(w.remote, w.src.ModTime(ctx), int64(len(data)), true, nil, nil)
+	_, err := w.f.Put(ctx, bytes.NewReader(data), info)
+	return err
+}
+
+func (w *sessionChunkWriter) Abort(ctx context.Context) error {
+	return nil
+}
+
+func TestMultithreadCopyChunksResume(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.ResumeUploads = true
+
+	const chunkSize = 1000
+	var (
+		uploads      = map[string]*sessionChunkWriter{}
+		resumed      bool
+		ignoreResume bool
+		opened       int
+		failNext     = 3
+	)
+	defer func() {
+		r.Flocal.Features().OpenChunkWriter = nil
+	}()
+	r.Flocal.Features().OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		info := fs.ChunkWriterInfo{ChunkSize: chunkSize}
+		for _, option := range options {
+			if resumeOption, ok := option.(*fs.ResumeOption); ok && !ignoreResume {
+				cw := uploads[resumeOption.Session]
+				if cw == nil {
+					return info, nil, errors.New("upload not found")
+				}
+				resumed = true
+				cw.failChunk = failNext
+				cw.writes = nil
+				return info, cw, nil
+			}
+		}
+		opened++
+		cw := &sessionChunkWriter{
+			f:         r.Flocal,
+			remote:    remote,
+			src:       src,
+			id:        fmt.Sprintf("upload-%d", opened),
+			failChunk: failNext,
+			chunks:    map[int][]byte{},
+		}
+		uploads[cw.id] = cw
+		return info, cw, nil
+	}
+
+	contents := random.String(10 * chunkSize)
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteObject(ctx, "file1", contents, t1)
+	r.CheckRemoteItems(t, file1)
+	src, err := r.Fremote.NewObject(ctx, "file1")
+	require.NoError(t, err)
+
+	copyFile := func() (fs.Object, error) {
+		accounting.GlobalStats().ResetCounters()
+		tr := accounting.GlobalStats().NewTransfer(src)
+		dst, err := multiThreadCopy(ctx, r.Flocal, "file1", src, 1, tr)
+		tr.Done(ctx, err)
+		return dst, err
+	}
+
+	// The first upload fails part way through
+	_, err = copyFile()
+	require.Error(t, err)
+	assert.Equal(t, []int{0, 1, 2}, uploads["upload-1"].writes)
+
+	// The second carries on from where it got to
+	failNext = -1
+	dst, err := copyFile()
+	require.NoError(t, err)
+	assert.True(t, resumed)
+	assert.Equal(t, 1, opened)
+	assert.Equal(t, []int{3, 4, 5, 6, 7, 8, 9}, uploads["upload-1"].writes)
+	in, err := dst.Open(ctx)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, contents, string(got))
+
+	// The state is removed when the upload is complete so the
+	// next upload starts again
+	resumed = false
+	_, err = copyFile()
+	require.NoError(t, err)
+	assert.False(t, resumed)
+	assert.Equal(t, 2, opened)
+
+	// An upload isn't resumed if the source has changed
+	failNext = 3
+	_, err = copyFile()
+	require.Error(t, err)
+	assert.Equal(t, 3, opened)
+	failNext = -1
+	require.NoError(t, src.SetModTime(ctx, t1.Add(time.Hour)))
+	src, err = r.Fremote.NewObject(ctx, "file1")
+	require.NoError(t, err)
+	_, err = copyFile()
+	require.NoError(t, err)
+	assert.Equal(t, 4, opened)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, uploads["upload-4"].writes)
+
+	// An upload isn't resumed if the backend ignores the ResumeOption
+	// and starts a new upload instead
+	ignoreResume = true
+	failNext = 3
+	_, err = copyFile()
+	require.Error(t, err)
+	assert.Equal(t, 5, opened)
+	failNext = -1
+	_, err = copyFile()
+	require.NoError(t, err)
+	assert.Equal(t, 7, opened)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, uploads["upload-7"].writes)
+}
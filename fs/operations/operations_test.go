@@ -957,6 +957,43 @@ func TestCopyFile(t *testing.T) {
 	r.CheckRemoteItems(t, file2)
 }
 
+func TestCopyFileMetadata(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	r.CheckLocalItems(t, file1)
+	src, err := r.Flocal.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	srcMeta, err := fs.GetMetadata(ctx, src)
+	require.NoError(t, err)
+	require.NotEmpty(t, srcMeta)
+
+	fdst, err := fs.NewFs(ctx, ":memory:TestCopyFileMetadata")
+	require.NoError(t, err)
+	require.True(t, fdst.Features().WriteMetadata)
+	defer func() {
+		require.NoError(t, operations.Purge(ctx, fdst, ""))
+	}()
+
+	// Without --metadata nothing is copied
+	dst, err := operations.Copy(ctx, fdst, nil, "nometa", src)
+	require.NoError(t, err)
+	dstMeta, err := fs.GetMetadata(ctx, dst)
+	require.NoError(t, err)
+	assert.Nil(t, dstMeta)
+
+	// With --metadata the source metadata is copied
+	ci.Metadata = true
+	dst, err = operations.Copy(ctx, fdst, nil, "meta", src)
+	require.NoError(t, err)
+	dstMeta, err = fs.GetMetadata(ctx, dst)
+	require.NoError(t, err)
+	assert.Equal(t, srcMeta, dstMeta)
+}
+
 func TestCopyFileBackupDir(t *testing.T) {
 	ctx := context.Background()
 	ctx, ci := fs.AddConfig(ctx)
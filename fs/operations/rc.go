@@ -35,6 +35,7 @@ func init() {
     - dirsOnly - If set only show directories
     - filesOnly - If set only show files
     - hashTypes - array of strings of hash types to show if showHash set
+    - metadata - If set return metadata of objects also
 
 Returns:
 
@@ -428,6 +429,7 @@ This returns info about the remote passed in;
 		"ListR": false,
 		"MergeDirs": false,
 		"Move": true,
+		"OpenChunkWriter": false,
 		"OpenWriterAt": true,
 		"PublicLink": false,
 		"Purge": true,
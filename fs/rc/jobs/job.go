@@ -29,9 +29,25 @@ type Job struct {
 	Success   bool      `json:"success"`
 	Duration  float64   `json:"duration"`
 	Output    rc.Params `json:"output"`
+	Priority  int       `json:"priority"`
+	State     string    `json:"state"`
 	Stop      func()    `json:"-"`
 	listeners []*func()
 
+	// these are only set for async jobs which run from the queue
+	fn        rc.Func
+	in        rc.Params
+	ctx       context.Context
+	cancelRun func() // stops the current run if set
+	requeue   bool   // set to queue the job again when the run stops
+
+	// these are only set for async jobs recorded in the job store
+	path      string    // rc method called
+	params    rc.Params // parameters the job was started with
+	resumable bool      // set to restart the job if interrupted
+	redacted  bool      // set if parameters were blanked in params
+	store     *jobStore
+
 	// realErr is the Error before printing it as a string, it's used to return
 	// the real error to the upper application layers while still printing the
 	// string error message.
@@ -57,6 +73,14 @@ func (job *Job) finish(out rc.Params, err error) {
 		job.Success = true
 	}
 	job.Finished = true
+	job.State = StateFinished
+	var rec *jobRecord
+	if job.store != nil {
+		rec = job.record()
+		if stats, err := accounting.StatsGroup(context.Background(), job.Group).RemoteStats(); err == nil {
+			rec.Stats = stats
+		}
+	}
 
 	// Notify listeners that the job is finished
 	for i := range job.listeners {
@@ -64,6 +88,9 @@ func (job *Job) finish(out rc.Params, err error) {
 	}
 
 	job.mu.Unlock()
+	if rec != nil {
+		job.save(rec)
+	}
 	running.kickExpire() // make sure this job gets expired
 }
 
@@ -84,14 +111,19 @@ func (job *Job) removeListener(fn *func()) {
 	}
 }
 
-// run the job until completion writing the return status
-func (job *Job) run(ctx context.Context, fn rc.Func, in rc.Params) {
+// call fn returning a panic as an error
+func (job *Job) call(ctx context.Context, fn rc.Func, in rc.Params) (out rc.Params, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			job.finish(nil, fmt.Errorf("panic received: %v \n%s", r, string(debug.Stack())))
+			out, err = nil, fmt.Errorf("panic received: %v \n%s", r, string(debug.Stack()))
 		}
 	}()
-	job.finish(fn(ctx, in))
+	return fn(ctx, in)
+}
+
+// run the job until completion writing the return status
+func (job *Job) run(ctx context.Context, fn rc.Func, in rc.Params) {
+	job.finish(job.call(ctx, fn, in))
 }
 
 // Jobs describes a collection of running tasks
@@ -100,6 +132,10 @@ type Jobs struct {
 	jobs          map[int64]*Job
 	opt           *rc.Options
 	expireRunning bool
+	store         *jobStore // persistent store for async jobs if set
+	storePath     string    // file to keep the job store in if not the default
+	queue         []*Job    // async jobs waiting to run
+	nRunning      int       // number of async jobs running
 }
 
 var (
@@ -139,6 +175,12 @@ func (jobs *Jobs) kickExpire() {
 
 // Expire expires any jobs that haven't been collected
 func (jobs *Jobs) Expire() {
+	jobs.mu.RLock()
+	store := jobs.store
+	jobs.mu.RUnlock()
+	if store != nil {
+		store.pruneEvery(jobs.opt.JobHistoryDuration, jobStorePruneInterval)
+	}
 	jobs.mu.Lock()
 	defer jobs.mu.Unlock()
 	now := time.Now()
@@ -238,11 +280,73 @@ func getFilter(ctx context.Context, in rc.Params) (context.Context, error) {
 	return ctx, nil
 }
 
+// See if _bwlimit is set and if so adjust ctx to include it
+func getBwLimit(ctx context.Context, in rc.Params) (context.Context, error) {
+	bwlimit, err := in.GetString("_bwlimit")
+	if rc.IsErrParamNotFound(err) {
+		return ctx, nil
+	} else if err != nil {
+		return ctx, err
+	}
+	var bws fs.BwTimetable
+	err = bws.Set(bwlimit)
+	if err != nil {
+		return ctx, fmt.Errorf("bad _bwlimit: %w", err)
+	}
+	if len(bws) != 1 {
+		return ctx, errors.New("_bwlimit needs exactly 1 bandwidth setting")
+	}
+	delete(in, "_bwlimit") // remove the parameter
+	return accounting.WithBwLimit(ctx, bws[0].Bandwidth), nil
+}
+
+// See if _resumable is set
+func getResumable(in rc.Params) (bool, error) {
+	resumable, err := in.GetBool("_resumable")
+	if rc.NotErrParamNotFound(err) {
+		return false, err
+	}
+	delete(in, "_resumable") // remove the parameter
+	return resumable, nil
+}
+
+// See if _priority is set
+func getPriority(in rc.Params) (int, error) {
+	priority, err := in.GetInt64("_priority")
+	if rc.NotErrParamNotFound(err) {
+		return 0, err
+	}
+	delete(in, "_priority") // remove the parameter
+	return int(priority), nil
+}
+
 // NewJob creates a Job and executes it, possibly in the background if _async is set
 func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Job, out rc.Params, err error) {
+	return jobs.newJob(ctx, "", fn, in)
+}
+
+// newJob creates a Job for the rc method at path and executes it. If
+// path is set async jobs are recorded in the job store if there is one.
+func (jobs *Jobs) newJob(ctx context.Context, path string, fn rc.Func, in rc.Params) (job *Job, out rc.Params, err error) {
 	id := atomic.AddInt64(&jobID, 1)
 	in = in.Copy() // copy input so we can change it
 
+	// keep the parameters for the job store with any credentials blanked
+	params, redacted := redactParams(in)
+	delete(params, "_async")
+	delete(params, "_request")
+	delete(params, "_response")
+
+	resumable, err := getResumable(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	priority, err := getPriority(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	ctx, isAsync, err := getAsync(ctx, in)
 	if err != nil {
 		return nil, nil, err
@@ -258,28 +362,51 @@ func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Jo
 		return nil, nil, err
 	}
 
+	ctx, err = getBwLimit(ctx, in)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	ctx, group, err := getGroup(ctx, in, id)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
-	stop := func() {
-		cancel()
-		// Wait for cancel to propagate before returning.
-		<-ctx.Done()
-	}
 	job = &Job{
 		ID:        id,
 		Group:     group,
 		StartTime: time.Now(),
-		Stop:      stop,
+		Priority:  priority,
+		State:     StateRunning,
+	}
+	job.Stop = func() {
+		cancel()
+		// A job which hasn't started yet won't ever run
+		if jobs.dequeue(job) {
+			job.finish(nil, ctx.Err())
+		}
+		// Wait for cancel to propagate before returning.
+		<-ctx.Done()
 	}
 	jobs.mu.Lock()
 	jobs.jobs[job.ID] = job
+	store := jobs.store
 	jobs.mu.Unlock()
+	if isAsync && store != nil && path != "" {
+		job.path = path
+		job.params = params
+		job.resumable = resumable
+		job.redacted = redacted
+		job.store = store
+		job.mu.Lock()
+		rec := job.record()
+		job.mu.Unlock()
+		job.save(rec)
+	}
 	if isAsync {
-		go job.run(ctx, fn, in)
+		job.fn, job.in, job.ctx = fn, in, ctx
+		jobs.enqueue(job)
 		out = make(rc.Params)
 		out["jobid"] = job.ID
 		err = nil
@@ -297,6 +424,13 @@ func NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Job, out rc.Par
 	return running.NewJob(ctx, fn, in)
 }
 
+// NewJobForPath creates a Job for the rc method at path and executes
+// it on the global job queue, possibly in the background if _async is
+// set. Async jobs are recorded in the job store if it is open.
+func NewJobForPath(ctx context.Context, path string, fn rc.Func, in rc.Params) (job *Job, out rc.Params, err error) {
+	return running.newJob(ctx, path, fn, in)
+}
+
 // OnFinish adds listener to jobid that will be triggered when job is finished.
 // It returns a function to cancel listening.
 func OnFinish(jobID int64, fn func()) (func(), error) {
@@ -333,6 +467,8 @@ Results:
 - success - boolean - true for success false otherwise
 - output - output of the job as would have been returned if called synchronously
 - progress - output of the progress related to the underlying job
+- priority - priority of the job in the queue
+- state - one of "queued", "running", "paused" or "finished"
 `,
 	})
 }
@@ -345,7 +481,7 @@ func rcJobStatus(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	}
 	job := running.Get(jobID)
 	if job == nil {
-		return nil, errors.New("job not found")
+		return storedJobStatus(jobID)
 	}
 	job.mu.Lock()
 	defer job.mu.Unlock()
@@ -400,8 +536,7 @@ func rcJobStop(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	if job == nil {
 		return nil, errors.New("job not found")
 	}
-	job.mu.Lock()
-	defer job.mu.Unlock()
+	// Stop takes the locks it needs
 	out = make(rc.Params)
 	job.Stop()
 	return out, nil
@@ -0,0 +1,202 @@
+// Queue of async jobs waiting to run
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// States of a job
+const (
+	StateQueued   = "queued"   // waiting for a free slot to run
+	StateRunning  = "running"  // running now
+	StatePaused   = "paused"   // waiting for job/resume
+	StateFinished = "finished" // finished successfully or not
+)
+
+// enqueue adds an async job to the queue and starts it if
+// --rc-job-concurrency allows
+func (jobs *Jobs) enqueue(job *Job) {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	job.mu.Lock()
+	job.State = StateQueued
+	job.mu.Unlock()
+	jobs.queue = append(jobs.queue, job)
+	jobs.startQueued()
+}
+
+// dequeue removes job from the queue returning true if it was there
+func (jobs *Jobs) dequeue(job *Job) bool {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	for i, queued := range jobs.queue {
+		if queued == job {
+			jobs.queue = append(jobs.queue[:i], jobs.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// startQueued starts the queued jobs which aren't paused, highest
+// priority first then oldest first, while there are free slots
+//
+// Call with jobs.mu held
+func (jobs *Jobs) startQueued() {
+	for jobs.opt.JobConcurrency <= 0 || jobs.nRunning < jobs.opt.JobConcurrency {
+		best := -1
+		for i, job := range jobs.queue {
+			job.mu.Lock()
+			paused := job.State == StatePaused
+			job.mu.Unlock()
+			if paused {
+				continue
+			}
+			if best < 0 || job.Priority > jobs.queue[best].Priority ||
+				(job.Priority == jobs.queue[best].Priority && job.ID < jobs.queue[best].ID) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return
+		}
+		job := jobs.queue[best]
+		jobs.queue = append(jobs.queue[:best], jobs.queue[best+1:]...)
+		jobs.nRunning++
+		job.mu.Lock()
+		job.State = StateRunning
+		ctx, cancel := context.WithCancel(job.ctx)
+		job.cancelRun = cancel
+		job.mu.Unlock()
+		go jobs.runQueued(ctx, job)
+	}
+}
+
+// runQueued runs a job taken off the queue, then either finishes it
+// or queues it again if it was paused while running
+func (jobs *Jobs) runQueued(ctx context.Context, job *Job) {
+	// Give each run its own copy of the parameters so it can be run again
+	out, err := job.call(ctx, job.fn, job.in.Copy())
+	jobs.mu.Lock()
+	jobs.nRunning--
+	job.mu.Lock()
+	job.cancelRun()
+	job.cancelRun = nil
+	requeue := job.requeue && job.ctx.Err() == nil
+	job.requeue = false
+	job.mu.Unlock()
+	if requeue {
+		fs.Debugf(nil, "rc: job %d paused", job.ID)
+		jobs.queue = append(jobs.queue, job)
+	}
+	jobs.startQueued()
+	jobs.mu.Unlock()
+	if !requeue {
+		job.finish(out, err)
+	}
+}
+
+// pause stops job being started from the queue until it is resumed
+//
+// A running job is stopped and will be run again from the start when
+// resumed.
+func (jobs *Jobs) pause(job *Job) error {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	switch job.State {
+	case StatePaused:
+	case StateQueued:
+		job.State = StatePaused
+	case StateRunning:
+		if job.cancelRun == nil {
+			return errors.New("can't pause a job which isn't async")
+		}
+		job.State = StatePaused
+		job.requeue = true
+		job.cancelRun()
+	default:
+		return fmt.Errorf("can't pause a job which is %s", job.State)
+	}
+	return nil
+}
+
+// resume lets a paused job be started from the queue again
+func (jobs *Jobs) resume(job *Job) error {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	job.mu.Lock()
+	if job.State != StatePaused {
+		state := job.State
+		job.mu.Unlock()
+		return fmt.Errorf("can't resume a job which is %s", state)
+	}
+	job.State = StateQueued
+	job.mu.Unlock()
+	jobs.startQueued()
+	return nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/pause",
+		Fn:    rcJobPause,
+		Title: "Pause a queued or running job",
+		Help: `This stops an async job from being started from the queue until
+job/resume is called. If the job is running it is stopped and will be
+run again from the start when resumed, so only pause running jobs
+which are safe to run again, such as sync/copy.
+
+Parameters:
+
+- jobid - id of the job (integer).
+`,
+	})
+}
+
+// Pauses a job
+func rcJobPause(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	jobID, err := in.GetInt64("jobid")
+	if err != nil {
+		return nil, err
+	}
+	job := running.Get(jobID)
+	if job == nil {
+		return nil, errors.New("job not found")
+	}
+	return nil, running.pause(job)
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/resume",
+		Fn:    rcJobResume,
+		Title: "Resume a paused job",
+		Help: `This puts a job paused with job/pause back in the queue.
+
+Parameters:
+
+- jobid - id of the job (integer).
+`,
+	})
+}
+
+// Resumes a job
+func rcJobResume(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	jobID, err := in.GetInt64("jobid")
+	if err != nil {
+		return nil, err
+	}
+	job := running.Get(jobID)
+	if job == nil {
+		return nil, errors.New("job not found")
+	}
+	return nil, running.resume(job)
+}
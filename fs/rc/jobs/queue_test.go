@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jobState reads the state of job
+func jobState(job *Job) string {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.State
+}
+
+// waitState waits for job to get to state
+func waitState(t *testing.T, job *Job, state string) {
+	assert.Eventually(t, func() bool {
+		return jobState(job) == state
+	}, 5*time.Second, 10*time.Millisecond, "job %d waiting for %s", job.ID, state)
+}
+
+func TestJobQueue(t *testing.T) {
+	ctx := context.Background()
+	jobs := newJobs()
+	opt := *jobs.opt
+	opt.JobConcurrency = 1
+	jobs.opt = &opt
+
+	// Each job runs until its channel is closed
+	release := map[string]chan struct{}{}
+	for _, name := range []string{"first", "low", "normal", "high", "stopped"} {
+		release[name] = make(chan struct{})
+	}
+	var started = make(chan string, 10)
+	fn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		name, _ := in.GetString("name")
+		started <- name
+		select {
+		case <-release[name]:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+	newJob := func(name string, priority int) *Job {
+		job, _, err := jobs.NewJob(ctx, fn, rc.Params{"_async": true, "_priority": priority, "name": name})
+		require.NoError(t, err)
+		assert.Equal(t, priority, job.Priority)
+		return job
+	}
+
+	first := newJob("first", 0)
+	assert.Equal(t, "first", <-started)
+	assert.Equal(t, StateRunning, jobState(first))
+	low := newJob("low", -1)
+	normal := newJob("normal", 0)
+	high := newJob("high", 10)
+	for _, job := range []*Job{low, normal, high} {
+		assert.Equal(t, StateQueued, jobState(job))
+	}
+
+	// Paused jobs aren't started
+	require.NoError(t, jobs.pause(high))
+	assert.Equal(t, StatePaused, jobState(high))
+	assert.Error(t, jobs.resume(normal))
+
+	// Then jobs are started highest priority first
+	close(release["first"])
+	assert.Equal(t, "normal", <-started)
+	waitState(t, first, StateFinished)
+	require.NoError(t, jobs.resume(high))
+	assert.Equal(t, StateQueued, jobState(high))
+
+	// Pausing a running job stops it and runs it again when resumed
+	require.NoError(t, jobs.pause(normal))
+	assert.Equal(t, "high", <-started)
+	waitState(t, normal, StatePaused)
+	require.NoError(t, jobs.resume(normal))
+	close(release["high"])
+	assert.Equal(t, "normal", <-started)
+	close(release["normal"])
+	assert.Equal(t, "low", <-started)
+	waitState(t, normal, StateFinished)
+	assert.Equal(t, true, normal.Success)
+
+	// Stopping a queued job finishes it without running it
+	stopped := newJob("stopped", 0)
+	assert.Equal(t, StateQueued, jobState(stopped))
+	stopped.Stop()
+	assert.Equal(t, StateFinished, jobState(stopped))
+	assert.Equal(t, "context canceled", stopped.Error)
+
+	close(release["low"])
+	waitState(t, low, StateFinished)
+	select {
+	case name := <-started:
+		t.Errorf("unexpected job %q started", name)
+	default:
+	}
+	jobs.mu.Lock()
+	assert.Equal(t, 0, jobs.nRunning)
+	assert.Equal(t, 0, len(jobs.queue))
+	jobs.mu.Unlock()
+
+	assert.Error(t, jobs.pause(low))
+}
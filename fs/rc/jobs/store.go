@@ -0,0 +1,537 @@
+// Persistent store for async jobs
+
+package jobs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// jobRecord is the state of an async job kept in the store
+type jobRecord struct {
+	ID        int64     `json:"id"`
+	Path      string    `json:"path"`
+	Params    rc.Params `json:"params"`
+	Resumable bool      `json:"resumable"`
+	Group     string    `json:"group"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Error     string    `json:"error"`
+	Finished  bool      `json:"finished"`
+	Success   bool      `json:"success"`
+	Duration  float64   `json:"duration"`
+	Output    rc.Params `json:"output"`
+	Stats     rc.Params `json:"stats,omitempty"`
+	ResumedAs int64     `json:"resumedAs,omitempty"`
+	Redacted  bool      `json:"redacted,omitempty"`
+}
+
+// secretWords mark the names of parameters holding credentials
+var secretWords = []string{"pass", "secret", "token", "key", "auth", "credential"}
+
+// isSecretParam returns true if the parameter name may hold credentials
+func isSecretParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactRemote returns remote with the values of any options in its
+// connection string which may hold credentials blanked, and whether
+// any were.
+func redactRemote(remote string) (string, bool) {
+	parsed, err := fspath.Parse(remote)
+	if err != nil || len(parsed.Config) == 0 {
+		return remote, false
+	}
+	fsInfo, _, _, _, _ := fs.ParseRemote(remote)
+	redacted := false
+	for key, value := range parsed.Config {
+		secret := isSecretParam(key)
+		if !secret && fsInfo != nil {
+			if opt := fsInfo.Options.Get(key); opt != nil {
+				secret = opt.IsPassword
+			}
+		}
+		if secret && value != "" {
+			parsed.Config[key] = ""
+			redacted = true
+		}
+	}
+	if !redacted {
+		return remote, false
+	}
+	return parsed.Name + "," + parsed.Config.String() + ":" + parsed.Path, true
+}
+
+// redactValue returns a copy of value for the store with anything
+// which may hold credentials blanked, and whether anything was.
+func redactValue(value interface{}) (interface{}, bool) {
+	switch x := value.(type) {
+	case string:
+		return redactRemote(x)
+	case rc.Params:
+		return redactParams(x)
+	case map[string]interface{}:
+		out, redacted := redactParams(x)
+		return map[string]interface{}(out), redacted
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		redacted := false
+		for i := range x {
+			var itemRedacted bool
+			out[i], itemRedacted = redactValue(x[i])
+			redacted = redacted || itemRedacted
+		}
+		return out, redacted
+	case []string:
+		out := make([]string, len(x))
+		redacted := false
+		for i := range x {
+			var itemRedacted bool
+			out[i], itemRedacted = redactRemote(x[i])
+			redacted = redacted || itemRedacted
+		}
+		return out, redacted
+	}
+	return value, false
+}
+
+// redactParams returns a copy of in for the store with the values of
+// parameters which may hold credentials blanked, looking inside
+// nested objects and the connection strings of remotes too, and
+// whether any were.
+func redactParams(in rc.Params) (out rc.Params, redacted bool) {
+	out = make(rc.Params, len(in))
+	for name, value := range in {
+		var valueRedacted bool
+		if isSecretParam(name) {
+			value, valueRedacted = "", value != nil && value != ""
+		} else {
+			value, valueRedacted = redactValue(value)
+		}
+		redacted = redacted || valueRedacted
+		out[name] = value
+	}
+	return out, redacted
+}
+
+// noOutputPaths are the prefixes of the rc methods whose output is
+// never written to the store as it may be made of credentials
+var noOutputPaths = []string{"config/", "core/obscure"}
+
+// redactOutput returns the output of a call to the rc method at path
+// to keep in the store
+func redactOutput(path string, output rc.Params) rc.Params {
+	if output == nil {
+		return nil
+	}
+	for _, prefix := range noOutputPaths {
+		if strings.HasPrefix(path, prefix) {
+			return nil
+		}
+	}
+	out, _ := redactParams(output)
+	return out
+}
+
+// jobStorePruneInterval is how often old jobs are pruned from the store
+const jobStorePruneInterval = time.Hour
+
+// jobStore keeps jobRecords in a key-value database
+type jobStore struct {
+	db *kv.DB
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// jobKey makes the database key for a job so keys sort by ID
+func jobKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// kvPutJob: save a job record
+type kvPutJob struct {
+	rec *jobRecord
+}
+
+func (op *kvPutJob) Do(ctx context.Context, b kv.Bucket) error {
+	data, err := json.Marshal(op.rec)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return b.Put(jobKey(op.rec.ID), data)
+}
+
+// kvGetJob: load a single job record
+type kvGetJob struct {
+	id  int64
+	rec *jobRecord
+}
+
+func (op *kvGetJob) Do(ctx context.Context, b kv.Bucket) error {
+	data := b.Get(jobKey(op.id))
+	if len(data) == 0 {
+		return nil
+	}
+	op.rec = new(jobRecord)
+	return json.Unmarshal(data, op.rec)
+}
+
+// kvListJobs: load all the job records in ID order
+type kvListJobs struct {
+	recs []*jobRecord
+}
+
+func (op *kvListJobs) Do(ctx context.Context, b kv.Bucket) error {
+	return b.ForEach(func(key, data []byte) error {
+		rec := new(jobRecord)
+		if err := json.Unmarshal(data, rec); err != nil {
+			fs.Debugf(nil, "rc: ignoring invalid job record %x: %v", key, err)
+			return nil
+		}
+		op.recs = append(op.recs, rec)
+		return nil
+	})
+}
+
+// kvPruneJobs: delete finished jobs which ended before cutoff
+type kvPruneJobs struct {
+	cutoff time.Time
+	pruned int
+}
+
+func (op *kvPruneJobs) Do(ctx context.Context, b kv.Bucket) error {
+	var keys [][]byte
+	err := b.ForEach(func(key, data []byte) error {
+		var rec jobRecord
+		if err := json.Unmarshal(data, &rec); err != nil || (rec.Finished && rec.EndTime.Before(op.cutoff)) {
+			keys = append(keys, append([]byte(nil), key...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+	}
+	op.pruned = len(keys)
+	return nil
+}
+
+// put saves rec in the store
+func (s *jobStore) put(rec *jobRecord) error {
+	err := s.db.Do(true, &kvPutJob{rec: rec})
+	if err != nil && rec.Output != nil {
+		// The output may not be representable as JSON so try
+		// again without it
+		fs.Debugf(nil, "rc: failed to store output of job %d: %v", rec.ID, err)
+		recCopy := *rec
+		recCopy.Output = rc.Params{"error": "output could not be stored"}
+		err = s.db.Do(true, &kvPutJob{rec: &recCopy})
+	}
+	return err
+}
+
+// get loads the record for job id returning nil if not found
+func (s *jobStore) get(id int64) (*jobRecord, error) {
+	op := &kvGetJob{id: id}
+	err := s.db.Do(false, op)
+	if err == kv.ErrEmpty {
+		return nil, nil
+	}
+	return op.rec, err
+}
+
+// list loads all the job records in ID order
+func (s *jobStore) list() ([]*jobRecord, error) {
+	op := &kvListJobs{}
+	err := s.db.Do(false, op)
+	if err == kv.ErrEmpty {
+		return nil, nil
+	}
+	return op.recs, err
+}
+
+// prune removes finished jobs older than maxAge
+func (s *jobStore) prune(maxAge time.Duration) error {
+	if maxAge <= 0 {
+		return nil
+	}
+	op := &kvPruneJobs{cutoff: time.Now().Add(-maxAge)}
+	err := s.db.Do(true, op)
+	if op.pruned > 0 {
+		fs.Debugf(nil, "rc: pruned %d jobs from the job store", op.pruned)
+	}
+	return err
+}
+
+// pruneEvery prunes the store if it wasn't pruned within interval
+func (s *jobStore) pruneEvery(maxAge, interval time.Duration) {
+	s.mu.Lock()
+	if time.Since(s.lastPrune) < interval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPrune = time.Now()
+	s.mu.Unlock()
+	if err := s.prune(maxAge); err != nil {
+		fs.Errorf(nil, "rc: failed to prune job store: %v", err)
+	}
+}
+
+// storedJobStatus returns the status of a job which is no longer in
+// memory from the job store
+func storedJobStatus(id int64) (out rc.Params, err error) {
+	running.mu.RLock()
+	store := running.store
+	running.mu.RUnlock()
+	if store == nil {
+		return nil, errors.New("job not found")
+	}
+	rec, err := store.get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job store: %w", err)
+	}
+	if rec == nil {
+		return nil, errors.New("job not found")
+	}
+	out = make(rc.Params)
+	err = rc.Reshape(&out, rec)
+	if err != nil {
+		return nil, fmt.Errorf("reshape failed in job status: %w", err)
+	}
+	return out, nil
+}
+
+// OpenStore opens the persistent job store and starts recording the
+// async jobs into it.
+//
+// Any jobs left unfinished by a previous run are marked as
+// interrupted, and those started with _resumable set are started
+// again as new jobs.
+func OpenStore(ctx context.Context) error {
+	return running.openStore(ctx)
+}
+
+func (jobs *Jobs) openStore(ctx context.Context) error {
+	var (
+		db  *kv.DB
+		err error
+	)
+	if jobs.storePath != "" {
+		db, err = kv.StartPath(ctx, "rcjobs", jobs.storePath)
+	} else {
+		db, err = kv.Start(ctx, "rcjobs", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open job store: %w", err)
+	}
+	store := &jobStore{db: db}
+	store.pruneEvery(jobs.opt.JobHistoryDuration, 0)
+	recs, err := store.list()
+	if err != nil {
+		_ = db.Stop(false)
+		return fmt.Errorf("failed to read job store: %w", err)
+	}
+
+	// Make sure new job IDs don't clash with the stored ones
+	for _, rec := range recs {
+		for {
+			id := atomic.LoadInt64(&jobID)
+			if id >= rec.ID || atomic.CompareAndSwapInt64(&jobID, id, rec.ID) {
+				break
+			}
+		}
+	}
+
+	jobs.mu.Lock()
+	jobs.store = store
+	jobs.mu.Unlock()
+
+	for _, rec := range recs {
+		if rec.Finished {
+			continue
+		}
+		rec.Finished = true
+		rec.Success = false
+		rec.EndTime = time.Now()
+		rec.Duration = rec.EndTime.Sub(rec.StartTime).Seconds()
+		rec.Error = "interrupted by restart"
+		if rec.Resumable {
+			if job, err := jobs.restart(rec); err != nil {
+				fs.Errorf(nil, "rc: failed to resume job %d: %v", rec.ID, err)
+			} else {
+				fs.Logf(nil, "rc: resumed job %d %q as job %d", rec.ID, rec.Path, job.ID)
+				rec.ResumedAs = job.ID
+				rec.Error = fmt.Sprintf("interrupted by restart, resumed as job %d", job.ID)
+			}
+		}
+		if err := store.put(rec); err != nil {
+			fs.Errorf(nil, "rc: failed to update job %d in store: %v", rec.ID, err)
+		}
+	}
+	return nil
+}
+
+// restart starts an interrupted job again as a new async job
+func (jobs *Jobs) restart(rec *jobRecord) (*Job, error) {
+	call := rc.Calls.Get(rec.Path)
+	if call == nil {
+		return nil, fmt.Errorf("couldn't find method %q", rec.Path)
+	}
+	if rec.Redacted {
+		return nil, errors.New("parameters which may hold credentials were blanked in the store")
+	}
+	in := rec.Params.Copy()
+	in["_async"] = true
+	job, _, err := jobs.newJob(context.Background(), rec.Path, call.Fn, in)
+	if job == nil && err == nil {
+		err = errors.New("job not started")
+	}
+	return job, err
+}
+
+// record returns the state of job to keep in the store
+//
+// Call with job.mu held
+func (job *Job) record() *jobRecord {
+	return &jobRecord{
+		ID:        job.ID,
+		Path:      job.path,
+		Params:    job.params,
+		Resumable: job.resumable,
+		Group:     job.Group,
+		StartTime: job.StartTime,
+		EndTime:   job.EndTime,
+		Error:     job.Error,
+		Finished:  job.Finished,
+		Success:   job.Success,
+		Duration:  job.Duration,
+		Output:    redactOutput(job.path, job.Output),
+		Redacted:  job.redacted,
+	}
+}
+
+// save writes rec to the store if there is one
+func (job *Job) save(rec *jobRecord) {
+	if job.store == nil {
+		return
+	}
+	if err := job.store.put(rec); err != nil {
+		fs.Errorf(nil, "rc: failed to store job %d: %v", job.ID, err)
+	}
+}
+
+// history returns the finished jobs, most recent first, optionally
+// only those in group and at most limit of them if limit > 0
+func (jobs *Jobs) history(group string, limit int) (recs []*jobRecord, err error) {
+	jobs.mu.RLock()
+	store := jobs.store
+	jobs.mu.RUnlock()
+	if store != nil {
+		recs, err = store.list()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Without a store only the jobs still in memory are known
+		jobs.mu.RLock()
+		for _, job := range jobs.jobs {
+			job.mu.Lock()
+			rec := job.record()
+			job.mu.Unlock()
+			recs = append(recs, rec)
+		}
+		jobs.mu.RUnlock()
+	}
+	finished := recs[:0]
+	for _, rec := range recs {
+		if rec.Finished && (group == "" || rec.Group == group) {
+			finished = append(finished, rec)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].ID > finished[j].ID
+	})
+	if limit > 0 && len(finished) > limit {
+		finished = finished[:limit]
+	}
+	return finished, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/history",
+		Fn:    rcJobHistory,
+		Title: "Lists the finished jobs",
+		Help: `This returns the finished async jobs, most recent first. With
+--rc-job-store these include jobs from previous runs of the rc server
+until they are older than --rc-job-history-duration, otherwise only
+the jobs which haven't expired yet are returned.
+
+Parameters:
+
+- group - only return jobs in this stats group (optional)
+- limit - the maximum number of jobs to return (optional)
+
+Results:
+
+- jobs - array of jobs each with the fields returned by job/status
+  and also
+    - path - the rc method called
+    - params - the parameters it was called with, with any which may
+      hold credentials blanked
+    - resumable - whether it is restarted if interrupted
+    - stats - the stats of the job's group when it finished
+    - resumedAs - the id of the job started to resume this job
+    - redacted - set if parameters which may hold credentials were
+      blanked in params
+`,
+	})
+}
+
+// Returns the finished jobs
+func rcJobHistory(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	group, err := in.GetString("group")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	limit, err := in.GetInt64("limit")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	recs, err := running.history(group, int(limit))
+	if err != nil {
+		return nil, err
+	}
+	if recs == nil {
+		recs = []*jobRecord{}
+	}
+	out = make(rc.Params)
+	out["jobs"] = recs
+	return out, nil
+}
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobStore(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "rcjobs.bolt")
+	jobs := newJobs()
+	jobs.storePath = storePath
+	require.NoError(t, jobs.openStore(ctx))
+	store := jobs.store
+
+	// Async jobs with a path are recorded
+	job, _, err := jobs.newJob(ctx, "rc/noop", noopFn, rc.Params{"_async": true, "a": 1})
+	require.NoError(t, err)
+	var rec *jobRecord
+	assert.Eventually(t, func() bool {
+		rec, err = store.get(job.ID)
+		return err == nil && rec != nil && rec.Finished
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NotNil(t, rec)
+	assert.Equal(t, "rc/noop", rec.Path)
+	assert.Equal(t, true, rec.Success)
+	assert.Equal(t, float64(1), rec.Params["a"])
+	_, found := rec.Params["_async"]
+	assert.False(t, found)
+	assert.False(t, rec.Redacted)
+
+	// Sync jobs aren't
+	job2, _, err := jobs.newJob(ctx, "rc/noop", noopFn, rc.Params{})
+	require.NoError(t, err)
+	rec, err = store.get(job2.ID)
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+
+	recs, err := jobs.history("", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(recs))
+	assert.Equal(t, job.ID, recs[0].ID)
+
+	recs, err = jobs.history("no such group", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(recs))
+
+	// Simulate jobs left running by a previous run
+	const interruptedID, resumableID, redactedID = 1000, 1001, 1002
+	require.NoError(t, store.put(&jobRecord{
+		ID:        interruptedID,
+		Path:      "rc/noop",
+		Params:    rc.Params{},
+		StartTime: time.Now(),
+	}))
+	require.NoError(t, store.put(&jobRecord{
+		ID:        resumableID,
+		Path:      "rc/noop",
+		Params:    rc.Params{"_resumable": true, "b": 2},
+		Resumable: true,
+		StartTime: time.Now(),
+	}))
+	require.NoError(t, store.put(&jobRecord{
+		ID:        redactedID,
+		Path:      "rc/noop",
+		Params:    rc.Params{"_resumable": true},
+		Resumable: true,
+		Redacted:  true,
+		StartTime: time.Now(),
+	}))
+
+	// Close the store and open it again as a restart would
+	require.NoError(t, store.db.Stop(false))
+	jobs2 := newJobs()
+	jobs2.storePath = storePath
+	require.NoError(t, jobs2.openStore(ctx))
+	store = jobs2.store
+	defer func() {
+		_ = store.db.Stop(true)
+	}()
+
+	// The finished job survived the restart
+	rec, err = store.get(job.ID)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	assert.True(t, rec.Success)
+	assert.Equal(t, float64(1), rec.Params["a"])
+
+	rec, err = store.get(interruptedID)
+	require.NoError(t, err)
+	assert.True(t, rec.Finished)
+	assert.False(t, rec.Success)
+	assert.Equal(t, "interrupted by restart", rec.Error)
+	assert.Equal(t, int64(0), rec.ResumedAs)
+
+	rec, err = store.get(resumableID)
+	require.NoError(t, err)
+	assert.True(t, rec.Finished)
+	assert.Greater(t, rec.ResumedAs, int64(resumableID))
+
+	resumed := jobs2.Get(rec.ResumedAs)
+	require.NotNil(t, resumed)
+	assert.True(t, resumed.resumable)
+	assert.Equal(t, float64(2), resumed.params["b"])
+
+	// Jobs with parameters blanked in the store aren't resumed
+	rec, err = store.get(redactedID)
+	require.NoError(t, err)
+	assert.True(t, rec.Finished)
+	assert.Equal(t, "interrupted by restart", rec.Error)
+	assert.Equal(t, int64(0), rec.ResumedAs)
+
+	recs, err = jobs2.history("", 0)
+	require.NoError(t, err)
+	ids := []int64{}
+	for _, rec := range recs {
+		ids = append(ids, rec.ID)
+	}
+	assert.Contains(t, ids, job.ID)
+}
+
+func TestJobStoreRedactsCredentials(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	ctx := context.Background()
+	jobs := newJobs()
+	jobs.storePath = filepath.Join(t.TempDir(), "rcjobs.bolt")
+	require.NoError(t, jobs.openStore(ctx))
+	store := jobs.store
+	defer func() {
+		_ = store.db.Stop(true)
+	}()
+
+	// finished returns the stored record of job once it has finished
+	finished := func(job *Job) (rec *jobRecord) {
+		assert.Eventually(t, func() bool {
+			var err error
+			rec, err = store.get(job.ID)
+			return err == nil && rec != nil && rec.Finished
+		}, 5*time.Second, 10*time.Millisecond)
+		require.NotNil(t, rec)
+		return rec
+	}
+
+	outputFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		return rc.Params{"remote": rc.Params{"type": "s3", "secret_access_key": "secret"}}, nil
+	}
+	job, _, err := jobs.newJob(ctx, "rc/noop", outputFn, rc.Params{
+		"_async":  true,
+		"_config": rc.Params{"Transfers": 1},
+		"_filter": rc.Params{"IncludeRule": []string{"*.txt"}},
+		"srcFs":   ":s3,secret_access_key=secret,region=eu:bucket",
+		"dstFs":   "dst:",
+		"parameters": map[string]interface{}{
+			"user":          "me",
+			"pass":          "secret",
+			"client_secret": "secret",
+		},
+		"AccessToken": "secret",
+	})
+	require.NoError(t, err)
+	rec := finished(job)
+	assert.True(t, rec.Redacted)
+	assert.Equal(t, rc.Params{
+		"_config": map[string]interface{}{"Transfers": float64(1)},
+		"_filter": map[string]interface{}{"IncludeRule": []interface{}{"*.txt"}},
+		"srcFs":   ":s3,region='eu',secret_access_key='':bucket",
+		"dstFs":   "dst:",
+		"parameters": map[string]interface{}{
+			"user":          "me",
+			"pass":          "",
+			"client_secret": "",
+		},
+		"AccessToken": "",
+	}, rec.Params)
+	assert.Equal(t, rc.Params{
+		"remote": map[string]interface{}{"type": "s3", "secret_access_key": ""},
+	}, rec.Output)
+
+	// Jobs with _config and _filter but no credentials can be resumed
+	job, _, err = jobs.newJob(ctx, "rc/noop", noopFn, rc.Params{
+		"_async":  true,
+		"_config": rc.Params{"Transfers": 1},
+		"_filter": rc.Params{"IncludeRule": []string{"*.txt"}},
+	})
+	require.NoError(t, err)
+	rec = finished(job)
+	assert.False(t, rec.Redacted)
+	assert.Equal(t, map[string]interface{}{"Transfers": float64(1)}, rec.Params["_config"])
+
+	// The output of the config calls isn't stored at all
+	job, _, err = jobs.newJob(ctx, "config/dump", outputFn, rc.Params{"_async": true})
+	require.NoError(t, err)
+	rec = finished(job)
+	assert.True(t, rec.Success)
+	assert.Nil(t, rec.Output)
+}
+
+func TestRedactParams(t *testing.T) {
+	in := rc.Params{
+		"srcFs":    "src:",
+		"password": "secret",
+		"opt":      rc.Params{"token": "secret", "size": 1},
+		"remotes":  []interface{}{":sftp,host=example.com,pass=secret:", "dst:"},
+	}
+	out, redacted := redactParams(in)
+	assert.True(t, redacted)
+	assert.Equal(t, rc.Params{
+		"srcFs":    "src:",
+		"password": "",
+		"opt":      rc.Params{"token": "", "size": 1},
+		"remotes":  []interface{}{":sftp,host='example.com',pass='':", "dst:"},
+	}, out)
+	assert.Equal(t, "secret", in["password"], "input must not be changed")
+	assert.Equal(t, ":sftp,host=example.com,pass=secret:", in["remotes"].([]interface{})[0], "input must not be changed")
+
+	out, redacted = redactParams(rc.Params{"srcFs": "src:", "path": "dir/file,with=comma:txt", "token": ""})
+	assert.False(t, redacted)
+	assert.Equal(t, rc.Params{"srcFs": "src:", "path": "dir/file,with=comma:txt", "token": ""}, out)
+}
+
+func TestRedactOutput(t *testing.T) {
+	assert.Nil(t, redactOutput("config/get", rc.Params{"type": "local"}))
+	assert.Nil(t, redactOutput("core/obscure", rc.Params{"obscured": "secret"}))
+	assert.Nil(t, redactOutput("rc/noop", nil))
+	assert.Equal(t, rc.Params{"url": "https://example.com/", "auth": ""}, redactOutput("operations/publiclink", rc.Params{"url": "https://example.com/", "auth": "secret"}))
+}
@@ -32,14 +32,18 @@ type Options struct {
 	EnableMetrics            bool   // set to disable prometheus metrics on /metrics
 	JobExpireDuration        time.Duration
 	JobExpireInterval        time.Duration
+	JobStore                 bool          // set to keep async jobs in a persistent store
+	JobHistoryDuration       time.Duration // how long finished jobs are kept in the store
+	JobConcurrency           int           // max number of async jobs to run at once, 0 for unlimited
 }
 
 // DefaultOpt is the default values used for Options
 var DefaultOpt = Options{
-	HTTPOptions:       httplib.DefaultOpt,
-	Enabled:           false,
-	JobExpireDuration: 60 * time.Second,
-	JobExpireInterval: 10 * time.Second,
+	HTTPOptions:        httplib.DefaultOpt,
+	Enabled:            false,
+	JobExpireDuration:  60 * time.Second,
+	JobExpireInterval:  10 * time.Second,
+	JobHistoryDuration: 7 * 24 * time.Hour,
 }
 
 func init() {
@@ -29,5 +29,8 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &Opt.EnableMetrics, "rc-enable-metrics", "", false, "Enable prometheus metrics on /metrics")
 	flags.DurationVarP(flagSet, &Opt.JobExpireDuration, "rc-job-expire-duration", "", Opt.JobExpireDuration, "Expire finished async jobs older than this value")
 	flags.DurationVarP(flagSet, &Opt.JobExpireInterval, "rc-job-expire-interval", "", Opt.JobExpireInterval, "Interval to check for expired async jobs")
+	flags.BoolVarP(flagSet, &Opt.JobStore, "rc-job-store", "", false, "Keep async jobs in a persistent store so they survive restarts")
+	flags.DurationVarP(flagSet, &Opt.JobHistoryDuration, "rc-job-history-duration", "", Opt.JobHistoryDuration, "Remove finished jobs from the job store after this long")
+	flags.IntVarP(flagSet, &Opt.JobConcurrency, "rc-job-concurrency", "", Opt.JobConcurrency, "Max number of async jobs to run at once, queueing the rest (0 for unlimited)")
 	httpflags.AddFlagsPrefix(flagSet, "rc-", &Opt.HTTPOptions)
 }
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -31,6 +32,7 @@ import (
 	"github.com/rclone/rclone/fs/rc/jobs"
 	"github.com/rclone/rclone/fs/rc/rcflags"
 	"github.com/rclone/rclone/fs/rc/webgui"
+	"github.com/rclone/rclone/lib/audit"
 	"github.com/rclone/rclone/lib/http/serve"
 	"github.com/rclone/rclone/lib/random"
 	"github.com/skratchdot/open-golang/open"
@@ -57,6 +59,11 @@ func init() {
 // If the server wasn't configured the *Server returned may be nil
 func Start(ctx context.Context, opt *rc.Options) (*Server, error) {
 	jobs.SetOpt(opt) // set the defaults for jobs
+	if opt.JobStore {
+		if err := jobs.OpenStore(ctx); err != nil {
+			return nil, err
+		}
+	}
 	if opt.Enabled {
 		// Serve on the DefaultServeMux so can have global registrations appear
 		s := newServer(ctx, opt, http.DefaultServeMux)
@@ -255,13 +262,17 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	// Find the call
 	call := rc.Calls.Get(path)
 	if call == nil {
-		writeError(path, in, w, fmt.Errorf("couldn't find method %q", path), http.StatusNotFound)
+		err := fmt.Errorf("couldn't find method %q", path)
+		auditCall(r, path, err)
+		writeError(path, in, w, err, http.StatusNotFound)
 		return
 	}
 
 	// Check to see if it requires authorisation
 	if !s.opt.NoAuth && call.AuthRequired && !s.UsingAuth() {
-		writeError(path, in, w, fmt.Errorf("authentication must be set up on the rc server to use %q or the --rc-no-auth flag must be in use", path), http.StatusForbidden)
+		err := fmt.Errorf("authentication must be set up on the rc server to use %q or the --rc-no-auth flag must be in use", path)
+		auditCall(r, path, err)
+		writeError(path, in, w, err, http.StatusForbidden)
 		return
 	}
 
@@ -277,7 +288,8 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	}
 
 	fs.Debugf(nil, "rc: %q: with parameters %+v", path, in)
-	job, out, err := jobs.NewJob(ctx, call.Fn, in)
+	job, out, err := jobs.NewJobForPath(ctx, path, call.Fn, in)
+	auditCall(r, path, err)
 	if job != nil {
 		w.Header().Add("x-rclone-jobid", fmt.Sprintf("%d", job.ID))
 	}
@@ -298,6 +310,26 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	}
 }
 
+// auditCall records the rc call to path made by r in the audit log
+func auditCall(r *http.Request, path string, err error) {
+	if !audit.Enabled() {
+		return
+	}
+	user, _ := r.Context().Value(httplib.ContextUserKey).(string)
+	client := r.RemoteAddr
+	if host, _, splitErr := net.SplitHostPort(client); splitErr == nil {
+		client = host
+	}
+	audit.Log(audit.Record{
+		Server: "rc",
+		User:   user,
+		Client: client,
+		Op:     audit.OpRC,
+		Path:   path,
+		Result: audit.Result(err),
+	})
+}
+
 func (s *Server) handleOptions(w http.ResponseWriter, r *http.Request, path string) {
 	w.WriteHeader(http.StatusOK)
 }
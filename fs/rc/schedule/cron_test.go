@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"x * * * *",
+		"@every",
+		"@every 1ms",
+		"@sometimes",
+	} {
+		_, err := parseCron(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Wednesday
+	start := time.Date(2022, 6, 15, 10, 30, 20, 0, time.UTC)
+	for _, test := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2022, 6, 15, 10, 31, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2022, 6, 16, 2, 0, 0, 0, time.UTC)},
+		{"45 10 * * *", time.Date(2022, 6, 15, 10, 45, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2022, 6, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2022, 6, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2022, 6, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2022, 6, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either day of month or day of week
+		{"0 0 20 * mon", time.Date(2022, 6, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 16 * mon", time.Date(2022, 6, 16, 0, 0, 0, 0, time.UTC)},
+		{"0,30 * * * *", time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2022, 6, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2022, 6, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", start.Add(90 * time.Second)},
+		{"0 0 31 feb *", time.Time{}},
+	} {
+		c, err := parseCron(test.spec)
+		require.NoError(t, err, test.spec)
+		assert.Equal(t, test.want, c.next(start), test.spec)
+	}
+}
@@ -0,0 +1,465 @@
+// Package schedule runs rc calls on a schedule
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/jobs"
+)
+
+// Overlap policies for when a schedule fires while its previous run
+// is still going
+const (
+	OverlapSkip  = "skip"  // don't start a new run (default)
+	OverlapQueue = "queue" // start a new run when the previous one finishes
+	OverlapKill  = "kill"  // stop the previous run and start a new one when it has stopped
+)
+
+// Schedule describes an rc call to run on a schedule
+type Schedule struct {
+	Name     string    `json:"name"`              // unique name of the schedule
+	Schedule string    `json:"schedule"`          // cron style schedule e.g. "0 2 * * *"
+	Path     string    `json:"path"`              // rc method to call e.g. "sync/sync"
+	Params   rc.Params `json:"params,omitempty"`  // parameters for the call
+	Overlap  string    `json:"overlap,omitempty"` // overlap policy - skip, queue or kill
+}
+
+// entry is a Schedule which has been added to the scheduler
+type entry struct {
+	mu        sync.Mutex
+	sch       Schedule
+	spec      *cronSpec
+	timer     *time.Timer
+	next      time.Time // when the schedule fires next
+	job       *jobs.Job // the current or last run
+	running   bool      // set if job is running
+	queued    bool      // set if a run is waiting for job to finish or stop
+	removed   bool      // set if removed from the scheduler
+	runs      int       // number of runs started
+	skipped   int       // number of runs skipped because of overlap
+	lastStart time.Time // when the last run was started
+	lastError string    // error starting the last run
+}
+
+// Scheduler runs the schedules
+type Scheduler struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	file    string // file to save the schedules to if set
+}
+
+// the global scheduler
+var scheduler = newScheduler()
+
+func newScheduler() *Scheduler {
+	return &Scheduler{
+		entries: map[string]*entry{},
+	}
+}
+
+// group returns the stats group used for the runs of a schedule
+func group(name string) string {
+	return "schedule/" + name
+}
+
+// check validates sch and returns the parsed schedule
+func (sch *Schedule) check() (*cronSpec, error) {
+	if sch.Name == "" {
+		return nil, errors.New("schedule needs a name")
+	}
+	call := rc.Calls.Get(sch.Path)
+	if call == nil {
+		return nil, fmt.Errorf("couldn't find method %q", sch.Path)
+	}
+	if call.NeedsRequest || call.NeedsResponse {
+		return nil, fmt.Errorf("method %q can't be scheduled", sch.Path)
+	}
+	switch sch.Overlap {
+	case "":
+		sch.Overlap = OverlapSkip
+	case OverlapSkip, OverlapQueue, OverlapKill:
+	default:
+		return nil, fmt.Errorf("unknown overlap policy %q - must be skip, queue or kill", sch.Overlap)
+	}
+	return parseCron(sch.Schedule)
+}
+
+// Load reads the schedules from file and starts running them
+//
+// The file is a JSON array of schedules. It needn't exist and is
+// written when schedules are added or removed via the rc.
+func Load(ctx context.Context, file string) error {
+	return scheduler.load(ctx, file)
+}
+
+func (s *Scheduler) load(ctx context.Context, file string) error {
+	var schedules []Schedule
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		if err = json.Unmarshal(data, &schedules); err != nil {
+			return fmt.Errorf("failed to parse schedule file %q: %w", file, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read schedule file: %w", err)
+	}
+	for _, sch := range schedules {
+		if err = s.add(sch); err != nil {
+			return fmt.Errorf("schedule file %q: %q: %w", file, sch.Name, err)
+		}
+	}
+	s.mu.Lock()
+	s.file = file
+	s.mu.Unlock()
+	fs.Infof(nil, "Loaded %d schedules from %q", len(schedules), file)
+	return nil
+}
+
+// save writes the schedules to the schedule file if set
+//
+// Call with s.mu held
+func (s *Scheduler) save() error {
+	if s.file == "" {
+		return nil
+	}
+	schedules := make([]Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		schedules = append(schedules, e.sch)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	data, err := json.MarshalIndent(schedules, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.file), filepath.Base(s.file)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save schedules: %w", err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save schedules: %w", err)
+	}
+	return nil
+}
+
+// add starts running sch replacing any schedule with the same name
+func (s *Scheduler) add(sch Schedule) error {
+	spec, err := sch.check()
+	if err != nil {
+		return err
+	}
+	e := &entry{
+		sch:  sch,
+		spec: spec,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old := s.entries[sch.Name]; old != nil {
+		old.stop()
+	}
+	s.entries[sch.Name] = e
+	e.mu.Lock()
+	e.arm(time.Now())
+	e.mu.Unlock()
+	return s.save()
+}
+
+// remove stops the schedule called name
+//
+// A run in progress isn't stopped.
+func (s *Scheduler) remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[name]
+	if e == nil {
+		return fmt.Errorf("schedule %q not found", name)
+	}
+	e.stop()
+	delete(s.entries, name)
+	return s.save()
+}
+
+// list returns the status of all the schedules sorted by name
+func (s *Scheduler) list() []rc.Params {
+	s.mu.Lock()
+	entries := make([]*entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].sch.Name < entries[j].sch.Name
+	})
+	out := make([]rc.Params, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.status())
+	}
+	return out
+}
+
+// stop stops the schedule firing
+func (e *entry) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.removed = true
+	e.queued = false
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+}
+
+// arm sets the timer for the next time the schedule fires after now
+//
+// Call with e.mu held
+func (e *entry) arm(now time.Time) {
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	e.next = e.spec.next(now)
+	if e.next.IsZero() {
+		fs.Errorf(nil, "schedule %q: never fires again", e.sch.Name)
+		return
+	}
+	e.timer = time.AfterFunc(e.next.Sub(now), e.fire)
+}
+
+// fire is called when the schedule is due
+func (e *entry) fire() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.removed {
+		return
+	}
+	e.arm(time.Now())
+	if e.running {
+		switch e.sch.Overlap {
+		case OverlapQueue:
+			fs.Infof(nil, "schedule %q: previous run (job %d) still running - queueing this run", e.sch.Name, e.job.ID)
+			e.queued = true
+			return
+		case OverlapKill:
+			// The new run is started by finished once the
+			// previous run has stopped so they never overlap
+			fs.Infof(nil, "schedule %q: previous run (job %d) still running - stopping it", e.sch.Name, e.job.ID)
+			e.queued = true
+			e.job.Stop()
+			return
+		default:
+			fs.Infof(nil, "schedule %q: previous run (job %d) still running - skipping this run", e.sch.Name, e.job.ID)
+			e.skipped++
+			return
+		}
+	}
+	e.start()
+}
+
+// start starts a run of the schedule as an async job
+//
+// Call with e.mu held
+func (e *entry) start() {
+	e.lastStart = time.Now()
+	e.lastError = ""
+	call := rc.Calls.Get(e.sch.Path)
+	if call == nil {
+		e.lastError = fmt.Sprintf("couldn't find method %q", e.sch.Path)
+		fs.Errorf(nil, "schedule %q: %s", e.sch.Name, e.lastError)
+		return
+	}
+	in := e.sch.Params.Copy()
+	if in == nil {
+		in = rc.Params{}
+	}
+	in["_async"] = true
+	in["_group"] = group(e.sch.Name)
+	job, _, err := jobs.NewJobForPath(context.Background(), e.sch.Path, call.Fn, in)
+	if err != nil {
+		e.lastError = err.Error()
+		fs.Errorf(nil, "schedule %q: failed to start %q: %v", e.sch.Name, e.sch.Path, err)
+		return
+	}
+	e.job = job
+	e.running = true
+	e.runs++
+	fs.Infof(nil, "schedule %q: started %q as job %d", e.sch.Name, e.sch.Path, job.ID)
+	// The callback may be called straight away so must not take e.mu here
+	_, err = jobs.OnFinish(job.ID, func() {
+		go e.finished(job)
+	})
+	if err != nil {
+		fs.Errorf(nil, "schedule %q: failed to watch job %d: %v", e.sch.Name, job.ID, err)
+	}
+}
+
+// finished is called when a run has finished
+func (e *entry) finished(job *jobs.Job) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.job != job {
+		// an old run which was stopped
+		return
+	}
+	e.running = false
+	if e.queued && !e.removed {
+		e.queued = false
+		e.start()
+	}
+}
+
+// status returns the state of the schedule for the rc
+func (e *entry) status() rc.Params {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := rc.Params{
+		"name":      e.sch.Name,
+		"schedule":  e.sch.Schedule,
+		"path":      e.sch.Path,
+		"params":    e.sch.Params,
+		"overlap":   e.sch.Overlap,
+		"group":     group(e.sch.Name),
+		"next":      e.next,
+		"running":   e.running,
+		"queued":    e.queued,
+		"runs":      e.runs,
+		"skipped":   e.skipped,
+		"lastStart": e.lastStart,
+		"lastError": e.lastError,
+	}
+	if e.job != nil {
+		out["lastJobId"] = e.job.ID
+	}
+	return out
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/add",
+		AuthRequired: true,
+		Fn:           rcAdd,
+		Title:        "Add a schedule to run an rc call",
+		Help: `This runs an rc call on a schedule as an async job, so each run
+shows up in job/list. All the runs of a schedule use the stats group
+"schedule/NAME".
+
+Parameters:
+
+- name - unique name of the schedule, replacing any with the same name
+- schedule - when to run, either 5 cron fields "minute hour
+  day-of-month month day-of-week" (e.g. "0 2 * * *" for 02:00 every
+  day), a shortcut (@hourly, @daily, @weekly, @monthly, @yearly)
+  or "@every DURATION" (e.g. "@every 30m")
+- path - the rc method to call (e.g. "sync/sync")
+- params - object with the parameters for the call (optional)
+- overlap - what to do if the previous run is still going when the
+  schedule fires: "skip" (default) doesn't start a new run, "queue"
+  starts it when the previous run finishes and "kill" stops the
+  previous run and starts a new one once it has stopped
+
+Schedules use the local time zone. If rclone rcd was started with
+--schedule the schedules are saved in that file.
+`,
+	})
+}
+
+// Add a schedule
+func rcAdd(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	var sch Schedule
+	if sch.Name, err = in.GetString("name"); err != nil {
+		return nil, err
+	}
+	if sch.Schedule, err = in.GetString("schedule"); err != nil {
+		return nil, err
+	}
+	if sch.Path, err = in.GetString("path"); err != nil {
+		return nil, err
+	}
+	if err = in.GetStructMissingOK("params", &sch.Params); err != nil {
+		return nil, err
+	}
+	if sch.Overlap, err = in.GetString("overlap"); rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if err = scheduler.add(sch); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/list",
+		AuthRequired: true,
+		Fn:           rcList,
+		Title:        "List the schedules",
+		Help: `The parameters of the schedules are returned as given, so this needs
+authentication as they may hold credentials.
+
+Parameters: None.
+
+Results:
+
+- schedules - array of schedules each with the parameters given to
+  schedule/add and
+    - group - the stats group of the runs
+    - next - when the schedule fires next
+    - running - whether a run is in progress
+    - queued - whether a run is waiting for the previous one to finish
+    - runs - number of runs started
+    - skipped - number of runs skipped because the previous run was going
+    - lastJobId - job id of the last run
+    - lastStart - when the last run started
+    - lastError - error starting the last run if any
+`,
+	})
+}
+
+// List the schedules
+func rcList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	out = make(rc.Params)
+	out["schedules"] = scheduler.list()
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/remove",
+		AuthRequired: true,
+		Fn:           rcRemove,
+		Title:        "Remove a schedule",
+		Help: `This stops the schedule firing. A run in progress is not stopped, use
+job/stop for that.
+
+Parameters:
+
+- name - name of the schedule
+`,
+	})
+}
+
+// Remove a schedule
+func rcRemove(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	name, err := in.GetString("name")
+	if err != nil {
+		return nil, err
+	}
+	return nil, scheduler.remove(name)
+}
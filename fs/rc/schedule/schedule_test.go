@@ -0,0 +1,180 @@
+package schedule
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blocking is closed to release the runs of test/schedule/block
+var blocking chan struct{}
+
+// stubborn is closed to release the runs of test/schedule/stubborn
+// which don't stop when cancelled
+var stubborn chan struct{}
+
+func init() {
+	rc.Add(rc.Call{
+		Path: "test/schedule/block",
+		Fn: func(ctx context.Context, in rc.Params) (rc.Params, error) {
+			select {
+			case <-blocking:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			return nil, nil
+		},
+	})
+	rc.Add(rc.Call{
+		Path: "test/schedule/stubborn",
+		Fn: func(ctx context.Context, in rc.Params) (rc.Params, error) {
+			<-stubborn
+			return nil, ctx.Err()
+		},
+	})
+}
+
+func TestScheduleCheck(t *testing.T) {
+	for _, sch := range []Schedule{
+		{Schedule: "@daily", Path: "rc/noop"},
+		{Name: "a", Schedule: "@daily", Path: "not/found"},
+		{Name: "a", Schedule: "@daily", Path: "rc/noop", Overlap: "sometimes"},
+		{Name: "a", Schedule: "bad", Path: "rc/noop"},
+	} {
+		_, err := sch.check()
+		assert.Error(t, err, sch)
+	}
+	sch := Schedule{Name: "a", Schedule: "@daily", Path: "rc/noop"}
+	_, err := sch.check()
+	require.NoError(t, err)
+	assert.Equal(t, OverlapSkip, sch.Overlap)
+}
+
+func TestSchedulerAddRemove(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "schedule.json")
+	s := newScheduler()
+	require.NoError(t, s.load(ctx, file))
+
+	require.NoError(t, s.add(Schedule{Name: "b", Schedule: "0 2 * * *", Path: "rc/noop", Params: rc.Params{"x": "y"}}))
+	require.NoError(t, s.add(Schedule{Name: "a", Schedule: "@hourly", Path: "rc/noop", Overlap: OverlapQueue}))
+	list := s.list()
+	require.Equal(t, 2, len(list))
+	assert.Equal(t, "a", list[0]["name"])
+	assert.Equal(t, "b", list[1]["name"])
+	assert.Equal(t, "schedule/b", list[1]["group"])
+	assert.True(t, list[1]["next"].(time.Time).After(time.Now()))
+
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"0 2 * * *"`)
+
+	// Reload the schedules from the file
+	s2 := newScheduler()
+	require.NoError(t, s2.load(ctx, file))
+	list = s2.list()
+	require.Equal(t, 2, len(list))
+	assert.Equal(t, OverlapQueue, list[0]["overlap"])
+	assert.Equal(t, rc.Params{"x": "y"}, list[1]["params"])
+
+	require.NoError(t, s.remove("a"))
+	assert.Error(t, s.remove("a"))
+	assert.Equal(t, 1, len(s.list()))
+	require.NoError(t, s.remove("b"))
+	require.NoError(t, s2.remove("a"))
+	require.NoError(t, s2.remove("b"))
+}
+
+// waitRunning waits for the running state of e to be want
+func waitRunning(t *testing.T, e *entry, want bool) {
+	assert.Eventually(t, func() bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.running == want
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestScheduleOverlap(t *testing.T) {
+	blocking = make(chan struct{})
+	s := newScheduler()
+	for _, overlap := range []string{OverlapSkip, OverlapQueue, OverlapKill} {
+		require.NoError(t, s.add(Schedule{Name: overlap, Schedule: "@yearly", Path: "test/schedule/block", Overlap: overlap}))
+	}
+	defer func() {
+		for _, overlap := range []string{OverlapSkip, OverlapQueue, OverlapKill} {
+			_ = s.remove(overlap)
+		}
+	}()
+	skip, queue, kill := s.entries[OverlapSkip], s.entries[OverlapQueue], s.entries[OverlapKill]
+
+	for _, e := range []*entry{skip, queue, kill} {
+		e.fire()
+		e.fire()
+	}
+
+	skip.mu.Lock()
+	assert.Equal(t, 1, skip.runs)
+	assert.Equal(t, 1, skip.skipped)
+	skip.mu.Unlock()
+
+	queue.mu.Lock()
+	assert.Equal(t, 1, queue.runs)
+	assert.True(t, queue.queued)
+	queue.mu.Unlock()
+
+	// The killed run stops and the new run starts
+	assert.Eventually(t, func() bool {
+		kill.mu.Lock()
+		defer kill.mu.Unlock()
+		return kill.runs == 2 && kill.running && !kill.queued
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Let the runs finish - the queued run starts then finishes too
+	close(blocking)
+	waitRunning(t, skip, false)
+	waitRunning(t, kill, false)
+	assert.Eventually(t, func() bool {
+		queue.mu.Lock()
+		defer queue.mu.Unlock()
+		return queue.runs == 2 && !queue.running && !queue.queued
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestScheduleOverlapKillWaits(t *testing.T) {
+	stubborn = make(chan struct{})
+	s := newScheduler()
+	require.NoError(t, s.add(Schedule{Name: "kill", Schedule: "@yearly", Path: "test/schedule/stubborn", Overlap: OverlapKill}))
+	defer func() {
+		_ = s.remove("kill")
+	}()
+	e := s.entries["kill"]
+
+	e.fire()
+	e.mu.Lock()
+	first := e.job
+	e.mu.Unlock()
+	e.fire()
+
+	// The new run doesn't start until the stopped run has finished
+	time.Sleep(100 * time.Millisecond)
+	e.mu.Lock()
+	assert.Equal(t, 1, e.runs)
+	assert.True(t, e.running)
+	assert.True(t, e.queued)
+	assert.Equal(t, first, e.job)
+	e.mu.Unlock()
+
+	close(stubborn)
+	assert.Eventually(t, func() bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.runs == 2 && e.job != first && !e.queued
+	}, 5*time.Second, 10*time.Millisecond)
+	waitRunning(t, e, false)
+}
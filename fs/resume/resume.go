@@ -0,0 +1,139 @@
+// Package resume keeps the state of uploads in progress so that an
+// interrupted upload can be carried on by a later run of rclone.
+package resume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// facility is the name of the key-value database the state is kept in
+const facility = "resume"
+
+// State is the saved state of an upload
+type State struct {
+	Size      int64     `json:"size"`                // size of the source
+	ModTime   time.Time `json:"modTime"`             // modification time of the source
+	ChunkSize int64     `json:"chunkSize,omitempty"` // size of the chunks being uploaded
+	Written   []int     `json:"written,omitempty"`   // numbers of the chunks uploaded so far
+	Session   string    `json:"session"`             // backend specific state of the upload
+	Updated   time.Time `json:"updated"`             // when the state was last saved
+}
+
+// NewState returns the State for a new upload of a source with the
+// size and modTime passed in
+func NewState(size int64, modTime time.Time) *State {
+	return &State{
+		Size:    size,
+		ModTime: modTime,
+	}
+}
+
+// Matches returns true if the source still has the size and modTime
+// it had when the upload was started
+func (st *State) Matches(size int64, modTime time.Time) bool {
+	return st.Size == size && st.ModTime.Equal(modTime)
+}
+
+// Store keeps the State of the uploads to a remote
+type Store struct {
+	db   *kv.DB
+	root string
+}
+
+// Open the Store for the uploads to f
+//
+// Close must be called when finished with it.
+func Open(ctx context.Context, f fs.Fs) (*Store, error) {
+	db, err := kv.Start(ctx, facility, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload resume store: %w", err)
+	}
+	return &Store{
+		db:   db,
+		root: f.Root(),
+	}, nil
+}
+
+// Close the Store
+func (s *Store) Close() error {
+	return s.db.Stop(false)
+}
+
+// key makes the database key for remote
+func (s *Store) key(remote string) []byte {
+	return []byte(path.Join(s.root, remote))
+}
+
+// kvGetState: load the State of an upload
+type kvGetState struct {
+	key []byte
+	st  *State
+}
+
+func (op *kvGetState) Do(ctx context.Context, b kv.Bucket) error {
+	data := b.Get(op.key)
+	if len(data) == 0 {
+		return nil
+	}
+	op.st = new(State)
+	return json.Unmarshal(data, op.st)
+}
+
+// kvPutState: save the State of an upload
+type kvPutState struct {
+	key []byte
+	st  *State
+}
+
+func (op *kvPutState) Do(ctx context.Context, b kv.Bucket) error {
+	data, err := json.Marshal(op.st)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return b.Put(op.key, data)
+}
+
+// kvDeleteState: remove the State of an upload
+type kvDeleteState struct {
+	key []byte
+}
+
+func (op *kvDeleteState) Do(ctx context.Context, b kv.Bucket) error {
+	return b.Delete(op.key)
+}
+
+// Get returns the State saved for the upload to remote or nil if
+// there isn't one
+func (s *Store) Get(remote string) (*State, error) {
+	op := &kvGetState{key: s.key(remote)}
+	err := s.db.Do(false, op)
+	if err == kv.ErrEmpty {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return op.st, nil
+}
+
+// Put saves the State of the upload to remote
+func (s *Store) Put(remote string, st *State) error {
+	st.Updated = time.Now()
+	return s.db.Do(true, &kvPutState{key: s.key(remote), st: st})
+}
+
+// Delete removes the State of the upload to remote
+func (s *Store) Delete(remote string) error {
+	err := s.db.Do(true, &kvDeleteState{key: s.key(remote)})
+	if err == kv.ErrEmpty {
+		return nil
+	}
+	return err
+}
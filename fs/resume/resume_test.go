@@ -0,0 +1,66 @@
+package resume
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/lib/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	ctx := context.Background()
+	s, err := Open(ctx, mockfs.NewFs(ctx, "resumetest", "bucket/root"))
+	require.NoError(t, err)
+	defer func() {
+		_ = s.db.Stop(true)
+	}()
+
+	st, err := s.Get("file.bin")
+	require.NoError(t, err)
+	assert.Nil(t, st)
+	require.NoError(t, s.Delete("file.bin"))
+
+	modTime := time.Date(2023, 4, 5, 6, 7, 8, 9, time.UTC)
+	st = NewState(1000, modTime)
+	st.ChunkSize = 100
+	st.Written = []int{0, 2}
+	st.Session = "upload-id"
+	require.NoError(t, s.Put("file.bin", st))
+	assert.False(t, st.Updated.IsZero())
+
+	got, err := s.Get("file.bin")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, int64(1000), got.Size)
+	assert.Equal(t, int64(100), got.ChunkSize)
+	assert.Equal(t, []int{0, 2}, got.Written)
+	assert.Equal(t, "upload-id", got.Session)
+	assert.True(t, got.Matches(1000, modTime))
+	assert.True(t, got.Matches(1000, modTime.In(time.Local)))
+	assert.False(t, got.Matches(1001, modTime))
+	assert.False(t, got.Matches(1000, modTime.Add(time.Second)))
+
+	// Uploads to other files and other roots are kept separately
+	other, err := s.Get("other.bin")
+	require.NoError(t, err)
+	assert.Nil(t, other)
+	s2 := &Store{db: s.db, root: "bucket"}
+	other, err = s2.Get("file.bin")
+	require.NoError(t, err)
+	assert.Nil(t, other)
+	other, err = s2.Get("root/file.bin")
+	require.NoError(t, err)
+	assert.NotNil(t, other)
+
+	require.NoError(t, s.Delete("file.bin"))
+	got, err = s.Get("file.bin")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,216 @@
+// Package audit writes a record of the operations done by the clients
+// of rclone's servers
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Operations recorded
+const (
+	OpOpen   = "open"
+	OpRead   = "read"
+	OpWrite  = "write"
+	OpRename = "rename"
+	OpDelete = "delete"
+	OpMkdir  = "mkdir"
+	OpRC     = "rc"
+)
+
+// ResultOK is the Result of a successful operation
+const ResultOK = "ok"
+
+// Record is one entry in the audit log
+type Record struct {
+	Time    time.Time `json:"time"`
+	Server  string    `json:"server"`            // server which did the operation, e.g. "sftp"
+	User    string    `json:"user,omitempty"`    // user name if known
+	Client  string    `json:"client,omitempty"`  // client IP address
+	Op      string    `json:"op"`                // one of the Op constants
+	Path    string    `json:"path,omitempty"`    // path operated on, or the rc call
+	NewPath string    `json:"newPath,omitempty"` // destination of a rename
+	Bytes   int64     `json:"bytes,omitempty"`   // bytes transferred
+	Result  string    `json:"result"`            // ResultOK or the error
+}
+
+// Options for the audit log
+type Options struct {
+	File   string // file to append the records to
+	Socket string // unix socket to send the records to
+}
+
+// Opt is the options for the audit log set by the command line flags
+var Opt Options
+
+// socketRetry is how long to wait before reconnecting to the socket
+// after a failure
+const socketRetry = 10 * time.Second
+
+// socketTimeout is how long a write to the socket can take
+const socketTimeout = time.Second
+
+// logger writes the records
+type logger struct {
+	mu         sync.Mutex
+	opt        Options
+	file       io.WriteCloser // nil if not writing to a file
+	conn       net.Conn       // nil if not connected to the socket
+	lastDial   time.Time      // when the socket was last dialled
+	socketDown bool           // set if the socket failed
+}
+
+var (
+	mu     sync.Mutex
+	active *logger // nil if auditing is off
+)
+
+// Start the audit log with the options passed in. It does nothing if
+// neither a file nor a socket is configured.
+func Start(opt *Options) error {
+	if opt.File == "" && opt.Socket == "" {
+		return nil
+	}
+	l := &logger{opt: *opt}
+	if opt.File != "" {
+		f, err := os.OpenFile(opt.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		l.file = f
+	}
+	if opt.Socket != "" {
+		l.mu.Lock()
+		err := l._dial()
+		l.mu.Unlock()
+		if err != nil {
+			fs.Errorf(nil, "audit: will keep trying to connect to %q: %v", opt.Socket, err)
+		}
+	}
+	mu.Lock()
+	old := active
+	active = l
+	mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+	return nil
+}
+
+// Stop the audit log
+func Stop() {
+	mu.Lock()
+	l := active
+	active = nil
+	mu.Unlock()
+	if l != nil {
+		l.close()
+	}
+}
+
+// Enabled returns whether audit records are being written
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return active != nil
+}
+
+// Result turns err into the Result of a Record
+func Result(err error) string {
+	if err == nil {
+		return ResultOK
+	}
+	return err.Error()
+}
+
+// Log writes rec to the audit log filling in the Time if it isn't
+// set. It does nothing if the audit log isn't running.
+func Log(rec Record) {
+	mu.Lock()
+	l := active
+	mu.Unlock()
+	if l == nil {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		fs.Errorf(nil, "audit: failed to encode record: %v", err)
+		return
+	}
+	data = append(data, '\n')
+	l.write(data)
+}
+
+// _dial connects to the socket
+//
+// call with l.mu held
+func (l *logger) _dial() (err error) {
+	l.lastDial = time.Now()
+	l.conn, err = net.DialTimeout("unix", l.opt.Socket, socketTimeout)
+	if err != nil {
+		l.conn = nil
+		return err
+	}
+	return nil
+}
+
+// write data to the file and the socket
+func (l *logger) write(data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		if _, err := l.file.Write(data); err != nil {
+			fs.Errorf(nil, "audit: failed to write to %q: %v", l.opt.File, err)
+		}
+	}
+	if l.opt.Socket == "" {
+		return
+	}
+	if l.conn == nil && time.Since(l.lastDial) >= socketRetry {
+		if err := l._dial(); err != nil && !l.socketDown {
+			fs.Errorf(nil, "audit: failed to connect to %q: %v", l.opt.Socket, err)
+			l.socketDown = true
+		}
+	}
+	if l.conn == nil {
+		return
+	}
+	_ = l.conn.SetWriteDeadline(time.Now().Add(socketTimeout))
+	if _, err := l.conn.Write(data); err != nil {
+		fs.Errorf(nil, "audit: failed to write to %q - records will be dropped until reconnected: %v", l.opt.Socket, err)
+		_ = l.conn.Close()
+		l.conn = nil
+		l.socketDown = true
+		return
+	}
+	if l.socketDown {
+		fs.Logf(nil, "audit: reconnected to %q", l.opt.Socket)
+		l.socketDown = false
+	}
+}
+
+// close the file and the socket
+func (l *logger) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		if err := l.file.Close(); err != nil {
+			fs.Errorf(nil, "audit: failed to close %q: %v", l.opt.File, err)
+		}
+		l.file = nil
+	}
+	if l.conn != nil {
+		_ = l.conn.Close()
+		l.conn = nil
+	}
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readRecords reads the JSON lines records from data
+func readRecords(t *testing.T, data string) (recs []Record) {
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		var rec Record
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestDisabled(t *testing.T) {
+	require.NoError(t, Start(&Options{}))
+	assert.False(t, Enabled())
+	Log(Record{Op: OpRead}) // must not panic
+}
+
+func TestFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, Start(&Options{File: file}))
+	defer Stop()
+	assert.True(t, Enabled())
+
+	Log(Record{Server: "sftp", User: "user", Client: "1.2.3.4", Op: OpWrite, Path: "dir/file", Bytes: 42, Result: Result(nil)})
+	Log(Record{Server: "sftp", User: "user", Op: OpRename, Path: "a", NewPath: "b", Result: Result(errors.New("permission denied"))})
+	Stop()
+	assert.False(t, Enabled())
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	recs := readRecords(t, string(data))
+	require.Len(t, recs, 2)
+	assert.False(t, recs[0].Time.IsZero())
+	recs[0].Time = time.Time{}
+	assert.Equal(t, Record{Server: "sftp", User: "user", Client: "1.2.3.4", Op: OpWrite, Path: "dir/file", Bytes: 42, Result: ResultOK}, recs[0])
+	assert.Equal(t, "b", recs[1].NewPath)
+	assert.Equal(t, "permission denied", recs[1].Result)
+
+	// the file is appended to
+	require.NoError(t, Start(&Options{File: file}))
+	Log(Record{Op: OpDelete, Path: "c", Result: ResultOK})
+	Stop()
+	data, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Len(t, readRecords(t, string(data)), 3)
+}
+
+func TestSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "audit.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer func() {
+		_ = listener.Close()
+	}()
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	require.NoError(t, Start(&Options{Socket: socket}))
+	Log(Record{Server: "rc", Op: OpRC, Path: "core/stats", Result: ResultOK})
+	Stop()
+
+	var got []string
+	for line := range lines {
+		got = append(got, line)
+	}
+	recs := readRecords(t, strings.Join(got, "\n"))
+	require.Len(t, recs, 1)
+	assert.Equal(t, "core/stats", recs[0].Path)
+}
+
+func TestSocketMissing(t *testing.T) {
+	// a socket which isn't listening doesn't stop the audit log
+	// starting and records are dropped
+	socket := filepath.Join(t.TempDir(), "missing.sock")
+	require.NoError(t, Start(&Options{Socket: socket}))
+	defer Stop()
+	assert.True(t, Enabled())
+	Log(Record{Op: OpRead, Result: ResultOK})
+}
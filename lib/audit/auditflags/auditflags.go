@@ -0,0 +1,15 @@
+// Package auditflags implements command line flags to set up the
+// audit log
+package auditflags
+
+import (
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/lib/audit"
+	"github.com/spf13/pflag"
+)
+
+// AddFlags adds the audit log flags to the flagSet
+func AddFlags(flagSet *pflag.FlagSet) {
+	flags.StringVarP(flagSet, &audit.Opt.File, "audit-log", "", audit.Opt.File, "Record what clients of the servers and rc do as JSON lines in this file")
+	flags.StringVarP(flagSet, &audit.Opt.Socket, "audit-socket", "", audit.Opt.Socket, "Send the audit records as JSON lines to this unix socket")
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/jwtutil"
 	"github.com/spf13/pflag"
 )
 
@@ -31,6 +32,27 @@ The password file can be updated while rclone is running.
 Use --realm to set the authentication realm.
 
 Use --salt to change the password hashing salt from the default.
+` + BearerHelp
+
+// BearerHelp contains text describing the bearer token authentication
+// to add to the command help.
+var BearerHelp = `
+Use --oidc-issuer to accept JWT bearer tokens issued by an OpenID
+Connect provider, e.g. --oidc-issuer https://sso.example.com/realms/rclone.
+Clients send the token in an "Authorization: Bearer <token>" header.
+Rclone finds the issuer's signing keys with OpenID Connect discovery
+and accepts tokens signed with RS256 by those keys which were issued
+by --oidc-issuer and haven't expired.
+
+Use --oidc-audience to only accept tokens issued for that audience,
+which is usually the client ID registered with the provider.
+
+The user name is read from the claim named by --oidc-user-claim
+which is "sub" by default.  Set it to "email" or
+"preferred_username" to use a more readable name.
+
+This can be used together with the other authentication methods, in
+which case clients can use either bearer tokens or basic auth.
 `
 
 // CustomAuthFn if used will be used to authenticate user, pass. If an error
@@ -39,6 +61,13 @@ Use --salt to change the password hashing salt from the default.
 // If a non nil value is returned then it is added to the context under the key
 type CustomAuthFn func(user, pass string) (value interface{}, err error)
 
+// CustomClaimsAuthFn if used will be called with the user and claims
+// of each verified bearer token. If an error is returned then the
+// user is not authenticated.
+//
+// If a non nil value is returned then it is added to the context under the key
+type CustomClaimsAuthFn func(user string, claims jwtutil.Claims) (value interface{}, err error)
+
 // Options contains options for the http authentication
 type Options struct {
 	HtPasswd  string       // htpasswd file - if not provided no authentication is done
@@ -47,24 +76,34 @@ type Options struct {
 	BasicPass string       // password for BasicUser
 	Salt      string       // password hashing salt
 	Auth      CustomAuthFn `json:"-"` // custom Auth (not set by command line flags)
+
+	OIDCIssuer    string             // OpenID Connect issuer to accept bearer tokens from
+	OIDCAudience  string             // audience bearer tokens must be issued for
+	OIDCUserClaim string             // claim to read the user name from
+	ClaimsAuth    CustomClaimsAuthFn `json:"-"` // custom Auth for bearer tokens (not set by command line flags)
 }
 
 // Auth instantiates middleware that authenticates users based on the configuration
 func Auth(opt Options) http.Middleware {
+	var basic http.Middleware
 	if opt.Auth != nil {
-		return CustomAuth(opt.Auth, opt.Realm)
+		basic = CustomAuth(opt.Auth, opt.Realm)
 	} else if opt.HtPasswd != "" {
-		return HtPasswdAuth(opt.HtPasswd, opt.Realm)
+		basic = HtPasswdAuth(opt.HtPasswd, opt.Realm)
 	} else if opt.BasicUser != "" {
-		return SingleAuth(opt.BasicUser, opt.BasicPass, opt.Realm, opt.Salt)
+		basic = SingleAuth(opt.BasicUser, opt.BasicPass, opt.Realm, opt.Salt)
+	}
+	if opt.OIDCIssuer != "" {
+		return BearerAuth(opt, basic)
 	}
-	return nil
+	return basic
 }
 
 // Options set by command line flags
 var (
 	Opt = Options{
-		Salt: "dlPL2MqE",
+		Salt:          "dlPL2MqE",
+		OIDCUserClaim: "sub",
 	}
 )
 
@@ -75,6 +114,9 @@ func AddFlagsPrefix(flagSet *pflag.FlagSet, prefix string, Opt *Options) {
 	flags.StringVarP(flagSet, &Opt.BasicUser, prefix+"user", "", Opt.BasicUser, "User name for authentication")
 	flags.StringVarP(flagSet, &Opt.BasicPass, prefix+"pass", "", Opt.BasicPass, "Password for authentication")
 	flags.StringVarP(flagSet, &Opt.Salt, prefix+"salt", "", Opt.Salt, "Password hashing salt")
+	flags.StringVarP(flagSet, &Opt.OIDCIssuer, prefix+"oidc-issuer", "", Opt.OIDCIssuer, "OpenID Connect issuer URL to accept bearer tokens from")
+	flags.StringVarP(flagSet, &Opt.OIDCAudience, prefix+"oidc-audience", "", Opt.OIDCAudience, "Only accept bearer tokens issued for this audience")
+	flags.StringVarP(flagSet, &Opt.OIDCUserClaim, prefix+"oidc-user-claim", "", Opt.OIDCUserClaim, "Bearer token claim to use as the user name")
 }
 
 // AddFlags adds flags for the http/auth
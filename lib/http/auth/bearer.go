@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rclone/rclone/fs"
+	httplib "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/jwtutil"
+)
+
+type contextClaimsType struct{}
+
+// ContextClaimsKey is a simple context key for storing the
+// jwtutil.Claims of a request authenticated with a bearer token
+var ContextClaimsKey = &contextClaimsType{}
+
+// BearerAuth instantiates middleware that authenticates JWT bearer
+// tokens from the OpenID Connect issuer in opt.
+//
+// Requests without a bearer token are passed to basic if it is not
+// nil, otherwise they are rejected.
+func BearerAuth(opt Options, basic httplib.Middleware) httplib.Middleware {
+	fs.Infof(nil, "Using %q as OpenID Connect issuer", opt.OIDCIssuer)
+	verifier := jwtutil.NewOIDCVerifier(context.Background(), opt.OIDCIssuer, opt.OIDCAudience)
+	userClaim := opt.OIDCUserClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+	return func(next http.Handler) http.Handler {
+		var basicHandler http.Handler
+		if basic != nil {
+			basicHandler = basic(next)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			unauthorized := func() {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+opt.Realm+`"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			}
+			token, ok := jwtutil.BearerToken(r)
+			if !ok {
+				if basicHandler != nil {
+					basicHandler.ServeHTTP(w, r)
+				} else {
+					unauthorized()
+				}
+				return
+			}
+			user, claims, err := verifier.User(token, userClaim)
+			if err != nil {
+				fs.Infof(r.URL.Path, "%s: Bearer token rejected: %v", r.RemoteAddr, err)
+				unauthorized()
+				return
+			}
+			if opt.ClaimsAuth != nil {
+				value, err := opt.ClaimsAuth(user, claims)
+				if err != nil {
+					fs.Infof(r.URL.Path, "%s: Auth failed from %s: %v", r.RemoteAddr, user, err)
+					unauthorized()
+					return
+				}
+				if value != nil {
+					r = r.WithContext(context.WithValue(r.Context(), ContextAuthKey, value))
+				}
+			}
+			r = r.WithContext(context.WithValue(r.Context(), ContextClaimsKey, claims))
+			r = r.WithContext(context.WithValue(r.Context(), ContextUserKey, user))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
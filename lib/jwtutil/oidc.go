@@ -0,0 +1,337 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+	"golang.org/x/oauth2/jws"
+)
+
+const (
+	// clockSkew is the leeway allowed when checking exp and nbf
+	clockSkew = time.Minute
+	// minKeyRefresh is the minimum time between fetches of the
+	// JWKS when a token with an unknown key ID is seen
+	minKeyRefresh = time.Minute
+	// minKeyRetry is the minimum time between fetches of the JWKS
+	// after a fetch failed
+	minKeyRetry = 10 * time.Second
+)
+
+// Claims are the claims of a verified JWT
+type Claims map[string]interface{}
+
+// String returns the claim called name if it is a string
+func (c Claims) String(name string) (value string, ok bool) {
+	value, ok = c[name].(string)
+	return value, ok
+}
+
+// OIDCVerifier verifies JWT bearer tokens issued by an OpenID
+// Connect issuer against the keys it publishes in its JWKS.
+type OIDCVerifier struct {
+	issuer   string
+	audience string
+	client   *http.Client
+
+	mu       sync.Mutex
+	jwksURI  string                    // found by discovery
+	keys     map[string]*rsa.PublicKey // keys indexed by key ID
+	fetched  time.Time                 // when the keys were last fetched
+	fetchErr error                     // error from the last fetch if it failed
+	fetching chan struct{}             // closed when the fetch in progress is done
+}
+
+// NewOIDCVerifier makes a verifier for tokens from issuer.
+//
+// If audience is set then the token must have been issued for it.
+func NewOIDCVerifier(ctx context.Context, issuer, audience string) *OIDCVerifier {
+	return &OIDCVerifier{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		audience: audience,
+		client:   fshttp.NewClient(ctx),
+	}
+}
+
+// getJSON reads the JSON at url into result
+func (v *OIDCVerifier) getJSON(url string, result interface{}) (err error) {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(resp.Body, &err)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %q: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// jwk is a JSON Web Key as found in a JWKS
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// publicKey decodes the RSA public key from the jwk
+func (k *jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("bad modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("bad exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 || exponent.Int64() < 3 {
+		return nil, errors.New("bad exponent")
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+// fetchKeys discovers the jwks_uri if it is empty then reads the
+// signing keys from it
+func (v *OIDCVerifier) fetchKeys(jwksURI string) (string, map[string]*rsa.PublicKey, error) {
+	if jwksURI == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		err := v.getJSON(v.issuer+"/.well-known/openid-configuration", &discovery)
+		if err != nil {
+			return "", nil, fmt.Errorf("jwtutil: OIDC discovery failed: %w", err)
+		}
+		if strings.TrimSuffix(discovery.Issuer, "/") != v.issuer {
+			return "", nil, fmt.Errorf("jwtutil: OIDC discovery returned issuer %q, expecting %q", discovery.Issuer, v.issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return "", nil, errors.New("jwtutil: OIDC discovery didn't return jwks_uri")
+		}
+		jwksURI = discovery.JWKSURI
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	err := v.getJSON(jwksURI, &jwks)
+	if err != nil {
+		return "", nil, fmt.Errorf("jwtutil: failed to read JWKS: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for i := range jwks.Keys {
+		k := &jwks.Keys[i]
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			fs.Debugf(nil, "jwtutil: ignoring JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return jwksURI, keys, nil
+}
+
+// _findKey returns the key with kid, or the only key if kid is
+// empty
+//
+// call with v.mu held
+func (v *OIDCVerifier) _findKey(kid string) *rsa.PublicKey {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key
+		}
+	}
+	return v.keys[kid]
+}
+
+// _canFetch returns whether the keys may be fetched again
+//
+// call with v.mu held
+func (v *OIDCVerifier) _canFetch() bool {
+	if v.fetched.IsZero() {
+		return true
+	}
+	wait := minKeyRefresh
+	if v.fetchErr != nil {
+		wait = minKeyRetry
+	}
+	return time.Since(v.fetched) >= wait
+}
+
+// key returns the public key with kid, fetching the keys if they
+// haven't been read or kid is unknown as the issuer may have rotated
+// its keys.
+//
+// Only one caller fetches the keys at once, without holding the lock,
+// and the others wait for it. The keys aren't fetched more often than
+// minKeyRefresh, or minKeyRetry after a failure, so tokens with unknown
+// key IDs can't be used to flood the issuer with requests.
+func (v *OIDCVerifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	key := v._findKey(kid)
+	for key == nil && v.fetching != nil {
+		done := v.fetching
+		v.mu.Unlock()
+		<-done
+		v.mu.Lock()
+		key = v._findKey(kid)
+	}
+	if key == nil && v._canFetch() {
+		done := make(chan struct{})
+		v.fetching = done
+		v.fetched = time.Now()
+		jwksURI := v.jwksURI
+		v.mu.Unlock()
+		jwksURI, keys, err := v.fetchKeys(jwksURI)
+		v.mu.Lock()
+		v.fetching = nil
+		close(done)
+		v.fetchErr = err
+		if err == nil {
+			v.jwksURI, v.keys = jwksURI, keys
+		}
+		key = v._findKey(kid)
+	}
+	err := v.fetchErr
+	v.mu.Unlock()
+	if key != nil {
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("jwtutil: no key found for key ID %q", kid)
+}
+
+// decodeSegment decodes the base64 JSON segment of a JWT into result
+func decodeSegment(segment string, result interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// numericDate reads the claim called name as a time returning ok
+// false if it isn't present
+func (c Claims) numericDate(name string) (t time.Time, ok bool, err error) {
+	value, found := c[name]
+	if !found {
+		return t, false, nil
+	}
+	seconds, isNumber := value.(float64)
+	if !isNumber {
+		return t, false, fmt.Errorf("jwtutil: claim %q is not a number", name)
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// hasAudience returns whether the aud claim contains audience
+func (c Claims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, item := range aud {
+			if s, ok := item.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Verify checks the signature and the iss, aud, exp and nbf claims of
+// the RS256 signed token returning its claims if it is valid.
+func (v *OIDCVerifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwtutil: malformed token")
+	}
+	var header jws.Header
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("jwtutil: failed to decode token header: %w", err)
+	}
+	if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("jwtutil: unsupported token algorithm %q", header.Algorithm)
+	}
+	key, err := v.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := jws.Verify(token, key); err != nil {
+		return nil, fmt.Errorf("jwtutil: bad token signature: %w", err)
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("jwtutil: failed to decode token claims: %w", err)
+	}
+	if iss, _ := claims.String("iss"); strings.TrimSuffix(iss, "/") != v.issuer {
+		return nil, fmt.Errorf("jwtutil: token issued by %q not %q", iss, v.issuer)
+	}
+	if v.audience != "" && !claims.hasAudience(v.audience) {
+		return nil, fmt.Errorf("jwtutil: token not issued for audience %q", v.audience)
+	}
+	now := time.Now()
+	exp, ok, err := claims.numericDate("exp")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("jwtutil: token has no expiry")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return nil, errors.New("jwtutil: token has expired")
+	}
+	nbf, ok, err := claims.numericDate("nbf")
+	if err != nil {
+		return nil, err
+	}
+	if ok && now.Add(clockSkew).Before(nbf) {
+		return nil, errors.New("jwtutil: token is not valid yet")
+	}
+	return claims, nil
+}
+
+// BearerToken returns the token from an "Authorization: Bearer"
+// header of r if there is one
+func BearerToken(r *http.Request) (token string, ok bool) {
+	s := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(s) != 2 || !strings.EqualFold(s[0], "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(s[1])
+	return token, token != ""
+}
+
+// User verifies token and returns the value of userClaim from its
+// claims as the user name
+func (v *OIDCVerifier) User(token, userClaim string) (user string, claims Claims, err error) {
+	claims, err = v.Verify(token)
+	if err != nil {
+		return "", nil, err
+	}
+	user, ok := claims.String(userClaim)
+	if !ok || user == "" {
+		return "", nil, fmt.Errorf("jwtutil: token has no %q claim", userClaim)
+	}
+	return user, claims, nil
+}
@@ -0,0 +1,208 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2/jws"
+)
+
+// testIssuer is an OIDC issuer serving discovery and a JWKS
+type testIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	jwksCalls int32 // number of times the JWKS was fetched
+	fail      int32 // set to make fetching the JWKS fail
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ti := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   ti.URL,
+			"jwks_uri": ti.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&ti.jwksCalls, 1)
+		if atomic.LoadInt32(&ti.fail) != 0 {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	ti.Server = httptest.NewServer(mux)
+	return ti
+}
+
+// calls returns the number of times the JWKS was fetched
+func (ti *testIssuer) calls() int32 {
+	return atomic.LoadInt32(&ti.jwksCalls)
+}
+
+// token makes a token signed by key with the claims passed in
+func (ti *testIssuer) token(t *testing.T, key *rsa.PrivateKey, kid string, claims *jws.ClaimSet) string {
+	token, err := jws.Encode(&jws.Header{Algorithm: "RS256", Typ: "JWT", KeyID: kid}, claims, key)
+	require.NoError(t, err)
+	return token
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	v := NewOIDCVerifier(context.Background(), ti.URL+"/", "rclone")
+
+	now := time.Now()
+	good := func() *jws.ClaimSet {
+		return &jws.ClaimSet{
+			Iss: ti.URL,
+			Aud: "rclone",
+			Sub: "user1",
+			Iat: now.Unix(),
+			Exp: now.Add(time.Hour).Unix(),
+			PrivateClaims: map[string]interface{}{
+				"email": "user1@example.com",
+			},
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		claims, err := v.Verify(ti.token(t, ti.key, "key1", good()))
+		require.NoError(t, err)
+		sub, ok := claims.String("sub")
+		assert.True(t, ok)
+		assert.Equal(t, "user1", sub)
+
+		user, _, err := v.User(ti.token(t, ti.key, "key1", good()), "email")
+		require.NoError(t, err)
+		assert.Equal(t, "user1@example.com", user)
+
+		_, _, err = v.User(ti.token(t, ti.key, "key1", good()), "missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		cs := good()
+		cs.Exp = now.Add(-time.Hour).Unix()
+		_, err := v.Verify(ti.token(t, ti.key, "key1", cs))
+		assert.ErrorContains(t, err, "expired")
+	})
+
+	t.Run("WrongAudience", func(t *testing.T) {
+		cs := good()
+		cs.Aud = "other"
+		_, err := v.Verify(ti.token(t, ti.key, "key1", cs))
+		assert.ErrorContains(t, err, "audience")
+	})
+
+	t.Run("WrongIssuer", func(t *testing.T) {
+		cs := good()
+		cs.Iss = "https://evil.example.com"
+		_, err := v.Verify(ti.token(t, ti.key, "key1", cs))
+		assert.ErrorContains(t, err, "issued by")
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		_, err = v.Verify(ti.token(t, otherKey, "key1", good()))
+		assert.ErrorContains(t, err, "signature")
+	})
+
+	t.Run("UnknownKeyID", func(t *testing.T) {
+		calls := ti.calls()
+		_, err := v.Verify(ti.token(t, ti.key, "key2", good()))
+		assert.ErrorContains(t, err, "no key found")
+		// the keys were fetched recently so aren't fetched again
+		assert.Equal(t, calls, ti.calls())
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := v.Verify("not.a-token")
+		assert.Error(t, err)
+	})
+}
+
+func TestOIDCVerifierFetchLimits(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	ctx := context.Background()
+	now := time.Now()
+	cs := &jws.ClaimSet{
+		Iss: ti.URL,
+		Sub: "user1",
+		Iat: now.Unix(),
+		Exp: now.Add(time.Hour).Unix(),
+	}
+
+	// Concurrent tokens with an unknown key ID fetch the keys once
+	v := NewOIDCVerifier(ctx, ti.URL, "")
+	unknown := ti.token(t, ti.key, "unknown", cs)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := v.Verify(unknown)
+			assert.ErrorContains(t, err, "no key found")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), ti.calls())
+
+	// A failed fetch isn't retried until minKeyRetry has passed
+	v = NewOIDCVerifier(ctx, ti.URL, "")
+	atomic.StoreInt32(&ti.fail, 1)
+	token := ti.token(t, ti.key, "key1", cs)
+	_, err := v.Verify(token)
+	assert.ErrorContains(t, err, "failed to read JWKS")
+	_, err = v.Verify(token)
+	assert.ErrorContains(t, err, "failed to read JWKS")
+	assert.Equal(t, int32(2), ti.calls())
+
+	atomic.StoreInt32(&ti.fail, 0)
+	v.mu.Lock()
+	v.fetched = v.fetched.Add(-minKeyRetry)
+	v.mu.Unlock()
+	_, err = v.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), ti.calls())
+}
+
+func TestBearerToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	_, ok := BearerToken(r)
+	assert.False(t, ok)
+
+	r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	_, ok = BearerToken(r)
+	assert.False(t, ok)
+
+	r.Header.Set("Authorization", "Bearer abc.def.ghi")
+	token, ok := BearerToken(r)
+	assert.True(t, ok)
+	assert.Equal(t, "abc.def.ghi", token)
+}
@@ -79,11 +79,36 @@ func Start(ctx context.Context, facility string, f fs.Fs) (*DB, error) {
 	}
 
 	name := makeName(facility, f)
+	return lockedStart(ctx, facility, name, filepath.Join(dir, name), true)
+}
+
+// StartPath starts a key-value database for facility kept in the
+// file at path
+//
+// Unlike Start the database isn't dropped when run from a unit test
+// as the caller chose where it is kept.
+func StartPath(ctx context.Context, facility string, path string) (*DB, error) {
+	dbMut.Lock()
+	defer dbMut.Unlock()
+	if db := lockedGetName(path); db != nil {
+		return db, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dbDirMode); err != nil {
+		return nil, err
+	}
+	return lockedStart(ctx, facility, path, path, false)
+}
+
+// lockedStart starts the database name for facility in the file at
+// path, dropping any database left by a unit test if dropTest is set
+//
+// call with dbMut held
+func lockedStart(ctx context.Context, facility, name, path string, dropTest bool) (*DB, error) {
 	lockTime := fs.GetConfig(ctx).KvLockTime
 
 	db := &DB{
 		name:      name,
-		path:      filepath.Join(dir, name),
+		path:      path,
 		facility:  facility,
 		refs:      1,
 		lockTime:  lockTime,
@@ -95,7 +120,7 @@ func Start(ctx context.Context, facility string, f fs.Fs) (*DB, error) {
 	}
 
 	fi, err := os.Stat(db.path)
-	if strings.HasSuffix(os.Args[0], ".test") || (err == nil && fi.Size() == 0) {
+	if (dropTest && strings.HasSuffix(os.Args[0], ".test")) || (err == nil && fi.Size() == 0) {
 		_ = os.Remove(db.path)
 		fs.Infof(db.name, "drop cache remaining after unit test")
 	}
@@ -117,7 +142,14 @@ func Get(facility string, f fs.Fs) *DB {
 }
 
 func lockedGet(facility string, f fs.Fs) *DB {
-	name := makeName(facility, f)
+	return lockedGetName(makeName(facility, f))
+}
+
+// lockedGetName returns the database called name adding a reference
+// to it or nil if it isn't running
+//
+// call with dbMut held
+func lockedGetName(name string) *DB {
 	db := dbMap[name]
 	if db != nil {
 		db.mu.Lock()
@@ -20,6 +20,11 @@ func Start(ctx context.Context, facility string, f fs.Fs) (*DB, error) {
 	return nil, ErrUnsupported
 }
 
+// StartPath starts a key-value database kept in the file at path
+func StartPath(ctx context.Context, facility string, path string) (*DB, error) {
+	return nil, ErrUnsupported
+}
+
 // Get returns database for given filesystem and facility
 func Get(f fs.Fs, facility string) *DB { return nil }
 
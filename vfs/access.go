@@ -0,0 +1,207 @@
+package vfs
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// accessControl enforces the vfscommon.Access in the options of the
+// VFS, checking the permissions of each operation and keeping track
+// of the space used for the quotas.
+//
+// A nil *accessControl allows everything.
+type accessControl struct {
+	vfs      *VFS
+	access   *vfscommon.Access
+	writable []string // paths the quotas apply to
+
+	mu      sync.Mutex
+	counted bool  // set if usage has been counted
+	bytes   int64 // bytes in use in writable
+	files   int64 // files in use in writable
+}
+
+// newAccessControl makes the accessControl for vfs returning nil if
+// no access restrictions are set
+func newAccessControl(vfs *VFS) *accessControl {
+	if vfs.Opt.Access == nil {
+		return nil
+	}
+	return &accessControl{
+		vfs:      vfs,
+		access:   vfs.Opt.Access,
+		writable: vfs.Opt.Access.Writable(),
+	}
+}
+
+// check returns EPERM unless all of perm are granted on the VFS path p
+func (ac *accessControl) check(p string, perm vfscommon.Perm) error {
+	if ac == nil || ac.access.Allowed(p, perm) {
+		return nil
+	}
+	fs.Infof(p, "vfs: permission denied: %q needed but have %q", perm, ac.access.Perms(p))
+	return EPERM
+}
+
+// visible returns whether the VFS path p can be looked up
+func (ac *accessControl) visible(p string) bool {
+	return ac == nil || ac.access.Visible(p)
+}
+
+// filter removes the items which can't be listed from items
+func (ac *accessControl) filter(items Nodes) Nodes {
+	if ac == nil {
+		return items
+	}
+	out := items[:0]
+	for _, item := range items {
+		p := item.Path()
+		if ac.access.Allowed(p, vfscommon.PermRead) || (item.IsDir() && ac.access.Leads(p)) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// inQuota returns whether the VFS path p counts towards the quotas
+func (ac *accessControl) inQuota(p string) bool {
+	if ac == nil || (ac.access.MaxSize <= 0 && ac.access.MaxFiles <= 0) {
+		return false
+	}
+	p = strings.Trim(p, "/")
+	for _, dir := range ac.writable {
+		if dir == "" || p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// _count the space used in the writable paths
+//
+// call with ac.mu held
+func (ac *accessControl) _count(ctx context.Context) error {
+	var bytes, files int64
+	for _, dir := range ac.writable {
+		err := walk.ListR(ctx, ac.vfs.f, dir, true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+			entries.ForObject(func(o fs.Object) {
+				bytes += o.Size()
+				files++
+			})
+			return nil
+		})
+		if err == fs.ErrorDirNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+	}
+	fs.Debugf(ac.vfs.f, "vfs: quota usage is %d files using %v", files, fs.SizeSuffix(bytes))
+	ac.bytes, ac.files, ac.counted = bytes, files, true
+	return nil
+}
+
+// checkQuota returns EPERM if writing to the VFS path p would
+// exceed the quotas. isNew should be set if p is a new file.
+//
+// The quotas are checked when a file is opened for write. Each write
+// is then limited to the space left when the file was opened with
+// sizeLimit.
+func (ac *accessControl) checkQuota(p string, isNew bool) error {
+	if !ac.inQuota(p) {
+		return nil
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if !ac.counted {
+		if err := ac._count(context.TODO()); err != nil {
+			fs.Errorf(p, "vfs: failed to count quota usage: %v", err)
+			return err
+		}
+	}
+	if ac.access.MaxSize > 0 && ac.bytes >= int64(ac.access.MaxSize) {
+		fs.Infof(p, "vfs: size quota of %v exceeded", ac.access.MaxSize)
+		return EPERM
+	}
+	if isNew && ac.access.MaxFiles > 0 && ac.files >= ac.access.MaxFiles {
+		fs.Infof(p, "vfs: quota of %d files exceeded", ac.access.MaxFiles)
+		return EPERM
+	}
+	return nil
+}
+
+// sizeLimit returns the size the file at VFS path p, which is size
+// bytes now, may grow to before it takes the usage over the size
+// quota, or -1 if it isn't limited.
+//
+// Each handle open for write may use all the space left when it was
+// opened, so only writing several files at once can exceed the quota.
+func (ac *accessControl) sizeLimit(p string, size int64) int64 {
+	if !ac.inQuota(p) || ac.access.MaxSize <= 0 {
+		return -1
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if !ac.counted {
+		if err := ac._count(context.TODO()); err != nil {
+			fs.Errorf(p, "vfs: failed to count quota usage: %v", err)
+			return size
+		}
+	}
+	left := int64(ac.access.MaxSize) - ac.bytes
+	if left < 0 {
+		left = 0
+	}
+	return size + left
+}
+
+// checkSize returns EPERM if the file at VFS path p would grow to
+// size which is more than limit from sizeLimit
+func checkSize(p string, limit, size int64) error {
+	if limit < 0 || size <= limit {
+		return nil
+	}
+	fs.Infof(p, "vfs: size quota exceeded growing file to %d bytes", size)
+	return EPERM
+}
+
+// changed records the VFS path p using bytes and files more
+func (ac *accessControl) changed(p string, bytes, files int64) {
+	if !ac.inQuota(p) {
+		return
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if ac.counted {
+		ac.bytes += bytes
+		ac.files += files
+	}
+}
+
+// recount marks the usage to be counted again when next needed
+func (ac *accessControl) recount() {
+	if ac == nil {
+		return
+	}
+	ac.mu.Lock()
+	ac.counted = false
+	ac.mu.Unlock()
+}
+
+// usage returns the bytes and files used in the writable paths
+// counting them if necessary
+func (ac *accessControl) usage() (bytes, files int64, err error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if !ac.counted {
+		if err := ac._count(context.TODO()); err != nil {
+			return 0, 0, err
+		}
+	}
+	return ac.bytes, ac.files, nil
+}
@@ -0,0 +1,179 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// names returns the names of the nodes
+func names(nodes Nodes) (out []string) {
+	for _, node := range nodes {
+		out = append(out, node.Name())
+	}
+	return out
+}
+
+func TestAccessDropbox(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.Access = &vfscommon.Access{
+		Rules: []vfscommon.AccessRule{
+			{Path: "/public", Perms: vfscommon.PermRead},
+			{Path: "/inbox", Perms: vfscommon.PermWrite},
+			{Path: "/inbox/area", Perms: vfscommon.PermAll},
+		},
+	}
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+
+	ctx := context.Background()
+	r.WriteObject(ctx, "secret/file", "secret", t1)
+	r.WriteObject(ctx, "public/file", "public", t1)
+	r.WriteObject(ctx, "inbox/other", "other", t1)
+	r.WriteObject(ctx, "inbox/area/file", "area", t1)
+
+	// only the paths leading to rules are listed
+	root, err := vfs.Root()
+	require.NoError(t, err)
+	nodes, err := root.ReadDirAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inbox", "public"}, names(nodes))
+
+	// paths outside the rules can't be found
+	_, err = vfs.Stat("secret/file")
+	assert.Equal(t, ENOENT, err)
+	_, err = vfs.Stat("secret")
+	assert.Equal(t, ENOENT, err)
+
+	// read only paths can be read but not written or deleted
+	data, err := vfs.ReadFile("public/file")
+	require.NoError(t, err)
+	assert.Equal(t, "public", string(data))
+	_, err = vfs.OpenFile("public/new", os.O_WRONLY|os.O_CREATE, 0666)
+	assert.Equal(t, EPERM, err)
+	assert.Equal(t, EPERM, vfs.Remove("public/file"))
+	assert.Equal(t, EPERM, vfs.Mkdir("public/dir", 0777))
+
+	// the write only inbox doesn't show its files
+	nodes, err = vfs.root.cachedDir("inbox").ReadDirAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"area"}, names(nodes))
+	_, err = vfs.ReadFile("inbox/other")
+	assert.Equal(t, EPERM, err)
+
+	// but can be uploaded to
+	fd, err := vfs.OpenFile("inbox/upload", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	require.NoError(t, err)
+	_, err = fd.Write([]byte("upload"))
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+	assert.Equal(t, EPERM, vfs.Remove("inbox/upload"))
+	assert.Equal(t, EPERM, vfs.Rename("inbox/upload", "inbox/area/upload"))
+
+	// everything is allowed in the area
+	require.NoError(t, vfs.Mkdir("inbox/area/dir", 0777))
+	require.NoError(t, vfs.Rename("inbox/area/file", "inbox/area/dir/file"))
+	require.NoError(t, vfs.Remove("inbox/area/dir/file"))
+}
+
+func TestAccessQuota(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.Access = &vfscommon.Access{
+		Rules: []vfscommon.AccessRule{
+			{Path: "", Perms: vfscommon.PermAll},
+		},
+		MaxSize:  10,
+		MaxFiles: 2,
+	}
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+	r.WriteObject(context.Background(), "file1", "12345", t1)
+
+	write := func(name, contents string) error {
+		fd, err := vfs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return err
+		}
+		_, err = fd.Write([]byte(contents))
+		closeErr := fd.Close()
+		if err != nil {
+			return err
+		}
+		return closeErr
+	}
+
+	// a write can't take us over the size quota
+	assert.Equal(t, EPERM, write("file2", "1234567"))
+
+	// but can fill it up
+	require.NoError(t, write("file2", "12345"))
+	bytes, files, err := vfs.access.usage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), bytes)
+	assert.Equal(t, int64(2), files)
+	assert.Equal(t, EPERM, write("file3", "1"))
+
+	total, used, free := vfs.Statfs()
+	assert.Equal(t, int64(10), total)
+	assert.Equal(t, int64(10), used)
+	assert.Equal(t, int64(0), free)
+
+	// removing a file frees up space but not enough files
+	require.NoError(t, vfs.Remove("file2"))
+	bytes, files, err = vfs.access.usage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), bytes)
+	assert.Equal(t, int64(1), files)
+	require.NoError(t, write("file3", "1"))
+	assert.Equal(t, EPERM, write("file4", "1"))
+
+	// existing files can still be overwritten within the quota
+	require.NoError(t, write("file3", "12"))
+	bytes, _, err = vfs.access.usage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), bytes)
+	assert.Equal(t, EPERM, write("file3", "123456"))
+}
+
+func TestAccessQuotaWriteAt(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.CacheMode = vfscommon.CacheModeWrites
+	opt.WriteBack = writeBackDelay
+	opt.Access = &vfscommon.Access{
+		Rules: []vfscommon.AccessRule{
+			{Path: "", Perms: vfscommon.PermAll},
+		},
+		MaxSize: 10,
+	}
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+	r.WriteObject(context.Background(), "file1", "12345", t1)
+
+	fd, err := vfs.OpenFile("file1", os.O_RDWR, 0666)
+	require.NoError(t, err)
+
+	// the file can be changed and grown into the space left
+	_, err = fd.WriteAt([]byte("abc"), 0)
+	require.NoError(t, err)
+	_, err = fd.WriteAt([]byte("abcde"), 5)
+	require.NoError(t, err)
+
+	// but not past it
+	_, err = fd.WriteAt([]byte("x"), 10)
+	assert.Equal(t, EPERM, err)
+	assert.Equal(t, EPERM, fd.Truncate(11))
+	require.NoError(t, fd.Truncate(8))
+	require.NoError(t, fd.Close())
+
+	// the usage is updated when the file is uploaded
+	vfs.WaitForWriters(waitForWritersDelay)
+	assert.Eventually(t, func() bool {
+		bytes, _, err := vfs.access.usage()
+		return err == nil && bytes == 8
+	}, 10*time.Second, 10*time.Millisecond)
+}
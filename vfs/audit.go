@@ -0,0 +1,181 @@
+package vfs
+
+import (
+	"net"
+	"os"
+	"sync"
+
+	"github.com/rclone/rclone/lib/audit"
+)
+
+// Auditor writes the operations one client of a server does on the
+// VFS to the audit log.
+//
+// A nil *Auditor does nothing so the servers can call it without
+// checking whether the audit log is enabled.
+type Auditor struct {
+	server string
+	user   string
+	client string
+}
+
+// NewAuditor makes an Auditor for the user connected from the
+// network address client to server. It returns nil if the audit log
+// is not enabled.
+func NewAuditor(server, user, client string) *Auditor {
+	if !audit.Enabled() {
+		return nil
+	}
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	return &Auditor{
+		server: server,
+		user:   user,
+		client: client,
+	}
+}
+
+// log writes a record for op with bytes on path
+func (a *Auditor) log(op, path, newPath string, bytes int64, err error) {
+	if a == nil {
+		return
+	}
+	audit.Log(audit.Record{
+		Server:  a.server,
+		User:    a.user,
+		Client:  a.client,
+		Op:      op,
+		Path:    path,
+		NewPath: newPath,
+		Bytes:   bytes,
+		Result:  audit.Result(err),
+	})
+}
+
+// Log records op on path with its result
+func (a *Auditor) Log(op, path string, err error) {
+	a.log(op, path, "", 0, err)
+}
+
+// Rename records the rename of oldPath to newPath with its result
+func (a *Auditor) Rename(oldPath, newPath string, err error) {
+	a.log(audit.OpRename, oldPath, newPath, 0, err)
+}
+
+// Open records the opening of path with flags and its result. If
+// the open succeeded it returns h wrapped so the bytes read and
+// written are recorded when it is closed.
+//
+// Directories aren't recorded as opening them only lists them.
+func (a *Auditor) Open(path string, flags int, h Handle, err error) Handle {
+	if a == nil || (err == nil && h.Node().IsDir()) {
+		return h
+	}
+	a.Log(audit.OpOpen, path, err)
+	if err != nil {
+		return h
+	}
+	return &auditHandle{
+		Handle:  h,
+		auditor: a,
+		path:    path,
+		write:   flags&accessModeMask != os.O_RDONLY,
+	}
+}
+
+// auditHandle counts the bytes transferred through a Handle and
+// records them when it is closed
+type auditHandle struct {
+	Handle
+	auditor *Auditor
+	path    string
+	write   bool // set if opened for writing
+
+	mu      sync.Mutex
+	read    int64 // bytes read
+	written int64 // bytes written
+	done    bool  // set when the record has been written
+}
+
+// count adds n bytes read or written
+func (h *auditHandle) count(n int, write bool) {
+	if n <= 0 {
+		return
+	}
+	h.mu.Lock()
+	if write {
+		h.written += int64(n)
+	} else {
+		h.read += int64(n)
+	}
+	h.mu.Unlock()
+}
+
+// Read bytes from the handle counting them
+func (h *auditHandle) Read(b []byte) (n int, err error) {
+	n, err = h.Handle.Read(b)
+	h.count(n, false)
+	return n, err
+}
+
+// ReadAt bytes from the handle counting them
+func (h *auditHandle) ReadAt(b []byte, off int64) (n int, err error) {
+	n, err = h.Handle.ReadAt(b, off)
+	h.count(n, false)
+	return n, err
+}
+
+// Write bytes to the handle counting them
+func (h *auditHandle) Write(b []byte) (n int, err error) {
+	n, err = h.Handle.Write(b)
+	h.count(n, true)
+	return n, err
+}
+
+// WriteAt bytes to the handle counting them
+func (h *auditHandle) WriteAt(b []byte, off int64) (n int, err error) {
+	n, err = h.Handle.WriteAt(b, off)
+	h.count(n, true)
+	return n, err
+}
+
+// WriteString to the handle counting the bytes
+func (h *auditHandle) WriteString(s string) (n int, err error) {
+	n, err = h.Handle.WriteString(s)
+	h.count(n, true)
+	return n, err
+}
+
+// record writes the audit records for the handle the first time it
+// is called
+func (h *auditHandle) record(err error) {
+	h.mu.Lock()
+	if h.done {
+		h.mu.Unlock()
+		return
+	}
+	h.done = true
+	read, written := h.read, h.written
+	h.mu.Unlock()
+	if read > 0 || !h.write {
+		h.auditor.log(audit.OpRead, h.path, "", read, err)
+	}
+	if written > 0 || h.write {
+		h.auditor.log(audit.OpWrite, h.path, "", written, err)
+	}
+}
+
+// Close the handle recording the bytes transferred
+func (h *auditHandle) Close() error {
+	err := h.Handle.Close()
+	h.record(err)
+	return err
+}
+
+// Release the handle recording the bytes transferred
+func (h *auditHandle) Release() error {
+	err := h.Handle.Release()
+	h.record(err)
+	return err
+}
@@ -0,0 +1,95 @@
+package vfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/lib/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditorDisabled(t *testing.T) {
+	assert.Nil(t, NewAuditor("sftp", "user", "1.2.3.4:22"))
+
+	// a nil auditor does nothing
+	var a *Auditor
+	a.Log(audit.OpDelete, "file", nil)
+	a.Rename("a", "b", nil)
+	assert.Nil(t, a.Open("file", os.O_RDONLY, nil, errors.New("failed")))
+}
+
+func TestAuditor(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, audit.Start(&audit.Options{File: file}))
+	defer audit.Stop()
+
+	r, vfs, cleanup := newTestVFS(t)
+	defer cleanup()
+	r.WriteObject(context.Background(), "file", "hello world", t1)
+
+	a := NewAuditor("sftp", "user", "1.2.3.4:2022")
+	require.NotNil(t, a)
+
+	// read some of a file
+	h, err := vfs.OpenFile("file", os.O_RDONLY, 0)
+	h = a.Open("file", os.O_RDONLY, h, err)
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = h.Read(buf)
+	require.NoError(t, err)
+	_, err = h.ReadAt(buf[:3], 6)
+	require.NoError(t, err)
+	require.NoError(t, h.Close())
+	require.NoError(t, h.Release()) // already closed so not recorded again
+
+	// write a file
+	h, err = vfs.OpenFile("new", os.O_WRONLY|os.O_CREATE, 0666)
+	h = a.Open("new", os.O_WRONLY, h, err)
+	require.NoError(t, err)
+	_, err = h.WriteString("potato")
+	require.NoError(t, err)
+	require.NoError(t, h.Close())
+
+	// fail to open a file
+	h, err = vfs.OpenFile("missing", os.O_RDONLY, 0)
+	h = a.Open("missing", os.O_RDONLY, h, err)
+	assert.Error(t, err)
+	assert.Nil(t, h)
+
+	// directories aren't recorded
+	h, err = vfs.OpenFile("", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	assert.Equal(t, h, a.Open("", os.O_RDONLY, h, err))
+	require.NoError(t, h.Close())
+
+	a.Rename("new", "newer", vfs.Rename("new", "newer"))
+	a.Log(audit.OpDelete, "newer", vfs.Remove("newer"))
+	audit.Stop()
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec audit.Record
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		assert.Equal(t, "sftp", rec.Server)
+		assert.Equal(t, "user", rec.User)
+		assert.Equal(t, "1.2.3.4", rec.Client)
+		got = append(got, strings.Join([]string{rec.Op, rec.Path, rec.NewPath, rec.Result}, " ")+" "+strings.Repeat("*", int(rec.Bytes)))
+	}
+	assert.Equal(t, []string{
+		"open file  ok ",
+		"read file  ok ********",
+		"open new  ok ",
+		"write new  ok ******",
+		"open missing  file does not exist ",
+		"rename new newer ok ",
+		"delete newer  ok ",
+	}, got)
+}
@@ -450,12 +450,28 @@ func (d *Dir) _readDir() error {
 	} else {
 		return nil
 	}
-	entries, err := list.DirSorted(context.TODO(), d.f, false, d.path)
+	ctx := context.TODO()
+	entries, err := list.DirSorted(ctx, d.f, false, d.path)
 	if err == fs.ErrorDirNotFound {
 		// We treat directory not found as empty because we
 		// create directories on the fly
+		if d.vfs.dirStore != nil {
+			d.vfs.dirStore.remove(d.path)
+		}
 	} else if err != nil {
-		return err
+		// If the remote can't be reached use the persisted
+		// listing if there is one
+		if d.vfs.dirStore == nil {
+			return err
+		}
+		var found bool
+		entries, found = d.vfs.dirStore.load(d.path)
+		if !found {
+			return err
+		}
+		fs.Logf(d.path, "Using persisted directory listing as listing failed: %v", err)
+	} else if d.vfs.dirStore != nil {
+		d.vfs.dirStore.save(ctx, d.path, entries)
 	}
 
 	err = d._readDirFromEntries(entries, nil, time.Time{})
@@ -667,10 +683,16 @@ func (d *Dir) readDirTree() error {
 	d.mu.RUnlock()
 	when := time.Now()
 	fs.Debugf(path, "Reading directory tree")
-	dt, err := walk.NewDirTree(context.TODO(), f, path, false, -1)
+	ctx := context.TODO()
+	dt, err := walk.NewDirTree(ctx, f, path, false, -1)
 	if err != nil {
 		return err
 	}
+	if d.vfs.dirStore != nil {
+		for dir, entries := range dt {
+			d.vfs.dirStore.save(ctx, dir, entries)
+		}
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	d.read = time.Time{}
@@ -755,6 +777,9 @@ func (d *Dir) SetModTime(modTime time.Time) error {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err := d.vfs.access.check(d.path, vfscommon.PermWrite); err != nil {
+		return err
+	}
 	d.modTimeMu.Lock()
 	d.modTime = modTime
 	d.modTimeMu.Unlock()
@@ -796,6 +821,9 @@ func (d *Dir) cachedNode(relativePath string) Node {
 // Stat need not to handle the names "." and "..".
 func (d *Dir) Stat(name string) (node Node, err error) {
 	// fs.Debugf(path, "Dir.Stat")
+	if !d.vfs.access.visible(path.Join(d.path, name)) {
+		return nil, ENOENT
+	}
 	node, err = d.stat(name)
 	if err != nil {
 		if err != ENOENT {
@@ -821,6 +849,7 @@ func (d *Dir) ReadDirAll() (items Nodes, err error) {
 		items = append(items, item)
 	}
 	d.mu.Unlock()
+	items = d.vfs.access.filter(items)
 	sort.Sort(items)
 	// fs.Debugf(d.path, "Dir.ReadDirAll OK with %d entries", len(items))
 	return items, nil
@@ -872,6 +901,9 @@ func (d *Dir) Mkdir(name string) (*Dir, error) {
 		return nil, EROFS
 	}
 	path := path.Join(d.path, name)
+	if err := d.vfs.access.check(path, vfscommon.PermMkdir); err != nil {
+		return nil, err
+	}
 	node, err := d.stat(name)
 	switch err {
 	case ENOENT:
@@ -905,6 +937,9 @@ func (d *Dir) Remove() error {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err := d.vfs.access.check(d.path, vfscommon.PermDelete); err != nil {
+		return err
+	}
 	// Check directory is empty first
 	empty, err := d.isEmpty()
 	if err != nil {
@@ -933,6 +968,9 @@ func (d *Dir) RemoveAll() error {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err := d.vfs.access.check(d.path, vfscommon.PermDelete); err != nil {
+		return err
+	}
 	// Remove contents of the directory
 	nodes, err := d.ReadDirAll()
 	if err != nil {
@@ -978,6 +1016,12 @@ func (d *Dir) Rename(oldName, newName string, destDir *Dir) error {
 	}
 	oldPath := path.Join(d.path, oldName)
 	newPath := path.Join(destDir.path, newName)
+	if err := d.vfs.access.check(oldPath, vfscommon.PermDelete); err != nil {
+		return err
+	}
+	if err := d.vfs.access.check(newPath, vfscommon.PermWrite); err != nil {
+		return err
+	}
 	// fs.Debugf(oldPath, "Dir.Rename to %q", newPath)
 	oldNode, err := d.stat(oldName)
 	if err != nil {
@@ -1038,6 +1082,15 @@ func (d *Dir) Rename(oldName, newName string, destDir *Dir) error {
 	d.delObject(oldName)
 	destDir.addObject(oldNode)
 
+	// Move the usage between the quotas
+	if oldNode.IsFile() {
+		size := oldNode.Size()
+		d.vfs.access.changed(oldPath, -size, -1)
+		d.vfs.access.changed(newPath, size, 1)
+	} else {
+		d.vfs.access.recount()
+	}
+
 	// fs.Debugf(newPath, "Dir.Rename renamed from %q", oldPath)
 	// fs.Debugf(d, "AFTER\n%s", d.dump())
 	return nil
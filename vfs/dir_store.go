@@ -0,0 +1,296 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// dirStore persists the directory listings of the VFS to disk so
+// that the directory tree can be browsed when the remote can't be
+// reached.
+type dirStore struct {
+	f    fs.Fs
+	db   *kv.DB
+	root string
+}
+
+// storedEntry is a directory entry as persisted in the dirStore
+type storedEntry struct {
+	Name    string            `json:"name"`
+	IsDir   bool              `json:"isDir,omitempty"`
+	Size    int64             `json:"size"`
+	ModTime time.Time         `json:"modTime"`
+	Hashes  map[string]string `json:"hashes,omitempty"`
+}
+
+// newDirStore opens the dirStore for f
+func newDirStore(ctx context.Context, f fs.Fs) (*dirStore, error) {
+	db, err := kv.Start(ctx, "vfsdir", f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open directory store: %w", err)
+	}
+	return &dirStore{
+		f:    f,
+		db:   db,
+		root: f.Root(),
+	}, nil
+}
+
+// close the dirStore
+func (s *dirStore) close() {
+	_ = s.db.Stop(false)
+}
+
+// key makes the database key for the directory dir
+func (s *dirStore) key(dir string) []byte {
+	return []byte(path.Join(s.root, dir) + "/")
+}
+
+// kvGetDir: load a directory listing
+type kvGetDir struct {
+	key     []byte
+	entries []storedEntry
+	found   bool
+}
+
+func (op *kvGetDir) Do(ctx context.Context, b kv.Bucket) error {
+	data := b.Get(op.key)
+	if data == nil {
+		return nil
+	}
+	op.found = true
+	return json.Unmarshal(data, &op.entries)
+}
+
+// kvPutDir: save a directory listing
+type kvPutDir struct {
+	key     []byte
+	entries []storedEntry
+}
+
+func (op *kvPutDir) Do(ctx context.Context, b kv.Bucket) error {
+	data, err := json.Marshal(op.entries)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return b.Put(op.key, data)
+}
+
+// save the listing of dir
+//
+// Hashes are only saved if they are quick to read.
+func (s *dirStore) save(ctx context.Context, dir string, entries fs.DirEntries) {
+	var hashes hash.Set
+	if !s.f.Features().SlowHash {
+		hashes = s.f.Hashes()
+	}
+	stored := make([]storedEntry, 0, len(entries))
+	for _, entry := range entries {
+		se := storedEntry{
+			Name:    path.Base(entry.Remote()),
+			Size:    entry.Size(),
+			ModTime: entry.ModTime(ctx),
+		}
+		switch x := entry.(type) {
+		case fs.Directory:
+			se.IsDir = true
+		case fs.Object:
+			for _, hashType := range hashes.Array() {
+				sum, err := x.Hash(ctx, hashType)
+				if err == nil && sum != "" {
+					if se.Hashes == nil {
+						se.Hashes = make(map[string]string)
+					}
+					se.Hashes[hashType.String()] = sum
+				}
+			}
+		}
+		stored = append(stored, se)
+	}
+	err := s.db.Do(true, &kvPutDir{key: s.key(dir), entries: stored})
+	if err != nil {
+		fs.Debugf(dir, "vfs: failed to save directory listing: %v", err)
+	}
+}
+
+// kvRemoveDir: remove the listings of the directories whose keys start
+// with prefix
+type kvRemoveDir struct {
+	prefix []byte
+}
+
+func (op *kvRemoveDir) Do(ctx context.Context, b kv.Bucket) error {
+	var keys [][]byte
+	c := b.Cursor()
+	var key []byte
+	if len(op.prefix) == 0 {
+		key, _ = c.First()
+	} else {
+		key, _ = c.Seek(op.prefix)
+	}
+	for ; key != nil && bytes.HasPrefix(key, op.prefix); key, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	for _, key := range keys {
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove the listing of dir and of any directories below it, as when
+// dir has been found not to exist
+func (s *dirStore) remove(dir string) {
+	prefix := s.key(dir)
+	if path.Join(s.root, dir) == "" {
+		// everything is below the root
+		prefix = nil
+	}
+	err := s.db.Do(true, &kvRemoveDir{prefix: prefix})
+	if err != nil && err != kv.ErrEmpty {
+		fs.Debugf(dir, "vfs: failed to remove directory listing: %v", err)
+	}
+}
+
+// load the listing of dir saved with save returning false if there
+// isn't one
+func (s *dirStore) load(dir string) (entries fs.DirEntries, found bool) {
+	op := &kvGetDir{key: s.key(dir)}
+	err := s.db.Do(false, op)
+	if err != nil && err != kv.ErrEmpty {
+		fs.Debugf(dir, "vfs: failed to load directory listing: %v", err)
+	}
+	if err != nil || !op.found {
+		return nil, false
+	}
+	for _, se := range op.entries {
+		remote := path.Join(dir, se.Name)
+		if se.IsDir {
+			entries = append(entries, fs.NewDir(remote, se.ModTime).SetSize(se.Size))
+		} else {
+			entries = append(entries, &storedObject{
+				f:       s.f,
+				remote:  remote,
+				size:    se.Size,
+				modTime: se.ModTime,
+				hashes:  se.Hashes,
+			})
+		}
+	}
+	return entries, true
+}
+
+// errOffline is returned by a storedObject if the remote can't be reached
+var errOffline = errors.New("remote is offline: using persisted directory listing")
+
+// storedObject is an fs.Object read from the dirStore
+//
+// It reports the size, modification time and hashes the object had
+// when it was listed so any copy in the VFS cache can be used. Any
+// operation which needs the remote looks the object up again.
+type storedObject struct {
+	f       fs.Fs
+	remote  string
+	size    int64
+	modTime time.Time
+	hashes  map[string]string
+}
+
+// Fs returns the Fs the object came from
+func (o *storedObject) Fs() fs.Info {
+	return o.f
+}
+
+// String returns a description of the Object
+func (o *storedObject) String() string {
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *storedObject) Remote() string {
+	return o.remote
+}
+
+// ModTime returns the modification date of the file
+func (o *storedObject) ModTime(ctx context.Context) time.Time {
+	return o.modTime
+}
+
+// Size returns the size of the file
+func (o *storedObject) Size() int64 {
+	return o.size
+}
+
+// Hash returns the hash of the object as it was when listed
+func (o *storedObject) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	if sum, ok := o.hashes[ht.String()]; ok {
+		return sum, nil
+	}
+	return "", hash.ErrUnsupported
+}
+
+// Storable says whether this object can be stored
+func (o *storedObject) Storable() bool {
+	return true
+}
+
+// object looks up the real object on the remote
+func (o *storedObject) object(ctx context.Context) (fs.Object, error) {
+	obj, err := o.f.NewObject(ctx, o.remote)
+	if err == fs.ErrorObjectNotFound {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", errOffline, err)
+	}
+	return obj, nil
+}
+
+// SetModTime sets the modification time of the object on the remote
+func (o *storedObject) SetModTime(ctx context.Context, t time.Time) error {
+	obj, err := o.object(ctx)
+	if err != nil {
+		return err
+	}
+	return obj.SetModTime(ctx, t)
+}
+
+// Open the object on the remote for read
+func (o *storedObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	obj, err := o.object(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Open(ctx, options...)
+}
+
+// Update the object on the remote
+func (o *storedObject) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	obj, err := o.object(ctx)
+	if err != nil {
+		return err
+	}
+	return obj.Update(ctx, in, src, options...)
+}
+
+// Remove the object from the remote
+func (o *storedObject) Remove(ctx context.Context) error {
+	obj, err := o.object(ctx)
+	if err != nil {
+		return err
+	}
+	return obj.Remove(ctx)
+}
+
+// Check the interfaces are satisfied
+var _ fs.Object = (*storedObject)(nil)
@@ -0,0 +1,110 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/kv"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offlineFs is an fs.Fs which can't be listed when offline is set
+type offlineFs struct {
+	fs.Fs
+	offline bool
+}
+
+func (f *offlineFs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	if f.offline {
+		return nil, errors.New("network unreachable")
+	}
+	return f.Fs.List(ctx, dir)
+}
+
+func TestDirStoreOffline(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	r.CheckRemoteItems(t, file1)
+
+	f := &offlineFs{Fs: r.Fremote}
+	opt := vfscommon.DefaultOpt
+	opt.PersistDirCache = true
+	vfs := New(f, &opt)
+	defer cleanupVFS(t, vfs)
+	require.NotNil(t, vfs.dirStore)
+
+	checkDir := func() {
+		nodes, err := vfs.ReadDir("dir")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(nodes))
+		assert.Equal(t, "file1", nodes[0].Name())
+		assert.Equal(t, int64(14), nodes[0].Size())
+		assert.Equal(t, t1, nodes[0].ModTime().UTC())
+
+		root, err := vfs.ReadDir("")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(root))
+		assert.Equal(t, "dir", root[0].Name())
+		assert.True(t, root[0].IsDir())
+	}
+
+	// Read the listings while online to save them
+	checkDir()
+
+	// Now the remote is offline the saved listings are used
+	f.offline = true
+	vfs.FlushDirCache()
+	checkDir()
+}
+
+func TestDirStoreDirNotFound(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "dir/sub/file1", "file1 contents", t1)
+	r.CheckRemoteItems(t, file1)
+
+	opt := vfscommon.DefaultOpt
+	opt.PersistDirCache = true
+	vfs := New(r.Fremote, &opt)
+	defer cleanupVFS(t, vfs)
+	require.NotNil(t, vfs.dirStore)
+
+	// Read the listings while the directory exists to save them
+	_, err := vfs.ReadDir("dir/sub")
+	require.NoError(t, err)
+	for _, dir := range []string{"", "dir", "dir/sub"} {
+		_, found := vfs.dirStore.load(dir)
+		assert.True(t, found, dir)
+	}
+	node, err := vfs.Stat("dir")
+	require.NoError(t, err)
+	dir := node.(*Dir)
+
+	// Once the directory has gone its listings are removed
+	require.NoError(t, operations.Purge(ctx, r.Fremote, "dir"))
+	dir.ForgetAll()
+	nodes, err := dir.ReadDirAll()
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(nodes))
+	for _, dir := range []string{"dir", "dir/sub"} {
+		_, found := vfs.dirStore.load(dir)
+		assert.False(t, found, dir)
+	}
+	_, found := vfs.dirStore.load("")
+	assert.True(t, found)
+}
@@ -11,6 +11,7 @@ import (
 type Error byte
 
 // NB if changing errors translateError in cmd/mount/fs.go, cmd/cmount/fs.go
+// and nfsErrors in cmd/serve/nfs/nfs3.go
 
 // Low level errors
 const (
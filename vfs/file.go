@@ -374,6 +374,9 @@ func (f *File) SetModTime(modTime time.Time) error {
 	if f.d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err := f.d.vfs.access.check(f._path(), vfscommon.PermWrite); err != nil {
+		return err
+	}
 
 	f.pendingModTime = modTime
 
@@ -453,11 +456,18 @@ func (f *File) setSize(n int64) {
 // Update the object when written and add it to the directory
 func (f *File) setObject(o fs.Object) {
 	f.mu.Lock()
+	var oldSize, oldFiles int64
+	if f.o != nil {
+		oldSize, oldFiles = f.o.Size(), 1
+	}
 	f.o = o
 	_ = f._applyPendingModTime()
 	d := f.d
+	p := f._path()
 	f.mu.Unlock()
 
+	d.vfs.access.changed(p, o.Size()-oldSize, 1-oldFiles)
+
 	// Release File.mu before calling Dir method
 	d.addObject(f)
 }
@@ -580,6 +590,9 @@ func (f *File) Remove() (err error) {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	if err := d.vfs.access.check(f.Path(), vfscommon.PermDelete); err != nil {
+		return err
+	}
 
 	// Remove the object from the cache
 	wasWriting := false
@@ -594,7 +607,11 @@ func (f *File) Remove() (err error) {
 	f.muRW.Lock() // muRW must be locked before mu to avoid
 	f.mu.Lock()   // deadlock in RWFileHandle.openPending and .close
 	if f.o != nil {
+		size := f.o.Size()
 		err = f.o.Remove(context.TODO())
+		if err == nil {
+			d.vfs.access.changed(f._path(), -size, -1)
+		}
 	}
 	f.mu.Unlock()
 	f.muRW.Unlock()
@@ -702,6 +719,24 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 	f.mu.RLock()
 	d := f.d
 	f.mu.RUnlock()
+
+	// Check the access restrictions and quotas. Opening for read
+	// and write doesn't need read permission if there is nothing
+	// to read.
+	isNew := !f.exists()
+	if read && !(write && (isNew || flags&os.O_TRUNC != 0)) {
+		if err = d.vfs.access.check(f.Path(), vfscommon.PermRead); err != nil {
+			return nil, err
+		}
+	}
+	if write {
+		if err = d.vfs.access.check(f.Path(), vfscommon.PermWrite); err != nil {
+			return nil, err
+		}
+		if err = d.vfs.access.checkQuota(f.Path(), isNew); err != nil {
+			return nil, err
+		}
+	}
 	CacheMode := d.vfs.Opt.CacheMode
 	if CacheMode >= vfscommon.CacheModeMinimal && (d.vfs.cache.InUse(f.Path()) || d.vfs.cache.Exists(f.Path())) {
 		fd, err = f.openRW(flags)
@@ -735,6 +770,10 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 		// called without File.mu held
 		d.addObject(f)
 	}
+	// look for the next files to prefetch if reading the directory
+	if err == nil && read && !write {
+		d.vfs.prefetch.opened(d, f)
+	}
 	return fd, err
 }
 
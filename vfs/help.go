@@ -54,6 +54,21 @@ Or individual files or directories:
 
     rclone rc vfs/forget file=path/to/file dir=path/to/dir
 
+The directory cache is normally only kept in memory so nothing can be
+listed if the remote can't be reached, for example when a laptop is
+offline. If !--vfs-persist-dir-cache! is set then every directory
+listing (names, sizes, modification times and any hashes which are
+quick to read) is also saved in the cache directory. If listing a
+directory fails then the saved listing is used instead, so the
+directories which have been listed before can still be browsed. When
+used with !--vfs-cache-mode full! any files which are completely in
+the cache can still be read, and files written while offline are
+uploaded when the remote can be reached again. On remotes where hashes
+are slow to read (e.g. sftp) use !--vfs-fast-fingerprint! too,
+otherwise the cached files won't match the saved listing.
+
+    --vfs-persist-dir-cache     Save directory listings to disk so they can be browsed when the remote can't be reached
+
 ### VFS File Buffering
 
 The !--buffer-size! flag determines the amount of memory,
@@ -88,6 +103,8 @@ find that you need one or the other or both.
     --vfs-cache-max-age duration         Max age of objects in the cache (default 1h0m0s)
     --vfs-cache-max-size SizeSuffix      Max total size of objects in the cache (default off)
     --vfs-cache-poll-interval duration   Interval to poll the cache for stale objects (default 1m0s)
+    --vfs-cache-shared-max-age duration  Max age of objects in the cache shared by all the mounts in the process (0 is off)
+    --vfs-cache-shared-max-size SizeSuffix Max total size of objects in the cache shared by all the mounts in the process (default off)
     --vfs-write-back duration            Time to writeback files after last use when using cache (default 5s)
 
 If run with !-vv! rclone will print the location of the file cache.  The
@@ -110,6 +127,19 @@ for two reasons.  Firstly because it is only checked every
 !--vfs-cache-poll-interval!.  Secondly because open files cannot be
 evicted from the cache.
 
+Each VFS has its own cache and its own !--vfs-cache-max-size!, so
+when rclone is running several mounts (for example with the
+!mount/mount! rc call) the caches can together use much more space.
+To limit the total, set !--vfs-cache-shared-max-size! and/or
+!--vfs-cache-shared-max-age! on each of the mounts. All the caches in
+the process with these set share one size and age budget. When the
+total size is over the limit the least recently used files which
+aren't open are evicted, whichever mount they belong to, so a busy
+mount can use the space an idle one isn't. If the mounts are given
+different limits then the smallest is used. The per mount limits are
+still enforced as well. The usage of the shared cache is shown in the
+!diskCache.shared! section of the !vfs/stats! rc call.
+
 You **should not** run two copies of rclone using the same VFS cache
 with the same or overlapping remotes if using !--vfs-cache-mode > off!.
 This can potentially cause data corruption if you do. You can work
@@ -179,6 +209,24 @@ whereas the !--vfs-read-ahead! is buffered on disk.
 When using this mode it is recommended that !--buffer-size! is not set
 too large and !--vfs-read-ahead! is set large if required.
 
+If !--vfs-prefetch-budget! is set then rclone will also prefetch data
+it expects to be read soon into the cache in the background.
+
+    --vfs-prefetch-budget SizeSuffix     Max bytes to prefetch for sequential reads when using cache-mode full (default off)
+    --vfs-prefetch-files int             Number of files to prefetch when a directory is read in order (default 4)
+
+When a file is read sequentially, for example when playing a video,
+the read ahead starts at 1 MiB and doubles with each sequential read
+up to !--vfs-prefetch-budget!. Seeking elsewhere in the file resets
+it.
+
+When the files in a directory are opened one after another in listing
+order, for example by a media scanner or !tar!, rclone starts
+downloading the next !--vfs-prefetch-files! files in the directory so
+opening them doesn't have to wait for the remote. Up to
+!--vfs-prefetch-budget! bytes in total are fetched from the start of
+those files.
+
 **IMPORTANT** not all file systems support sparse files. In particular
 FAT/exFAT do not. Rclone will perform very badly if the cache
 directory is on a filesystem which doesn't support sparse files and it
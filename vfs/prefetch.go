@@ -0,0 +1,216 @@
+package vfs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// number of files which must be opened in listing order before the
+// next files are prefetched
+const minSequentialOpens = 2
+
+// maximum number of opens waiting to be looked at - any more are
+// ignored
+const maxPrefetchQueue = 64
+
+// maximum number of directory indexes to keep
+const maxDirIndexes = 16
+
+// prefetcher spots the files in a directory being opened in listing
+// order, as a media scanner or tar would, and downloads the start of
+// the next files into the cache in the background so they are ready
+// when they are opened.
+//
+// The opens are looked at in order by a background go-routine so
+// opening a file doesn't wait for the directory to be listed.
+type prefetcher struct {
+	vfs *VFS
+
+	mu       sync.Mutex
+	queue    []prefetchOpen      // opens waiting to be looked at
+	running  bool                // set if the go-routine looking at queue is running
+	inFlight map[string]struct{} // paths being prefetched
+
+	// only used by the go-routine looking at queue
+	lastDir   *Dir               // directory of the last file opened
+	lastIndex int                // index of the last file opened in lastDir
+	run       int                // number of files opened in order
+	indexes   map[*Dir]*dirIndex // files in listing order for each directory
+}
+
+// prefetchOpen is a file opened for reading
+type prefetchOpen struct {
+	d *Dir
+	f *File
+}
+
+// dirIndex is the files of a directory in listing order
+type dirIndex struct {
+	read  time.Time     // when the directory listing was read
+	files []*File       // files in listing order
+	index map[*File]int // index of each file in files
+}
+
+// newPrefetcher makes a prefetcher for vfs
+func newPrefetcher(vfs *VFS) *prefetcher {
+	return &prefetcher{
+		vfs:      vfs,
+		inFlight: make(map[string]struct{}),
+		indexes:  make(map[*Dir]*dirIndex),
+	}
+}
+
+// opened should be called when f in d has been opened for reading
+func (p *prefetcher) opened(d *Dir, f *File) {
+	if p.vfs.Opt.CacheMode < vfscommon.CacheModeFull || p.vfs.Opt.PrefetchBudget <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) >= maxPrefetchQueue {
+		return
+	}
+	p.queue = append(p.queue, prefetchOpen{d: d, f: f})
+	if !p.running {
+		p.running = true
+		go p.lookAtQueue()
+	}
+}
+
+// lookAtQueue looks at the opens in the queue in order until it is
+// empty
+func (p *prefetcher) lookAtQueue() {
+	for {
+		p.mu.Lock()
+		if len(p.queue) == 0 {
+			p.running = false
+			p.mu.Unlock()
+			return
+		}
+		open := p.queue[0]
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+		p.lookAhead(open.d, open.f)
+	}
+}
+
+// dirIndex returns the index of the files in d, listing the directory
+// again if it has been read since the index was made or f isn't in it
+func (p *prefetcher) dirIndex(d *Dir, f *File) *dirIndex {
+	d.mu.RLock()
+	read := d.read
+	d.mu.RUnlock()
+	idx := p.indexes[d]
+	if idx != nil && idx.read.Equal(read) {
+		if _, found := idx.index[f]; found {
+			return idx
+		}
+	}
+	nodes, err := d.ReadDirAll()
+	if err != nil {
+		return nil
+	}
+	d.mu.RLock()
+	read = d.read
+	d.mu.RUnlock()
+	idx = &dirIndex{
+		read:  read,
+		index: make(map[*File]int, len(nodes)),
+	}
+	for _, node := range nodes {
+		if file, ok := node.(*File); ok {
+			idx.index[file] = len(idx.files)
+			idx.files = append(idx.files, file)
+		}
+	}
+	if len(p.indexes) >= maxDirIndexes {
+		p.indexes = make(map[*Dir]*dirIndex)
+	}
+	p.indexes[d] = idx
+	return idx
+}
+
+// lookAhead prefetches the files after f in d if the files in d are
+// being opened in listing order
+func (p *prefetcher) lookAhead(d *Dir, f *File) {
+	idx := p.dirIndex(d, f)
+	if idx == nil {
+		return
+	}
+	index, found := idx.index[f]
+	if !found {
+		return
+	}
+
+	switch {
+	case p.lastDir == d && index == p.lastIndex+1:
+		p.run++
+	case p.lastDir == d && index == p.lastIndex:
+		// reopening the same file doesn't break the run
+	default:
+		p.run = 1
+	}
+	p.lastDir, p.lastIndex = d, index
+	if p.run < minSequentialOpens {
+		return
+	}
+
+	// Prefetch the next files while the budget lasts
+	budget := int64(p.vfs.Opt.PrefetchBudget)
+	for i, file := range idx.files[index+1:] {
+		if i >= p.vfs.Opt.PrefetchFiles || budget <= 0 {
+			break
+		}
+		size := file.Size()
+		if size > budget {
+			size = budget
+		}
+		budget -= size
+		p.prefetch(file, size)
+	}
+}
+
+// prefetch the first size bytes of file in the background unless it
+// is being prefetched already
+func (p *prefetcher) prefetch(file *File, size int64) {
+	o := file.getObject()
+	cache := p.vfs.cache
+	if o == nil || cache == nil || size <= 0 {
+		return
+	}
+	path := file.Path()
+	p.mu.Lock()
+	if _, found := p.inFlight[path]; found {
+		p.mu.Unlock()
+		return
+	}
+	p.inFlight[path] = struct{}{}
+	p.mu.Unlock()
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.inFlight, path)
+			p.mu.Unlock()
+		}()
+		fs.Debugf(path, "vfs prefetch: fetching first %v", fs.SizeSuffix(size))
+		err := cache.Item(path).Prefetch(o, size)
+		if err != nil {
+			fs.Debugf(path, "vfs prefetch: failed: %v", err)
+		}
+	}()
+}
+
+// inProgress returns the number of opens waiting to be looked at and
+// prefetches in progress
+func (p *prefetcher) inProgress() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.queue) + len(p.inFlight)
+	if p.running {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,75 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchDirectory(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.CacheMode = vfscommon.CacheModeFull
+	opt.WriteBack = writeBackDelay
+	opt.PrefetchBudget = 10
+	opt.PrefetchFiles = 2
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+
+	ctx := context.Background()
+	var items []fstest.Item
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		items = append(items, r.WriteObject(ctx, "dir/"+name, "0123456"+name, t1))
+	}
+	r.CheckRemoteItems(t, items...)
+
+	// check which files are in the cache
+	cached := func() (out []string) {
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			if vfs.cache.Item("dir/" + name).HasRange(ranges.Range{Pos: 0, Size: 1}) {
+				out = append(out, name)
+			}
+		}
+		return out
+	}
+	open := func(name string) {
+		fd, err := vfs.OpenFile("dir/"+name, os.O_RDONLY, 0)
+		require.NoError(t, err)
+		buf := make([]byte, 8)
+		_, err = fd.Read(buf)
+		require.NoError(t, err)
+		require.NoError(t, fd.Close())
+		for i := 0; i < 100 && vfs.prefetch.inProgress() > 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, 0, vfs.prefetch.inProgress())
+	}
+
+	// Opening one file doesn't prefetch anything
+	open("a")
+	assert.Equal(t, []string{"a"}, cached())
+
+	// Opening the next one prefetches the next two files with
+	// the first getting most of the budget
+	open("b")
+	assert.Equal(t, []string{"a", "b", "c", "d"}, cached())
+	assert.True(t, vfs.cache.Item("dir/c").HasRange(ranges.Range{Pos: 0, Size: 8}))
+	assert.True(t, vfs.cache.Item("dir/d").HasRange(ranges.Range{Pos: 0, Size: 2}))
+
+	// The index of the directory is kept until it is listed again
+	node, err := vfs.Stat("dir")
+	require.NoError(t, err)
+	dir := node.(*Dir)
+	idx := vfs.prefetch.indexes[dir]
+	require.NotNil(t, idx)
+	assert.Equal(t, 5, len(idx.files))
+	open("c")
+	assert.True(t, idx == vfs.prefetch.indexes[dir])
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, cached())
+}
@@ -407,6 +407,16 @@ This returns stats for the selected VFS.
             "outOfSpace": false,
             "path": "/home/user/.cache/rclone/vfs/local/mnt/a",
             "pathMeta": "/home/user/.cache/rclone/vfsMeta/local/mnt/a",
+            // Status of the shared cache - only present if
+            // --vfs-cache-shared-max-size or --vfs-cache-shared-max-age set
+            "shared": {
+                "bytesUsed": 0,    // total size of all the shared caches
+                "caches": 2,       // number of caches sharing
+                "evictedBytes": 0, // bytes evicted from this cache
+                "evictedFiles": 0, // files evicted from this cache
+                "maxAge": 0,       // shared max age in seconds
+                "maxSize": 0       // shared max size in bytes
+            },
             "uploadsInProgress": 0,
             "uploadsQueued": 0
         },
@@ -80,7 +80,8 @@ func (fh *ReadFileHandle) openPending() (err error) {
 	}
 	tr := accounting.GlobalStats().NewTransfer(o)
 	fh.done = tr.Done
-	fh.r = tr.Account(context.TODO(), r).WithBuffer() // account the transfer
+	ctx := accounting.WithRemoteBwLimit(context.TODO(), o.Fs(), nil)
+	fh.r = tr.Account(ctx, r).WithBuffer() // account the transfer
 	fh.opened = true
 
 	return nil
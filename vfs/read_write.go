@@ -22,6 +22,8 @@ type RWFileHandle struct {
 	flags int            // open flags
 	item  *vfscache.Item // cached file item
 
+	sizeLimit int64 // size the file may grow to or -1 if unlimited
+
 	// read write variables protected by mutex
 	mu          sync.Mutex
 	offset      int64 // file pointer offset
@@ -43,10 +45,14 @@ func newRWFileHandle(d *Dir, f *File, flags int) (fh *RWFileHandle, err error) {
 	}
 
 	fh = &RWFileHandle{
-		file:  f,
-		d:     d,
-		flags: flags,
-		item:  item,
+		file:      f,
+		d:         d,
+		flags:     flags,
+		item:      item,
+		sizeLimit: -1,
+	}
+	if !fh.readOnly() {
+		fh.sizeLimit = d.vfs.access.sizeLimit(f.Path(), f.Size())
 	}
 
 	// truncate immediately if O_TRUNC is set or O_CREATE is set and file doesn't exist
@@ -334,6 +340,9 @@ func (fh *RWFileHandle) _writeAt(b []byte, off int64, release bool) (n int, err
 		fh.offset = size
 		off = fh.offset
 	}
+	if err = checkSize(fh.logPrefix(), fh.sizeLimit, off+int64(len(b))); err != nil {
+		return n, err
+	}
 	fh.writeCalled = true
 	if release {
 		// Do the writing with fh.mu unlocked
@@ -383,6 +392,9 @@ func (fh *RWFileHandle) _truncate(size int64) (err error) {
 	if size == fh._size() {
 		return nil
 	}
+	if err = checkSize(fh.logPrefix(), fh.sizeLimit, size); err != nil {
+		return err
+	}
 	fh.file.setSize(size)
 	return fh.item.Truncate(size)
 }
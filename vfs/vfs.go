@@ -169,6 +169,9 @@ type VFS struct {
 	usage       *fs.Usage
 	pollChan    chan time.Duration
 	inUse       int32 // count of number of opens accessed with atomic
+	prefetch    *prefetcher
+	dirStore    *dirStore      // persisted directory listings - may be nil
+	access      *accessControl // access restrictions - may be nil
 }
 
 // Keep track of active VFS keyed on fs.ConfigString(f)
@@ -214,9 +217,25 @@ func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 	// Put the VFS into the active cache
 	active[configName] = append(active[configName], vfs)
 
+	// Open the store for the directory listings if required
+	if vfs.Opt.PersistDirCache {
+		store, err := newDirStore(context.TODO(), f)
+		if err != nil {
+			fs.Errorf(f, "Failed to persist directory cache - disabling: %v", err)
+		} else {
+			vfs.dirStore = store
+		}
+	}
+
+	// Enforce any access restrictions
+	vfs.access = newAccessControl(vfs)
+
 	// Create root directory
 	vfs.root = newDir(vfs, f, nil, fsDir)
 
+	// Create the prefetcher for sequential reads of directories
+	vfs.prefetch = newPrefetcher(vfs)
+
 	// Start polling function
 	features := vfs.f.Features()
 	if do := features.ChangeNotify; do != nil {
@@ -335,6 +354,10 @@ func (vfs *VFS) Shutdown() {
 	activeMu.Unlock()
 
 	vfs.shutdownCache()
+
+	if vfs.dirStore != nil {
+		vfs.dirStore.close()
+	}
 }
 
 // CleanUp deletes the contents of the on disk cache
@@ -621,6 +644,20 @@ func (vfs *VFS) Statfs() (total, used, free int64) {
 		}
 	}
 	total, used, free = fillInMissingSizes(total, used, free, unknownFreeBytes)
+	// Report the size quota as the size of the disk
+	if vfs.access != nil && vfs.access.access.MaxSize > 0 {
+		bytes, _, err := vfs.access.usage()
+		if err != nil {
+			fs.Errorf(vfs.f, "Statfs failed to count quota usage: %v", err)
+			return
+		}
+		total = int64(vfs.access.access.MaxSize)
+		used = bytes
+		free = total - used
+		if free < 0 {
+			free = 0
+		}
+	}
 	return
 }
 
@@ -143,6 +143,15 @@ func New(ctx context.Context, fremote fs.Fs, opt *vfscommon.Options, avFn AddVir
 
 	go c.cleaner(ctx)
 
+	// Share the size and age budget with the other caches if required
+	if usesShared(opt) {
+		shared.register(c)
+		go func() {
+			<-ctx.Done()
+			shared.unregister(c)
+		}()
+	}
+
 	return c, nil
 }
 
@@ -159,12 +168,16 @@ func (c *Cache) Stats() (out rc.Params) {
 	out["uploadsQueued"] = uploadsQueued
 
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	out["files"] = len(c.item)
 	out["erroredFiles"] = len(c.errItems)
 	out["bytesUsed"] = c.used
 	out["outOfSpace"] = c.outOfSpace
+	c.mu.Unlock()
+
+	// must be called without c.mu held
+	if sharedStats := shared.stats(c); sharedStats != nil {
+		out["shared"] = sharedStats
+	}
 
 	return out
 }
@@ -546,6 +559,9 @@ func (c *Cache) KickCleaner() {
 	   cache mutex to avoid letting a thread kick again after the clearer just
 	   finished cleaning and unlock the cache mutex. */
 	fs.Debugf(nil, "vfs cache: at the beginning of KickCleaner")
+	if usesShared(c.opt) {
+		shared.kickCleaner()
+	}
 	c.kickerMu.Lock()
 	if !c.cleanerKicked {
 		c.cleanerKicked = true
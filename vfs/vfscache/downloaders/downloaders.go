@@ -30,6 +30,8 @@ const (
 	// If a downloader is within this range or --buffer-size
 	// whichever is the larger, we will reuse the downloader
 	minWindow = 1024 * 1024
+	// number of sequential reads before the read ahead is increased
+	minSequentialReads = 2
 )
 
 // Item is the interface that an item to download must obey
@@ -71,6 +73,9 @@ type Downloaders struct {
 	waiters    []waiter
 	errorCount int   // number of consecutive errors
 	lastErr    error // last error received
+	seqEnd     int64 // end of the last range asked for
+	seqReads   int   // number of sequential reads asked for
+	prefetch   int64 // current adaptive read ahead
 }
 
 // waiter is a range we are waiting for and a channel to signal when
@@ -258,6 +263,7 @@ func (dls *Downloaders) Download(r ranges.Range) (err error) {
 		errChan: errChan,
 	}
 
+	dls._updatePrefetch(r)
 	err = dls._ensureDownloader(r)
 	if err != nil {
 		dls.mu.Unlock()
@@ -294,6 +300,9 @@ func (dls *Downloaders) _ensureDownloader(r ranges.Range) (err error) {
 		r.Size += int64(dls.opt.ReadAhead)
 	}
 
+	// Increase it further if the file is being read sequentially
+	r.Size += dls.prefetch
+
 	// We may be reopening a downloader after a failure here or
 	// doing a tentative prefetch so check to see that we haven't
 	// read some stuff already.
@@ -374,9 +383,44 @@ func (dls *Downloaders) _ensureDownloader(r ranges.Range) (err error) {
 func (dls *Downloaders) EnsureDownloader(r ranges.Range) (err error) {
 	dls.mu.Lock()
 	defer dls.mu.Unlock()
+	dls._updatePrefetch(r)
 	return dls._ensureDownloader(r)
 }
 
+// _updatePrefetch adjusts the adaptive read ahead for a read of r
+//
+// Once the reads have been sequential for long enough the read ahead
+// starts at minWindow and doubles with each sequential read up to
+// --vfs-prefetch-budget. Any other read turns it off again.
+//
+// call with lock held
+func (dls *Downloaders) _updatePrefetch(r ranges.Range) {
+	budget := int64(dls.opt.PrefetchBudget)
+	if budget <= 0 {
+		return
+	}
+	// Allow small skips forward as readers don't always read
+	// every byte
+	if r.Pos >= dls.seqEnd && r.Pos <= dls.seqEnd+minWindow {
+		dls.seqReads++
+	} else {
+		dls.seqReads = 0
+		dls.prefetch = 0
+	}
+	dls.seqEnd = r.End()
+	if dls.seqReads < minSequentialReads {
+		return
+	}
+	if dls.prefetch == 0 {
+		dls.prefetch = minWindow
+	} else {
+		dls.prefetch *= 2
+	}
+	if dls.prefetch > budget {
+		dls.prefetch = budget
+	}
+}
+
 // _dispatchWaiters() sends any waiters which have completed back to
 // their callers.
 //
@@ -129,4 +129,29 @@ func TestDownloaders(t *testing.T) {
 		time.Sleep(time.Second)
 		assert.True(t, item.HasRange(r))
 	})
+
+	t.Run("Prefetch", func(t *testing.T) {
+		_, dls := newTest()
+		defer cancel(dls)
+		dls.opt.PrefetchBudget = 3 * minWindow
+		for _, test := range []struct {
+			r    ranges.Range
+			want int64
+		}{
+			{r: ranges.Range{Pos: 0, Size: 100}, want: 0},
+			{r: ranges.Range{Pos: 100, Size: 100}, want: minWindow},
+			{r: ranges.Range{Pos: 250, Size: 100}, want: 2 * minWindow},
+			{r: ranges.Range{Pos: 350, Size: 100}, want: 3 * minWindow},
+			{r: ranges.Range{Pos: 450, Size: 100}, want: 3 * minWindow},
+			{r: ranges.Range{Pos: 25000000, Size: 100}, want: 0},
+			{r: ranges.Range{Pos: 25000100, Size: 100}, want: 0},
+			{r: ranges.Range{Pos: 25000200, Size: 100}, want: minWindow},
+		} {
+			dls.mu.Lock()
+			dls._updatePrefetch(test.r)
+			got := dls.prefetch
+			dls.mu.Unlock()
+			assert.Equal(t, test.want, got, test.r)
+		}
+	})
 }
@@ -1255,6 +1255,29 @@ func (item *Item) readAt(b []byte, off int64) (n int, err error) {
 	return n, err
 }
 
+// Prefetch downloads the first size bytes of o into the cache file
+// so they are ready when the file is opened, returning when they have
+// been downloaded.
+func (item *Item) Prefetch(o fs.Object, size int64) (err error) {
+	if size <= 0 {
+		return nil
+	}
+	err = item.Open(o)
+	if err != nil {
+		return err
+	}
+	item.preAccess()
+	item.mu.Lock()
+	err = item._ensure(0, size)
+	item.mu.Unlock()
+	item.postAccess()
+	closeErr := item.Close(nil)
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // WriteAt bytes to the file at off
 func (item *Item) WriteAt(b []byte, off int64) (n int, err error) {
 	item.preAccess()
@@ -0,0 +1,237 @@
+package vfscache
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// sharedCache enforces a single size and age budget on all the
+// Caches in the process which have --vfs-cache-shared-max-size or
+// --vfs-cache-shared-max-age set.
+//
+// Each Cache still enforces its own limits. The shared cleaner
+// removes the least recently used items which are not in use across
+// all the Caches until the total is below the shared quota, so a
+// busy mount can use the space an idle one isn't.
+//
+// Lock ordering is sharedCache.mu then Cache.mu then Item.mu
+type sharedCache struct {
+	mu      sync.Mutex
+	caches  map[*Cache]*sharedStats // registered caches
+	kick    chan struct{}           // kick the cleaner to run now
+	stop    chan struct{}           // closed to stop the cleaner
+	running bool                    // set if the cleaner is running
+}
+
+// sharedStats are the stats kept for each Cache by the shared cleaner
+type sharedStats struct {
+	evictedFiles int   // number of items removed by the shared cleaner
+	evictedBytes int64 // bytes freed by the shared cleaner
+}
+
+// shared is the process wide sharedCache
+var shared = &sharedCache{
+	caches: make(map[*Cache]*sharedStats),
+}
+
+// usesShared returns true if the options ask for the Cache to use the
+// shared budget
+func usesShared(opt *vfscommon.Options) bool {
+	return opt.CacheSharedMaxSize > 0 || opt.CacheSharedMaxAge > 0
+}
+
+// register c with the shared cleaner, starting it if necessary
+func (s *sharedCache) register(c *Cache) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caches[c] = &sharedStats{}
+	fs.Debugf(nil, "vfs cache: %q using shared cache, %d caches sharing", c.root, len(s.caches))
+	if !s.running && c.opt.CachePollInterval > 0 {
+		s.running = true
+		s.kick = make(chan struct{}, 1)
+		s.stop = make(chan struct{})
+		go s.cleaner(s.kick, s.stop)
+	}
+}
+
+// unregister c from the shared cleaner, stopping it if no Caches
+// are left
+func (s *sharedCache) unregister(c *Cache) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.caches, c)
+	if len(s.caches) == 0 && s.running {
+		s.running = false
+		close(s.stop)
+	}
+}
+
+// _limits returns the quota and maximum age to use
+//
+// If the registered Caches have different limits then the smallest
+// is used. Call with s.mu held.
+func (s *sharedCache) _limits() (quota int64, maxAge time.Duration, interval time.Duration) {
+	for c := range s.caches {
+		if size := int64(c.opt.CacheSharedMaxSize); size > 0 && (quota <= 0 || size < quota) {
+			quota = size
+		}
+		if age := c.opt.CacheSharedMaxAge; age > 0 && (maxAge <= 0 || age < maxAge) {
+			maxAge = age
+		}
+		if poll := c.opt.CachePollInterval; poll > 0 && (interval <= 0 || poll < interval) {
+			interval = poll
+		}
+	}
+	return quota, maxAge, interval
+}
+
+// kickCleaner kicks the shared cleaner to run now if it is running
+func (s *sharedCache) kickCleaner() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// sharedItem is an Item which the shared cleaner could remove
+type sharedItem struct {
+	c     *Cache
+	item  *Item
+	aTime time.Time
+}
+
+// clean removes items over the shared age and then removes the least
+// recently used items not in use across all the Caches until the
+// total size is below the shared quota
+func (s *sharedCache) clean() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quota, maxAge, _ := s._limits()
+
+	if maxAge > 0 {
+		for c := range s.caches {
+			c.mu.Lock()
+			for _, item := range c.item {
+				s._evict(c, item, maxAge)
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	if quota <= 0 {
+		return
+	}
+	var (
+		used  int64
+		items []sharedItem
+	)
+	for c := range s.caches {
+		c.updateUsed()
+		c.mu.Lock()
+		used += c.used
+		for _, item := range c.item {
+			if !item.inUse() {
+				items = append(items, sharedItem{c: c, item: item, aTime: item.getATime()})
+			}
+		}
+		c.mu.Unlock()
+	}
+	if used < quota {
+		return
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].aTime.Before(items[j].aTime)
+	})
+	oldUsed := used
+	for _, it := range items {
+		if used < quota {
+			break
+		}
+		c := it.c
+		c.mu.Lock()
+		// check the item wasn't removed while the lock was released
+		if c.item[it.item.name] == it.item {
+			used -= s._evict(c, it.item, 0)
+		}
+		c.mu.Unlock()
+	}
+	fs.Infof(nil, "vfs cache: shared cache cleaned: %d caches, total size %v (was %v), quota %v",
+		len(s.caches), fs.SizeSuffix(used), fs.SizeSuffix(oldUsed), fs.SizeSuffix(quota))
+}
+
+// _evict removes item from c if it isn't in use and is older than
+// maxAge (or any age if maxAge is 0) returning the space freed
+//
+// Call with s.mu and c.mu held.
+func (s *sharedCache) _evict(c *Cache, item *Item, maxAge time.Duration) (freed int64) {
+	before := c.used
+	c.removeNotInUse(item, maxAge, false)
+	freed = before - c.used
+	stats := s.caches[c]
+	if _, found := c.item[item.name]; !found {
+		stats.evictedFiles++
+	}
+	stats.evictedBytes += freed
+	return freed
+}
+
+// cleaner calls clean at regular intervals and when kicked
+//
+// It doesn't return until stop is closed.
+func (s *sharedCache) cleaner(kick, stop chan struct{}) {
+	for {
+		s.mu.Lock()
+		_, _, interval := s._limits()
+		s.mu.Unlock()
+		if interval <= 0 {
+			interval = vfscommon.DefaultOpt.CachePollInterval
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-kick:
+			timer.Stop()
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			fs.Debugf(nil, "vfs cache: shared cache cleaner exiting")
+			return
+		}
+		s.clean()
+	}
+}
+
+// stats returns info about the shared cache as seen by c or nil if
+// c isn't using it
+func (s *sharedCache) stats(c *Cache) (out rc.Params) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cStats, ok := s.caches[c]
+	if !ok {
+		return nil
+	}
+	quota, maxAge, _ := s._limits()
+	var used int64
+	for other := range s.caches {
+		other.mu.Lock()
+		used += other.used
+		other.mu.Unlock()
+	}
+	out = make(rc.Params)
+	out["maxSize"] = quota
+	out["maxAge"] = maxAge.Seconds()
+	out["caches"] = len(s.caches)
+	out["bytesUsed"] = used
+	out["evictedFiles"] = cStats.evictedFiles
+	out["evictedBytes"] = cStats.evictedBytes
+	return out
+}
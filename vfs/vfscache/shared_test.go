@@ -0,0 +1,78 @@
+package vfscache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSharedCache(t *testing.T) (c *Cache, cleanup func()) {
+	opt := vfscommon.DefaultOpt
+
+	// Disable the cache cleaners as they interfere with these tests
+	opt.CachePollInterval = 0
+
+	// Disable synchronous write
+	opt.WriteBack = 0
+
+	opt.CacheSharedMaxSize = 13
+	_, c, cleanup = newTestCacheOpt(t, opt)
+	return c, cleanup
+}
+
+func TestSharedCachePurgeOverQuota(t *testing.T) {
+	c1, cleanup1 := newTestSharedCache(t)
+	defer cleanup1()
+	c2, cleanup2 := newTestSharedCache(t)
+	defer cleanup2()
+
+	shared.mu.Lock()
+	assert.Contains(t, shared.caches, c1)
+	assert.Contains(t, shared.caches, c2)
+	shared.mu.Unlock()
+
+	// Make some test files with potato the oldest
+	potato := c1.Item("potato")
+	itemWrite(t, potato, "hello")
+	require.NoError(t, potato.Close(nil))
+	potato2 := c2.Item("potato2")
+	itemWrite(t, potato2, "hello2")
+	require.NoError(t, potato2.Close(nil))
+	potato3 := c2.Item("potato3")
+	itemWrite(t, potato3, "hello3")
+
+	t1 := time.Now().Add(10 * time.Second)
+	potato.info.ATime = t1
+	potato2.info.ATime = t1.Add(10 * time.Second)
+
+	// Each cache is under the quota but together they are over it
+	// so the oldest item which is not in use is removed
+	shared.clean()
+	assert.Equal(t, []string(nil), itemAsString(c1))
+	assert.Equal(t, []string{
+		`name="potato2" opens=0 size=6`,
+		`name="potato3" opens=1 size=6`,
+	}, itemAsString(c2))
+
+	// Check the stats
+	stats, ok := c1.Stats()["shared"].(rc.Params)
+	require.True(t, ok)
+	assert.Equal(t, int64(13), stats["maxSize"])
+	assert.Equal(t, 2, stats["caches"])
+	assert.Equal(t, int64(12), stats["bytesUsed"])
+	assert.Equal(t, 1, stats["evictedFiles"])
+	assert.Equal(t, int64(5), stats["evictedBytes"])
+
+	// The smallest quota is used and potato3 is in use so only
+	// potato2 can be removed
+	c2.opt.CacheSharedMaxSize = 1
+	shared.clean()
+	assert.Equal(t, []string{
+		`name="potato3" opens=1 size=6`,
+	}, itemAsString(c2))
+	require.NoError(t, potato3.Close(nil))
+}
@@ -0,0 +1,165 @@
+package vfscommon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Perm is a set of permissions granted by an AccessRule
+type Perm uint8
+
+// Permissions which can be granted
+const (
+	PermRead   Perm = 1 << iota // read files and list directories
+	PermWrite                   // create and overwrite files and set modification times
+	PermDelete                  // remove files and directories and move them away
+	PermMkdir                   // make directories
+	PermNone   Perm = 0
+	PermAll         = PermRead | PermWrite | PermDelete | PermMkdir
+)
+
+// permLetters are the letters used to write Perm in order
+const permLetters = "rwdm"
+
+// String turns the permissions into letters, e.g. "rw"
+func (p Perm) String() string {
+	var out strings.Builder
+	for i := range permLetters {
+		if p&(1<<i) != 0 {
+			out.WriteByte(permLetters[i])
+		}
+	}
+	if out.Len() == 0 {
+		return "-"
+	}
+	return out.String()
+}
+
+// Set the permissions from letters, e.g. "rwdm" or "-" for none
+func (p *Perm) Set(s string) error {
+	var perm Perm
+	for _, c := range s {
+		if c == '-' {
+			continue
+		}
+		i := strings.IndexRune(permLetters, c)
+		if i < 0 {
+			return fmt.Errorf("unknown permission %q in %q: use letters from %q", c, s, permLetters)
+		}
+		perm |= 1 << i
+	}
+	*p = perm
+	return nil
+}
+
+// MarshalJSON encodes the permissions as letters
+func (p Perm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON decodes the permissions from letters
+func (p *Perm) UnmarshalJSON(in []byte) error {
+	var s string
+	err := json.Unmarshal(in, &s)
+	if err != nil {
+		return err
+	}
+	return p.Set(s)
+}
+
+// AccessRule grants Perms on Path and everything below it
+type AccessRule struct {
+	Path  string `json:"path"`
+	Perms Perm   `json:"perms"`
+}
+
+// Access restricts what a user of the VFS can do.
+//
+// The rule with the longest Path containing a path gives the
+// permissions for it. Paths not covered by a rule can't be seen
+// except for the directories leading to a rule.
+type Access struct {
+	Rules    []AccessRule  `json:"rules"`
+	MaxSize  fs.SizeSuffix `json:"max_size,omitempty"`  // max bytes in writable paths if > 0
+	MaxFiles int64         `json:"max_files,omitempty"` // max files in writable paths if > 0
+}
+
+// cleanRulePath makes a rule or VFS path comparable
+func cleanRulePath(p string) string {
+	return strings.Trim(p, "/")
+}
+
+// isWithin returns whether p is dir or below it
+func isWithin(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// Perms returns the permissions on the VFS path p
+func (a *Access) Perms(p string) Perm {
+	if a == nil {
+		return PermAll
+	}
+	p = cleanRulePath(p)
+	perms, best := PermNone, -1
+	for _, rule := range a.Rules {
+		rulePath := cleanRulePath(rule.Path)
+		if len(rulePath) > best && isWithin(p, rulePath) {
+			perms, best = rule.Perms, len(rulePath)
+		}
+	}
+	return perms
+}
+
+// Allowed returns whether all of perm are granted on the VFS path p
+func (a *Access) Allowed(p string, perm Perm) bool {
+	return a.Perms(p)&perm == perm
+}
+
+// Leads returns whether the VFS path p is a directory above a rule
+// granting some permissions, so needs to be seen to reach it.
+func (a *Access) Leads(p string) bool {
+	if a == nil {
+		return true
+	}
+	p = cleanRulePath(p)
+	for _, rule := range a.Rules {
+		rulePath := cleanRulePath(rule.Path)
+		if rule.Perms != PermNone && rulePath != p && isWithin(rulePath, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Visible returns whether the VFS path p can be looked up
+func (a *Access) Visible(p string) bool {
+	return a.Perms(p) != PermNone || a.Leads(p)
+}
+
+// Writable returns the paths of the rules granting write permission
+// with any paths inside other writable paths removed. The quotas
+// apply to everything inside these.
+func (a *Access) Writable() (dirs []string) {
+	if a == nil {
+		return []string{""}
+	}
+	for _, rule := range a.Rules {
+		if rule.Perms&PermWrite != 0 {
+			dirs = append(dirs, cleanRulePath(rule.Path))
+		}
+	}
+	var out []string
+outer:
+	for i, dir := range dirs {
+		for j, other := range dirs {
+			if i != j && isWithin(dir, other) && (dir != other || j < i) {
+				continue outer
+			}
+		}
+		out = append(out, dir)
+	}
+	return out
+}
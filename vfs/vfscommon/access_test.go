@@ -0,0 +1,101 @@
+package vfscommon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerm(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want Perm
+		out  string
+	}{
+		{"", PermNone, "-"},
+		{"-", PermNone, "-"},
+		{"r", PermRead, "r"},
+		{"wr", PermRead | PermWrite, "rw"},
+		{"rwdm", PermAll, "rwdm"},
+	} {
+		var p Perm
+		require.NoError(t, p.Set(test.in), test.in)
+		assert.Equal(t, test.want, p, test.in)
+		assert.Equal(t, test.out, p.String(), test.in)
+	}
+	var p Perm
+	assert.Error(t, p.Set("rx"))
+}
+
+func TestAccessJSON(t *testing.T) {
+	var a Access
+	err := json.Unmarshal([]byte(`{"rules":[{"path":"/in","perms":"w"}],"max_size":"1k","max_files":3}`), &a)
+	require.NoError(t, err)
+	assert.Equal(t, Access{
+		Rules:    []AccessRule{{Path: "/in", Perms: PermWrite}},
+		MaxSize:  1024,
+		MaxFiles: 3,
+	}, a)
+	out, err := json.Marshal(a.Rules[0])
+	require.NoError(t, err)
+	assert.Equal(t, `{"path":"/in","perms":"w"}`, string(out))
+}
+
+func TestAccessPerms(t *testing.T) {
+	a := &Access{
+		Rules: []AccessRule{
+			{Path: "/", Perms: PermRead},
+			{Path: "/a/b", Perms: PermWrite},
+			{Path: "/a/b/c", Perms: PermNone},
+			{Path: "/x/y", Perms: PermAll},
+		},
+	}
+	for _, test := range []struct {
+		path    string
+		perms   Perm
+		visible bool
+	}{
+		{"", PermRead, true},
+		{"file", PermRead, true},
+		{"a", PermRead, true},
+		{"a/b", PermWrite, true},
+		{"a/bb", PermRead, true},
+		{"a/b/file", PermWrite, true},
+		{"a/b/c/file", PermNone, false},
+		{"x/y/z", PermAll, true},
+	} {
+		assert.Equal(t, test.perms, a.Perms(test.path), test.path)
+		assert.Equal(t, test.visible, a.Visible(test.path), test.path)
+	}
+
+	a = &Access{
+		Rules: []AccessRule{
+			{Path: "/a/b", Perms: PermWrite},
+		},
+	}
+	assert.True(t, a.Visible(""))
+	assert.True(t, a.Visible("a"))
+	assert.False(t, a.Visible("ab"))
+	assert.False(t, a.Visible("c"))
+	assert.True(t, a.Allowed("a/b/c", PermWrite))
+	assert.False(t, a.Allowed("a/b/c", PermRead|PermWrite))
+
+	var none *Access
+	assert.Equal(t, PermAll, none.Perms("anything"))
+	assert.True(t, none.Visible("anything"))
+}
+
+func TestAccessWritable(t *testing.T) {
+	a := &Access{
+		Rules: []AccessRule{
+			{Path: "/", Perms: PermRead},
+			{Path: "/a", Perms: PermWrite},
+			{Path: "/a/b", Perms: PermAll},
+			{Path: "/c", Perms: PermWrite | PermRead},
+			{Path: "c/", Perms: PermWrite},
+		},
+	}
+	assert.Equal(t, []string{"a", "c"}, a.Writable())
+}
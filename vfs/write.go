@@ -27,6 +27,7 @@ type WriteFileHandle struct {
 	opened      bool
 	flags       int
 	truncated   bool
+	sizeLimit   int64 // size the file may grow to or -1 if unlimited
 }
 
 // Check interfaces
@@ -38,10 +39,11 @@ var (
 
 func newWriteFileHandle(d *Dir, f *File, remote string, flags int) (*WriteFileHandle, error) {
 	fh := &WriteFileHandle{
-		remote: remote,
-		flags:  flags,
-		result: make(chan error, 1),
-		file:   f,
+		remote:    remote,
+		flags:     flags,
+		result:    make(chan error, 1),
+		file:      f,
+		sizeLimit: d.vfs.access.sizeLimit(f.Path(), f.Size()),
 	}
 	fh.cond = sync.NewCond(&fh.mu)
 	fh.file.addWriter(fh)
@@ -136,6 +138,9 @@ func (fh *WriteFileHandle) writeAt(p []byte, off int64) (n int, err error) {
 		fs.Errorf(fh.remote, "WriteFileHandle.Write: can't seek in file without --vfs-cache-mode >= writes")
 		return 0, ESPIPE
 	}
+	if err = checkSize(fh.remote, fh.sizeLimit, off+int64(len(p))); err != nil {
+		return 0, err
+	}
 	if err = fh.openPending(); err != nil {
 		return 0, err
 	}